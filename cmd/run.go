@@ -59,6 +59,9 @@ func start(cliCtx *cli.Context) error {
 	if c.Metrics.Enabled {
 		metrics.Init()
 	}
+	if err := metrics.InitStatsd(c.Metrics.Statsd); err != nil {
+		log.Fatal(err)
+	}
 	components := cliCtx.StringSlice(config.FlagComponents)
 
 	// Only runs migration if the component is the synchronizer and if the flag is deactivated
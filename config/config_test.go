@@ -36,6 +36,10 @@ func Test_Defaults(t *testing.T) {
 			path:          "Log.Outputs",
 			expectedValue: []string{"stderr"},
 		},
+		{
+			path:          "Log.Encoding",
+			expectedValue: "",
+		},
 		{
 			path:          "Synchronizer.SyncChunkSize",
 			expectedValue: uint64(100),
@@ -52,6 +56,14 @@ func Test_Defaults(t *testing.T) {
 			path:          "Synchronizer.L1ParallelSynchronization.MaxPendingNoProcessedBlocks",
 			expectedValue: uint64(25),
 		},
+		{
+			path:          "Sequencer.Mode",
+			expectedValue: "active",
+		},
+		{
+			path:          "Sequencer.StandbyStreamUpdateInterval",
+			expectedValue: types.NewDuration(5 * time.Second),
+		},
 		{
 			path:          "Sequencer.DeletePoolTxsL1BlockConfirmations",
 			expectedValue: uint64(100),
@@ -60,6 +72,18 @@ func Test_Defaults(t *testing.T) {
 			path:          "Sequencer.DeletePoolTxsCheckInterval",
 			expectedValue: types.NewDuration(12 * time.Hour),
 		},
+		{
+			path:          "Sequencer.DeletePoolTxsBatchSize",
+			expectedValue: uint64(10000),
+		},
+		{
+			path:          "Sequencer.DeleteFailedPoolTxsRetryInterval",
+			expectedValue: types.NewDuration(time.Minute),
+		},
+		{
+			path:          "Sequencer.MinDeleteConfirmations",
+			expectedValue: uint64(64),
+		},
 		{
 			path:          "Sequencer.TxLifetimeCheckInterval",
 			expectedValue: types.NewDuration(10 * time.Minute),
@@ -68,14 +92,174 @@ func Test_Defaults(t *testing.T) {
 			path:          "Sequencer.TxLifetimeMax",
 			expectedValue: types.NewDuration(3 * time.Hour),
 		},
+		{
+			path:          "Sequencer.TxLifetimeSkipInFlight",
+			expectedValue: true,
+		},
+		{
+			path:          "Sequencer.ExpiredTxsMaxRetries",
+			expectedValue: uint64(3),
+		},
+		{
+			path:          "Sequencer.TxAgeWarnThreshold",
+			expectedValue: types.NewDuration(1 * time.Hour),
+		},
+		{
+			path:          "Sequencer.TxAgeWarnEventInterval",
+			expectedValue: types.NewDuration(30 * time.Minute),
+		},
 		{
 			path:          "Sequencer.LoadPoolTxsCheckInterval",
 			expectedValue: types.NewDuration(500 * time.Millisecond),
 		},
+		{
+			path:          "Sequencer.LoadPoolTxsMaxPerIteration",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsRoundRobin",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsOnlyWhenSynced",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsRampStart",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsRampMultiplier",
+			expectedValue: uint64(2),
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsDedupTTL",
+			expectedValue: types.NewDuration(2 * time.Second),
+		},
+		{
+			path:          "Sequencer.LoadPoolTxsCursorFile",
+			expectedValue: "",
+		},
+		{
+			path:          "Sequencer.MaxBatchesAheadOfL1",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.BatchesAheadOfL1CheckInterval",
+			expectedValue: types.NewDuration(10 * time.Second),
+		},
+		{
+			path:          "Sequencer.SyncCheckL1MaxRetries",
+			expectedValue: uint64(3),
+		},
+		{
+			path:          "Sequencer.SyncCheckL1RetryBackoff",
+			expectedValue: types.NewDuration(100 * time.Millisecond),
+		},
+		{
+			path:          "Sequencer.MaxWorkerBytes",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.MaxAcceptedGasLimit",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.RejectTxsExceedingBatchConstraints",
+			expectedValue: true,
+		},
+		{
+			path:          "Sequencer.SenderRateLimit",
+			expectedValue: float64(0),
+		},
+		{
+			path:          "Sequencer.SenderRateLimitBurst",
+			expectedValue: uint64(10),
+		},
+		{
+			path:          "Sequencer.SenderRateLimitNewSenderGrace",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.AdmissionBatchFullnessThreshold",
+			expectedValue: float64(0),
+		},
+		{
+			path:          "Sequencer.AdmissionHighPriorityGasPrice",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.TxTrackerErrorPolicy",
+			expectedValue: "fail",
+		},
+		{
+			path:          "Sequencer.ReconcilePendingTxsAtStartup",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.WIPStatusUpdateMaxRetries",
+			expectedValue: uint64(3),
+		},
+		{
+			path:          "Sequencer.WIPStatusUpdateRetryInterval",
+			expectedValue: types.NewDuration(100 * time.Millisecond),
+		},
+		{
+			path:          "Sequencer.DropRecordsSize",
+			expectedValue: uint64(1000),
+		},
+		{
+			path:          "Sequencer.LogDropsToEventLog",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.DropEventsMaxPerSecond",
+			expectedValue: uint64(10),
+		},
+		{
+			path:          "Sequencer.EventLogMinLevel",
+			expectedValue: "debug",
+		},
+		{
+			path:          "Sequencer.ReplacementRecordsSize",
+			expectedValue: uint64(1000),
+		},
+		{
+			path:          "Sequencer.FinalizerWarmupDelay",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.FinalizerWarmupMinTxs",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.PauseDrainTimeout",
+			expectedValue: types.NewDuration(1 * time.Minute),
+		},
+		{
+			path:          "Sequencer.MinHaltInterval",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.LoopMaxRestarts",
+			expectedValue: uint64(5),
+		},
+		{
+			path:          "Sequencer.LoopRestartBackoff",
+			expectedValue: types.NewDuration(1 * time.Second),
+		},
 		{
 			path:          "Sequencer.StateConsistencyCheckInterval",
 			expectedValue: types.NewDuration(5 * time.Second),
 		},
+		{
+			path:          "Sequencer.MetricsLogInterval",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.MetricsForkIDLabel",
+			expectedValue: false,
+		},
 		{
 			path:          "Sequencer.Finalizer.ForcedBatchesTimeout",
 			expectedValue: types.NewDuration(60 * time.Second),
@@ -128,6 +312,206 @@ func Test_Defaults(t *testing.T) {
 			path:          "Sequencer.StreamServer.Enabled",
 			expectedValue: false,
 		},
+		{
+			path:          "Sequencer.StreamServer.VerifyBlockHash",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.VerifyBatchNumber",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.BlocksPerAtomicOp",
+			expectedValue: uint64(1),
+		},
+		{
+			path:          "Sequencer.StreamServer.SkipIntermediateStateRoots",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.StorageCacheSize",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.EmitReceiptsReadyEvents",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.PauseBufferSize",
+			expectedValue: uint64(1000),
+		},
+		{
+			path:          "Sequencer.StreamServer.PauseBufferFullPolicy",
+			expectedValue: "block",
+		},
+		{
+			path:          "Sequencer.StreamServer.TimestampSkewTolerance",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.TimestampSkewPolicy",
+			expectedValue: "clamp",
+		},
+		{
+			path:          "Sequencer.StreamServer.RequiredAtStartup",
+			expectedValue: true,
+		},
+		{
+			path:          "Sequencer.StreamServer.IncludeDecodedTxMetadata",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.IncludeSender",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.ExportSchema",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.EmitBatchBoundaries",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.IncludeL1InfoRoot",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.IncludeStorageDiffs",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.IncludeBlockBlooms",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.CheckpointEveryNBlocks",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.Encoding",
+			expectedValue: "binary",
+		},
+		{
+			path:          "Sequencer.StreamServer.BlockStartExcludedFields",
+			expectedValue: []string{},
+		},
+		{
+			path:          "Sequencer.StreamServer.ChannelBufferSize",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.ReconnectQuietPeriod",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.RegenerateInBackground",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.FileUpdateMaxRetries",
+			expectedValue: uint64(3),
+		},
+		{
+			path:          "Sequencer.StreamServer.FileUpdateRetryInterval",
+			expectedValue: types.NewDuration(1 * time.Second),
+		},
+		{
+			path:          "Sequencer.StreamServer.PipelinedEncoding",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.PipelineBufferSize",
+			expectedValue: uint64(10),
+		},
+		{
+			path:          "Sequencer.StreamServer.MaxRestarts",
+			expectedValue: uint64(5),
+		},
+		{
+			path:          "Sequencer.StreamServer.RestartBackoff",
+			expectedValue: types.NewDuration(1 * time.Second),
+		},
+		{
+			path:          "Sequencer.StreamServer.FinalityCheckInterval",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.StartupDelay",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.ReadyTimeout",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.StartMaxRetries",
+			expectedValue: uint64(3),
+		},
+		{
+			path:          "Sequencer.StreamServer.StartRetryInterval",
+			expectedValue: types.NewDuration(time.Second),
+		},
+		{
+			path:          "Sequencer.StreamServer.RecentBlockHashesSize",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.AtomicOpTimeout",
+			expectedValue: types.NewDuration(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.Archive.Enabled",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.Archive.SegmentEntries",
+			expectedValue: uint64(10000),
+		},
+		{
+			path:          "Sequencer.StreamServer.Archive.FlushInterval",
+			expectedValue: types.NewDuration(10 * time.Minute),
+		},
+		{
+			path:          "Sequencer.StreamServer.Archive.QueueSize",
+			expectedValue: uint64(10000),
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.Enabled",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.Port",
+			expectedValue: uint16(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.Filename",
+			expectedValue: "",
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.MinValue",
+			expectedValue: big.NewInt(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.MinGas",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "Sequencer.StreamServer.PriorityStream.QueueSize",
+			expectedValue: uint64(10000),
+		},
+		{
+			path:          "Sequencer.DebugStreamServer.Enabled",
+			expectedValue: false,
+		},
+		{
+			path:          "Sequencer.DebugStreamServer.Port",
+			expectedValue: uint16(0),
+		},
+		{
+			path:          "Sequencer.DebugStreamServer.Filename",
+			expectedValue: "",
+		},
 		{
 			path:          "SequenceSender.WaitPeriodSendSequence",
 			expectedValue: types.NewDuration(5 * time.Second),
@@ -417,6 +801,18 @@ func Test_Defaults(t *testing.T) {
 			path:          "Metrics.Enabled",
 			expectedValue: false,
 		},
+		{
+			path:          "Metrics.Statsd.Enabled",
+			expectedValue: false,
+		},
+		{
+			path:          "Metrics.Statsd.Address",
+			expectedValue: "localhost:8125",
+		},
+		{
+			path:          "Metrics.Statsd.Prefix",
+			expectedValue: "zkevm.",
+		},
 		{
 			path:          "Aggregator.Host",
 			expectedValue: "0.0.0.0",
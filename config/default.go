@@ -10,6 +10,7 @@ ForkUpgradeNewForkId = 0
 Environment = "development" # "production" or "development"
 Level = "info"
 Outputs = ["stderr"]
+Encoding = "" # "console" or "json", empty to use the encoding of the Environment
 
 [State]
 	[State.DB]
@@ -116,12 +117,59 @@ L1SynchronizationMode = "parallel"
 			ApplyAfterNumRollupReceived = 10
 
 [Sequencer]
+Mode = "active"
+StandbyStreamUpdateInterval = "5s"
 DeletePoolTxsL1BlockConfirmations = 100
 DeletePoolTxsCheckInterval = "12h"
+DeletePoolTxsBatchSize = 10000
+DeleteFailedPoolTxsRetryInterval = "1m"
+MinDeleteConfirmations = 64
 TxLifetimeCheckInterval = "10m"
 TxLifetimeMax = "3h"
+TxLifetimeSkipInFlight = true
+ExpiredTxsMaxRetries = 3
+TxAgeWarnThreshold = "1h"
+TxAgeWarnEventInterval = "30m"
 LoadPoolTxsCheckInterval = "500ms"
+LoadPoolTxsMaxPerIteration = 0
+LoadPoolTxsRoundRobin = false
+LoadPoolTxsOnlyWhenSynced = false
+LoadPoolTxsRampStart = 0
+LoadPoolTxsRampMultiplier = 2
+LoadPoolTxsDedupTTL = "2s"
+LoadPoolTxsCursorFile = ""
+SyncCheckL1MaxRetries = 3
+SyncCheckL1RetryBackoff = "100ms"
+MaxBatchesAheadOfL1 = 0
+BatchesAheadOfL1CheckInterval = "10s"
 StateConsistencyCheckInterval = "5s"
+MetricsLogInterval = "0s"
+MetricsForkIDLabel = false
+MaxWorkerTxs = 0
+MaxWorkerBytes = 0
+MaxAcceptedGasLimit = 0
+RejectTxsExceedingBatchConstraints = true
+SenderRateLimit = 0
+SenderRateLimitBurst = 10
+SenderRateLimitNewSenderGrace = 0
+AdmissionBatchFullnessThreshold = 0
+AdmissionHighPriorityGasPrice = 0
+WorkerFullPolicy = "block"
+TxTrackerErrorPolicy = "fail"
+ReconcilePendingTxsAtStartup = false
+WIPStatusUpdateMaxRetries = 3
+WIPStatusUpdateRetryInterval = "100ms"
+DropRecordsSize = 1000
+LogDropsToEventLog = false
+DropEventsMaxPerSecond = 10
+EventLogMinLevel = "debug"
+ReplacementRecordsSize = 1000
+FinalizerWarmupDelay = "0s"
+FinalizerWarmupMinTxs = 0
+PauseDrainTimeout = "1m"
+MinHaltInterval = "0s"
+LoopMaxRestarts = 5
+LoopRestartBackoff = "1s"
 	[Sequencer.Finalizer]
 		NewTxsWaitInterval = "100ms"
 		ForcedBatchesTimeout = "60s"
@@ -138,6 +186,65 @@ StateConsistencyCheckInterval = "5s"
 		Port = 0
 		Filename = ""
 		Enabled = false
+		VerifyBlockHash = false
+		VerifyBatchNumber = false
+		BlocksPerAtomicOp = 1
+		SkipIntermediateStateRoots = false
+		StorageCacheSize = 0
+		EmitReceiptsReadyEvents = false
+		PauseBufferSize = 1000
+		PauseBufferFullPolicy = "block"
+		TimestampSkewTolerance = "0s"
+		TimestampSkewPolicy = "clamp"
+		RequiredAtStartup = true
+		IncludeDecodedTxMetadata = false
+		IncludeSender = false
+		ExportSchema = false
+		EmitBatchBoundaries = false
+		Encoding = "binary"
+		BlockStartExcludedFields = []
+		IncludeL1InfoRoot = false
+		IncludeStorageDiffs = false
+		IncludeBlockBlooms = false
+		CheckpointEveryNBlocks = 0
+		ChannelBufferSize = 0
+		ReconnectQuietPeriod = "0s"
+		RegenerateInBackground = false
+		FileUpdateMaxRetries = 3
+		FileUpdateRetryInterval = "1s"
+		PipelinedEncoding = false
+		PipelineBufferSize = 10
+		MaxRestarts = 5
+		RestartBackoff = "1s"
+		FinalityCheckInterval = "0s"
+		StartupDelay = "0s"
+		ReadyTimeout = "0s"
+		StartMaxRetries = 3
+		StartRetryInterval = "1s"
+		RecentBlockHashesSize = 0
+		AtomicOpTimeout = "0s"
+		[Sequencer.StreamServer.Archive]
+			Enabled = false
+			Endpoint = ""
+			Region = ""
+			Bucket = ""
+			Prefix = ""
+			AccessKeyID = ""
+			SecretAccessKey = ""
+			SegmentEntries = 10000
+			FlushInterval = "10m"
+			QueueSize = 10000
+		[Sequencer.StreamServer.PriorityStream]
+			Enabled = false
+			Port = 0
+			Filename = ""
+			MinValue = "0"
+			MinGas = 0
+			QueueSize = 10000
+	[Sequencer.DebugStreamServer]
+		Enabled = false
+		Port = 0
+		Filename = ""
 
 [SequenceSender]
 WaitPeriodSendSequence = "5s"
@@ -181,6 +288,10 @@ MaxGRPCMessageSize = 100000000
 Host = "0.0.0.0"
 Port = 9091
 Enabled = false
+	[Metrics.Statsd]
+	Enabled = false
+	Address = "localhost:8125"
+	Prefix = "zkevm."
 
 [HashDB]
 User = "prover_user"
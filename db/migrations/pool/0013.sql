@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE pool.transaction
+    ADD COLUMN inclusion_deadline TIMESTAMP WITH TIME ZONE;
+
+-- +migrate Down
+ALTER TABLE pool.transaction
+    DROP COLUMN inclusion_deadline;
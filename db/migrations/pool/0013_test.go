@@ -0,0 +1,46 @@
+package pool_migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// this migration adds the inclusion deadline of the txs
+type migrationTest0013 struct{}
+
+func (m migrationTest0013) InsertData(db *sql.DB) error {
+	const insertTx = `
+		INSERT INTO pool.transaction (hash, ip, received_at, from_address)
+		VALUES ('0x0001', '127.0.0.1', '2023-12-07', '0x0011')`
+
+	_, err := db.Exec(insertTx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m migrationTest0013) RunAssertsAfterMigrationUp(t *testing.T, db *sql.DB) {
+	const insertTx = `
+		INSERT INTO pool.transaction (hash, ip, received_at, from_address, inclusion_deadline)
+		VALUES ('0x0002', '127.0.0.1', '2023-12-07', '0x0022', '2023-12-08')`
+
+	_, err := db.Exec(insertTx)
+	assert.NoError(t, err)
+}
+
+func (m migrationTest0013) RunAssertsAfterMigrationDown(t *testing.T, db *sql.DB) {
+	const insertTx = `
+		INSERT INTO pool.transaction (hash, ip, received_at, from_address, inclusion_deadline)
+		VALUES ('0x0003', '127.0.0.1', '2023-12-07', '0x0033', '2023-12-08')`
+
+	_, err := db.Exec(insertTx)
+	assert.Error(t, err)
+}
+
+func TestMigration0013(t *testing.T) {
+	runMigrationTest(t, 13, migrationTest0013{})
+}
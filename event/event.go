@@ -40,6 +40,30 @@ const (
 	EventID_SynchronizerRestart EventID = "SYNCHRONIZER RESTART"
 	// EventID_SynchronizerHalt is triggered when the synchronizer halts
 	EventID_SynchronizerHalt EventID = "SYNCHRONIZER HALT"
+	// EventID_DataStreamerBlockHashMismatch is triggered when the hash of a L2 block to stream doesn't match the one stored in the state
+	EventID_DataStreamerBlockHashMismatch EventID = "DATA STREAMER BLOCK HASH MISMATCH"
+	// EventID_DataStreamerReceiptsReady is triggered when a L2 block has been committed to the data stream and its txs are final for the sequencer
+	EventID_DataStreamerReceiptsReady EventID = "DATA STREAMER RECEIPTS READY"
+	// EventID_DataStreamerL2BlockDropped is triggered when a L2 block is not streamed because the pause buffer is full
+	EventID_DataStreamerL2BlockDropped EventID = "DATA STREAMER L2 BLOCK DROPPED"
+	// EventID_DataStreamerDisabled is triggered when the stream server can't be started and the sequencer continues with the streaming disabled
+	EventID_DataStreamerDisabled EventID = "DATA STREAMER DISABLED"
+	// EventID_SequencerTxDropped is triggered when a tx is dropped, replaced or expired by the sequencer
+	EventID_SequencerTxDropped EventID = "SEQUENCER TX DROPPED"
+	// EventID_WorkerTxAgeWarning is triggered when there are txs in the worker older than the warn threshold
+	EventID_WorkerTxAgeWarning EventID = "WORKER TX AGE WARNING"
+	// EventID_DataStreamerTimestampSkew is triggered when the timestamp of a L2 block to stream is before the timestamp of the previous L2 block
+	EventID_DataStreamerTimestampSkew EventID = "DATA STREAMER TIMESTAMP SKEW"
+	// EventID_DataStreamerBatchNumberMismatch is triggered when the batch number of a L2 block to stream doesn't match the batch number stored in the state
+	EventID_DataStreamerBatchNumberMismatch EventID = "DATA STREAMER BATCH NUMBER MISMATCH"
+	// EventID_DataStreamerRestart is triggered when the goroutine sending the L2 blocks to the data stream exits unexpectedly and it's restarted
+	EventID_DataStreamerRestart EventID = "DATA STREAMER RESTART"
+	// EventID_DataStreamerArchiveDisabled is triggered when the archive of the data stream in the object store is disabled because of an error
+	EventID_DataStreamerArchiveDisabled EventID = "DATA STREAMER ARCHIVE DISABLED"
+	// EventID_SequencerLoopRestart is triggered when a background loop of the sequencer panics and it's restarted
+	EventID_SequencerLoopRestart EventID = "SEQUENCER LOOP RESTART"
+	// EventID_DataStreamerAtomicOpTimeout is triggered when the commit of an atomic op to the data stream doesn't finish in time
+	EventID_DataStreamerAtomicOpTimeout EventID = "DATA STREAMER ATOMIC OP TIMEOUT"
 	// Source_Node is the source of the event
 	Source_Node Source = "node"
 
@@ -10,4 +10,7 @@ type Config struct {
 	Level string `mapstructure:"Level" jsonschema:"enum=debug,enum=info,enum=warn,enum=error,enum=dpanic,enum=panic,enum=fatal"`
 	// Outputs
 	Outputs []string `mapstructure:"Outputs"`
+	// Encoding of the log lines ("console" or "json"). If it's empty the encoding of the Environment is used (console in
+	// development and json in production). The structured fields of the log lines are emitted as JSON fields with the json encoding
+	Encoding string `mapstructure:"Encoding" jsonschema:"enum=,enum=console,enum=json"`
 }
@@ -22,6 +22,13 @@ const (
 	EnvironmentDevelopment = LogEnvironment("development")
 )
 
+const (
+	// EncodingConsole encodes the log lines as human readable text
+	EncodingConsole = "console"
+	// EncodingJSON encodes the log lines as JSON objects, one per line
+	EncodingJSON = "json"
+)
+
 // Logger is a wrapper providing logging facilities.
 type Logger struct {
 	x *zap.SugaredLogger
@@ -82,6 +89,17 @@ func NewLogger(cfg Config) (*zap.SugaredLogger, *zap.AtomicLevel, error) {
 		zapCfg = zap.NewDevelopmentConfig()
 		zapCfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
+	switch cfg.Encoding {
+	case "":
+	case EncodingConsole, EncodingJSON:
+		zapCfg.Encoding = cfg.Encoding
+		if cfg.Encoding == EncodingJSON {
+			// The keys of the production encoder are used, so the JSON fields are the same in all the environments
+			zapCfg.EncoderConfig = zap.NewProductionEncoderConfig()
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid log encoding: %s", cfg.Encoding)
+	}
 	zapCfg.Level = level
 	zapCfg.OutputPaths = cfg.Outputs
 	zapCfg.InitialFields = map[string]interface{}{
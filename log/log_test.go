@@ -1,7 +1,13 @@
 package log
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogNotInitialized(t *testing.T) {
@@ -35,3 +41,23 @@ func TestLog(t *testing.T) {
 	Warnf("Test log.Warnf %d", 10)
 	Warnw("Test log.Warnw", "value", 10)
 }
+
+func TestNewLogger_JSONEncoding(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	logger, _, err := NewLogger(Config{Environment: EnvironmentDevelopment, Level: "info", Outputs: []string{logFile}, Encoding: EncodingJSON})
+	require.NoError(t, err)
+
+	logger.Infow("Test log.Infow", "value", 10)
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	line := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &line))
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "Test log.Infow", line["msg"])
+	assert.Equal(t, float64(10), line["value"])
+
+	_, _, err = NewLogger(Config{Environment: EnvironmentDevelopment, Level: "info", Outputs: []string{logFile}, Encoding: "xml"})
+	require.Error(t, err)
+}
@@ -14,4 +14,7 @@ type Config struct {
 	ProfilingPort int `mapstructure:"ProfilingPort"`
 	// ProfilingEnabled is the flag to enable/disable the profiling server
 	ProfilingEnabled bool `mapstructure:"ProfilingEnabled"`
+	// Statsd is the configuration of the statsd emitter, that mirrors the
+	// metrics to a statsd server in addition to Prometheus
+	Statsd StatsdConfig `mapstructure:"Statsd"`
 }
@@ -102,6 +102,10 @@ func Gauge(name string) (gauge prometheus.Gauge, exist bool) {
 
 // GaugeSet sets the value for gauge with the given name.
 func GaugeSet(name string, value float64) {
+	if c := getStatsdClient(); c != nil {
+		c.Gauge(name, value)
+	}
+
 	if !initialized {
 		return
 	}
@@ -113,6 +117,10 @@ func GaugeSet(name string, value float64) {
 
 // GaugeInc increments the gauge with the given name.
 func GaugeInc(name string) {
+	if c := getStatsdClient(); c != nil {
+		c.GaugeAdd(name, 1)
+	}
+
 	if !initialized {
 		return
 	}
@@ -124,6 +132,10 @@ func GaugeInc(name string) {
 
 // GaugeDec decrements the gauge with the given name.
 func GaugeDec(name string) {
+	if c := getStatsdClient(); c != nil {
+		c.GaugeAdd(name, -1)
+	}
+
 	if !initialized {
 		return
 	}
@@ -166,6 +178,10 @@ func Counter(name string) (counter prometheus.Counter, exist bool) {
 
 // CounterInc increments the counter with the given name.
 func CounterInc(name string) {
+	if c := getStatsdClient(); c != nil {
+		c.Count(name, 1)
+	}
+
 	if !initialized {
 		return
 	}
@@ -177,6 +193,10 @@ func CounterInc(name string) {
 
 // CounterAdd increments the counter with the given name.
 func CounterAdd(name string, value float64) {
+	if c := getStatsdClient(); c != nil {
+		c.Count(name, value)
+	}
+
 	if !initialized {
 		return
 	}
@@ -204,6 +224,10 @@ func UnregisterCounters(names ...string) {
 // RegisterCounterVecs registers the provided counter vec metrics to the
 // Prometheus registerer.
 func RegisterCounterVecs(opts ...CounterVecOpts) {
+	for _, options := range opts {
+		setStatsdLabel(options.Name, options.Labels)
+	}
+
 	if !initialized {
 		return
 	}
@@ -232,6 +256,10 @@ func CounterVec(name string) (counterVec *prometheus.CounterVec, exist bool) {
 
 // CounterVecInc increments the counter vec with the given name and label.
 func CounterVecInc(name string, label string) {
+	if c := getStatsdClient(); c != nil {
+		c.Count(name, 1, statsdTag(name, label))
+	}
+
 	if !initialized {
 		return
 	}
@@ -244,6 +272,10 @@ func CounterVecInc(name string, label string) {
 // CounterVecAdd increments the counter vec by the given value, with the given
 // name and label.
 func CounterVecAdd(name string, label string, value float64) {
+	if c := getStatsdClient(); c != nil {
+		c.Count(name, value, statsdTag(name, label))
+	}
+
 	if !initialized {
 		return
 	}
@@ -301,6 +333,10 @@ func Histogram(name string) (histogram prometheus.Histogram, exist bool) {
 
 // HistogramObserve observes the histogram from the given start time.
 func HistogramObserve(name string, value float64) {
+	if c := getStatsdClient(); c != nil {
+		c.Histogram(name, value)
+	}
+
 	if !initialized {
 		return
 	}
@@ -328,6 +364,10 @@ func UnregisterHistogram(names ...string) {
 // RegisterHistogramVecs registers the provided histogram vec metrics to the
 // Prometheus registerer.
 func RegisterHistogramVecs(opts ...HistogramVecOpts) {
+	for _, options := range opts {
+		setStatsdLabel(options.Name, options.Labels)
+	}
+
 	if !initialized {
 		return
 	}
@@ -356,6 +396,10 @@ func HistogramVec(name string) (histgramVec *prometheus.HistogramVec, exist bool
 
 // HistogramVecObserve observes the histogram vec with the given name, label and value.
 func HistogramVecObserve(name string, label string, value float64) {
+	if c := getStatsdClient(); c != nil {
+		c.Histogram(name, value, statsdTag(name, label))
+	}
+
 	if !initialized {
 		return
 	}
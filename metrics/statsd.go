@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// StatsdConfig represents the configuration of the statsd emitter, which
+// mirrors the metrics to a statsd server in addition to Prometheus
+type StatsdConfig struct {
+	// Enabled is the flag to enable/disable the statsd emitter
+	Enabled bool `mapstructure:"Enabled"`
+	// Address is the host:port of the statsd server, the metrics are sent
+	// over UDP
+	Address string `mapstructure:"Address"`
+	// Prefix is prepended to the name of the metrics sent to statsd
+	Prefix string `mapstructure:"Prefix"`
+}
+
+// StatsdClient sends the values of the metrics to a statsd server. The tags
+// are given as label:value pairs
+type StatsdClient interface {
+	// Count adds value to the counter with the given name
+	Count(name string, value float64, tags ...string)
+	// Gauge sets the value of the gauge with the given name
+	Gauge(name string, value float64, tags ...string)
+	// GaugeAdd adds value (that can be negative) to the gauge with the given
+	// name
+	GaugeAdd(name string, value float64, tags ...string)
+	// Histogram observes value in the histogram with the given name
+	Histogram(name string, value float64, tags ...string)
+}
+
+var (
+	statsdMutex  sync.RWMutex
+	statsdClient StatsdClient
+	// statsdLabels are the label names of the vec metrics, used as the key
+	// of the tag of the label value
+	statsdLabels = map[string]string{}
+)
+
+// InitStatsd creates the statsd client when it's enabled in the config, so
+// the metrics are mirrored to the statsd server. It's independent of the
+// Prometheus metrics, that are kept as they are
+func InitStatsd(cfg StatsdConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	client, err := newUDPStatsdClient(cfg.Address, cfg.Prefix)
+	if err != nil {
+		return fmt.Errorf("failed to create the statsd client for %s: %w", cfg.Address, err)
+	}
+	SetStatsdClient(client)
+	log.Infof("statsd metrics emitted to %s", cfg.Address)
+
+	return nil
+}
+
+// SetStatsdClient sets the client the metrics are mirrored to, nil stops
+// mirroring them
+func SetStatsdClient(client StatsdClient) {
+	statsdMutex.Lock()
+	defer statsdMutex.Unlock()
+
+	statsdClient = client
+}
+
+// getStatsdClient returns the statsd client, nil if statsd is not enabled
+func getStatsdClient() StatsdClient {
+	statsdMutex.RLock()
+	defer statsdMutex.RUnlock()
+
+	return statsdClient
+}
+
+// setStatsdLabel keeps the label name of a vec metric, it's called on the
+// registration regardless of Prometheus being initialized
+func setStatsdLabel(name string, labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	statsdMutex.Lock()
+	defer statsdMutex.Unlock()
+
+	statsdLabels[name] = labels[0]
+}
+
+// statsdTag returns the tag of the label value of the vec metric with the
+// given name
+func statsdTag(name string, label string) string {
+	statsdMutex.RLock()
+	defer statsdMutex.RUnlock()
+
+	labelName, ok := statsdLabels[name]
+	if !ok {
+		labelName = "label"
+	}
+	return labelName + ":" + label
+}
+
+// udpStatsdClient is a StatsdClient that sends each value in its own UDP
+// packet, using the DogStatsD format for the tags. The errors are ignored as
+// the metrics are best effort
+type udpStatsdClient struct {
+	conn   net.Conn
+	prefix string
+}
+
+// newUDPStatsdClient creates a udpStatsdClient that sends the metrics to the
+// given address
+func newUDPStatsdClient(address string, prefix string) (*udpStatsdClient, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &udpStatsdClient{conn: conn, prefix: prefix}, nil
+}
+
+// Count adds value to the counter with the given name
+func (c *udpStatsdClient) Count(name string, value float64, tags ...string) {
+	c.send(name, formatStatsdValue(value), "c", tags)
+}
+
+// Gauge sets the value of the gauge with the given name
+func (c *udpStatsdClient) Gauge(name string, value float64, tags ...string) {
+	// A signed value is a delta in statsd, so the negative gauges are reset
+	// to 0 before
+	if value < 0 {
+		c.send(name, "0", "g", tags)
+	}
+	c.send(name, formatStatsdValue(value), "g", tags)
+}
+
+// GaugeAdd adds value (that can be negative) to the gauge with the given name
+func (c *udpStatsdClient) GaugeAdd(name string, value float64, tags ...string) {
+	delta := formatStatsdValue(value)
+	if value >= 0 {
+		delta = "+" + delta
+	}
+	c.send(name, delta, "g", tags)
+}
+
+// Histogram observes value in the histogram with the given name
+func (c *udpStatsdClient) Histogram(name string, value float64, tags ...string) {
+	c.send(name, formatStatsdValue(value), "h", tags)
+}
+
+// send writes the statsd line of the metric to the connection
+func (c *udpStatsdClient) send(name string, value string, metricType string, tags []string) {
+	line := c.prefix + name + ":" + value + "|" + metricType
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	if _, err := c.conn.Write([]byte(line)); err != nil {
+		log.Debugf("failed to send the statsd metric %s: %v", name, err)
+	}
+}
+
+// formatStatsdValue formats the value with the minimum number of decimals
+func formatStatsdValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
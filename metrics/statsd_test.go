@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatsdMetric is a value sent to the fakeStatsdClient
+type fakeStatsdMetric struct {
+	kind  string
+	name  string
+	value float64
+	tags  []string
+}
+
+// fakeStatsdClient keeps the values sent to statsd
+type fakeStatsdClient struct {
+	mu      sync.Mutex
+	metrics []fakeStatsdMetric
+}
+
+func (f *fakeStatsdClient) add(kind string, name string, value float64, tags []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.metrics = append(f.metrics, fakeStatsdMetric{kind: kind, name: name, value: value, tags: tags})
+}
+
+func (f *fakeStatsdClient) Count(name string, value float64, tags ...string) {
+	f.add("count", name, value, tags)
+}
+
+func (f *fakeStatsdClient) Gauge(name string, value float64, tags ...string) {
+	f.add("gauge", name, value, tags)
+}
+
+func (f *fakeStatsdClient) GaugeAdd(name string, value float64, tags ...string) {
+	f.add("gaugeAdd", name, value, tags)
+}
+
+func (f *fakeStatsdClient) Histogram(name string, value float64, tags ...string) {
+	f.add("histogram", name, value, tags)
+}
+
+func TestStatsdMirroring(t *testing.T) {
+	setup()
+	defer cleanup()
+
+	client := &fakeStatsdClient{}
+	SetStatsdClient(client)
+	defer SetStatsdClient(nil)
+
+	RegisterGauges(gaugeOpts)
+	RegisterCounters(counterOpts)
+	RegisterCounterVecs(counterVecOpts)
+	RegisterHistogramVecs(histogramVecOpts)
+
+	GaugeSet(gaugeName, 5)
+	GaugeDec(gaugeName)
+	CounterAdd(counterName, 2)
+	CounterVecInc(counterVecName, counterVecLabelVal)
+	HistogramVecObserve(histogramVecName, histogramVecLabelVal, 0.5)
+
+	assert.Equal(t, []fakeStatsdMetric{
+		{kind: "gauge", name: gaugeName, value: 5},
+		{kind: "gaugeAdd", name: gaugeName, value: -1},
+		{kind: "count", name: counterName, value: 2},
+		{kind: "count", name: counterVecName, value: 1, tags: []string{counterVecLabelName + ":" + counterVecLabelVal}},
+		{kind: "histogram", name: histogramVecName, value: 0.5, tags: []string{histogramVecLabelName + ":" + histogramVecLabelVal}},
+	}, client.metrics)
+
+	// The Prometheus metrics are updated as before
+	g, ok := Gauge(gaugeName)
+	require.True(t, ok)
+	assert.Equal(t, float64(4), testutil.ToFloat64(g))
+}
+
+func TestStatsdMirroring_PrometheusDisabled(t *testing.T) {
+	client := &fakeStatsdClient{}
+	SetStatsdClient(client)
+	defer SetStatsdClient(nil)
+
+	CounterInc("statsdOnlyCounter")
+
+	assert.Equal(t, []fakeStatsdMetric{{kind: "count", name: "statsdOnlyCounter", value: 1}}, client.metrics)
+}
+
+func TestUDPStatsdClient(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, InitStatsd(StatsdConfig{Enabled: true, Address: conn.LocalAddr().String(), Prefix: "zkevm."}))
+	defer SetStatsdClient(nil)
+
+	RegisterCounterVecs(CounterVecOpts{prometheus.CounterOpts{Name: "udpCounterVec"}, []string{"kind"}})
+	CounterVecAdd("udpCounterVec", "confirmed", 3)
+	GaugeInc("udpGauge")
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "zkevm.udpCounterVec:3|c|#kind:confirmed", string(buf[:n]))
+	n, _, err = conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "zkevm.udpGauge:+1|g", string(buf[:n]))
+}
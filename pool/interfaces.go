@@ -15,6 +15,7 @@ type storage interface {
 	AddTx(ctx context.Context, tx Transaction) error
 	CountTransactionsByStatus(ctx context.Context, status ...TxStatus) (uint64, error)
 	CountTransactionsByFromAndStatus(ctx context.Context, from common.Address, status ...TxStatus) (uint64, error)
+	CountPendingTxsByWIPStatus(ctx context.Context, isWIP bool) (uint64, error)
 	DeleteTransactionsByHashes(ctx context.Context, hashes []common.Hash) error
 	GetGasPrices(ctx context.Context) (uint64, uint64, error)
 	GetNonce(ctx context.Context, address common.Address) (uint64, error)
@@ -22,6 +23,7 @@ type storage interface {
 	GetTxsByFromAndNonce(ctx context.Context, from common.Address, nonce uint64) ([]Transaction, error)
 	GetTxsByStatus(ctx context.Context, state TxStatus, limit uint64) ([]Transaction, error)
 	GetNonWIPPendingTxs(ctx context.Context) ([]Transaction, error)
+	GetOldestNonWIPPendingTxTime(ctx context.Context) (time.Time, error)
 	IsTxPending(ctx context.Context, hash common.Hash) (bool, error)
 	SetGasPrices(ctx context.Context, l2GasPrice uint64, l1GasPrice uint64) error
 	DeleteGasPricesHistoryOlderThan(ctx context.Context, date time.Time) error
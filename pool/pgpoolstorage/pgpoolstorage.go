@@ -75,10 +75,11 @@ func (p *PostgresPoolStorage) AddTx(ctx context.Context, tx pool.Transaction) er
 			from_address,
 			is_wip,
 			ip,
-			failed_reason
+			failed_reason,
+			inclusion_deadline
 		) 
 		VALUES 
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NULL)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NULL, $20)
 			ON CONFLICT (hash) DO UPDATE SET 
 			encoded = $2,
 			decoded = $3,
@@ -98,7 +99,8 @@ func (p *PostgresPoolStorage) AddTx(ctx context.Context, tx pool.Transaction) er
 			from_address = $17,
 			is_wip = $18,
 			ip = $19,
-			failed_reason = NULL
+			failed_reason = NULL,
+			inclusion_deadline = $20
 	`
 
 	// Get FromAddress from the JSON data
@@ -127,7 +129,8 @@ func (p *PostgresPoolStorage) AddTx(ctx context.Context, tx pool.Transaction) er
 		tx.ReceivedAt,
 		fromAddress,
 		tx.IsWIP,
-		tx.IP); err != nil {
+		tx.IP,
+		tx.InclusionDeadline); err != nil {
 		return err
 	}
 	return nil
@@ -144,11 +147,11 @@ func (p *PostgresPoolStorage) GetTxsByStatus(ctx context.Context, status pool.Tx
 	)
 	if limit == 0 {
 		sql = `SELECT encoded, status, received_at, is_wip, ip, cumulative_gas_used, used_keccak_hashes, used_poseidon_hashes, used_poseidon_paddings, used_mem_aligns,
-				used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason FROM pool.transaction WHERE status = $1 ORDER BY gas_price DESC`
+				used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason, inclusion_deadline FROM pool.transaction WHERE status = $1 ORDER BY gas_price DESC`
 		rows, err = p.db.Query(ctx, sql, status.String())
 	} else {
 		sql = `SELECT encoded, status, received_at, is_wip, ip, cumulative_gas_used, used_keccak_hashes, used_poseidon_hashes, used_poseidon_paddings, used_mem_aligns,
-				used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason FROM pool.transaction WHERE status = $1 ORDER BY gas_price DESC LIMIT $2`
+				used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason, inclusion_deadline FROM pool.transaction WHERE status = $1 ORDER BY gas_price DESC LIMIT $2`
 		rows, err = p.db.Query(ctx, sql, status.String(), limit)
 	}
 	if err != nil {
@@ -177,7 +180,7 @@ func (p *PostgresPoolStorage) GetNonWIPPendingTxs(ctx context.Context) ([]pool.T
 	)
 
 	sql = `SELECT encoded, status, received_at, is_wip, ip, cumulative_gas_used, used_keccak_hashes, used_poseidon_hashes, used_poseidon_paddings, used_mem_aligns,
-		used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason FROM pool.transaction WHERE is_wip IS FALSE and status = $1`
+		used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason, inclusion_deadline FROM pool.transaction WHERE is_wip IS FALSE and status = $1`
 	rows, err = p.db.Query(ctx, sql, pool.TxStatusPending)
 
 	if err != nil {
@@ -197,6 +200,20 @@ func (p *PostgresPoolStorage) GetNonWIPPendingTxs(ctx context.Context) ([]pool.T
 	return txs, nil
 }
 
+// GetOldestNonWIPPendingTxTime returns the received time of the oldest non WIP pending tx
+func (p *PostgresPoolStorage) GetOldestNonWIPPendingTxTime(ctx context.Context) (time.Time, error) {
+	sql := "SELECT MIN(received_at) FROM pool.transaction WHERE is_wip IS FALSE and status = $1"
+	var receivedAt *time.Time
+	err := p.db.QueryRow(ctx, sql, pool.TxStatusPending).Scan(&receivedAt)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && receivedAt == nil) {
+		return time.Time{}, pool.ErrNotFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	return *receivedAt, nil
+}
+
 // GetPendingTxHashesSince returns the pending tx since the given time.
 func (p *PostgresPoolStorage) GetPendingTxHashesSince(ctx context.Context, since time.Time) ([]common.Hash, error) {
 	sql := "SELECT hash FROM pool.transaction WHERE status = $1 AND received_at >= $2"
@@ -363,6 +380,17 @@ func (p *PostgresPoolStorage) CountTransactionsByStatus(ctx context.Context, sta
 	return counter, nil
 }
 
+// CountPendingTxsByWIPStatus returns the number of pending transactions with the provided WIP status
+func (p *PostgresPoolStorage) CountPendingTxsByWIPStatus(ctx context.Context, isWIP bool) (uint64, error) {
+	sql := "SELECT COUNT(*) FROM pool.transaction WHERE status = $1 AND is_wip = $2"
+	var counter uint64
+	err := p.db.QueryRow(ctx, sql, pool.TxStatusPending, isWIP).Scan(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
+
 // CountTransactionsByFromAndStatus get number of transactions
 // accordingly to the from address and provided statuses
 func (p *PostgresPoolStorage) CountTransactionsByFromAndStatus(ctx context.Context, from common.Address, status ...pool.TxStatus) (uint64, error) {
@@ -511,7 +539,8 @@ func (p *PostgresPoolStorage) IsTxPending(ctx context.Context, hash common.Hash)
 // GetTxsByFromAndNonce get all the transactions from the pool with the same from and nonce
 func (p *PostgresPoolStorage) GetTxsByFromAndNonce(ctx context.Context, from common.Address, nonce uint64) ([]pool.Transaction, error) {
 	sql := `SELECT encoded, status, received_at, is_wip, ip, cumulative_gas_used, used_keccak_hashes, used_poseidon_hashes, 
-				   used_poseidon_paddings, used_mem_aligns,	used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason
+				   used_poseidon_paddings, used_mem_aligns,	used_arithmetics, used_binaries, used_steps, used_sha256_hashes, failed_reason,
+				   inclusion_deadline
 	          FROM pool.transaction
 			 WHERE from_address = $1
 			   AND nonce = $2`
@@ -682,10 +711,11 @@ func scanTx(rows pgx.Rows) (*pool.Transaction, error) {
 		usedSteps            uint32
 		usedSHA256Hashes     uint32
 		failedReason         *string
+		inclusionDeadline    *time.Time
 	)
 
 	if err := rows.Scan(&encoded, &status, &receivedAt, &isWIP, &ip, &cumulativeGasUsed, &usedKeccakHashes, &usedPoseidonHashes,
-		&usedPoseidonPaddings, &usedMemAligns, &usedArithmetics, &usedBinaries, &usedSteps, &usedSHA256Hashes, &failedReason); err != nil {
+		&usedPoseidonPaddings, &usedMemAligns, &usedArithmetics, &usedBinaries, &usedSteps, &usedSHA256Hashes, &failedReason, &inclusionDeadline); err != nil {
 		return nil, err
 	}
 
@@ -714,6 +744,7 @@ func scanTx(rows pgx.Rows) (*pool.Transaction, error) {
 	tx.ZKCounters.UsedSteps = usedSteps
 	tx.ZKCounters.UsedSha256Hashes_V2 = usedSHA256Hashes
 	tx.FailedReason = failedReason
+	tx.InclusionDeadline = inclusionDeadline
 
 	return tx, nil
 }
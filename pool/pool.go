@@ -354,6 +354,11 @@ func (p *Pool) GetNonWIPPendingTxs(ctx context.Context) ([]Transaction, error) {
 	return p.storage.GetNonWIPPendingTxs(ctx)
 }
 
+// GetOldestNonWIPPendingTxTime returns the received time of the oldest non WIP pending tx in the pool
+func (p *Pool) GetOldestNonWIPPendingTxTime(ctx context.Context) (time.Time, error) {
+	return p.storage.GetOldestNonWIPPendingTxTime(ctx)
+}
+
 // GetSelectedTxs gets selected txs from the pool db
 func (p *Pool) GetSelectedTxs(ctx context.Context, limit uint64) ([]Transaction, error) {
 	return p.storage.GetTxsByStatus(ctx, TxStatusSelected, limit)
@@ -397,6 +402,11 @@ func (p *Pool) CountPendingTransactions(ctx context.Context) (uint64, error) {
 	return p.storage.CountTransactionsByStatus(ctx, TxStatusPending)
 }
 
+// CountPendingTxsByWIPStatus returns the number of pending transactions that are (or are not) WIP in the sequencer
+func (p *Pool) CountPendingTxsByWIPStatus(ctx context.Context, isWIP bool) (uint64, error) {
+	return p.storage.CountPendingTxsByWIPStatus(ctx, isWIP)
+}
+
 // IsTxPending check if tx is still pending
 func (p *Pool) IsTxPending(ctx context.Context, hash common.Hash) (bool, error) {
 	return p.storage.IsTxPending(ctx, hash)
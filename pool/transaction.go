@@ -45,6 +45,8 @@ type Transaction struct {
 	IsWIP                 bool
 	IP                    string
 	FailedReason          *string
+	// InclusionDeadline is the optional time after which the tx must not be included in a batch anymore
+	InclusionDeadline *time.Time
 }
 
 // NewTransaction creates a new transaction
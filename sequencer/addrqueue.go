@@ -90,22 +90,24 @@ func (a *addrQueue) addPendingTxToStore(txHash common.Hash) {
 	a.pendingTxsToStore[txHash] = struct{}{}
 }
 
-// ExpireTransactions removes the txs that have been in the queue for more than maxTime
-func (a *addrQueue) ExpireTransactions(maxTime time.Duration) ([]*TxTracker, *TxTracker) {
+// ExpireTransactions removes the txs that have been in the queue for more than maxTime or whose inclusion deadline has been reached.
+// If skipInFlight is true the txs being processed by the finalizer are kept
+func (a *addrQueue) ExpireTransactions(maxTime time.Duration, skipInFlight bool) ([]*TxTracker, *TxTracker) {
 	var (
 		txs         []*TxTracker
 		prevReadyTx *TxTracker
 	)
 
+	now := time.Now()
 	for _, txTracker := range a.notReadyTxs {
-		if txTracker.ReceivedAt.Add(maxTime).Before(time.Now()) {
+		if txTracker.isExpired(maxTime, now) && !(skipInFlight && txTracker.InFlight) {
 			txs = append(txs, txTracker)
 			delete(a.notReadyTxs, txTracker.Nonce)
 			log.Debugf("deleting notReadyTx %s from addrQueue %s", txTracker.HashStr, a.fromStr)
 		}
 	}
 
-	if a.readyTx != nil && a.readyTx.ReceivedAt.Add(maxTime).Before(time.Now()) {
+	if a.readyTx != nil && a.readyTx.isExpired(maxTime, now) && !(skipInFlight && a.readyTx.InFlight) {
 		prevReadyTx = a.readyTx
 		txs = append(txs, a.readyTx)
 		a.readyTx = nil
@@ -115,11 +117,86 @@ func (a *addrQueue) ExpireTransactions(maxTime time.Duration) ([]*TxTracker, *Tx
 	return txs, prevReadyTx
 }
 
+// clear deletes the ready and notReady txs of the addrQueue, except the ones being processed by the finalizer (in-flight).
+// It returns the txs deleted and the previous readyTx if it was deleted
+func (a *addrQueue) clear() ([]*TxTracker, *TxTracker) {
+	var (
+		txs         []*TxTracker
+		prevReadyTx *TxTracker
+	)
+
+	for nonce, txTracker := range a.notReadyTxs {
+		if !txTracker.InFlight {
+			txs = append(txs, txTracker)
+			delete(a.notReadyTxs, nonce)
+		}
+	}
+
+	if a.readyTx != nil && !a.readyTx.InFlight {
+		prevReadyTx = a.readyTx
+		txs = append(txs, a.readyTx)
+		a.readyTx = nil
+	}
+
+	return txs, prevReadyTx
+}
+
+// countTxsOlderThan returns the number of txs (ready and notReady) that have been in the queue for more than age
+func (a *addrQueue) countTxsOlderThan(age time.Duration) int {
+	count := 0
+	for _, txTracker := range a.notReadyTxs {
+		if txTracker.ReceivedAt.Add(age).Before(time.Now()) {
+			count++
+		}
+	}
+	if a.readyTx != nil && a.readyTx.ReceivedAt.Add(age).Before(time.Now()) {
+		count++
+	}
+	return count
+}
+
 // IsEmpty returns true if the addrQueue is empty
 func (a *addrQueue) IsEmpty() bool {
 	return a.readyTx == nil && len(a.notReadyTxs) == 0 && len(a.forcedTxs) == 0 && len(a.pendingTxsToStore) == 0
 }
 
+// countTxs returns the number of txs (ready and notReady) in the addrQueue
+func (a *addrQueue) countTxs() int {
+	count := len(a.notReadyTxs)
+	if a.readyTx != nil {
+		count++
+	}
+	return count
+}
+
+// lowestNonce returns the lowest nonce of the txs (ready and notReady) of the addrQueue, false if there are no txs
+func (a *addrQueue) lowestNonce() (uint64, bool) {
+	found := a.readyTx != nil
+	nonce := uint64(0)
+	if found {
+		nonce = a.readyTx.Nonce
+	}
+	for txNonce := range a.notReadyTxs {
+		if !found || txNonce < nonce {
+			nonce = txNonce
+			found = true
+		}
+	}
+	return nonce, found
+}
+
+// countBytes returns the approximate number of bytes held by the txs (ready and notReady) of the addrQueue
+func (a *addrQueue) countBytes() uint64 {
+	bytes := uint64(0)
+	for _, txTracker := range a.notReadyTxs {
+		bytes += uint64(len(txTracker.RawTx))
+	}
+	if a.readyTx != nil {
+		bytes += uint64(len(a.readyTx.RawTx))
+	}
+	return bytes
+}
+
 // deleteTx deletes the tx from the addrQueue
 func (a *addrQueue) deleteTx(txHash common.Hash) (deletedReadyTx *TxTracker) {
 	txHashStr := txHash.String()
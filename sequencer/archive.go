@@ -0,0 +1,365 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+const (
+	// archiveManifestName is the name of the object with the manifest of the archived segments
+	archiveManifestName = "manifest.json"
+	// archiveManifestVersion is the version of the format of the manifest and the segments
+	archiveManifestVersion = 1
+	// archiveUploadRetryInterval is the time waited before retrying a failed upload to the object store
+	archiveUploadRetryInterval = 5 * time.Second
+)
+
+var (
+	// errObjectNotFound is returned by the object store when the object doesn't exist
+	errObjectNotFound = errors.New("object not found")
+)
+
+// objectStore contains the methods required to upload the archive of the data stream to an object store
+type objectStore interface {
+	PutObject(ctx context.Context, key string, data []byte) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+}
+
+// streamEntryReader reads the entries already committed to the data stream
+type streamEntryReader interface {
+	GetEntry(entryNum uint64) (datastreamer.FileEntry, error)
+}
+
+// archiveManifest lists the segments of the data stream uploaded to the object store
+type archiveManifest struct {
+	Version  int              `json:"version"`
+	Segments []archiveSegment `json:"segments"`
+}
+
+// archiveSegment is an object with the consecutive entries of the data stream from FirstEntry to LastEntry
+type archiveSegment struct {
+	Key        string `json:"key"`
+	FirstEntry uint64 `json:"firstEntry"`
+	LastEntry  uint64 `json:"lastEntry"`
+	Size       int    `json:"size"`
+}
+
+// objectStoreSink accumulates the entries committed to the data stream into segments and uploads them, along with the
+// manifest, to an object store. The segments are uploaded from its own goroutine, so the data stream server is never blocked
+type objectStoreSink struct {
+	cfg      ArchiveCfg
+	store    objectStore
+	eventLog *event.EventLog
+	// reader reads the entries written to the data stream without being committed to the sink, e.g. by its regeneration
+	reader streamEntryReader
+	// uploadEnabled returns false while the uploads are paused, the entries keep being added to the segment meanwhile. If
+	// nil the uploads are always enabled
+	uploadEnabled func() bool
+
+	// committed receives the entries of each atomic op committed to the data stream
+	committed chan []datastreamer.FileEntry
+	// disabled is set when the archive fails or committed is full, the entries are not archived anymore as there would be a gap
+	disabled     atomic.Bool
+	disabledOnce sync.Once
+
+	manifest  archiveManifest
+	nextEntry uint64
+	segment   []datastreamer.FileEntry
+	// segmentStart is the time the first entry of the segment was added
+	segmentStart time.Time
+}
+
+// newObjectStoreSink creates the sink of the data stream archive
+func newObjectStoreSink(cfg ArchiveCfg, store objectStore, eventLog *event.EventLog) *objectStoreSink {
+	return &objectStoreSink{
+		cfg:       cfg,
+		store:     store,
+		eventLog:  eventLog,
+		committed: make(chan []datastreamer.FileEntry, max(cfg.QueueSize, 1)),
+		manifest:  archiveManifest{Version: archiveManifestVersion},
+	}
+}
+
+// commit queues the entries of an atomic op committed to the data stream to be archived. It never blocks, if the queue is
+// full the archive is disabled
+func (o *objectStoreSink) commit(entries []datastreamer.FileEntry) {
+	if o.disabled.Load() || len(entries) == 0 {
+		return
+	}
+
+	select {
+	case o.committed <- entries:
+	default:
+		o.disable(fmt.Sprintf("data stream archive disabled, the queue of committed entries is full (size: %d)", cap(o.committed)))
+	}
+}
+
+// disable disables the archive, logging the error the first time
+func (o *objectStoreSink) disable(description string) {
+	o.disabled.Store(true)
+	o.disabledOnce.Do(func() {
+		log.Error(description)
+
+		event := &event.Event{
+			ReceivedAt:  time.Now(),
+			Source:      event.Source_Node,
+			Component:   event.Component_Sequencer,
+			Level:       event.Level_Error,
+			EventID:     event.EventID_DataStreamerArchiveDisabled,
+			Description: description,
+		}
+		logEvent(context.Background(), o.eventLog, event)
+	})
+}
+
+// start resumes the archive from its manifest, archiving first the entries of the data stream up to totalEntries not archived
+// yet, and then keeps archiving the committed entries until ctx is done
+func (o *objectStoreSink) start(ctx context.Context, reader streamEntryReader, totalEntries uint64) {
+	err := o.loadManifest(ctx)
+	if err != nil {
+		o.disable(fmt.Sprintf("data stream archive disabled, failed to load the manifest, error: %v", err))
+		return
+	}
+
+	o.reader = reader
+	if !o.backfill(ctx, totalEntries) {
+		return
+	}
+
+	// If FlushInterval is 0 the segments are only uploaded once they are full
+	var tick <-chan time.Time
+	if o.cfg.FlushInterval.Duration > 0 {
+		ticker := time.NewTicker(o.cfg.FlushInterval.Duration)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entries := <-o.committed:
+			for _, entry := range entries {
+				o.add(ctx, entry)
+			}
+		case <-tick:
+			if len(o.segment) > 0 && time.Since(o.segmentStart) >= o.cfg.FlushInterval.Duration && o.isUploadEnabled() {
+				o.upload(ctx)
+			}
+		}
+	}
+}
+
+// loadManifest loads the manifest of the archive, resuming after the last entry archived
+func (o *objectStoreSink) loadManifest(ctx context.Context) error {
+	data, err := o.store.GetObject(ctx, o.key(archiveManifestName))
+	if errors.Is(err, errObjectNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, &o.manifest)
+	if err != nil {
+		return err
+	}
+	if o.manifest.Version != archiveManifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", o.manifest.Version)
+	}
+	if len(o.manifest.Segments) > 0 {
+		o.nextEntry = o.manifest.Segments[len(o.manifest.Segments)-1].LastEntry + 1
+	}
+	log.Infof("data stream archive resumed from entry %d", o.nextEntry)
+	return nil
+}
+
+// backfill adds the entries of the data stream from the next entry to archive up to toEntry, reading them with the reader.
+// It returns false if an entry can't be read, disabling the archive
+func (o *objectStoreSink) backfill(ctx context.Context, toEntry uint64) bool {
+	for entryNum := o.nextEntry; entryNum < toEntry; entryNum++ {
+		entry, err := o.reader.GetEntry(entryNum)
+		if err != nil {
+			o.disable(fmt.Sprintf("data stream archive disabled, failed to read entry %d, error: %v", entryNum, err))
+			return false
+		}
+		o.append(ctx, entry)
+	}
+	return true
+}
+
+// add adds the entry to the current segment, uploading it once it has SegmentEntries entries. The entries already archived
+// are skipped, and the entries written to the data stream since the last entry archived, but not committed to the sink,
+// are backfilled first so the archive has no gaps
+func (o *objectStoreSink) add(ctx context.Context, entry datastreamer.FileEntry) {
+	if entry.Number < o.nextEntry {
+		return
+	}
+	if entry.Number > o.nextEntry && !o.backfill(ctx, entry.Number) {
+		return
+	}
+	o.append(ctx, entry)
+}
+
+// append appends the entry, the next one to archive, to the current segment, uploading it once it has SegmentEntries entries
+func (o *objectStoreSink) append(ctx context.Context, entry datastreamer.FileEntry) {
+	if len(o.segment) == 0 {
+		o.segmentStart = time.Now()
+	}
+	o.segment = append(o.segment, entry)
+	o.nextEntry = entry.Number + 1
+
+	if uint64(len(o.segment)) >= o.cfg.SegmentEntries && o.isUploadEnabled() {
+		o.upload(ctx)
+	}
+}
+
+// isUploadEnabled returns false while the uploads are paused
+func (o *objectStoreSink) isUploadEnabled() bool {
+	return o.uploadEnabled == nil || o.uploadEnabled()
+}
+
+// upload uploads the current segment and the manifest updated with it, retrying until they are uploaded or ctx is done
+func (o *objectStoreSink) upload(ctx context.Context) {
+	first, last := o.segment[0].Number, o.segment[len(o.segment)-1].Number
+	data := encodeArchiveSegment(o.segment)
+	segment := archiveSegment{
+		Key:        o.key(fmt.Sprintf("segment-%020d-%020d.bin", first, last)),
+		FirstEntry: first,
+		LastEntry:  last,
+		Size:       len(data),
+	}
+
+	manifest := o.manifest
+	manifest.Segments = append(manifest.Segments[:len(manifest.Segments):len(manifest.Segments)], segment)
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		log.Errorf("failed to encode the data stream archive manifest, error: %v", err)
+		return
+	}
+
+	for {
+		err = o.store.PutObject(ctx, segment.Key, data)
+		if err == nil {
+			err = o.store.PutObject(ctx, o.key(archiveManifestName), manifestData)
+		}
+		if err == nil {
+			break
+		}
+
+		log.Errorf("failed to upload data stream archive segment of entries %d to %d, retrying in %s, error: %v", first, last, archiveUploadRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(archiveUploadRetryInterval):
+		}
+	}
+
+	o.manifest = manifest
+	o.segment = nil
+	log.Infof("data stream archive segment of entries %d to %d uploaded", first, last)
+}
+
+// key returns the key of the object with the given name under the archive prefix
+func (o *objectStoreSink) key(name string) string {
+	return path.Join(o.cfg.Prefix, name)
+}
+
+// encodeArchiveSegment encodes the entries of a segment. Each entry is encoded as its type (4 bytes), number (8 bytes),
+// data length (4 bytes) and data
+func encodeArchiveSegment(entries []datastreamer.FileEntry) []byte {
+	data := make([]byte, 0)
+	for _, entry := range entries {
+		data = binary.LittleEndian.AppendUint32(data, uint32(entry.Type))
+		data = binary.LittleEndian.AppendUint64(data, entry.Number)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(entry.Data)))
+		data = append(data, entry.Data...)
+	}
+	return data
+}
+
+// decodeArchiveSegment decodes the entries of a segment encoded with encodeArchiveSegment
+func decodeArchiveSegment(data []byte) ([]datastreamer.FileEntry, error) {
+	entries := []datastreamer.FileEntry{}
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 16 { //nolint:gomnd
+			return nil, fmt.Errorf("invalid archive segment entry header at %d", pos)
+		}
+		entry := datastreamer.FileEntry{
+			Type:   datastreamer.EntryType(binary.LittleEndian.Uint32(data[pos : pos+4])),
+			Number: binary.LittleEndian.Uint64(data[pos+4 : pos+12]),
+		}
+		length := int(binary.LittleEndian.Uint32(data[pos+12 : pos+16]))
+		pos += 16
+		if len(data)-pos < length {
+			return nil, fmt.Errorf("invalid archive segment entry %d length %d", entry.Number, length)
+		}
+		entry.Data = data[pos : pos+length]
+		pos += length
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// archivedStreamServer sends the entries to the data stream server and, once their atomic op is committed, to the archive sink
+type archivedStreamServer struct {
+	dataStreamServer
+	sink *objectStoreSink
+	// entries are the entries added in the current atomic op
+	entries []datastreamer.FileEntry
+}
+
+// newArchivedStreamServer creates a data stream server that archives the entries committed to streamServer with sink
+func newArchivedStreamServer(streamServer dataStreamServer, sink *objectStoreSink) *archivedStreamServer {
+	return &archivedStreamServer{dataStreamServer: streamServer, sink: sink}
+}
+
+// StartAtomicOp starts an atomic op in the data stream server
+func (a *archivedStreamServer) StartAtomicOp() error {
+	a.entries = nil
+	return a.dataStreamServer.StartAtomicOp()
+}
+
+// AddStreamEntry adds an entry to the data stream server, keeping it to be archived once committed
+func (a *archivedStreamServer) AddStreamEntry(etype datastreamer.EntryType, data []byte) (uint64, error) {
+	entryNum, err := a.dataStreamServer.AddStreamEntry(etype, data)
+	if err == nil {
+		a.entries = append(a.entries, datastreamer.FileEntry{Type: etype, Number: entryNum, Data: data})
+	}
+	return entryNum, err
+}
+
+// AddStreamBookmark adds a bookmark to the data stream server, keeping it to be archived once committed
+func (a *archivedStreamServer) AddStreamBookmark(bookmark []byte) (uint64, error) {
+	entryNum, err := a.dataStreamServer.AddStreamBookmark(bookmark)
+	if err == nil {
+		a.entries = append(a.entries, datastreamer.FileEntry{Type: datastreamer.EtBookmark, Number: entryNum, Data: bookmark})
+	}
+	return entryNum, err
+}
+
+// CommitAtomicOp commits the atomic op in the data stream server, sending its entries to the archive sink
+func (a *archivedStreamServer) CommitAtomicOp() error {
+	err := a.dataStreamServer.CommitAtomicOp()
+	if err == nil {
+		a.sink.commit(a.entries)
+	}
+	a.entries = nil
+	return err
+}
+
+// RollbackAtomicOp rolls back the atomic op in the data stream server, discarding its entries
+func (a *archivedStreamServer) RollbackAtomicOp() error {
+	a.entries = nil
+	return a.dataStreamServer.RollbackAtomicOp()
+}
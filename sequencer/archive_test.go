@@ -0,0 +1,251 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectStore is an in-memory object store
+type fakeObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeObjectStore() *fakeObjectStore {
+	return &fakeObjectStore{objects: map[string][]byte{}}
+}
+
+func (f *fakeObjectStore) PutObject(ctx context.Context, key string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = append([]byte{}, data...)
+	return nil
+}
+
+func (f *fakeObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, errObjectNotFound
+	}
+	return data, nil
+}
+
+// manifest returns the archive manifest uploaded to the store with the given prefix
+func (f *fakeObjectStore) manifest(t *testing.T, prefix string) archiveManifest {
+	data, err := f.GetObject(context.Background(), prefix+"/"+archiveManifestName)
+	if err != nil {
+		return archiveManifest{}
+	}
+	manifest := archiveManifest{}
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	return manifest
+}
+
+// archivedEntries returns the entries of the segments listed in the manifest
+func (f *fakeObjectStore) archivedEntries(t *testing.T, manifest archiveManifest) []datastreamer.FileEntry {
+	entries := []datastreamer.FileEntry{}
+	for _, segment := range manifest.Segments {
+		data, err := f.GetObject(context.Background(), segment.Key)
+		require.NoError(t, err)
+		assert.Equal(t, segment.Size, len(data))
+		segmentEntries, err := decodeArchiveSegment(data)
+		require.NoError(t, err)
+		entries = append(entries, segmentEntries...)
+	}
+	return entries
+}
+
+func TestObjectStoreSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamServer := newTestStreamServer(t)
+	store := newFakeObjectStore()
+	cfg := ArchiveCfg{Prefix: "archive", SegmentEntries: 2, QueueSize: 10}
+	sink := newObjectStoreSink(cfg, store, nil)
+	go sink.start(ctx, streamServer, streamServer.GetHeader().TotalEntries)
+
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+	p := newStreamPipeline(StreamServerCfg{}, newArchivedStreamServer(streamServer, sink), stMock, nil, nil)
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 2, 1)}))
+	totalEntries := streamServer.GetHeader().TotalEntries
+
+	// Only full segments are uploaded as FlushInterval is 0
+	require.Eventually(t, func() bool {
+		manifest := store.manifest(t, "archive")
+		return len(manifest.Segments) == int(totalEntries/2)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	manifest := store.manifest(t, "archive")
+	assert.Equal(t, archiveManifestVersion, manifest.Version)
+	assert.Equal(t, "archive/segment-00000000000000000000-00000000000000000001.bin", manifest.Segments[0].Key)
+	entries := store.archivedEntries(t, manifest)
+	for i, entry := range entries {
+		streamEntry, err := streamServer.GetEntry(uint64(i))
+		require.NoError(t, err)
+		assert.Equal(t, streamEntry.Type, entry.Type)
+		assert.Equal(t, streamEntry.Number, entry.Number)
+		assert.Equal(t, streamEntry.Data, entry.Data)
+	}
+}
+
+func TestObjectStoreSink_ResumeFromManifest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamServer := newTestStreamServer(t)
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+	p := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1)}))
+	totalEntries := streamServer.GetHeader().TotalEntries
+
+	// The first 2 entries were already archived
+	store := newFakeObjectStore()
+	cfg := ArchiveCfg{Prefix: "archive", SegmentEntries: 100, FlushInterval: cfgTypes.NewDuration(10 * time.Millisecond), QueueSize: 10}
+	previous := archiveManifest{Version: archiveManifestVersion, Segments: []archiveSegment{{Key: "archive/previous.bin", FirstEntry: 0, LastEntry: 1}}}
+	data, err := json.Marshal(previous)
+	require.NoError(t, err)
+	require.NoError(t, store.PutObject(ctx, "archive/"+archiveManifestName, data))
+
+	// The entries not archived yet are backfilled and the segment is flushed although it's not full
+	sink := newObjectStoreSink(cfg, store, nil)
+	go sink.start(ctx, streamServer, totalEntries)
+
+	require.Eventually(t, func() bool {
+		return len(store.manifest(t, "archive").Segments) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	segment := store.manifest(t, "archive").Segments[1]
+	assert.Equal(t, uint64(2), segment.FirstEntry)
+	assert.Equal(t, totalEntries-1, segment.LastEntry)
+}
+
+func TestObjectStoreSink_BackfillUncommittedEntries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamServer := newTestStreamServer(t)
+	store := newFakeObjectStore()
+	cfg := ArchiveCfg{Prefix: "archive", SegmentEntries: 1, QueueSize: 10}
+	sink := newObjectStoreSink(cfg, store, nil)
+	go sink.start(ctx, streamServer, streamServer.GetHeader().TotalEntries)
+
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+
+	// The first L2 block is written without the archived stream server, as the regeneration in background does
+	regeneration := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+	require.NoError(t, regeneration.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1)}))
+	p := newStreamPipeline(StreamServerCfg{}, newArchivedStreamServer(streamServer, sink), stMock, nil, nil)
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 2, 1)}))
+	totalEntries := streamServer.GetHeader().TotalEntries
+
+	require.Eventually(t, func() bool {
+		return len(store.manifest(t, "archive").Segments) == int(totalEntries)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	entries := store.archivedEntries(t, store.manifest(t, "archive"))
+	require.Len(t, entries, int(totalEntries))
+	for i, entry := range entries {
+		streamEntry, err := streamServer.GetEntry(uint64(i))
+		require.NoError(t, err)
+		assert.Equal(t, streamEntry.Number, entry.Number)
+		assert.Equal(t, streamEntry.Type, entry.Type)
+	}
+}
+
+func TestObjectStoreSink_UploadDisabled(t *testing.T) {
+	store := newFakeObjectStore()
+	sink := newObjectStoreSink(ArchiveCfg{Prefix: "archive", SegmentEntries: 1}, store, nil)
+	var enabled atomic.Bool
+	sink.uploadEnabled = enabled.Load
+
+	// The entries are accumulated while the uploads are disabled, and uploaded in a single segment once enabled
+	sink.add(context.Background(), datastreamer.FileEntry{Type: state.EntryTypeL2Tx, Number: 0})
+	sink.add(context.Background(), datastreamer.FileEntry{Type: state.EntryTypeL2Tx, Number: 1})
+	assert.Empty(t, store.manifest(t, "archive").Segments)
+
+	enabled.Store(true)
+	sink.add(context.Background(), datastreamer.FileEntry{Type: state.EntryTypeL2Tx, Number: 2})
+	segments := store.manifest(t, "archive").Segments
+	require.Len(t, segments, 1)
+	assert.Equal(t, uint64(0), segments[0].FirstEntry)
+	assert.Equal(t, uint64(2), segments[0].LastEntry)
+}
+
+func TestObjectStoreSink_QueueFull(t *testing.T) {
+	store := newFakeObjectStore()
+	sink := newObjectStoreSink(ArchiveCfg{SegmentEntries: 1, QueueSize: 1}, store, nil)
+
+	// The sink is not started, so the second commit finds the queue full and the archive is disabled without blocking
+	sink.commit([]datastreamer.FileEntry{{Type: state.EntryTypeL2Tx, Number: 0}})
+	sink.commit([]datastreamer.FileEntry{{Type: state.EntryTypeL2Tx, Number: 1}})
+	assert.True(t, sink.disabled.Load())
+
+	sink.commit([]datastreamer.FileEntry{{Type: state.EntryTypeL2Tx, Number: 2}})
+	assert.Len(t, sink.committed, 1)
+}
+
+func TestS3ObjectStore(t *testing.T) {
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			objects[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, err := w.Write(data)
+			require.NoError(t, err)
+		}
+	}))
+	defer server.Close()
+
+	store := newS3ObjectStore(ArchiveCfg{Endpoint: server.URL, Region: "us-east-1", Bucket: "bucket", AccessKeyID: "key", SecretAccessKey: "secret"})
+	ctx := context.Background()
+
+	_, err := store.GetObject(ctx, "archive/manifest.json")
+	require.ErrorIs(t, err, errObjectNotFound)
+
+	require.NoError(t, store.PutObject(ctx, "archive/manifest.json", []byte("{}")))
+	assert.Equal(t, []byte("{}"), objects["/bucket/archive/manifest.json"])
+	data, err := store.GetObject(ctx, "archive/manifest.json")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), data)
+
+	// The requests are rejected without credentials
+	store = newS3ObjectStore(ArchiveCfg{Endpoint: server.URL, Bucket: "bucket"})
+	require.Error(t, store.PutObject(ctx, "archive/manifest.json", []byte("{}")))
+}
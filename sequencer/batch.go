@@ -28,10 +28,38 @@ type Batch struct {
 	closingReason      state.ClosingReason
 }
 
+// BatchUsage is the usage of the resources of the wip batch
+type BatchUsage struct {
+	BatchNumber   uint64
+	CountOfTxs    uint64
+	UsedResources state.BatchResources
+}
+
 func (w *Batch) isEmpty() bool {
 	return w.countOfTxs == 0
 }
 
+// updateWIPBatchUsage updates the usage of the wip batch returned by WIPBatchUsage
+func (f *finalizer) updateWIPBatchUsage() {
+	usage := BatchUsage{
+		BatchNumber:   f.wipBatch.batchNumber,
+		CountOfTxs:    uint64(f.wipBatch.countOfTxs),
+		UsedResources: getUsedBatchResources(f.batchConstraints, f.wipBatch.remainingResources),
+	}
+
+	f.wipBatchUsageMux.Lock()
+	f.wipBatchUsage = usage
+	f.wipBatchUsageMux.Unlock()
+}
+
+// WIPBatchUsage returns the usage of the resources of the wip batch
+func (f *finalizer) WIPBatchUsage() BatchUsage {
+	f.wipBatchUsageMux.Lock()
+	defer f.wipBatchUsageMux.Unlock()
+
+	return f.wipBatchUsage
+}
+
 // setWIPBatch sets finalizer wip batch to the state batch passed as parameter
 func (f *finalizer) setWIPBatch(ctx context.Context, wipStateBatch *state.Batch) (*Batch, error) {
 	// Retrieve prevStateBatch to init the initialStateRoot of the wip batch
@@ -390,10 +418,7 @@ func (f *finalizer) batchSanityCheck(ctx context.Context, batchNum uint64, initi
 				Description: string(payload),
 				Json:        executorBatchRequest,
 			}
-			err = f.eventLog.LogEvent(ctx, event)
-			if err != nil {
-				log.Errorf("error storing payload, error: %w", err)
-			}
+			logEvent(ctx, f.eventLog, event)
 		}
 
 		return nil, ErrProcessBatchOOC
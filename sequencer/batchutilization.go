@@ -0,0 +1,66 @@
+package sequencer
+
+import (
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// BatchUtilization is the utilization of the wip batch of the finalizer. The utilization of each resource is the percentage
+// (0-100) used of its batch constraint
+type BatchUtilization struct {
+	Constraints state.BatchConstraintsCfg
+	BatchNumber uint64
+
+	Txs              float64
+	Bytes            float64
+	GasUsed          float64
+	KeccakHashes     float64
+	PoseidonHashes   float64
+	PoseidonPaddings float64
+	MemAligns        float64
+	Arithmetics      float64
+	Binaries         float64
+	Steps            float64
+	SHA256Hashes     float64
+}
+
+// BatchUtilization returns the batch constraints and the utilization of the wip batch of the finalizer. If the finalizer
+// is not started the utilization is 0
+func (s *Sequencer) BatchUtilization() BatchUtilization {
+	constraints := s.batchCfg.Constraints
+	utilization := BatchUtilization{Constraints: constraints}
+	if s.finalizer == nil {
+		return utilization
+	}
+
+	usage := s.finalizer.WIPBatchUsage()
+	used := usage.UsedResources.ZKCounters
+
+	utilization.BatchNumber = usage.BatchNumber
+	utilization.Txs = utilizationPercentage(usage.CountOfTxs, constraints.MaxTxsPerBatch)
+	utilization.Bytes = utilizationPercentage(usage.UsedResources.Bytes, constraints.MaxBatchBytesSize)
+	utilization.GasUsed = utilizationPercentage(used.GasUsed, constraints.MaxCumulativeGasUsed)
+	utilization.KeccakHashes = utilizationPercentage(uint64(used.UsedKeccakHashes), uint64(constraints.MaxKeccakHashes))
+	utilization.PoseidonHashes = utilizationPercentage(uint64(used.UsedPoseidonHashes), uint64(constraints.MaxPoseidonHashes))
+	utilization.PoseidonPaddings = utilizationPercentage(uint64(used.UsedPoseidonPaddings), uint64(constraints.MaxPoseidonPaddings))
+	utilization.MemAligns = utilizationPercentage(uint64(used.UsedMemAligns), uint64(constraints.MaxMemAligns))
+	utilization.Arithmetics = utilizationPercentage(uint64(used.UsedArithmetics), uint64(constraints.MaxArithmetics))
+	utilization.Binaries = utilizationPercentage(uint64(used.UsedBinaries), uint64(constraints.MaxBinaries))
+	utilization.Steps = utilizationPercentage(uint64(used.UsedSteps), uint64(constraints.MaxSteps))
+	utilization.SHA256Hashes = utilizationPercentage(uint64(used.UsedSha256Hashes_V2), uint64(constraints.MaxSHA256Hashes))
+
+	return utilization
+}
+
+// Fullness returns the utilization of the most used resource of the batch, which is the one that closes it
+func (u BatchUtilization) Fullness() float64 {
+	return max(u.Txs, u.Bytes, u.GasUsed, u.KeccakHashes, u.PoseidonHashes, u.PoseidonPaddings, u.MemAligns, u.Arithmetics,
+		u.Binaries, u.Steps, u.SHA256Hashes)
+}
+
+// utilizationPercentage returns the percentage of used over maxUsage. If maxUsage is 0 it returns 0
+func utilizationPercentage(used, maxUsage uint64) float64 {
+	if maxUsage == 0 {
+		return 0
+	}
+	return float64(used) * 100 / float64(maxUsage) //nolint:gomnd
+}
@@ -0,0 +1,47 @@
+package sequencer
+
+import (
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_BatchUtilization(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+	s.batchCfg.Constraints = state.BatchConstraintsCfg{
+		MaxTxsPerBatch:       100,
+		MaxBatchBytesSize:    1000,
+		MaxCumulativeGasUsed: 2000,
+		MaxKeccakHashes:      10,
+		MaxSteps:             400,
+	}
+
+	// The finalizer is not started
+	utilization := s.BatchUtilization()
+	assert.Equal(t, s.batchCfg.Constraints, utilization.Constraints)
+	assert.Equal(t, float64(0), utilization.Txs)
+
+	s.finalizer = &fakeFinalizer{usage: BatchUsage{
+		BatchNumber: 7,
+		CountOfTxs:  25,
+		UsedResources: state.BatchResources{
+			ZKCounters: state.ZKCounters{GasUsed: 1500, UsedKeccakHashes: 1, UsedSteps: 400, UsedArithmetics: 5},
+			Bytes:      100,
+		},
+	}}
+
+	utilization = s.BatchUtilization()
+	assert.Equal(t, s.batchCfg.Constraints, utilization.Constraints)
+	assert.Equal(t, uint64(7), utilization.BatchNumber)
+	assert.Equal(t, float64(25), utilization.Txs)
+	assert.Equal(t, float64(10), utilization.Bytes)
+	assert.Equal(t, float64(75), utilization.GasUsed)
+	assert.Equal(t, float64(10), utilization.KeccakHashes)
+	assert.Equal(t, float64(100), utilization.Steps)
+	// The resources without constraint are reported as not used
+	assert.Equal(t, float64(0), utilization.Arithmetics)
+	assert.Equal(t, float64(0), utilization.PoseidonHashes)
+	// The fullness is the utilization of the most used resource
+	assert.Equal(t, float64(100), utilization.Fullness())
+}
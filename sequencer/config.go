@@ -1,35 +1,231 @@
 package sequencer
 
 import (
+	"math/big"
+
 	"github.com/0xPolygonHermez/zkevm-data-streamer/log"
 	"github.com/0xPolygonHermez/zkevm-node/config/types"
 )
 
 // Config represents the configuration of a sequencer
 type Config struct {
+	// Mode is the mode in which the sequencer runs:
+	// - active: the sequencer loads txs from the pool and produces L2 blocks
+	// - standby: the sequencer doesn't load txs from the pool nor produce L2 blocks, it only keeps the data stream
+	//   updated with the closed batches of the state every StandbyStreamUpdateInterval (read-only mirror)
+	Mode string `mapstructure:"Mode" jsonschema:"enum=active,enum=standby"`
+
+	// StandbyStreamUpdateInterval is the time the sequencer waits in standby mode to update the data stream with the new batches of the state
+	StandbyStreamUpdateInterval types.Duration `mapstructure:"StandbyStreamUpdateInterval"`
+
 	// DeletePoolTxsL1BlockConfirmations is blocks amount after which txs will be deleted from the pool
 	DeletePoolTxsL1BlockConfirmations uint64 `mapstructure:"DeletePoolTxsL1BlockConfirmations"`
 
 	// DeletePoolTxsCheckInterval is frequency with which txs will be checked for deleting
 	DeletePoolTxsCheckInterval types.Duration `mapstructure:"DeletePoolTxsCheckInterval"`
 
+	// MinDeleteConfirmations is the minimum number of L1 block confirmations used to delete the txs from the pool. If
+	// DeletePoolTxsL1BlockConfirmations is lower it's clamped up to this value (with a warning), so a misconfigured low value
+	// doesn't delete txs that can still be reorged
+	MinDeleteConfirmations uint64 `mapstructure:"MinDeleteConfirmations"`
+
+	// DeletePoolTxsBatchSize is the maximum number of txs deleted from the pool in each call to the pool, so a large number of
+	// txs to delete (e.g. after a long stall) doesn't time out the DB. If it's 0 all the txs are deleted in a single call
+	DeletePoolTxsBatchSize uint64 `mapstructure:"DeletePoolTxsBatchSize"`
+
+	// DeleteFailedPoolTxsRetryInterval is the time waited to retry the deletion of the old failed txs from the pool when it
+	// fails, without deleting the confirmed txs again. If it's 0 the deletion is retried in the next check
+	// (DeletePoolTxsCheckInterval)
+	DeleteFailedPoolTxsRetryInterval types.Duration `mapstructure:"DeleteFailedPoolTxsRetryInterval"`
+
 	// TxLifetimeCheckInterval is the time the sequencer waits to check txs lifetime
 	TxLifetimeCheckInterval types.Duration `mapstructure:"TxLifetimeCheckInterval"`
 
 	// TxLifetimeMax is the time a tx can be in the sequencer/worker memory
 	TxLifetimeMax types.Duration `mapstructure:"TxLifetimeMax"`
 
+	// TxLifetimeSkipInFlight makes the expiration of the txs skip the ones the finalizer is processing, so a tx included in a L2 block
+	// while it's expired is not set as failed in the pool. The skipped txs are expired in a later check if they are still in the worker
+	TxLifetimeSkipInFlight bool `mapstructure:"TxLifetimeSkipInFlight"`
+
+	// ExpiredTxsMaxRetries is the max number of times the failed status update in the pool of an expired tx is retried in the
+	// next checks of the txs lifetime. Once an expired tx reaches it, its status update is not retried anymore
+	ExpiredTxsMaxRetries uint64 `mapstructure:"ExpiredTxsMaxRetries"`
+
+	// TxAgeWarnThreshold is the age from which the txs in the worker that are not yet expired are counted as old txs.
+	// The old txs are checked every TxLifetimeCheckInterval. If it is 0 the check is disabled
+	TxAgeWarnThreshold types.Duration `mapstructure:"TxAgeWarnThreshold"`
+
+	// TxAgeWarnEventInterval is the min time between the events logged when there are old txs in the worker.
+	// If it is 0 no events are logged
+	TxAgeWarnEventInterval types.Duration `mapstructure:"TxAgeWarnEventInterval"`
+
 	// LoadPoolTxsCheckInterval is the time the sequencer waits to check in there are new txs in the pool
 	LoadPoolTxsCheckInterval types.Duration `mapstructure:"LoadPoolTxsCheckInterval"`
 
+	// LoadPoolTxsMaxPerIteration is the maximum number of txs loaded from the pool in each check. If it's 0 there is no limit
+	LoadPoolTxsMaxPerIteration uint64 `mapstructure:"LoadPoolTxsMaxPerIteration"`
+
+	// LoadPoolTxsRoundRobin loads the pool txs round-robin across senders (the lowest nonce tx of each sender in turn)
+	// instead of in pool order. This avoids starving senders when LoadPoolTxsMaxPerIteration is reached
+	LoadPoolTxsRoundRobin bool `mapstructure:"LoadPoolTxsRoundRobin"`
+
+	// LoadPoolTxsOnlyWhenSynced makes the sequencer check that the state is synced with L1 before each load of txs from the pool.
+	// While it's not synced the load is deferred, so no txs are added to the worker with a stale nonce or balance of their sender
+	LoadPoolTxsOnlyWhenSynced bool `mapstructure:"LoadPoolTxsOnlyWhenSynced"`
+
+	// LoadPoolTxsRampStart is the max number of txs loaded from the pool in the first check after the sequencer starts.
+	// Each time this limit is reached it's multiplied by LoadPoolTxsRampMultiplier until it reaches LoadPoolTxsMaxPerIteration.
+	// This avoids adding a huge backlog of pool txs at once after a long downtime. If it's 0 the ramp is disabled
+	LoadPoolTxsRampStart uint64 `mapstructure:"LoadPoolTxsRampStart"`
+
+	// LoadPoolTxsRampMultiplier is the factor applied to the max number of txs loaded from the pool while ramping up. It must be greater than 1
+	LoadPoolTxsRampMultiplier uint64 `mapstructure:"LoadPoolTxsRampMultiplier"`
+
+	// LoadPoolTxsDedupTTL is the time a tx loaded from the pool is skipped if the pool returns it again (e.g. due to
+	// a delay updating its WIP status). It must be short to not block the legit resubmissions. If it's 0 the txs are not deduplicated
+	LoadPoolTxsDedupTTL types.Duration `mapstructure:"LoadPoolTxsDedupTTL"`
+
+	// LoadPoolTxsCursorFile is the path of the file where the txs loaded from the pool in the last LoadPoolTxsDedupTTL that were
+	// left pending are persisted (load cursor). After a restart the cursor is validated against the pool and the txs still
+	// pending are not processed again until LoadPoolTxsDedupTTL. If it's empty the cursor is not persisted
+	LoadPoolTxsCursorFile string `mapstructure:"LoadPoolTxsCursorFile"`
+
+	// SyncCheckL1MaxRetries is the number of times the sequencer retries getting the last batch number from L1 when checking
+	// if the state is synced. If all the retries fail the last known value is used (if any)
+	SyncCheckL1MaxRetries uint64 `mapstructure:"SyncCheckL1MaxRetries"`
+
+	// SyncCheckL1RetryBackoff is the time the sequencer waits before the first retry getting the last batch number from L1.
+	// The time is doubled in each retry
+	SyncCheckL1RetryBackoff types.Duration `mapstructure:"SyncCheckL1RetryBackoff"`
+
+	// MaxBatchesAheadOfL1 is the max number of trusted batches ahead of the last virtual batch. Beyond it the finalizer is halted
+	// until the gap drops back to MaxBatchesAheadOfL1. If it's 0 there is no limit
+	MaxBatchesAheadOfL1 uint64 `mapstructure:"MaxBatchesAheadOfL1"`
+
+	// BatchesAheadOfL1CheckInterval is the time the sequencer waits to check the number of trusted batches ahead of the last virtual batch
+	BatchesAheadOfL1CheckInterval types.Duration `mapstructure:"BatchesAheadOfL1CheckInterval"`
+
 	// StateConsistencyCheckInterval is the time the sequencer waits to check if a state inconsistency has happened
 	StateConsistencyCheckInterval types.Duration `mapstructure:"StateConsistencyCheckInterval"`
 
+	// MetricsLogInterval is the time between the logs of a snapshot of the key metrics of the sequencer (txs processed, L2 blocks
+	// streamed, data stream channel length and state inconsistencies), for environments without a metrics scraper. The metrics
+	// are read from the metrics registry, so Metrics.Enabled must be set. If it's 0 the metrics are not logged
+	MetricsLogInterval types.Duration `mapstructure:"MetricsLogInterval"`
+
+	// MetricsForkIDLabel enables the metrics of the txs processed, the txs added to the worker, the L2 blocks streamed and the
+	// L2 blocks per batch labeled by the fork ID active when they are observed, to compare the behavior across fork versions
+	MetricsForkIDLabel bool `mapstructure:"MetricsForkIDLabel"`
+
+	// MaxWorkerTxs is the maximum number of txs the worker can hold. If it's 0 there is no limit
+	MaxWorkerTxs uint64 `mapstructure:"MaxWorkerTxs"`
+
+	// MaxWorkerBytes is the maximum number of bytes (size of the raw txs) the worker can hold. The txs that would exceed it
+	// are dropped (set as failed in the pool). If it's 0 there is no limit
+	MaxWorkerBytes uint64 `mapstructure:"MaxWorkerBytes"`
+
+	// MaxAcceptedGasLimit is the maximum gas limit of the txs loaded from the pool. The txs with a higher gas limit
+	// are dropped (set as failed in the pool) before being added to the worker. If it's 0 there is no limit
+	MaxAcceptedGasLimit uint64 `mapstructure:"MaxAcceptedGasLimit"`
+
+	// SenderRateLimit is the max number of txs per second of a single sender added to the worker, refilling a token bucket of
+	// SenderRateLimitBurst txs per sender. The txs over the limit are left pending in the pool and loaded again later.
+	// If it's 0 there is no limit
+	SenderRateLimit float64 `mapstructure:"SenderRateLimit"`
+
+	// SenderRateLimitBurst is the max number of txs of a single sender added to the worker at once when SenderRateLimit is set.
+	// If it's 0 it's 1
+	SenderRateLimitBurst uint64 `mapstructure:"SenderRateLimitBurst"`
+
+	// SenderRateLimitNewSenderGrace is the number of txs of a sender not seen before by the sequencer that are added to the worker
+	// regardless of SenderRateLimit, so the first-time users are not throttled. The senders are remembered until the sequencer restarts
+	SenderRateLimitNewSenderGrace uint64 `mapstructure:"SenderRateLimitNewSenderGrace"`
+
+	// AdmissionBatchFullnessThreshold is the fullness of the wip batch of the finalizer (the utilization percentage, 0-100, of its
+	// most used resource) above which the txs loaded from the pool that are not high priority are left pending in the pool and
+	// loaded again later, so they don't churn in the worker at the end of the batch. If it's 0 the txs are always admitted
+	AdmissionBatchFullnessThreshold float64 `mapstructure:"AdmissionBatchFullnessThreshold"`
+
+	// AdmissionHighPriorityGasPrice is the min gas price (wei) of the high priority txs, which are admitted in the worker
+	// regardless of AdmissionBatchFullnessThreshold. If it's 0 all the txs are deferred above the threshold
+	AdmissionHighPriorityGasPrice uint64 `mapstructure:"AdmissionHighPriorityGasPrice"`
+
+	// RejectTxsExceedingBatchConstraints enables checking the ZK counters of the txs loaded from the pool against the batch
+	// constraints before creating their tx tracker. The txs that can't fit in any batch are dropped (set as failed in the pool)
+	// with the counters exceeded as reason. If it's false they are dropped by the worker with a generic out of counters reason
+	RejectTxsExceedingBatchConstraints bool `mapstructure:"RejectTxsExceedingBatchConstraints"`
+
+	// WorkerFullPolicy is the policy applied when the worker reaches MaxWorkerTxs:
+	// - reject: the incoming tx is dropped (set as failed in the pool)
+	// - block: the sequencer stops loading txs from the pool until there is free space in the worker
+	WorkerFullPolicy string `mapstructure:"WorkerFullPolicy" jsonschema:"enum=reject,enum=block"`
+
+	// TxTrackerErrorPolicy is the policy applied when a pool tx can't be added to the worker because its tx tracker can't be
+	// created (e.g. the sender can't be recovered):
+	// - fail: the tx is set as failed in the pool so it's not loaded again
+	// - retry: the tx is kept as pending in the pool and it's loaded again in the next check
+	TxTrackerErrorPolicy string `mapstructure:"TxTrackerErrorPolicy" jsonschema:"enum=fail,enum=retry"`
+
+	// ReconcilePendingTxsAtStartup enables a pass at startup, before loading txs from the pool, that sets as failed the pending txs
+	// whose sender can't be recovered or whose nonce is lower than the current nonce of the sender in the state
+	ReconcilePendingTxsAtStartup bool `mapstructure:"ReconcilePendingTxsAtStartup"`
+
+	// WIPStatusUpdateMaxRetries is the number of times the update of the WIP status in the pool of a tx added to the worker is retried
+	// if it fails. If all the retries fail the tx is deleted from the worker, so it's loaded again from the pool in the next check
+	WIPStatusUpdateMaxRetries uint64 `mapstructure:"WIPStatusUpdateMaxRetries"`
+
+	// WIPStatusUpdateRetryInterval is the time waited before retrying the update of the WIP status of a tx
+	WIPStatusUpdateRetryInterval types.Duration `mapstructure:"WIPStatusUpdateRetryInterval"`
+
+	// DropRecordsSize is the number of most recent drop/replace/expire decisions kept by the sequencer to be queried by tx hash
+	DropRecordsSize uint64 `mapstructure:"DropRecordsSize"`
+
+	// LogDropsToEventLog enables logging an event for each tx dropped, replaced or expired by the sequencer
+	LogDropsToEventLog bool `mapstructure:"LogDropsToEventLog"`
+
+	// DropEventsMaxPerSecond is the max number of drop events logged per second when LogDropsToEventLog is enabled.
+	// The drops exceeding it are not logged. If it's 0 there is no limit
+	DropEventsMaxPerSecond uint64 `mapstructure:"DropEventsMaxPerSecond"`
+
+	// EventLogMinLevel is the min level of the events logged by the sequencer to the event log (debug, info, notice, warning,
+	// err, crit, alert or emerg). The less severe events are discarded. If it's empty all the events are logged
+	EventLogMinLevel string `mapstructure:"EventLogMinLevel" jsonschema:"enum=debug,enum=info,enum=notice,enum=warning,enum=err,enum=crit,enum=alert,enum=emerg"`
+
+	// ReplacementRecordsSize is the number of most recent tx replacements in the worker kept by the sequencer for debugging
+	ReplacementRecordsSize uint64 `mapstructure:"ReplacementRecordsSize"`
+
+	// FinalizerWarmupDelay is the max time the sequencer waits after starting before starting the finalizer, to let the
+	// worker accumulate txs and avoid producing many small batches. If it's 0 the finalizer starts immediately
+	FinalizerWarmupDelay types.Duration `mapstructure:"FinalizerWarmupDelay"`
+
+	// FinalizerWarmupMinTxs is the number of txs in the worker that ends the finalizer warmup before FinalizerWarmupDelay elapses.
+	// If it's 0 the finalizer always waits FinalizerWarmupDelay
+	FinalizerWarmupMinTxs uint64 `mapstructure:"FinalizerWarmupMinTxs"`
+
+	// PauseDrainTimeout is the max time Pause waits for the worker to be empty after stopping loading txs from the pool.
+	// If it's 0 Pause doesn't wait
+	PauseDrainTimeout types.Duration `mapstructure:"PauseDrainTimeout"`
+
+	// MinHaltInterval is the min time between successive halts of the finalizer. A halt requested before it elapses since the
+	// last halt is deferred until it elapses, coalescing the halt requests received meanwhile. If it's 0 the halts are not deferred
+	MinHaltInterval types.Duration `mapstructure:"MinHaltInterval"`
+
+	// LoopMaxRestarts is the max number of times a background loop of the sequencer is restarted if it panics. Once reached
+	// the loop is not restarted anymore
+	LoopMaxRestarts uint64 `mapstructure:"LoopMaxRestarts"`
+
+	// LoopRestartBackoff is the time waited before the first restart of a background loop that panicked. It's doubled on each restart
+	LoopRestartBackoff types.Duration `mapstructure:"LoopRestartBackoff"`
+
 	// Finalizer's specific config properties
 	Finalizer FinalizerCfg `mapstructure:"Finalizer"`
 
 	// StreamServerCfg is the config for the stream server
 	StreamServer StreamServerCfg `mapstructure:"StreamServer"`
+
+	// DebugStreamServer is the config for the debug stream server
+	DebugStreamServer DebugStreamServerCfg `mapstructure:"DebugStreamServer"`
 }
 
 // StreamServerCfg contains the data streamer's configuration properties
@@ -40,10 +236,192 @@ type StreamServerCfg struct {
 	Filename string `mapstructure:"Filename"`
 	// Enabled is a flag to enable/disable the data streamer
 	Enabled bool `mapstructure:"Enabled"`
+	// VerifyBlockHash enables the cross-check of the block hash and state root of each L2 block against the state before streaming it.
+	// If they don't match the block is not streamed and an event is logged
+	VerifyBlockHash bool `mapstructure:"VerifyBlockHash"`
+	// VerifyBatchNumber enables the cross-check of the batch number of each L2 block against the batch of the L2 block stored in
+	// the state before streaming it. If they don't match the block is not streamed and an event is logged
+	VerifyBatchNumber bool `mapstructure:"VerifyBatchNumber"`
+	// BlocksPerAtomicOp is the maximum number of L2 blocks written to the data stream in a single atomic op.
+	// The L2 blocks already available are drained from the channel up to this number. 0 or 1 means one L2 block per atomic op
+	BlocksPerAtomicOp uint64 `mapstructure:"BlocksPerAtomicOp"`
+	// SkipIntermediateStateRoots disables reading from the system SC the intermediate state root of the txs streamed without the
+	// state root set by the executor. If it's true those tx entries are streamed with an empty state root
+	SkipIntermediateStateRoots bool `mapstructure:"SkipIntermediateStateRoots"`
+	// StorageCacheSize is the number of intermediate state roots read from the system SC kept in a LRU cache, so they are not
+	// read again from the state when the L2 block is streamed again (e.g. on a retry). If it's 0 the values are not cached
+	StorageCacheSize uint64 `mapstructure:"StorageCacheSize"`
+	// EmitReceiptsReadyEvents enables logging an event with the L2 block number and the tx hashes each time a L2 block is committed to the data stream
+	EmitReceiptsReadyEvents bool `mapstructure:"EmitReceiptsReadyEvents"`
+	// PauseBufferSize is the max number of L2 blocks buffered while the streaming is paused
+	PauseBufferSize uint64 `mapstructure:"PauseBufferSize"`
+	// PauseBufferFullPolicy is the policy applied when the pause buffer is full:
+	// - block: the L2 blocks are not read until the streaming is resumed (the finalizer blocks when the data stream channel is full)
+	// - drop: the L2 blocks are dropped (not streamed) and an event is logged
+	PauseBufferFullPolicy string `mapstructure:"PauseBufferFullPolicy" jsonschema:"enum=block,enum=drop"`
+	// TimestampSkewTolerance is the max time the timestamp of a L2 block to stream can be before the timestamp of the previous
+	// L2 block streamed (e.g. because the clock of the host jumps backward). Beyond it an event is logged and TimestampSkewPolicy is applied
+	TimestampSkewTolerance types.Duration `mapstructure:"TimestampSkewTolerance"`
+	// TimestampSkewPolicy is the policy applied to a L2 block whose timestamp is before the previous one beyond TimestampSkewTolerance:
+	// - clamp: the L2 block is streamed with the timestamp of the previous L2 block + 1
+	// - skip: the L2 block is not streamed
+	TimestampSkewPolicy string `mapstructure:"TimestampSkewPolicy" jsonschema:"enum=clamp,enum=skip"`
+	// RequiredAtStartup makes the sequencer exit if the stream server can't be created/started. If it's false an event is logged
+	// and the sequencer keeps sequencing with the streaming disabled
+	RequiredAtStartup bool `mapstructure:"RequiredAtStartup"`
+	// IncludeDecodedTxMetadata makes the L2 txs streamed by the sequencer to include the decoded from, to, nonce, value, type and
+	// chain id of the tx along with the encoded tx, using the entry type EntryTypeL2TxWithMetadata instead of EntryTypeL2Tx
+	IncludeDecodedTxMetadata bool `mapstructure:"IncludeDecodedTxMetadata"`
+	// IncludeSender makes the L2 txs streamed by the sequencer to include the address of the sender of the tx, using the entry type
+	// EntryTypeL2TxWithSender instead of EntryTypeL2Tx. It's ignored if IncludeDecodedTxMetadata is enabled, as it already includes it
+	IncludeSender bool `mapstructure:"IncludeSender"`
+	// ExportSchema makes the sequencer to write a EntryTypeStreamSchema header, declaring the schema version and the entry types
+	// emitted, as the first entry of a new data stream file. The header of an existing file is always verified at startup
+	ExportSchema bool `mapstructure:"ExportSchema"`
+	// EmitBatchBoundaries makes the sequencer to stream a EntryTypeBatchStart entry before the first L2 block of each batch and a
+	// EntryTypeBatchEnd entry, with the final state root of the batch, when the first L2 block of the next batch is streamed
+	EmitBatchBoundaries bool `mapstructure:"EmitBatchBoundaries"`
+	// Encoding is the encoding of the payload of the L2 block start, L2 tx and L2 block end entries streamed by the sequencer:
+	// - binary: the fixed size binary encoding
+	// - protobuf: the protobuf messages of proto/src/proto/datastream/v1/datastream.proto, self-describing for non-Go consumers.
+	//   They use their own entry types (EntryTypeL2BlockStartProto, EntryTypeL2TxProto and EntryTypeL2BlockEndProto)
+	Encoding string `mapstructure:"Encoding" jsonschema:"enum=binary,enum=protobuf"`
+	// BlockStartExcludedFields are the fields excluded from the L2 block start entries streamed by the sequencer (globalExitRoot,
+	// coinbase, forkID). If it's not empty the L2 block starts are streamed with the entry type EntryTypeL2BlockStartMasked, whose
+	// binary encoding records the fields included, instead of EntryTypeL2BlockStart
+	BlockStartExcludedFields []string `mapstructure:"BlockStartExcludedFields"`
+	// IncludeL1InfoRoot makes the L2 block starts streamed by the sequencer to include the L1 info root used to process the L2 block,
+	// using the entry type EntryTypeL2BlockStartWithL1InfoRoot instead of EntryTypeL2BlockStart. It's ignored if BlockStartExcludedFields
+	// is not empty. The L2 blocks written from the state when the data stream file is updated at startup, whose L1 info root is not
+	// known, are written with the L2 block start entry type of the Encoding instead
+	IncludeL1InfoRoot bool `mapstructure:"IncludeL1InfoRoot"`
+	// IncludeStorageDiffs makes the sequencer to stream a EntryTypeL2BlockStorageDiff entry before the end of each L2 block, with the
+	// storage slots changed by its txs and their final values. The txs are processed with the storage trace of the executor to get
+	// them, which is expensive. The L2 blocks written from the state when the data stream file is updated at startup, and the forced
+	// batches, are streamed with a diff marked as unavailable, as their storage changes are not known
+	IncludeStorageDiffs bool `mapstructure:"IncludeStorageDiffs"`
+	// IncludeBlockBlooms makes the sequencer to stream a EntryTypeL2BlockBloom entry before the end of each L2 block, with the bloom
+	// filter of the addresses and topics of the logs emitted by its txs, so the log-indexing consumers can quickly test their
+	// presence. The L2 blocks written from the state when the data stream file is updated get their logs from the state
+	IncludeBlockBlooms bool `mapstructure:"IncludeBlockBlooms"`
+	// CheckpointEveryNBlocks makes the sequencer to stream a EntryTypeL2BlockCheckpoint entry before the end of every N L2 blocks,
+	// with the hash accumulating the block hashes of the L2 blocks since the previous checkpoint, so the consumers can verify
+	// contiguous ranges of L2 blocks cheaply. The accumulation starts over when the sequencer restarts and each time the data stream
+	// file is updated with the L2 blocks of the state. If it's 0 no checkpoints are streamed
+	CheckpointEveryNBlocks uint64 `mapstructure:"CheckpointEveryNBlocks"`
+	// ChannelBufferSize is the size of the channel buffer of the L2 blocks sent by the finalizer to the data stream.
+	// If it's 0 the size is MaxTxsPerBatch*2
+	ChannelBufferSize uint64 `mapstructure:"ChannelBufferSize"`
+	// ReconnectQuietPeriod is the time waited after all the retries sending L2 blocks to the data stream server fail before probing
+	// it with an empty atomic op. The probe is repeated after each quiet period until it succeeds, then the streaming is resumed
+	// with the L2 blocks that failed. If it's 0 the next L2 blocks are not streamed after all the retries fail
+	ReconnectQuietPeriod types.Duration `mapstructure:"ReconnectQuietPeriod"`
+	// RegenerateInBackground makes the sequencer to update the data stream file with the batches of the state at startup in the
+	// background, while the finalizer already produces L2 blocks. Their streaming is held until the update completes, buffering
+	// them as if the streaming was paused (PauseBufferSize, PauseBufferFullPolicy), then they are streamed in order skipping the
+	// ones already written by the update. If it's false the update completes before the finalizer starts. It's ignored in standby mode
+	RegenerateInBackground bool `mapstructure:"RegenerateInBackground"`
+	// FileUpdateMaxRetries is the number of times the update of the data stream file with the batches of the state is retried
+	// if it fails. Each retry resumes from the last entry committed
+	FileUpdateMaxRetries uint64 `mapstructure:"FileUpdateMaxRetries"`
+	// FileUpdateRetryInterval is the time waited before retrying the update of the data stream file
+	FileUpdateRetryInterval types.Duration `mapstructure:"FileUpdateRetryInterval"`
+	// PipelinedEncoding enables preparing the next L2 blocks (verification and intermediate state roots) in the background while
+	// the previous ones are committed to the data stream. The L2 blocks are always committed in the order they are received
+	PipelinedEncoding bool `mapstructure:"PipelinedEncoding"`
+	// PipelineBufferSize is the max number of groups of L2 blocks prepared ahead of the atomic op commit when PipelinedEncoding is enabled.
+	// If it's 0 a single group is prepared ahead
+	PipelineBufferSize uint64 `mapstructure:"PipelineBufferSize"`
+	// MaxRestarts is the max number of times the goroutine sending the L2 blocks to the data stream server is restarted if it
+	// exits unexpectedly. Once reached the L2 blocks are not streamed anymore
+	MaxRestarts uint64 `mapstructure:"MaxRestarts"`
+	// RestartBackoff is the time waited before the first restart of the goroutine sending the L2 blocks to the data stream server.
+	// It's doubled on each restart
+	RestartBackoff types.Duration `mapstructure:"RestartBackoff"`
+	// FinalityCheckInterval is the time between checks of the last L2 blocks virtualized and consolidated in L1, streaming the
+	// updates of the L2 blocks that became safe or finalized. If it's 0 the finality updates are not streamed
+	FinalityCheckInterval types.Duration `mapstructure:"FinalityCheckInterval"`
+	// StartupDelay is the time waited after the data stream server is started before writing to it for the first time
+	StartupDelay types.Duration `mapstructure:"StartupDelay"`
+	// ReadyTimeout is the max time waited, after StartupDelay, for the data stream server to accept connections on Port before
+	// writing to it for the first time. If it's not ready in time the server fails to start. If it's 0 the readiness is not checked
+	ReadyTimeout types.Duration `mapstructure:"ReadyTimeout"`
+	// StartMaxRetries is the number of times the creation and start of the data stream server is retried at startup if it fails
+	// (e.g. the port is still held by the previous process), before failing or disabling the streaming (RequiredAtStartup)
+	StartMaxRetries uint64 `mapstructure:"StartMaxRetries"`
+	// StartRetryInterval is the time waited before the first retry of the start of the data stream server, doubled on each retry
+	StartRetryInterval types.Duration `mapstructure:"StartRetryInterval"`
+	// RecentBlockHashesSize is the number of most recent L2 blocks streamed whose hashes are kept in memory, to be queried with
+	// RecentBlockHashes (e.g. to compare them against another source). If it's 0 no hashes are kept
+	RecentBlockHashesSize uint64 `mapstructure:"RecentBlockHashesSize"`
+	// AtomicOpTimeout is the time after which a commit of an atomic op to the data stream server that hasn't finished (e.g. if the
+	// disk stalls) is reported with an event. The commit is still waited for, it's only rolled back and retried if it fails. If it's 0 no event is logged
+	AtomicOpTimeout types.Duration `mapstructure:"AtomicOpTimeout"`
+	// Archive is the config of the archive of the data stream in an S3-compatible object store
+	Archive ArchiveCfg `mapstructure:"Archive"`
+	// PriorityStream is the config of the secondary data stream with only the txs above a value or gas threshold
+	PriorityStream PriorityStreamCfg `mapstructure:"PriorityStream"`
 	// Log is the log configuration
 	Log log.Config `mapstructure:"Log"`
 }
 
+// PriorityStreamCfg contains the configuration properties of the priority stream, a secondary data stream with only the txs
+// committed to the data stream whose value or gas is above the thresholds, each one within the start and end entries of its
+// L2 block. The data stream is not affected, it keeps all the txs
+type PriorityStreamCfg struct {
+	// Enabled is a flag to enable/disable the priority stream
+	Enabled bool `mapstructure:"Enabled"`
+	// Port to listen on
+	Port uint16 `mapstructure:"Port"`
+	// Filename of the binary data file
+	Filename string `mapstructure:"Filename"`
+	// MinValue is the min value in wei of the txs sent to the priority stream. If it's 0 the value is not checked
+	MinValue *big.Int `mapstructure:"MinValue"`
+	// MinGas is the min gas limit of the txs sent to the priority stream. If it's 0 the gas is not checked
+	MinGas uint64 `mapstructure:"MinGas"`
+	// QueueSize is the max number of atomic ops committed to the data stream queued to be sent to the priority stream. If the
+	// queue is full their L2 blocks are not sent, so the data stream server is never blocked by the priority stream
+	QueueSize uint64 `mapstructure:"QueueSize"`
+}
+
+// DebugStreamServerCfg contains the debug stream server configuration properties
+type DebugStreamServerCfg struct {
+	// Enabled is a flag to enable/disable the debug stream, with the dropped txs, intermediate state roots and decisions of the sequencer.
+	// It's a separate stream so the format of the data stream is not affected
+	Enabled bool `mapstructure:"Enabled"`
+	// Port to listen on
+	Port uint16 `mapstructure:"Port"`
+	// Filename of the binary data file
+	Filename string `mapstructure:"Filename"`
+	// Log is the log configuration. The logger of the data streamer is shared by all the stream servers, so it's only used
+	// if the data stream server is disabled
+	Log log.Config `mapstructure:"Log"`
+}
+
+// ArchiveCfg contains the configuration properties of the archive of the data stream in an S3-compatible object store
+type ArchiveCfg struct {
+	// Enabled is a flag to enable/disable the archive of the entries committed to the data stream
+	Enabled bool `mapstructure:"Enabled"`
+	// Endpoint is the URL of the S3-compatible object store, the objects are accessed with path-style URLs (Endpoint/Bucket/key)
+	Endpoint string `mapstructure:"Endpoint"`
+	// Region is the region of the object store used to sign the requests
+	Region string `mapstructure:"Region"`
+	// Bucket is the bucket where the archive is uploaded
+	Bucket string `mapstructure:"Bucket"`
+	// Prefix is the prefix of the keys of the segments and the manifest of the archive
+	Prefix string `mapstructure:"Prefix"`
+	// AccessKeyID and SecretAccessKey are the credentials of the object store. If AccessKeyID is empty the requests are not signed
+	AccessKeyID     string `mapstructure:"AccessKeyID"`
+	SecretAccessKey string `mapstructure:"SecretAccessKey"`
+	// SegmentEntries is the number of entries uploaded in each segment object
+	SegmentEntries uint64 `mapstructure:"SegmentEntries"`
+	// FlushInterval is the max time the entries are kept before uploading a segment that is not full. If it's 0 only full segments are uploaded
+	FlushInterval types.Duration `mapstructure:"FlushInterval"`
+	// QueueSize is the max number of committed atomic ops queued to be archived. If the queue is full the archive is disabled,
+	// so the data stream server is never blocked by the uploads
+	QueueSize uint64 `mapstructure:"QueueSize"`
+}
+
 // FinalizerCfg contains the finalizer's configuration properties
 type FinalizerCfg struct {
 	// ForcedBatchesTimeout is the time the finalizer waits after receiving closing signal to process Forced Batches
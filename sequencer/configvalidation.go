@@ -0,0 +1,161 @@
+package sequencer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0xPolygonHermez/zkevm-node/event"
+)
+
+// ConfigWarningSeverity is the severity of a config warning
+type ConfigWarningSeverity string
+
+const (
+	// ConfigWarningSeverityWarning is a combination of config values that works but probably not as intended
+	ConfigWarningSeverityWarning ConfigWarningSeverity = "warning"
+	// ConfigWarningSeverityError is an invalid combination of config values, the sequencer can't be created with it
+	ConfigWarningSeverityError ConfigWarningSeverity = "error"
+)
+
+// ConfigWarning is an inconsistency between the values of the config found by Config.Validate
+type ConfigWarning struct {
+	// Field is the path of the config field the warning refers to (e.g. StreamServer.IncludeSender)
+	Field    string
+	Severity ConfigWarningSeverity
+	// Message describes the inconsistency and how to fix it
+	Message string
+}
+
+// String returns a representation of the warning to be logged
+func (w ConfigWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Field, w.Message)
+}
+
+// Validate checks the consistency of the config values between them, returning the warnings for the combinations that
+// probably don't behave as intended and the errors for the invalid ones. The config is valid if there are no errors
+func (c Config) Validate() []ConfigWarning {
+	warnings := []ConfigWarning{}
+	warn := func(field string, format string, args ...interface{}) {
+		warnings = append(warnings, ConfigWarning{Field: field, Severity: ConfigWarningSeverityWarning, Message: fmt.Sprintf(format, args...)})
+	}
+	fail := func(field string, format string, args ...interface{}) {
+		warnings = append(warnings, ConfigWarning{Field: field, Severity: ConfigWarningSeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+	checkEnum := func(field string, value string, values ...string) {
+		if value == "" {
+			return
+		}
+		for _, v := range values {
+			if value == v {
+				return
+			}
+		}
+		fail(field, "invalid value %q, it must be one of: %s", value, strings.Join(values, ", "))
+	}
+
+	checkEnum("Mode", c.Mode, ModeActive, ModeStandby)
+	checkEnum("WorkerFullPolicy", c.WorkerFullPolicy, WorkerFullPolicyReject, WorkerFullPolicyBlock)
+	checkEnum("TxTrackerErrorPolicy", c.TxTrackerErrorPolicy, TxTrackerErrorPolicyFail, TxTrackerErrorPolicyRetry)
+	checkEnum("EventLogMinLevel", c.EventLogMinLevel, string(event.Level_Debug), string(event.Level_Info), string(event.Level_Notice), string(event.Level_Warning),
+		string(event.Level_Error), string(event.Level_Critical), string(event.Level_Alert), string(event.Level_Emergency))
+	checkEnum("StreamServer.PauseBufferFullPolicy", c.StreamServer.PauseBufferFullPolicy, PauseBufferFullPolicyBlock, PauseBufferFullPolicyDrop)
+	checkEnum("StreamServer.TimestampSkewPolicy", c.StreamServer.TimestampSkewPolicy, TimestampSkewPolicyClamp, TimestampSkewPolicySkip)
+	checkEnum("StreamServer.Encoding", c.StreamServer.Encoding, StreamEncodingBinary, StreamEncodingProtobuf)
+
+	if c.Mode == ModeStandby && !c.StreamServer.Enabled {
+		fail("Mode", "the standby mode only keeps the data stream updated, StreamServer.Enabled must be set")
+	}
+
+	if c.LoadPoolTxsRampStart > 0 && c.LoadPoolTxsRampMultiplier <= 1 {
+		fail("LoadPoolTxsRampMultiplier", "it must be greater than 1 when LoadPoolTxsRampStart is set, otherwise the ramp never reaches LoadPoolTxsMaxPerIteration")
+	}
+	if c.LoadPoolTxsRampStart > 0 && c.LoadPoolTxsMaxPerIteration > 0 && c.LoadPoolTxsRampStart >= c.LoadPoolTxsMaxPerIteration {
+		warn("LoadPoolTxsRampStart", "%d is not lower than LoadPoolTxsMaxPerIteration (%d), the ramp has no effect", c.LoadPoolTxsRampStart, c.LoadPoolTxsMaxPerIteration)
+	}
+
+	if c.LoadPoolTxsCursorFile != "" && c.LoadPoolTxsDedupTTL.Duration == 0 {
+		warn("LoadPoolTxsCursorFile", "it's ignored as LoadPoolTxsDedupTTL is 0, the txs loaded from the pool are not kept")
+	}
+
+	if c.TxLifetimeMax.Duration > 0 && c.TxLifetimeCheckInterval.Duration > c.TxLifetimeMax.Duration {
+		warn("TxLifetimeCheckInterval", "%s is greater than TxLifetimeMax (%s), the txs can be kept in the worker up to %s before they are expired, set it lower than TxLifetimeMax",
+			c.TxLifetimeCheckInterval.Duration, c.TxLifetimeMax.Duration, c.TxLifetimeMax.Duration+c.TxLifetimeCheckInterval.Duration)
+	}
+	if c.TxAgeWarnThreshold.Duration > 0 && c.TxLifetimeMax.Duration > 0 && c.TxAgeWarnThreshold.Duration >= c.TxLifetimeMax.Duration {
+		warn("TxAgeWarnThreshold", "%s is not lower than TxLifetimeMax (%s), the txs are expired before they are counted as old txs", c.TxAgeWarnThreshold.Duration, c.TxLifetimeMax.Duration)
+	}
+
+	if c.DeletePoolTxsL1BlockConfirmations < c.MinDeleteConfirmations {
+		warn("DeletePoolTxsL1BlockConfirmations", "%d is lower than MinDeleteConfirmations, it's clamped up to %d", c.DeletePoolTxsL1BlockConfirmations, c.MinDeleteConfirmations)
+	} else if c.DeletePoolTxsCheckInterval.Duration > 0 && c.DeletePoolTxsL1BlockConfirmations == 0 {
+		warn("DeletePoolTxsL1BlockConfirmations", "it's 0, every %s the txs are deleted from the pool as soon as their L1 block is synced and the failed txs regardless of their age, so their status can't be queried",
+			c.DeletePoolTxsCheckInterval.Duration)
+	}
+
+	if c.SenderRateLimitNewSenderGrace > 0 && c.SenderRateLimit == 0 {
+		warn("SenderRateLimitNewSenderGrace", "it's ignored as SenderRateLimit is 0 (no limit)")
+	}
+
+	if c.AdmissionBatchFullnessThreshold >= 100 { //nolint:gomnd
+		warn("AdmissionBatchFullnessThreshold", "%v is not lower than 100, the txs are never deferred", c.AdmissionBatchFullnessThreshold)
+	}
+
+	if c.WorkerFullPolicy == WorkerFullPolicyBlock && c.MaxWorkerTxs == 0 {
+		warn("WorkerFullPolicy", "the block policy has no effect as MaxWorkerTxs is 0 (no limit)")
+	}
+	if c.WIPStatusUpdateMaxRetries > 0 && c.WIPStatusUpdateRetryInterval.Duration == 0 {
+		warn("WIPStatusUpdateRetryInterval", "it's 0, the %d retries of the WIP status update are done immediately and will probably fail for the same reason", c.WIPStatusUpdateMaxRetries)
+	}
+
+	c.StreamServer.validate(warn, fail)
+
+	return warnings
+}
+
+// validate checks the consistency of the data stream config values, reporting the warnings with warn and the errors with fail
+func (c StreamServerCfg) validate(warn, fail func(field string, format string, args ...interface{})) {
+	if !c.Enabled {
+		for _, option := range []struct {
+			field string
+			set   bool
+		}{
+			{"StreamServer.FinalityCheckInterval", c.FinalityCheckInterval.Duration > 0},
+			{"StreamServer.ExportSchema", c.ExportSchema},
+			{"StreamServer.ReadyTimeout", c.ReadyTimeout.Duration > 0},
+			{"StreamServer.RegenerateInBackground", c.RegenerateInBackground},
+			{"StreamServer.IncludeStorageDiffs", c.IncludeStorageDiffs},
+			{"StreamServer.IncludeBlockBlooms", c.IncludeBlockBlooms},
+			{"StreamServer.CheckpointEveryNBlocks", c.CheckpointEveryNBlocks > 0},
+			{"StreamServer.Archive.Enabled", c.Archive.Enabled},
+			{"StreamServer.PriorityStream.Enabled", c.PriorityStream.Enabled},
+		} {
+			if option.set {
+				warn(option.field, "it's ignored as StreamServer.Enabled is not set")
+			}
+		}
+		return
+	}
+
+	if c.IncludeSender && c.IncludeDecodedTxMetadata {
+		warn("StreamServer.IncludeSender", "it's ignored as the sender is already included in the decoded tx metadata (IncludeDecodedTxMetadata)")
+	}
+	if c.IncludeL1InfoRoot && len(c.BlockStartExcludedFields) > 0 {
+		warn("StreamServer.IncludeL1InfoRoot", "it's ignored as the L2 block starts are masked (BlockStartExcludedFields)")
+	}
+	if c.Archive.Enabled {
+		if c.Archive.Endpoint == "" || c.Archive.Bucket == "" {
+			fail("StreamServer.Archive", "Endpoint and Bucket must be set when the archive is enabled")
+		}
+		if c.Archive.SegmentEntries == 0 {
+			warn("StreamServer.Archive.SegmentEntries", "it's 0, each entry of the data stream is uploaded in its own segment")
+		}
+	}
+	if c.PriorityStream.Enabled {
+		if c.PriorityStream.Port == c.Port || c.PriorityStream.Filename == c.Filename {
+			fail("StreamServer.PriorityStream", "Port and Filename must be different from the ones of the data stream")
+		}
+		if priorityStreamMinValue(c.PriorityStream).Sign() <= 0 && c.PriorityStream.MinGas == 0 {
+			warn("StreamServer.PriorityStream", "MinValue and MinGas are 0, no txs are sent to the priority stream")
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package sequencer
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configWarningFields returns the fields of the warnings with the given severity
+func configWarningFields(warnings []ConfigWarning, severity ConfigWarningSeverity) []string {
+	fields := []string{}
+	for _, warning := range warnings {
+		if warning.Severity == severity {
+			fields = append(fields, warning.Field)
+		}
+	}
+	return fields
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      Config
+		warnings []string
+		errors   []string
+	}{
+		{
+			name: "empty config",
+			cfg:  Config{},
+		},
+		{
+			name: "consistent config",
+			cfg: Config{
+				Mode:                              ModeStandby,
+				TxLifetimeMax:                     cfgTypes.NewDuration(3 * time.Hour),
+				TxLifetimeCheckInterval:           cfgTypes.NewDuration(10 * time.Minute),
+				TxAgeWarnThreshold:                cfgTypes.NewDuration(time.Hour),
+				DeletePoolTxsL1BlockConfirmations: 100,
+				DeletePoolTxsCheckInterval:        cfgTypes.NewDuration(12 * time.Hour),
+				LoadPoolTxsRampStart:              100,
+				LoadPoolTxsRampMultiplier:         2,
+				LoadPoolTxsMaxPerIteration:        1000,
+				StreamServer:                      StreamServerCfg{Enabled: true, Encoding: StreamEncodingProtobuf},
+			},
+		},
+		{
+			name: "tx lifetime check interval above the max lifetime",
+			cfg: Config{
+				TxLifetimeMax:           cfgTypes.NewDuration(time.Minute),
+				TxLifetimeCheckInterval: cfgTypes.NewDuration(10 * time.Minute),
+				TxAgeWarnThreshold:      cfgTypes.NewDuration(time.Minute),
+			},
+			warnings: []string{"TxLifetimeCheckInterval", "TxAgeWarnThreshold"},
+		},
+		{
+			name: "pool txs deleted without confirmations",
+			cfg: Config{
+				DeletePoolTxsCheckInterval: cfgTypes.NewDuration(time.Hour),
+			},
+			warnings: []string{"DeletePoolTxsL1BlockConfirmations"},
+		},
+		{
+			name: "pool txs deleted with too few confirmations",
+			cfg: Config{
+				DeletePoolTxsL1BlockConfirmations: 5,
+				MinDeleteConfirmations:            64,
+				DeletePoolTxsCheckInterval:        cfgTypes.NewDuration(time.Hour),
+			},
+			warnings: []string{"DeletePoolTxsL1BlockConfirmations"},
+		},
+		{
+			name: "ineffective worker and ramp limits",
+			cfg: Config{
+				WorkerFullPolicy:              WorkerFullPolicyBlock,
+				LoadPoolTxsRampStart:          100,
+				LoadPoolTxsRampMultiplier:     2,
+				LoadPoolTxsMaxPerIteration:    100,
+				LoadPoolTxsCursorFile:         "cursor.json",
+				SenderRateLimitNewSenderGrace: 5,
+				WIPStatusUpdateMaxRetries:     3,
+			},
+			warnings: []string{"LoadPoolTxsRampStart", "LoadPoolTxsCursorFile", "SenderRateLimitNewSenderGrace", "WorkerFullPolicy", "WIPStatusUpdateRetryInterval"},
+		},
+		{
+			name: "stream options with the stream server disabled",
+			cfg: Config{
+				StreamServer: StreamServerCfg{FinalityCheckInterval: cfgTypes.NewDuration(time.Second), ReadyTimeout: cfgTypes.NewDuration(time.Second), RegenerateInBackground: true, IncludeStorageDiffs: true, IncludeBlockBlooms: true, Archive: ArchiveCfg{Enabled: true},
+					PriorityStream: PriorityStreamCfg{Enabled: true}},
+			},
+			warnings: []string{"StreamServer.FinalityCheckInterval", "StreamServer.ReadyTimeout", "StreamServer.RegenerateInBackground", "StreamServer.IncludeStorageDiffs", "StreamServer.IncludeBlockBlooms", "StreamServer.Archive.Enabled",
+				"StreamServer.PriorityStream.Enabled"},
+		},
+		{
+			name: "invalid values",
+			cfg: Config{
+				Mode:                 "passive",
+				TxTrackerErrorPolicy: "ignore",
+				EventLogMinLevel:     "warn",
+				StreamServer:         StreamServerCfg{Enabled: true, Encoding: "json"},
+			},
+			errors: []string{"Mode", "TxTrackerErrorPolicy", "EventLogMinLevel", "StreamServer.Encoding"},
+		},
+		{
+			name:   "standby mode with the stream server disabled",
+			cfg:    Config{Mode: ModeStandby},
+			errors: []string{"Mode"},
+		},
+		{
+			name:   "ramp that never grows",
+			cfg:    Config{LoadPoolTxsRampStart: 100, LoadPoolTxsRampMultiplier: 1},
+			errors: []string{"LoadPoolTxsRampMultiplier"},
+		},
+		{
+			name: "archive without bucket",
+			cfg: Config{
+				StreamServer: StreamServerCfg{Enabled: true, IncludeSender: true, IncludeDecodedTxMetadata: true, IncludeL1InfoRoot: true, BlockStartExcludedFields: []string{BlockStartFieldCoinbase},
+					Archive: ArchiveCfg{Enabled: true, SegmentEntries: 100}},
+			},
+			warnings: []string{"StreamServer.IncludeSender", "StreamServer.IncludeL1InfoRoot"},
+			errors:   []string{"StreamServer.Archive"},
+		},
+		{
+			name: "priority stream without thresholds on the data stream file",
+			cfg: Config{
+				StreamServer: StreamServerCfg{Enabled: true, Port: 6900, Filename: "datastream.bin",
+					PriorityStream: PriorityStreamCfg{Enabled: true, Port: 6901, Filename: "datastream.bin", MinValue: big.NewInt(0)}},
+			},
+			warnings: []string{"StreamServer.PriorityStream"},
+			errors:   []string{"StreamServer.PriorityStream"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings := tc.cfg.Validate()
+			assert.ElementsMatch(t, tc.warnings, configWarningFields(warnings, ConfigWarningSeverityWarning))
+			assert.ElementsMatch(t, tc.errors, configWarningFields(warnings, ConfigWarningSeverityError))
+		})
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	ethermanMock := NewEthermanMock(t)
+
+	_, err := New(Config{Mode: ModeStandby}, state.BatchConfig{Constraints: bc}, pool.Config{}, nil, nil, ethermanMock, nil)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), "StreamServer.Enabled must be set")
+}
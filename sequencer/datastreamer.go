@@ -2,9 +2,12 @@ package sequencer
 
 import (
 	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
 )
 
-func (f *finalizer) DSSendL2Block(batchNumber uint64, blockResponse *state.ProcessBlockResponse) error {
+// DSSendL2Block sends the L2 block, processed with the given L1 info root, to the data streamer. The senders of the txs are
+// taken from the tx trackers, if they are not available they are recovered when streamed
+func (f *finalizer) DSSendL2Block(batchNumber uint64, blockResponse *state.ProcessBlockResponse, l1InfoRoot common.Hash, txs []*TxTracker) error {
 	forkID := f.stateIntf.GetForkIDByBatchNumber(batchNumber)
 
 	// Send data to streamer
@@ -18,9 +21,19 @@ func (f *finalizer) DSSendL2Block(batchNumber uint64, blockResponse *state.Proce
 			ForkID:         uint16(forkID),
 			BlockHash:      blockResponse.BlockHash,
 			StateRoot:      blockResponse.BlockHash, //TODO: in etrog the blockhash is the block root
+			L1InfoRoot:     l1InfoRoot,
+		}
+
+		senders := make(map[common.Hash]common.Address, len(txs))
+		storageChanges := make(map[common.Hash][]state.DSStorageChange, len(txs))
+		for _, tx := range txs {
+			senders[tx.Hash] = tx.From
+			storageChanges[tx.Hash] = tx.StorageChanges
 		}
 
 		l2Transactions := []state.DSL2Transaction{}
+		txsStorageChanges := [][]state.DSStorageChange{}
+		storageChangesUnavailable := false
 
 		for _, txResponse := range blockResponse.TransactionResponses {
 			binaryTxData, err := txResponse.Tx.MarshalBinary()
@@ -34,9 +47,24 @@ func (f *finalizer) DSSendL2Block(batchNumber uint64, blockResponse *state.Proce
 				IsValid:                     1,
 				EncodedLength:               uint32(len(binaryTxData)),
 				Encoded:                     binaryTxData,
+				From:                        senders[txResponse.TxHash],
+				StateRoot:                   txResponse.StateRoot,
 			}
 
 			l2Transactions = append(l2Transactions, l2Transaction)
+			txChanges, ok := storageChanges[txResponse.TxHash]
+			storageChangesUnavailable = storageChangesUnavailable || !ok
+			txsStorageChanges = append(txsStorageChanges, txChanges)
+		}
+
+		// The txs without tracker, e.g. the ones of the forced batches, are not processed with the storage trace
+		if f.traceStorageChanges && storageChangesUnavailable {
+			l2Block.StorageChangesUnavailable = true
+		} else if f.traceStorageChanges {
+			l2Block.StorageChanges = state.MergeDSStorageChanges(txsStorageChanges...)
+		}
+		if f.streamBlockLogs {
+			l2Block.Logs = blockResponse.Logs
 		}
 
 		f.dataToStream <- state.DSL2FullBlock{
@@ -0,0 +1,1210 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/event/nileventstorage"
+	zkmetrics "github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStreamServer returns a started stream server on a free port writing to a temporary file. The data streamer can't stop
+// a stream server, so its global logger is initialized before starting it: it can't be initialized again while the stream
+// servers of the previous tests are running
+func newTestStreamServer(t *testing.T) *datastreamer.StreamServer {
+	initStreamServerLog(dslog.Config{})
+	streamServer, err := datastreamer.NewServer(0, state.StreamTypeSequencer, filepath.Join(t.TempDir(), "datastream.bin"), nil)
+	require.NoError(t, err)
+	require.NoError(t, streamServer.Start())
+	return streamServer
+}
+
+// newTestL2FullBlock returns a L2 block with the given number of txs to send to the streamer
+func newTestL2FullBlock(batchNumber, l2BlockNumber uint64, numTxs int) state.DSL2FullBlock {
+	l2Block := state.DSL2FullBlock{
+		DSL2Block: state.DSL2Block{
+			BatchNumber:   batchNumber,
+			L2BlockNumber: l2BlockNumber,
+			Timestamp:     int64(l2BlockNumber),
+			BlockHash:     common.BigToHash(new(big.Int).SetUint64(l2BlockNumber)),
+			StateRoot:     common.BigToHash(new(big.Int).SetUint64(l2BlockNumber)),
+		},
+	}
+	for i := 0; i < numTxs; i++ {
+		encoded := []byte{byte(i)}
+		l2Block.Txs = append(l2Block.Txs, state.DSL2Transaction{
+			L2BlockNumber: l2BlockNumber,
+			IsValid:       1,
+			EncodedLength: uint32(len(encoded)),
+			Encoded:       encoded,
+		})
+	}
+	return l2Block
+}
+
+// atomicOpPhaseSampleCount returns the number of observations of the given data stream atomic op phase
+func atomicOpPhaseSampleCount(t *testing.T, phase metrics.DataStreamAtomicOpPhaseLabel) uint64 {
+	histogramVec, ok := zkmetrics.HistogramVec(metrics.DataStreamAtomicOpTimeName)
+	require.True(t, ok)
+	m := &dto.Metric{}
+	require.NoError(t, histogramVec.WithLabelValues(string(phase)).(prometheus.Histogram).Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestStreamPipeline_sendL2Blocks_AtomicOpPhaseMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	phases := []metrics.DataStreamAtomicOpPhaseLabel{metrics.DataStreamAtomicOpPhaseStart, metrics.DataStreamAtomicOpPhaseAddEntries, metrics.DataStreamAtomicOpPhaseCommit}
+	initialCounts := make(map[metrics.DataStreamAtomicOpPhaseLabel]uint64)
+	for _, phase := range phases {
+		initialCounts[phase] = atomicOpPhaseSampleCount(t, phase)
+	}
+
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 2)}))
+
+	for _, phase := range phases {
+		assert.Greater(t, atomicOpPhaseSampleCount(t, phase), initialCounts[phase], "phase %s", phase)
+	}
+	// batch bookmark + block bookmark + block start + 2 txs + block end
+	assert.Equal(t, uint64(6), streamServer.GetHeader().TotalEntries)
+}
+
+// forkIDCounterValue returns the value of the counter with the given name labeled by fork ID
+func forkIDCounterValue(t *testing.T, name string, labels ...string) float64 {
+	counterVec, ok := zkmetrics.CounterVec(name)
+	require.True(t, ok)
+	return testutil.ToFloat64(counterVec.WithLabelValues(labels...))
+}
+
+// forkIDHistogramSampleCount returns the number of observations of the histogram with the given name for the fork ID
+func forkIDHistogramSampleCount(t *testing.T, name string, forkID string) uint64 {
+	histogramVec, ok := zkmetrics.HistogramVec(name)
+	require.True(t, ok)
+	m := &dto.Metric{}
+	require.NoError(t, histogramVec.WithLabelValues(forkID).(prometheus.Histogram).Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestStreamPipeline_sendL2Blocks_ForkIDMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+	metrics.RegisterForkIDMetrics()
+
+	initialStreamed8 := forkIDCounterValue(t, metrics.DataStreamL2BlocksStreamedByForkIDName, "8")
+	initialStreamed9 := forkIDCounterValue(t, metrics.DataStreamL2BlocksStreamedByForkIDName, "9")
+	initialPerBatch8 := forkIDHistogramSampleCount(t, metrics.DataStreamL2BlocksPerBatchByForkIDName, "8")
+	initialPerBatch9 := forkIDHistogramSampleCount(t, metrics.DataStreamL2BlocksPerBatchByForkIDName, "9")
+
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+
+	// The fork 9 is activated in the batch 2
+	l2Blocks := []state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1), newTestL2FullBlock(1, 2, 1), newTestL2FullBlock(2, 3, 1), newTestL2FullBlock(3, 4, 1)}
+	for i := range l2Blocks {
+		l2Blocks[i].ForkID = 8
+		if l2Blocks[i].BatchNumber >= 2 {
+			l2Blocks[i].ForkID = 9
+		}
+	}
+	require.NoError(t, p.sendL2Blocks(l2Blocks))
+
+	assert.Equal(t, initialStreamed8+2, forkIDCounterValue(t, metrics.DataStreamL2BlocksStreamedByForkIDName, "8"))
+	assert.Equal(t, initialStreamed9+2, forkIDCounterValue(t, metrics.DataStreamL2BlocksStreamedByForkIDName, "9"))
+	// The batches 1 and 2 are completed, each one is observed with its own fork ID
+	assert.Equal(t, initialPerBatch8+1, forkIDHistogramSampleCount(t, metrics.DataStreamL2BlocksPerBatchByForkIDName, "8"))
+	assert.Equal(t, initialPerBatch9+1, forkIDHistogramSampleCount(t, metrics.DataStreamL2BlocksPerBatchByForkIDName, "9"))
+}
+
+func TestStreamPipeline_sendL2Blocks_VerifyBlockHash(t *testing.T) {
+	eventStorage, err := nileventstorage.NewNilEventStorage()
+	require.NoError(t, err)
+
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{VerifyBlockHash: true}, streamServer, stMock, event.NewEventLog(event.Config{}, eventStorage), nil)
+
+	// The block hash of the block 1 doesn't match the one stored in the state, it must not be streamed
+	mismatchedBlock := newTestL2FullBlock(1, 1, 1)
+	mismatchedBlock.BlockHash = common.HexToHash("0xdead")
+	stMock.On("GetL2BlockHeaderByNumber", mock.Anything, uint64(1), nil).Return(state.NewL2Header(&types.Header{Root: mismatchedBlock.StateRoot}), nil).Once()
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{mismatchedBlock}))
+	assert.Equal(t, uint64(0), streamServer.GetHeader().TotalEntries)
+
+	// The block 2 matches the state, it must be streamed
+	validBlock := newTestL2FullBlock(1, 2, 1)
+	stMock.On("GetL2BlockHeaderByNumber", mock.Anything, uint64(2), nil).Return(state.NewL2Header(&types.Header{Root: validBlock.StateRoot}), nil).Once()
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{validBlock}))
+
+	// batch bookmark + block bookmark + block start + 1 tx + block end of the block 2
+	assert.Equal(t, uint64(5), streamServer.GetHeader().TotalEntries)
+
+	_, err = streamServer.GetBookmark(state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: 1}.Encode())
+	assert.Error(t, err)
+	_, err = streamServer.GetBookmark(state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: 2}.Encode())
+	assert.NoError(t, err)
+}
+
+func TestStreamPipeline_sendL2Blocks_VerifyBatchNumber(t *testing.T) {
+	stMock := NewStateMock(t)
+	streamServer := newTestStreamServer(t)
+	events := make(eventStorageChan, 1)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, VerifyBatchNumber: true}, streamServer, stMock, event.NewEventLog(event.Config{}, events), nil)
+
+	// The block 1 belongs to the batch 2 in the state, it must not be streamed
+	stMock.On("GetBatchNumberOfL2Block", mock.Anything, uint64(1), nil).Return(uint64(2), nil).Once()
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 0)}))
+	assert.Equal(t, uint64(0), streamServer.GetHeader().TotalEntries)
+	select {
+	case e := <-events:
+		assert.Equal(t, event.EventID_DataStreamerBatchNumberMismatch, e.EventID)
+	default:
+		t.Fatal("batch number mismatch event not logged")
+	}
+
+	// The block 2 matches the state, it must be streamed
+	stMock.On("GetBatchNumberOfL2Block", mock.Anything, uint64(2), nil).Return(uint64(1), nil).Once()
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 2, 0)}))
+
+	// batch bookmark + block bookmark + block start + block end of the block 2
+	assert.Equal(t, uint64(4), streamServer.GetHeader().TotalEntries)
+	assert.Empty(t, events)
+}
+
+func TestStreamPipeline_sendL2Blocks_L2BlocksPerBatchMetric(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	histogram, ok := zkmetrics.Histogram(metrics.DataStreamL2BlocksPerBatchName)
+	require.True(t, ok)
+	initial := &dto.Metric{}
+	require.NoError(t, histogram.Write(initial))
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, streamServer, nil, nil, nil)
+
+	// Batch 1 has 3 L2 blocks and batch 2 has 1 L2 block. The L2 block of the batch 3 completes the batch 2
+	l2BlockNumber := uint64(0)
+	for _, batch := range []struct {
+		batchNumber uint64
+		numL2Blocks int
+	}{{1, 3}, {2, 1}, {3, 1}} {
+		for i := 0; i < batch.numL2Blocks; i++ {
+			l2BlockNumber++
+			require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(batch.batchNumber, l2BlockNumber, 0)}))
+		}
+	}
+
+	m := &dto.Metric{}
+	require.NoError(t, histogram.Write(m))
+	assert.Equal(t, initial.GetHistogram().GetSampleCount()+2, m.GetHistogram().GetSampleCount())
+	assert.Equal(t, initial.GetHistogram().GetSampleSum()+4, m.GetHistogram().GetSampleSum())
+	assert.Equal(t, uint64(3), p.currentBatchNumber)
+	assert.Equal(t, uint64(1), p.currentBatchL2Blocks)
+}
+
+func TestSequencer_setupStreamServer_RequiredAtStartup(t *testing.T) {
+	// The stream server can't be created because the directory of the data file doesn't exist
+	cfg := StreamServerCfg{Enabled: true, Filename: filepath.Join(t.TempDir(), "missing", "datastream.bin"), RequiredAtStartup: true}
+	s, _, _ := newTestSequencer(t, Config{StreamServer: cfg})
+
+	err := s.setupStreamServer(context.Background())
+	assert.ErrorContains(t, err, "failed to create stream server")
+}
+
+func TestSequencer_setupStreamServer_Degraded(t *testing.T) {
+	// The stream server can't be created because the directory of the data file doesn't exist
+	cfg := StreamServerCfg{Enabled: true, Filename: filepath.Join(t.TempDir(), "missing", "datastream.bin"), RequiredAtStartup: false}
+	s, _, _ := newTestSequencer(t, Config{StreamServer: cfg})
+	events := make(eventStorageChan, 1)
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	require.NoError(t, s.setupStreamServer(context.Background()))
+	assert.Nil(t, s.streamServer)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, event.EventID_DataStreamerDisabled, e.EventID)
+		assert.Contains(t, e.Description, "failed to create stream server")
+	default:
+		t.Fatal("data streamer disabled event not logged")
+	}
+
+	// The sequencer continues with the streaming disabled
+	assert.ErrorIs(t, s.PauseStreaming(), ErrStreamingDisabled)
+}
+
+func TestSequencer_setupStreamServer_StartRetries(t *testing.T) {
+	ctx := context.Background()
+	cfg := StreamServerCfg{Enabled: true, RequiredAtStartup: true, StartMaxRetries: 2, StartRetryInterval: cfgTypes.NewDuration(time.Millisecond)}
+	s, _, stMock := newTestSequencer(t, Config{StreamServer: cfg})
+
+	// The stream server fails to start twice, e.g. because the port is still held, and then it starts
+	streamServer := newTestStreamServer(t)
+	attempts := 0
+	s.streamServerFactory = func(s *Sequencer, filename string) (*datastreamer.StreamServer, error) {
+		attempts++
+		if attempts <= 2 {
+			return nil, errors.New("failed to start stream server, error: address already in use")
+		}
+		return streamServer, nil
+	}
+	stMock.On("GetDSGenesisBlock", ctx, nil).Return(&state.DSL2Block{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, mock.Anything, mock.Anything, true, nil).Return([]*state.DSBatch{}, nil)
+
+	require.NoError(t, s.setupStreamServer(ctx))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, streamServer, s.streamServer)
+
+	// Once all the retries fail the error is returned as the stream server is required
+	attempts = 0
+	s.cfg.StreamServer.StartMaxRetries = 1
+	err := s.setupStreamServer(ctx)
+	assert.ErrorContains(t, err, "address already in use")
+	assert.Equal(t, 2, attempts)
+}
+
+// streamedL2BlockNumbers returns the numbers of the L2 block ends of the data stream, in order
+func streamedL2BlockNumbers(t *testing.T, streamServer *datastreamer.StreamServer) []uint64 {
+	l2BlockNumbers := []uint64{}
+	for _, entry := range streamEntries(t, streamServer) {
+		if entry.Type == state.EntryTypeL2BlockEnd {
+			blockEnd, err := state.DSBinaryEncoder{}.DecodeL2BlockEnd(entry.Data)
+			require.NoError(t, err)
+			l2BlockNumbers = append(l2BlockNumbers, blockEnd.L2BlockNumber)
+		}
+	}
+	return l2BlockNumbers
+}
+
+func TestSequencer_SwapStreamFile(t *testing.T) {
+	ctx := context.Background()
+	cfg := StreamServerCfg{Enabled: true, PauseBufferSize: 100, PauseBufferFullPolicy: PauseBufferFullPolicyBlock, SkipIntermediateStateRoots: true}
+	s, _, _ := newTestSequencer(t, Config{StreamServer: cfg})
+
+	// The new data stream file is a copy of the current one made once the streaming is paused for the swap
+	currentFile := filepath.Join(t.TempDir(), "datastream.bin")
+	newFile := filepath.Join(t.TempDir(), "datastream.bin")
+	emptyFile := filepath.Join(t.TempDir(), "datastream.bin")
+	s.streamServerFactory = func(s *Sequencer, filename string) (*datastreamer.StreamServer, error) {
+		if filename == newFile {
+			data, err := os.ReadFile(currentFile)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(newFile, data, 0600))
+		}
+		initStreamServerLog(dslog.Config{})
+		streamServer, err := datastreamer.NewServer(0, state.StreamTypeSequencer, filename, nil)
+		if err != nil {
+			return nil, err
+		}
+		return streamServer, streamServer.Start()
+	}
+	currentStreamServer, err := s.createStreamServer(ctx, currentFile)
+	require.NoError(t, err)
+	s.streamServer = currentStreamServer
+	dataToStream := make(chan state.DSL2FullBlock)
+	s.streamPipeline = newStreamPipeline(cfg, s.streamServer, nil, nil, dataToStream)
+	go s.streamPipeline.start()
+
+	// The L2 blocks 1 to 30 are produced while the file is swapped after the L2 block 10
+	const lastL2BlockNumber = 30
+	produced := make(chan uint64, lastL2BlockNumber)
+	go func() {
+		for l2BlockNumber := uint64(1); l2BlockNumber <= lastL2BlockNumber; l2BlockNumber++ {
+			dataToStream <- newTestL2FullBlock(l2BlockNumber/5+1, l2BlockNumber, 1)
+			produced <- l2BlockNumber
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for l2BlockNumber := range produced {
+		if l2BlockNumber == 10 {
+			break
+		}
+	}
+
+	// The new file must end with the last L2 block streamed
+	err = s.SwapStreamFile(ctx, emptyFile)
+	require.ErrorIs(t, err, ErrStreamFileHeadMismatch)
+	assert.Equal(t, currentStreamServer, s.streamServer)
+
+	require.NoError(t, s.SwapStreamFile(ctx, newFile))
+	assert.NotEqual(t, currentStreamServer, s.streamServer)
+	assert.False(t, s.streamPipeline.paused.Load())
+
+	// All the L2 blocks are in the new file, without gaps or duplicates, and the current file stops at the swap. The data stream is
+	// read between the atomic ops of the pipeline
+	require.Eventually(t, func() bool {
+		s.streamPipeline.streamServerMutex.Lock()
+		defer s.streamPipeline.streamServerMutex.Unlock()
+		l2BlockNumber, err := getLastStreamedL2BlockNumber(s.streamServer)
+		return err == nil && l2BlockNumber == lastL2BlockNumber
+	}, 5*time.Second, 10*time.Millisecond)
+
+	expected := []uint64{}
+	for l2BlockNumber := uint64(1); l2BlockNumber <= lastL2BlockNumber; l2BlockNumber++ {
+		expected = append(expected, l2BlockNumber)
+	}
+	assert.Equal(t, expected, streamedL2BlockNumbers(t, s.streamServer))
+	currentL2BlockNumbers := streamedL2BlockNumbers(t, currentStreamServer)
+	assert.Equal(t, expected[:len(currentL2BlockNumbers)], currentL2BlockNumbers)
+	assert.Less(t, len(currentL2BlockNumbers), lastL2BlockNumber)
+}
+
+func TestSequencer_SwapStreamFile_Disabled(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+	assert.ErrorIs(t, s.SwapStreamFile(context.Background(), "datastream.bin"), ErrStreamingDisabled)
+}
+
+func TestStreamPipeline_sendL2Blocks_BatchBookmarks(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, streamServer, nil, nil, nil)
+
+	// Batch 1 has the L2 blocks 1 and 2, the first one sent alone and the second one with the L2 block 3 of the batch 2
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 0)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 2, 0), newTestL2FullBlock(2, 3, 0)}))
+
+	// The batch bookmark precedes the bookmark of the first L2 block of each batch
+	for _, batch := range []struct {
+		batchNumber        uint64
+		firstL2BlockNumber uint64
+	}{{1, 1}, {2, 3}} {
+		entryNumber, err := streamServer.GetBookmark(state.DSBookMark{Type: state.BookMarkTypeBatch, L2BlockNumber: batch.batchNumber}.Encode())
+		require.NoError(t, err)
+		entry, err := streamServer.GetEntry(entryNumber + 1)
+		require.NoError(t, err)
+		assert.Equal(t, state.EntryTypeBookMark, entry.Type)
+		assert.Equal(t, state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: batch.firstL2BlockNumber}.Encode(), entry.Data)
+	}
+
+	// 2 batch bookmarks + 3 L2 blocks * (block bookmark + block start + block end)
+	assert.Equal(t, uint64(11), streamServer.GetHeader().TotalEntries)
+
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), lastBatchNumber)
+}
+
+func TestNew_StreamChannelBufferSize(t *testing.T) {
+	ethermanMock := NewEthermanMock(t)
+	ethermanMock.On("TrustedSequencer").Return(common.Address{}, nil)
+
+	// The configured size is used
+	s, err := New(Config{StreamServer: StreamServerCfg{ChannelBufferSize: 10}}, state.BatchConfig{Constraints: bc}, pool.Config{}, nil, nil, ethermanMock, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 10, cap(s.dataToStream))
+
+	// If it's not configured the size is computed from the max txs per batch
+	s, err = New(Config{}, state.BatchConfig{Constraints: bc}, pool.Config{}, nil, nil, ethermanMock, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int(bc.MaxTxsPerBatch*datastreamChannelMultiplier), cap(s.dataToStream))
+
+	_, err = New(Config{}, state.BatchConfig{}, pool.Config{}, nil, nil, ethermanMock, nil)
+	assert.ErrorIs(t, err, ErrInvalidStreamChannelBufferSize)
+}
+
+func TestSequencer_updateDataToStreamMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	s, _, _ := newTestSequencer(t, Config{})
+	s.dataToStream = make(chan state.DSL2FullBlock, 4)
+
+	capacityGauge, ok := zkmetrics.Gauge(metrics.DataStreamChannelCapacityName)
+	require.True(t, ok)
+	lengthGauge, ok := zkmetrics.Gauge(metrics.DataStreamChannelLengthName)
+	require.True(t, ok)
+
+	s.updateDataToStreamMetrics()
+	assert.Equal(t, float64(4), testutil.ToFloat64(capacityGauge))
+	assert.Equal(t, float64(0), testutil.ToFloat64(lengthGauge))
+
+	// The length tracks the L2 blocks enqueued but not read yet
+	s.dataToStream <- newTestL2FullBlock(1, 1, 0)
+	s.dataToStream <- newTestL2FullBlock(1, 2, 0)
+	s.dataToStream <- newTestL2FullBlock(1, 3, 0)
+	s.updateDataToStreamMetrics()
+	assert.Equal(t, float64(4), testutil.ToFloat64(capacityGauge))
+	assert.Equal(t, float64(3), testutil.ToFloat64(lengthGauge))
+
+	<-s.dataToStream
+	s.updateDataToStreamMetrics()
+	assert.Equal(t, float64(2), testutil.ToFloat64(lengthGauge))
+}
+
+func TestSequencer_updateDataStreamerFile_ResumeAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{StreamServer: StreamServerCfg{FileUpdateRetryInterval: cfgTypes.NewDuration(time.Millisecond)}})
+	streamServer := newTestStreamServer(t)
+	s.streamServer = streamServer
+
+	batch := func(batchNumber uint64) *state.DSBatch {
+		return &state.DSBatch{Batch: state.Batch{BatchNumber: batchNumber, Timestamp: time.Unix(int64(batchNumber), 0)}}
+	}
+	l2Block := func(batchNumber, l2BlockNumber uint64) *state.DSL2Block {
+		block := newTestL2FullBlock(batchNumber, l2BlockNumber, 0).DSL2Block
+		return &block
+	}
+	l2Tx := func(l2BlockNumber uint64) *state.DSL2Transaction {
+		tx := newTestL2FullBlock(0, l2BlockNumber, 1).Txs[0]
+		return &tx
+	}
+
+	stMock.On("GetDSGenesisBlock", ctx, nil).Return(&state.DSL2Block{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(1), uint64(10001), true, nil).Return([]*state.DSBatch{batch(1), batch(2)}, nil).Once()
+	stMock.On("GetDSL2Blocks", ctx, uint64(1), uint64(2), nil).Return([]*state.DSL2Block{l2Block(1, 1), l2Block(2, 2)}, nil).Once()
+	stMock.On("GetDSL2Transactions", ctx, uint64(1), uint64(2), nil).Return([]*state.DSL2Transaction{l2Tx(1), l2Tx(2)}, nil).Once()
+	// The intermediate state root of the tx of the batch 2 can't be retrieved
+	stMock.On("GetStorageAt", ctx, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil).Once()
+	stMock.On("GetStorageAt", ctx, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(nil, errors.New("state error")).Once()
+
+	// The batch 2 is rolled back, the data stream keeps the genesis and the batch 1
+	_, err := s.updateDataStreamerFile(ctx)
+	var generationErr *state.DSGenerationError
+	require.ErrorAs(t, err, &generationErr)
+	assert.Equal(t, uint64(1), generationErr.LastBatchNumber)
+	// genesis (bookmark + block start + block end) + batch 1 (batch bookmark + block bookmark + block start + 1 tx + block end)
+	assert.Equal(t, uint64(8), generationErr.TotalEntries)
+	assert.Equal(t, uint64(8), streamServer.GetHeader().TotalEntries)
+
+	// The update is resumed from the checkpoint, the batch 2 is generated again
+	stMock.On("GetDSBatches", ctx, uint64(2), uint64(10002), true, nil).Return([]*state.DSBatch{batch(2)}, nil).Once()
+	stMock.On("GetDSL2Blocks", ctx, uint64(2), uint64(2), nil).Return([]*state.DSL2Block{l2Block(2, 2)}, nil).Once()
+	stMock.On("GetDSL2Transactions", ctx, uint64(2), uint64(2), nil).Return([]*state.DSL2Transaction{l2Tx(2)}, nil).Once()
+	stMock.On("GetStorageAt", ctx, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(10002), uint64(20002), true, nil).Return([]*state.DSBatch{}, nil).Once()
+
+	s.cfg.StreamServer.FileUpdateMaxRetries = 1
+	update, err := s.updateDataStreamerFile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(13), streamServer.GetHeader().TotalEntries)
+	// batch 2 (batch bookmark + block bookmark + block start + 1 tx + block end)
+	assert.Equal(t, uint64(5), update.EntriesWritten)
+	assert.Equal(t, uint64(2), update.FromBatchNumber)
+	assert.Equal(t, uint64(2), update.ToBatchNumber)
+
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), lastBatchNumber)
+}
+
+func TestSequencer_restart_ProtobufEncoding(t *testing.T) {
+	ctx := context.Background()
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, Encoding: StreamEncodingProtobuf}
+	s, _, stMock := newTestSequencer(t, Config{StreamServer: cfg})
+	streamServer := newTestStreamServer(t)
+	s.streamServer = streamServer
+
+	// The L2 block start, tx and end entries are streamed with the protobuf entry types
+	p := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(3, 5, 1)}))
+	var streamedEntryTypes []datastreamer.EntryType
+	for _, entry := range streamEntries(t, streamServer) {
+		streamedEntryTypes = append(streamedEntryTypes, entry.Type)
+	}
+	assert.Equal(t, []datastreamer.EntryType{state.EntryTypeBookMark, state.EntryTypeBookMark, state.EntryTypeL2BlockStartProto, state.EntryTypeL2TxProto, state.EntryTypeL2BlockEndProto}, streamedEntryTypes)
+
+	// Once restarted, the last L2 block streamed is read from the protobuf entries
+	restarted := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+	restarted.restoreLastStreamed(streamServer)
+	assert.Equal(t, uint64(3), restarted.currentBatchNumber)
+	assert.Equal(t, uint64(5), restarted.lastL2BlockNumber)
+	assert.Equal(t, common.BigToHash(big.NewInt(5)), restarted.lastStateRoot)
+
+	// The update of the data stream file resumes after the batch of the last L2 block streamed
+	stMock.On("GetDSBatches", ctx, uint64(4), uint64(10004), true, nil).Return([]*state.DSBatch{}, nil).Once()
+	update, err := s.updateDataStreamerFile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), update.EntriesWritten)
+}
+
+func TestStreamPipeline_sendL2Blocks_IncludeDecodedTxMetadata(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := common.HexToAddress("0x0102")
+	tx, err := types.SignNewTx(privateKey, types.NewEIP155Signer(big.NewInt(1000)), &types.LegacyTx{Nonce: 3, To: &to, Value: big.NewInt(10), Gas: 21000, GasPrice: big.NewInt(1)})
+	require.NoError(t, err)
+	encoded, err := tx.MarshalBinary()
+	require.NoError(t, err)
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, IncludeDecodedTxMetadata: true}, streamServer, nil, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+	l2Block.Txs = []state.DSL2Transaction{{L2BlockNumber: 1, IsValid: 1, EncodedLength: uint32(len(encoded)), Encoded: encoded}}
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	// batch bookmark + block bookmark + block start + tx
+	entry, err := streamServer.GetEntry(3)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2TxWithMetadata, entry.Type)
+	decoded := state.DSL2TransactionWithMetadata{}.Decode(entry.Data)
+	assert.Equal(t, encoded, decoded.Encoded)
+	assert.Equal(t, crypto.PubkeyToAddress(privateKey.PublicKey), decoded.From)
+	assert.Equal(t, &to, decoded.To)
+	assert.Equal(t, uint64(3), decoded.Nonce)
+	assert.Equal(t, int64(10), decoded.Value.Int64())
+}
+
+func TestStreamPipeline_sendL2Blocks_IncludeSender(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := common.HexToAddress("0x0102")
+	tx, err := types.SignNewTx(privateKey, types.NewEIP155Signer(big.NewInt(1000)), &types.LegacyTx{Nonce: 3, To: &to, Value: big.NewInt(10), Gas: 21000, GasPrice: big.NewInt(1)})
+	require.NoError(t, err)
+	encoded, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, IncludeSender: true}, streamServer, nil, nil, nil)
+
+	// The sender of the first tx comes from its tx tracker, the sender of the second one is recovered
+	l2Block := newTestL2FullBlock(1, 1, 0)
+	l2Block.Txs = []state.DSL2Transaction{
+		{L2BlockNumber: 1, IsValid: 1, EncodedLength: uint32(len(encoded)), Encoded: encoded, From: from},
+		{L2BlockNumber: 1, IsValid: 1, EncodedLength: uint32(len(encoded)), Encoded: encoded},
+	}
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	// batch bookmark + block bookmark + block start + txs
+	for _, entryNumber := range []uint64{3, 4} {
+		entry, err := streamServer.GetEntry(entryNumber)
+		require.NoError(t, err)
+		require.Equal(t, state.EntryTypeL2TxWithSender, entry.Type)
+		decoded := state.DSL2TransactionWithSender{}.Decode(entry.Data)
+		assert.Equal(t, encoded, decoded.Encoded)
+		assert.Equal(t, from, decoded.From)
+	}
+}
+
+func TestStreamPipeline_sendL2Blocks_EmitBatchBoundaries(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, BlocksPerAtomicOp: 2, EmitBatchBoundaries: true}, streamServer, nil, nil, nil)
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 0), newTestL2FullBlock(1, 2, 0)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(2, 3, 0)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(3, 4, 0)}))
+
+	// The markers bracket the L2 blocks of each batch, the batch end is added when the next batch starts
+	expectedTypes := []datastreamer.EntryType{
+		state.EntryTypeBookMark, state.EntryTypeBatchStart,
+		state.EntryTypeBookMark, state.EntryTypeL2BlockStart, state.EntryTypeL2BlockEnd,
+		state.EntryTypeBookMark, state.EntryTypeL2BlockStart, state.EntryTypeL2BlockEnd,
+		state.EntryTypeBatchEnd, state.EntryTypeBookMark, state.EntryTypeBatchStart,
+		state.EntryTypeBookMark, state.EntryTypeL2BlockStart, state.EntryTypeL2BlockEnd,
+		state.EntryTypeBatchEnd, state.EntryTypeBookMark, state.EntryTypeBatchStart,
+		state.EntryTypeBookMark, state.EntryTypeL2BlockStart, state.EntryTypeL2BlockEnd,
+	}
+	require.Equal(t, uint64(len(expectedTypes)), streamServer.GetHeader().TotalEntries)
+
+	var batchStarts []state.DSBatchStart
+	var batchEnds []state.DSBatchEnd
+	for i, expectedType := range expectedTypes {
+		entry, err := streamServer.GetEntry(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, expectedType, entry.Type, "entry %d", i)
+
+		switch entry.Type {
+		case state.EntryTypeBatchStart:
+			batchStarts = append(batchStarts, state.DSBatchStart{}.Decode(entry.Data))
+		case state.EntryTypeBatchEnd:
+			batchEnds = append(batchEnds, state.DSBatchEnd{}.Decode(entry.Data))
+		}
+	}
+
+	assert.Equal(t, []state.DSBatchStart{{BatchNumber: 1}, {BatchNumber: 2}, {BatchNumber: 3}}, batchStarts)
+	assert.Equal(t, []state.DSBatchEnd{
+		{BatchNumber: 1, StateRoot: newTestL2FullBlock(1, 2, 0).StateRoot},
+		{BatchNumber: 2, StateRoot: newTestL2FullBlock(2, 3, 0).StateRoot},
+	}, batchEnds)
+}
+
+func TestStreamPipeline_sendL2Blocks_TimestampSkew(t *testing.T) {
+	testCases := []struct {
+		name               string
+		policy             string
+		expectedTimestamps []int64
+	}{
+		{name: "clamp", policy: TimestampSkewPolicyClamp, expectedTimestamps: []int64{100, 101, 102}},
+		{name: "skip", policy: TimestampSkewPolicySkip, expectedTimestamps: []int64{100, 102}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			streamServer := newTestStreamServer(t)
+			events := make(eventStorageChan, 1)
+			cfg := StreamServerCfg{SkipIntermediateStateRoots: true, TimestampSkewTolerance: cfgTypes.NewDuration(5 * time.Second), TimestampSkewPolicy: tc.policy}
+			p := newStreamPipeline(cfg, streamServer, nil, event.NewEventLog(event.Config{}, events), nil)
+
+			l2Block := func(l2BlockNumber uint64, timestamp int64) state.DSL2FullBlock {
+				l2Block := newTestL2FullBlock(1, l2BlockNumber, 0)
+				l2Block.Timestamp = timestamp
+				return l2Block
+			}
+
+			// The L2 block 2 is 3s before the L2 block 1, within the tolerance
+			require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block(1, 100), l2Block(2, 97)}))
+			assert.Empty(t, events)
+
+			// The L2 block 3 is 10s before the L2 block 1, beyond the tolerance
+			require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block(3, 90), l2Block(4, 102)}))
+			select {
+			case e := <-events:
+				assert.Equal(t, event.EventID_DataStreamerTimestampSkew, e.EventID)
+				assert.Contains(t, e.Description, "l2block 3 timestamp 90")
+			default:
+				t.Fatal("timestamp skew event not logged")
+			}
+
+			// The timestamps of the L2 blocks streamed, except the L2 block 2 within the tolerance
+			timestamps := []int64{}
+			for _, l2BlockNumber := range []uint64{1, 3, 4} {
+				entryNumber, err := streamServer.GetBookmark(state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: l2BlockNumber}.Encode())
+				if err != nil {
+					continue
+				}
+				entry, err := streamServer.GetEntry(entryNumber + 1)
+				require.NoError(t, err)
+				timestamps = append(timestamps, state.DSL2BlockStart{}.Decode(entry.Data).Timestamp)
+			}
+			assert.Equal(t, tc.expectedTimestamps, timestamps)
+			assert.Equal(t, int64(102), p.lastTimestamp)
+		})
+	}
+}
+
+func TestSequencer_updateDataStreamerFile_Summary(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{})
+	s.streamServer = newTestStreamServer(t)
+
+	l2Block := func(batchNumber, l2BlockNumber uint64) *state.DSL2Block {
+		block := newTestL2FullBlock(batchNumber, l2BlockNumber, 0).DSL2Block
+		return &block
+	}
+
+	stMock.On("GetDSGenesisBlock", ctx, nil).Return(&state.DSL2Block{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(1), uint64(10001), true, nil).Return([]*state.DSBatch{
+		{Batch: state.Batch{BatchNumber: 1}}, {Batch: state.Batch{BatchNumber: 2}},
+	}, nil).Once()
+	stMock.On("GetDSL2Blocks", ctx, uint64(1), uint64(2), nil).Return([]*state.DSL2Block{l2Block(1, 1), l2Block(2, 2), l2Block(2, 3)}, nil).Once()
+	stMock.On("GetDSL2Transactions", ctx, uint64(1), uint64(3), nil).Return([]*state.DSL2Transaction{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(10001), uint64(20001), true, nil).Return([]*state.DSBatch{}, nil).Once()
+
+	update, err := s.updateDataStreamerFile(ctx)
+	require.NoError(t, err)
+
+	// genesis (bookmark + block start + block end) + 2 batch bookmarks + 3 L2 blocks (block bookmark + block start + block end)
+	assert.Equal(t, uint64(14), update.EntriesWritten)
+	assert.Equal(t, uint64(1), update.FromBatchNumber)
+	assert.Equal(t, uint64(2), update.ToBatchNumber)
+	assert.Greater(t, update.Duration, time.Duration(0))
+	assert.Equal(t, fmt.Sprintf("entries written: 14, batches: 1 to 2, duration: %v", update.Duration), update.String())
+}
+
+func TestSequencer_updateDataStreamerFile_EntriesConfig(t *testing.T) {
+	ctx := context.Background()
+	cfg := StreamServerCfg{
+		SkipIntermediateStateRoots: true,
+		Encoding:                   StreamEncodingProtobuf,
+		IncludeL1InfoRoot:          true,
+		IncludeStorageDiffs:        true,
+		IncludeBlockBlooms:         true,
+		CheckpointEveryNBlocks:     1,
+	}
+	s, _, stMock := newTestSequencer(t, Config{StreamServer: cfg})
+	streamServer := newTestStreamServer(t)
+	s.streamServer = streamServer
+
+	l2Block := newTestL2FullBlock(1, 1, 1)
+	l2Block.Logs = []*types.Log{{Address: common.HexToAddress("0x10"), Topics: []common.Hash{common.HexToHash("0x01")}}}
+
+	stMock.On("GetDSGenesisBlock", ctx, nil).Return(&state.DSL2Block{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(1), uint64(10001), true, nil).Return([]*state.DSBatch{{Batch: state.Batch{BatchNumber: 1}}}, nil).Once()
+	stMock.On("GetDSL2Blocks", ctx, uint64(1), uint64(1), nil).Return([]*state.DSL2Block{&l2Block.DSL2Block}, nil).Once()
+	stMock.On("GetDSL2Transactions", ctx, uint64(1), uint64(1), nil).Return([]*state.DSL2Transaction{&l2Block.Txs[0]}, nil).Once()
+	stMock.On("GetStorageAt", ctx, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil).Once()
+	stMock.On("GetLogsByBlockNumber", ctx, uint64(0), nil).Return([]*types.Log{}, nil).Once()
+	stMock.On("GetLogsByBlockNumber", ctx, uint64(1), nil).Return(l2Block.Logs, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(10001), uint64(20001), true, nil).Return([]*state.DSBatch{}, nil).Once()
+
+	_, err := s.updateDataStreamerFile(ctx)
+	require.NoError(t, err)
+	generated := streamEntries(t, streamServer)
+
+	// The genesis is generated with the same entries as any other L2 block
+	genesisEntryTypes := []datastreamer.EntryType{}
+	for _, entry := range generated[:6] {
+		genesisEntryTypes = append(genesisEntryTypes, entry.Type)
+	}
+	assert.Equal(t, []datastreamer.EntryType{
+		state.EntryTypeBookMark, state.EntryTypeL2BlockStartProto, state.EntryTypeL2BlockStorageDiff,
+		state.EntryTypeL2BlockBloom, state.EntryTypeL2BlockCheckpoint, state.EntryTypeL2BlockEndProto,
+	}, genesisEntryTypes)
+
+	// The L2 block generated from the state has the same entries as the L2 block streamed by the sequencer, except the ones
+	// with data not kept in the state
+	liveStreamServer := newTestStreamServer(t)
+	require.NoError(t, newStreamPipeline(cfg, liveStreamServer, nil, nil, nil).sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+	streamed := streamEntries(t, liveStreamServer)
+	require.Len(t, generated[6:], len(streamed))
+	for i, entry := range streamed {
+		if entry.Type == state.EntryTypeL2BlockStartWithL1InfoRoot {
+			// The L1 info roots are not kept in the state, so the generated L2 block start doesn't include it
+			require.Equal(t, state.EntryTypeL2BlockStartProto, generated[6+i].Type)
+			blockStart, err := state.DSProtobufEncoder{}.DecodeL2BlockStart(generated[6+i].Data)
+			require.NoError(t, err)
+			assert.Equal(t, state.DSL2BlockStartWithL1InfoRoot{}.Decode(entry.Data).DSL2BlockStart, blockStart)
+			continue
+		}
+
+		assert.Equal(t, entry.Type, generated[6+i].Type)
+		switch entry.Type {
+		case state.EntryTypeL2BlockBloom, state.EntryTypeL2BlockCheckpoint, state.EntryTypeL2BlockEndProto:
+			assert.Equal(t, entry.Data, generated[6+i].Data)
+		case state.EntryTypeL2BlockStorageDiff:
+			// The storage changes are not kept in the state, so the generated diff is marked as unavailable instead of empty
+			assert.False(t, state.DSL2BlockStorageDiff{}.Decode(entry.Data).Unavailable)
+			storageDiff := state.DSL2BlockStorageDiff{}.Decode(generated[6+i].Data)
+			assert.True(t, storageDiff.Unavailable)
+			assert.Empty(t, storageDiff.Changes)
+		}
+	}
+}
+
+// committedStreamServer is a stream server that signals each atomic op committed
+type committedStreamServer struct {
+	*datastreamer.StreamServer
+	committed chan struct{}
+}
+
+func (s committedStreamServer) CommitAtomicOp() error {
+	err := s.StreamServer.CommitAtomicOp()
+	if err == nil {
+		s.committed <- struct{}{}
+	}
+	return err
+}
+
+func TestStreamPipeline_start_PipelinedEncoding(t *testing.T) {
+	const numL2Blocks = 20
+
+	stMock := NewStateMock(t)
+	streamServer := newTestStreamServer(t)
+	committed := make(chan struct{}, numL2Blocks)
+	dataToStream := make(chan state.DSL2FullBlock, numL2Blocks)
+	p := newStreamPipeline(StreamServerCfg{PipelinedEncoding: true, PipelineBufferSize: 4}, committedStreamServer{streamServer, committed}, stMock, nil, dataToStream)
+
+	// The intermediate state root of each L2 block is its state root, read with a different delay so the L2 blocks are prepared at different paces
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, address common.Address, position *big.Int, root common.Hash) (*big.Int, error) {
+			time.Sleep(time.Duration(root.Big().Uint64()%3) * time.Millisecond)
+			return root.Big(), nil
+		})
+
+	for l2BlockNumber := uint64(1); l2BlockNumber <= numL2Blocks; l2BlockNumber++ {
+		dataToStream <- newTestL2FullBlock(1, l2BlockNumber, 1)
+	}
+
+	go p.start()
+
+	for i := 0; i < numL2Blocks; i++ {
+		select {
+		case <-committed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("atomic op not committed")
+		}
+	}
+
+	// 1 batch bookmark + L2 blocks * (block bookmark + block start + tx + block end)
+	totalEntries := uint64(1 + numL2Blocks*4)
+	require.Equal(t, totalEntries, streamServer.GetHeader().TotalEntries)
+
+	// The L2 blocks are committed in order, each tx with the intermediate state root of its L2 block
+	l2BlockNumber := uint64(0)
+	for entryNumber := uint64(0); entryNumber < totalEntries; entryNumber++ {
+		entry, err := streamServer.GetEntry(entryNumber)
+		require.NoError(t, err)
+
+		switch entry.Type {
+		case state.EntryTypeBookMark:
+			bookMark := state.DSBookMark{}.Decode(entry.Data)
+			if bookMark.Type == state.BookMarkTypeL2Block {
+				assert.Equal(t, l2BlockNumber+1, bookMark.L2BlockNumber)
+				l2BlockNumber = bookMark.L2BlockNumber
+			}
+		case state.EntryTypeL2Tx:
+			l2Transaction := state.DSL2Transaction{}.Decode(entry.Data)
+			assert.Equal(t, common.BigToHash(new(big.Int).SetUint64(l2BlockNumber)), l2Transaction.StateRoot)
+		}
+	}
+	assert.Equal(t, uint64(numL2Blocks), l2BlockNumber)
+}
+
+// panickingStreamServer is a stream server that panics the first time an atomic op is started
+type panickingStreamServer struct {
+	committedStreamServer
+	panicked *atomic.Bool
+}
+
+func (s panickingStreamServer) StartAtomicOp() error {
+	if s.panicked.CompareAndSwap(false, true) {
+		panic("start atomic op")
+	}
+	return s.StreamServer.StartAtomicOp()
+}
+
+func TestStreamPipeline_start_PipelinedEncodingRestart(t *testing.T) {
+	const numL2Blocks = 10
+
+	streamServer := newTestStreamServer(t)
+	committed := make(chan struct{}, numL2Blocks)
+	dataToStream := make(chan state.DSL2FullBlock, numL2Blocks)
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, PipelinedEncoding: true, PipelineBufferSize: 4}
+	p := newStreamPipeline(cfg, panickingStreamServer{committedStreamServer{streamServer, committed}, &atomic.Bool{}}, nil, nil, dataToStream)
+
+	for l2BlockNumber := uint64(1); l2BlockNumber <= numL2Blocks; l2BlockNumber++ {
+		dataToStream <- newTestL2FullBlock(1, l2BlockNumber, 1)
+	}
+
+	// The streaming panics with the first L2 block, the background goroutine is stopped before start returns
+	assert.Contains(t, runStreamer(p.start), "panic: start atomic op")
+
+	// Once restarted, the L2 blocks read by the stopped background goroutine are streamed in order with the rest
+	go p.start()
+
+	for i := 1; i < numL2Blocks; i++ {
+		select {
+		case <-committed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("atomic op not committed")
+		}
+	}
+
+	var l2BlockNumbers []uint64
+	for _, entry := range streamEntries(t, streamServer) {
+		if entry.Type == state.EntryTypeBookMark {
+			bookMark := state.DSBookMark{}.Decode(entry.Data)
+			if bookMark.Type == state.BookMarkTypeL2Block {
+				l2BlockNumbers = append(l2BlockNumbers, bookMark.L2BlockNumber)
+			}
+		}
+	}
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6, 7, 8, 9, 10}, l2BlockNumbers)
+}
+
+func TestStreamPipeline_sendL2Blocks_BlockStartExcludedFields(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, BlockStartExcludedFields: []string{BlockStartFieldGlobalExitRoot, BlockStartFieldCoinbase}}
+	p := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+	l2Block.GlobalExitRoot = common.HexToHash("0x01")
+	l2Block.Coinbase = common.HexToAddress("0x02")
+	l2Block.ForkID = 9
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	// The L2 block start follows the batch and L2 block bookmarks, only with the fork ID of the optional fields
+	entry, err := streamServer.GetEntry(2)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2BlockStartMasked, entry.Type)
+	blockStart := state.DSL2BlockStartMasked{}.Decode(entry.Data)
+	assert.Equal(t, state.DSL2BlockStartFieldForkID, blockStart.Fields)
+	assert.Equal(t, state.DSL2BlockStart{BatchNumber: 1, L2BlockNumber: 1, Timestamp: l2Block.Timestamp, ForkID: 9}, blockStart.DSL2BlockStart)
+
+	// The batch of the last L2 block is read from the masked L2 block start
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), lastBatchNumber)
+}
+
+func TestStreamPipeline_sendL2Blocks_IncludeL1InfoRoot(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, IncludeL1InfoRoot: true}
+	p := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+	l2Block.GlobalExitRoot = common.HexToHash("0x01")
+	l2Block.L1InfoRoot = common.HexToHash("0x02")
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	// The L2 block start follows the batch and L2 block bookmarks, with the L1 info root of the L2 block
+	entry, err := streamServer.GetEntry(2)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2BlockStartWithL1InfoRoot, entry.Type)
+	blockStart := state.DSL2BlockStartWithL1InfoRoot{}.Decode(entry.Data)
+	assert.Equal(t, state.DSL2BlockStartL1InfoRootVersion, blockStart.Version)
+	assert.Equal(t, l2Block.L1InfoRoot, blockStart.L1InfoRoot)
+	assert.Equal(t, l2Block.GlobalExitRoot, blockStart.GlobalExitRoot)
+	assert.Equal(t, uint64(1), blockStart.L2BlockNumber)
+
+	// The batch of the last L2 block is read from the L2 block start with L1 info root
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), lastBatchNumber)
+}
+
+func TestStreamPipeline_sendL2Blocks_IncludeStorageDiffs(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, IncludeStorageDiffs: true}, streamServer, nil, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 1)
+	l2Block.StorageChanges = []state.DSStorageChange{
+		{Address: common.HexToAddress("0x10"), Slot: common.HexToHash("0x01"), Value: common.HexToHash("0x0a")},
+		{Address: common.HexToAddress("0x20"), Slot: common.HexToHash("0x02"), Value: common.Hash{}},
+	}
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block, newTestL2FullBlock(1, 2, 0)}))
+
+	// batch bookmark + block bookmark + block start + tx + storage diff + block end
+	entry, err := streamServer.GetEntry(4)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2BlockStorageDiff, entry.Type)
+	storageDiff := state.DSL2BlockStorageDiff{}.Decode(entry.Data)
+	assert.Equal(t, state.DSL2BlockStorageDiffVersion, storageDiff.Version)
+	assert.Equal(t, uint64(1), storageDiff.L2BlockNumber)
+	assert.Equal(t, l2Block.StorageChanges, storageDiff.Changes)
+
+	entry, err = streamServer.GetEntry(5)
+	require.NoError(t, err)
+	assert.Equal(t, state.EntryTypeL2BlockEnd, entry.Type)
+
+	// The L2 blocks without changes are streamed with an empty diff
+	// block bookmark + block start + storage diff
+	entry, err = streamServer.GetEntry(8)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2BlockStorageDiff, entry.Type)
+	storageDiff = state.DSL2BlockStorageDiff{}.Decode(entry.Data)
+	assert.Equal(t, uint64(2), storageDiff.L2BlockNumber)
+	assert.False(t, storageDiff.Unavailable)
+	assert.Empty(t, storageDiff.Changes)
+
+	lastL2BlockNumber, err := getLastStreamedL2BlockNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), lastL2BlockNumber)
+}
+
+func TestFinalizer_DSSendL2Block_StorageChangesUnavailable(t *testing.T) {
+	stMock := NewStateMock(t)
+	stMock.On("GetForkIDByBatchNumber", uint64(1)).Return(uint64(9))
+	// The stream server is only checked to be set, the L2 blocks are sent to the channel
+	f := &finalizer{stateIntf: stMock, streamServer: &datastreamer.StreamServer{}, dataToStream: make(chan state.DSL2FullBlock, 2), traceStorageChanges: true}
+
+	tx1 := types.NewTransaction(0, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	tx2 := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	change := state.DSStorageChange{Address: common.HexToAddress("0x10"), Slot: common.HexToHash("0x01"), Value: common.HexToHash("0x0a")}
+	blockResponse := &state.ProcessBlockResponse{
+		BlockNumber: 1,
+		TransactionResponses: []*state.ProcessTransactionResponse{
+			{TxHash: tx1.Hash(), Tx: *tx1}, {TxHash: tx2.Hash(), Tx: *tx2},
+		},
+	}
+
+	// The storage changes of the txs with tracker are merged
+	txs := []*TxTracker{{Hash: tx1.Hash(), StorageChanges: []state.DSStorageChange{change}}, {Hash: tx2.Hash()}}
+	require.NoError(t, f.DSSendL2Block(1, blockResponse, common.Hash{}, txs))
+	l2Block := <-f.dataToStream
+	assert.False(t, l2Block.StorageChangesUnavailable)
+	assert.Equal(t, []state.DSStorageChange{change}, l2Block.StorageChanges)
+
+	// The txs without tracker, as the ones of the forced batches, have no storage trace
+	require.NoError(t, f.DSSendL2Block(1, blockResponse, common.Hash{}, nil))
+	l2Block = <-f.dataToStream
+	assert.True(t, l2Block.StorageChangesUnavailable)
+	assert.Empty(t, l2Block.StorageChanges)
+}
+
+func TestStreamPipeline_sendL2Blocks_IncludeBlockBlooms(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, IncludeBlockBlooms: true}, streamServer, nil, nil, nil)
+
+	contract, topic := common.HexToAddress("0x10"), common.HexToHash("0x01")
+	l2Block := newTestL2FullBlock(1, 1, 1)
+	l2Block.Logs = []*types.Log{{Address: contract, Topics: []common.Hash{topic}}}
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	// batch bookmark + block bookmark + block start + tx + bloom + block end
+	entry, err := streamServer.GetEntry(4)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeL2BlockBloom, entry.Type)
+	bloom := state.DSL2BlockBloom{}.Decode(entry.Data)
+	assert.Equal(t, state.DSL2BlockBloomVersion, bloom.Version)
+	assert.Equal(t, uint64(1), bloom.L2BlockNumber)
+	assert.True(t, types.BloomLookup(bloom.Bloom, contract))
+	assert.True(t, types.BloomLookup(bloom.Bloom, topic))
+	assert.False(t, types.BloomLookup(bloom.Bloom, common.HexToAddress("0x20")))
+
+	entry, err = streamServer.GetEntry(5)
+	require.NoError(t, err)
+	assert.Equal(t, state.EntryTypeL2BlockEnd, entry.Type)
+}
+
+// lastFinalityUpdates returns the L2 block finality updates at the end of the data stream
+func lastFinalityUpdates(t *testing.T, streamServer *datastreamer.StreamServer) []state.DSL2BlockFinality {
+	updates := []state.DSL2BlockFinality{}
+	for entryNumber := streamServer.GetHeader().TotalEntries; entryNumber > 0; entryNumber-- {
+		entry, err := streamServer.GetEntry(entryNumber - 1)
+		require.NoError(t, err)
+		if entry.Type != state.EntryTypeL2BlockFinality {
+			break
+		}
+		updates = append([]state.DSL2BlockFinality{state.DSL2BlockFinality{}.Decode(entry.Data)}, updates...)
+	}
+	return updates
+}
+
+func TestStreamPipeline_sendL2Blocks_L2BlockFinality(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, streamServer, nil, nil, nil)
+
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 3; l2BlockNumber++ {
+		require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, l2BlockNumber, 1)}))
+	}
+
+	// The finality updates wake up the pipeline and are streamed without L2 blocks
+	p.setL2BlockFinality(2, 1)
+	assert.Empty(t, p.readL2Blocks(nil))
+	require.NoError(t, p.sendL2Blocks(nil))
+	assert.Equal(t, []state.DSL2BlockFinality{
+		{Version: state.DSL2BlockFinalityVersion, L2BlockNumber: 2, Finality: state.L2BlockFinalitySafe},
+		{Version: state.DSL2BlockFinalityVersion, L2BlockNumber: 1, Finality: state.L2BlockFinalityFinalized},
+	}, lastFinalityUpdates(t, streamServer))
+
+	// The L2 blocks not streamed yet are upgraded up to the last L2 block streamed
+	p.setL2BlockFinality(5, 3)
+	require.NoError(t, p.sendL2Blocks(nil))
+	assert.Equal(t, []state.DSL2BlockFinality{
+		{Version: state.DSL2BlockFinalityVersion, L2BlockNumber: 3, Finality: state.L2BlockFinalitySafe},
+		{Version: state.DSL2BlockFinalityVersion, L2BlockNumber: 3, Finality: state.L2BlockFinalityFinalized},
+	}, lastFinalityUpdates(t, streamServer)[2:])
+
+	// The remaining safe L2 blocks are upgraded once they are streamed
+	totalEntries := streamServer.GetHeader().TotalEntries
+	require.NoError(t, p.sendL2Blocks(nil))
+	assert.Equal(t, totalEntries, streamServer.GetHeader().TotalEntries)
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 4, 1)}))
+	assert.Equal(t, []state.DSL2BlockFinality{
+		{Version: state.DSL2BlockFinalityVersion, L2BlockNumber: 4, Finality: state.L2BlockFinalitySafe},
+	}, lastFinalityUpdates(t, streamServer))
+
+	// The last L2 block and batch are read skipping the finality updates
+	lastL2BlockNumber, err := getLastStreamedL2BlockNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), lastL2BlockNumber)
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), lastBatchNumber)
+}
+
+func TestSequencer_trackL2BlockFinality(t *testing.T) {
+	s, _, stateMock := newTestSequencer(t, Config{})
+	s.streamPipeline = newStreamPipeline(StreamServerCfg{}, nil, nil, nil, nil)
+	stateMock.On("GetLastVirtualizedL2BlockNumber", mock.Anything, nil).Return(uint64(5), nil)
+	stateMock.On("GetLastConsolidatedL2BlockNumber", mock.Anything, nil).Return(uint64(0), state.ErrNotFound)
+
+	s.trackL2BlockFinality(context.Background())
+	assert.Equal(t, uint64(5), s.streamPipeline.safeL2BlockNumber.Load())
+	assert.Equal(t, uint64(0), s.streamPipeline.finalizedL2BlockNumber.Load())
+	assert.Len(t, s.streamPipeline.finalityCh, 1)
+}
+
+func TestNew_InvalidBlockStartExcludedFields(t *testing.T) {
+	ethermanMock := NewEthermanMock(t)
+	ethermanMock.On("TrustedSequencer").Return(common.Address{}, nil)
+
+	cfg := Config{StreamServer: StreamServerCfg{BlockStartExcludedFields: []string{BlockStartFieldCoinbase, "stateRoot"}}}
+	_, err := New(cfg, state.BatchConfig{Constraints: bc}, pool.Config{}, nil, nil, ethermanMock, nil)
+	assert.ErrorIs(t, err, ErrInvalidBlockStartField)
+}
+
+func TestSequencer_superviseStreamer(t *testing.T) {
+	cfg := Config{StreamServer: StreamServerCfg{MaxRestarts: 2, RestartBackoff: cfgTypes.NewDuration(time.Millisecond)}}
+	s, _, _ := newTestSequencer(t, cfg)
+	events := make(eventStorageChan, 3)
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	// The streamer returns on the first run and panics on the second one, the third run keeps streaming
+	runs := make(chan int, 3)
+	stop := make(chan struct{})
+	defer close(stop)
+	run := 0
+	go s.superviseStreamer(func() {
+		run++
+		runs <- run
+		switch run {
+		case 1:
+			return
+		case 2:
+			panic("streamer failure")
+		default:
+			<-stop
+		}
+	})
+
+	for expectedRun := 1; expectedRun <= 3; expectedRun++ {
+		select {
+		case r := <-runs:
+			assert.Equal(t, expectedRun, r)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("streamer not restarted, run %d", expectedRun)
+		}
+	}
+
+	for _, reason := range []string{"returned", "panic: streamer failure"} {
+		e := <-events
+		assert.Equal(t, event.EventID_DataStreamerRestart, e.EventID)
+		assert.Equal(t, event.Level_Critical, e.Level)
+		assert.Contains(t, e.Description, reason)
+	}
+	assert.Empty(t, events)
+}
+
+func TestSequencer_superviseStreamer_MaxRestarts(t *testing.T) {
+	cfg := Config{StreamServer: StreamServerCfg{MaxRestarts: 1, RestartBackoff: cfgTypes.NewDuration(time.Millisecond)}}
+	s, _, _ := newTestSequencer(t, cfg)
+	events := make(eventStorageChan, 2)
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	// The streamer always returns, it's restarted once and then the supervisor gives up
+	runs := 0
+	s.superviseStreamer(func() { runs++ })
+	assert.Equal(t, 2, runs)
+
+	require.Len(t, events, 2)
+	<-events
+	e := <-events
+	assert.Contains(t, e.Description, "max restarts (1) reached")
+}
@@ -0,0 +1,93 @@
+package sequencer
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// StreamTypeSequencerDebug represents the Sequencer debug stream
+	StreamTypeSequencerDebug datastreamer.StreamType = 2
+	// DebugEntryTypeTxDropped represents a tx dropped by the sequencer, the data is a JSON encoded DropRecord
+	DebugEntryTypeTxDropped datastreamer.EntryType = 1
+	// DebugEntryTypeTxReplaced represents a tx in the worker replaced by a new tx, the data is a JSON encoded ReplacementRecord
+	DebugEntryTypeTxReplaced datastreamer.EntryType = 2
+	// DebugEntryTypeIntermediateStateRoot represents the intermediate state root of a streamed L2 block, the data is a
+	// JSON encoded DebugIntermediateStateRoot
+	DebugEntryTypeIntermediateStateRoot datastreamer.EntryType = 3
+	// DebugEntryTypeFinalizerHaltState represents a change of the halt state of the finalizer, the data is a JSON encoded FinalizerHaltState
+	DebugEntryTypeFinalizerHaltState datastreamer.EntryType = 4
+)
+
+// DebugIntermediateStateRoot is the intermediate state root of a L2 block sent to the debug stream
+type DebugIntermediateStateRoot struct {
+	L2BlockNumber uint64
+	StateRoot     common.Hash
+}
+
+// debugStream sends the debug entries to the debug stream server. Each entry is added in its own atomic op
+type debugStream struct {
+	streamServer dataStreamServer
+	mutex        sync.Mutex
+}
+
+// newDebugStream creates a new debugStream
+func newDebugStream(streamServer dataStreamServer) *debugStream {
+	return &debugStream{streamServer: streamServer}
+}
+
+// addEntry adds an entry with the given data JSON encoded to the debug stream. If the debug stream is disabled (nil) it does nothing
+func (d *debugStream) addEntry(entryType datastreamer.EntryType, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Errorf("failed to encode debug stream entry, error: %w", err)
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	err = d.streamServer.StartAtomicOp()
+	if err != nil {
+		log.Errorf("failed to start debug stream atomic op, error: %w", err)
+		return
+	}
+
+	_, err = d.streamServer.AddStreamEntry(entryType, encoded)
+	if err != nil {
+		log.Errorf("failed to add debug stream entry, error: %w", err)
+		if err := d.streamServer.RollbackAtomicOp(); err != nil {
+			log.Errorf("failed to rollback debug stream atomic op, error: %w", err)
+		}
+		return
+	}
+
+	err = d.streamServer.CommitAtomicOp()
+	if err != nil {
+		log.Errorf("failed to commit debug stream atomic op, error: %w", err)
+	}
+}
+
+// setupDebugStream creates and starts the debug stream server. The debug stream is optional, if it can't be started
+// the sequencer keeps running without it
+func (s *Sequencer) setupDebugStream() {
+	initStreamServerLog(s.cfg.DebugStreamServer.Log)
+	streamServer, err := datastreamer.NewServer(s.cfg.DebugStreamServer.Port, StreamTypeSequencerDebug, s.cfg.DebugStreamServer.Filename, nil)
+	if err == nil {
+		err = streamServer.Start()
+	}
+	if err != nil {
+		log.Errorf("debug stream disabled, failed to start debug stream server, error: %w", err)
+		return
+	}
+
+	s.debugStream = newDebugStream(streamServer)
+}
@@ -0,0 +1,62 @@
+package sequencer
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugStream_EntriesOnlyInDebugStream(t *testing.T) {
+	stMock := NewStateMock(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+
+	s, _, _ := newTestSequencer(t, Config{})
+	streamServer := newTestStreamServer(t)
+	debugStreamServer := newTestStreamServer(t)
+	s.debugStream = newDebugStream(debugStreamServer)
+	p := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+	p.debugStream = s.debugStream
+
+	// The dropped tx is only sent to the debug stream
+	txHash := common.HexToHash("0x1")
+	s.recordDrop(txHash, DropPhaseExpire, ErrExpiredTransaction.Error())
+	assert.Equal(t, uint64(0), streamServer.GetHeader().TotalEntries)
+	require.Equal(t, uint64(1), debugStreamServer.GetHeader().TotalEntries)
+
+	entry, err := debugStreamServer.GetEntry(0)
+	require.NoError(t, err)
+	assert.Equal(t, DebugEntryTypeTxDropped, entry.Type)
+	var record DropRecord
+	require.NoError(t, json.Unmarshal(entry.Data, &record))
+	assert.Equal(t, txHash, record.Hash)
+	assert.Equal(t, DropPhaseExpire, record.Phase)
+
+	// The format of the main stream is not affected, the intermediate state root of the tx is sent to the debug stream
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1)}))
+	// batch bookmark + block bookmark + block start + 1 tx + block end
+	assert.Equal(t, uint64(5), streamServer.GetHeader().TotalEntries)
+	require.Equal(t, uint64(2), debugStreamServer.GetHeader().TotalEntries)
+
+	entry, err = debugStreamServer.GetEntry(1)
+	require.NoError(t, err)
+	assert.Equal(t, DebugEntryTypeIntermediateStateRoot, entry.Type)
+	var imStateRoot DebugIntermediateStateRoot
+	require.NoError(t, json.Unmarshal(entry.Data, &imStateRoot))
+	assert.Equal(t, DebugIntermediateStateRoot{L2BlockNumber: 1, StateRoot: common.BigToHash(big.NewInt(1))}, imStateRoot)
+}
+
+func TestDebugStream_Disabled(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+
+	// Without debug stream the entries are discarded
+	assert.Nil(t, s.debugStream)
+	assert.NotPanics(t, func() {
+		s.recordDrop(common.HexToHash("0x1"), DropPhaseAdd, "reason")
+	})
+}
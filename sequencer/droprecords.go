@@ -0,0 +1,116 @@
+package sequencer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DropPhase is the phase of the sequencer in which a tx was dropped
+type DropPhase string
+
+const (
+	// DropPhaseAdd is the phase in which the pool txs are added to the worker
+	DropPhaseAdd DropPhase = "add"
+	// DropPhaseReplace is used when a tx in the worker is replaced by a new tx with the same nonce
+	DropPhaseReplace DropPhase = "replace"
+	// DropPhaseExpire is used when a tx is expired in the worker because it exceeded TxLifetimeMax or its inclusion deadline
+	DropPhaseExpire DropPhase = "expire"
+	// DropPhaseReconcile is used when a stale pending tx is set as failed by the startup reconciliation
+	DropPhaseReconcile DropPhase = "reconcile"
+)
+
+// DropRecord is the record of a decision of the sequencer that dropped a tx
+type DropRecord struct {
+	Hash      common.Hash
+	Reason    string
+	Phase     DropPhase
+	Timestamp time.Time
+}
+
+// dropEventsThrottle limits the number of drop events logged per second
+type dropEventsThrottle struct {
+	maxPerSecond uint64
+	windowStart  time.Time
+	count        uint64
+	mutex        sync.Mutex
+}
+
+// newDropEventsThrottle creates a new dropEventsThrottle that allows up to maxPerSecond events per second. If it's 0 there is no limit
+func newDropEventsThrottle(maxPerSecond uint64) *dropEventsThrottle {
+	return &dropEventsThrottle{maxPerSecond: maxPerSecond}
+}
+
+// allow returns true if a new event can be logged in the current second
+func (d *dropEventsThrottle) allow() bool {
+	if d.maxPerSecond == 0 {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if time.Since(d.windowStart) >= time.Second {
+		d.windowStart = time.Now()
+		d.count = 0
+	}
+
+	if d.count >= d.maxPerSecond {
+		return false
+	}
+	d.count++
+	return true
+}
+
+// dropRecords is a fixed size ring buffer with the most recent drop records
+type dropRecords struct {
+	records []DropRecord
+	next    int
+	byHash  map[common.Hash]int
+	mutex   sync.Mutex
+}
+
+// newDropRecords creates a new dropRecords that keeps up to size records. If size is 0 no records are kept
+func newDropRecords(size uint64) *dropRecords {
+	return &dropRecords{
+		records: make([]DropRecord, 0, size),
+		byHash:  make(map[common.Hash]int),
+	}
+}
+
+// add adds a record, evicting the oldest one if the buffer is full
+func (d *dropRecords) add(record DropRecord) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if cap(d.records) == 0 {
+		return
+	}
+
+	if len(d.records) < cap(d.records) {
+		d.records = append(d.records, record)
+	} else {
+		evicted := d.records[d.next]
+		if index, found := d.byHash[evicted.Hash]; found && index == d.next {
+			delete(d.byHash, evicted.Hash)
+		}
+		d.records[d.next] = record
+	}
+
+	d.byHash[record.Hash] = d.next
+	d.next = (d.next + 1) % cap(d.records)
+}
+
+// get returns the most recent record of the tx hash
+func (d *dropRecords) get(hash common.Hash) (DropRecord, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	index, found := d.byHash[hash]
+	if !found {
+		return DropRecord{}, false
+	}
+
+	return d.records[index], true
+}
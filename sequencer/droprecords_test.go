@@ -0,0 +1,44 @@
+package sequencer
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropRecords(t *testing.T) {
+	d := newDropRecords(3)
+
+	for i := int64(1); i <= 4; i++ {
+		d.add(DropRecord{Hash: common.BigToHash(big.NewInt(i)), Reason: "reason", Phase: DropPhaseAdd, Timestamp: time.Now()})
+	}
+
+	// The first record has been evicted
+	_, found := d.get(common.BigToHash(big.NewInt(1)))
+	assert.False(t, found)
+	for i := int64(2); i <= 4; i++ {
+		record, found := d.get(common.BigToHash(big.NewInt(i)))
+		require.True(t, found)
+		assert.Equal(t, common.BigToHash(big.NewInt(i)), record.Hash)
+	}
+
+	// A new record of the same hash replaces the previous one and it's not removed when the old one is evicted
+	hash := common.BigToHash(big.NewInt(2))
+	d.add(DropRecord{Hash: hash, Reason: "replaced", Phase: DropPhaseReplace, Timestamp: time.Now()})
+	d.add(DropRecord{Hash: common.HexToHash("0x5"), Reason: "reason", Phase: DropPhaseAdd, Timestamp: time.Now()})
+	record, found := d.get(hash)
+	require.True(t, found)
+	assert.Equal(t, DropPhaseReplace, record.Phase)
+	assert.Equal(t, "replaced", record.Reason)
+}
+
+func TestDropRecords_ZeroSize(t *testing.T) {
+	d := newDropRecords(0)
+	d.add(DropRecord{Hash: common.HexToHash("0x1")})
+	_, found := d.get(common.HexToHash("0x1"))
+	assert.False(t, found)
+}
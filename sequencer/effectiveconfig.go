@@ -0,0 +1,56 @@
+package sequencer
+
+import (
+	"slices"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// redactedValue replaces the secrets of the config when it's logged
+const redactedValue = "<redacted>"
+
+// EffectiveConfig returns the config the sequencer is running with, once the defaults of the empty values and the clamps
+// are applied, e.g. DeletePoolTxsL1BlockConfirmations clamped up to MinDeleteConfirmations or the size of the channel of
+// L2 blocks to stream when ChannelBufferSize is 0
+func (s *Sequencer) EffectiveConfig() Config {
+	cfg := s.cfg
+	cfg.StreamServer.BlockStartExcludedFields = slices.Clone(s.cfg.StreamServer.BlockStartExcludedFields)
+
+	if cfg.Mode == "" {
+		cfg.Mode = ModeActive
+	}
+	if cfg.TxTrackerErrorPolicy == "" {
+		cfg.TxTrackerErrorPolicy = TxTrackerErrorPolicyFail
+	}
+	cfg.DeletePoolTxsL1BlockConfirmations = max(cfg.DeletePoolTxsL1BlockConfirmations, cfg.MinDeleteConfirmations)
+	if cfg.SenderRateLimit > 0 {
+		cfg.SenderRateLimitBurst = max(cfg.SenderRateLimitBurst, 1)
+	}
+
+	if s.dataToStream != nil {
+		cfg.StreamServer.ChannelBufferSize = uint64(cap(s.dataToStream))
+	}
+	if cfg.StreamServer.Encoding == "" {
+		cfg.StreamServer.Encoding = StreamEncodingBinary
+	}
+	if cfg.StreamServer.PauseBufferFullPolicy == "" {
+		cfg.StreamServer.PauseBufferFullPolicy = PauseBufferFullPolicyBlock
+	}
+	if cfg.StreamServer.TimestampSkewPolicy == "" {
+		cfg.StreamServer.TimestampSkewPolicy = TimestampSkewPolicyClamp
+	}
+	cfg.StreamServer.BlocksPerAtomicOp = max(cfg.StreamServer.BlocksPerAtomicOp, 1)
+	cfg.StreamServer.PipelineBufferSize = max(cfg.StreamServer.PipelineBufferSize, 1)
+	cfg.StreamServer.Archive.QueueSize = max(cfg.StreamServer.Archive.QueueSize, 1)
+
+	return cfg
+}
+
+// logEffectiveConfig logs the effective config of the sequencer, without its secrets
+func (s *Sequencer) logEffectiveConfig() {
+	cfg := s.EffectiveConfig()
+	if cfg.StreamServer.Archive.SecretAccessKey != "" {
+		cfg.StreamServer.Archive.SecretAccessKey = redactedValue
+	}
+	log.Infof("effective sequencer config: %+v", cfg)
+}
@@ -0,0 +1,46 @@
+package sequencer
+
+import (
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_EffectiveConfig(t *testing.T) {
+	cfg := Config{
+		DeletePoolTxsL1BlockConfirmations: 5,
+		MinDeleteConfirmations:            64,
+		SenderRateLimit:                   10,
+		StreamServer: StreamServerCfg{
+			BlockStartExcludedFields: []string{BlockStartFieldCoinbase},
+			Archive:                  ArchiveCfg{SecretAccessKey: "secret"},
+		},
+	}
+	ethermanMock := NewEthermanMock(t)
+	ethermanMock.On("TrustedSequencer").Return(common.Address{}, nil)
+	s, err := New(cfg, state.BatchConfig{Constraints: bc}, pool.Config{}, nil, nil, ethermanMock, nil)
+	require.NoError(t, err)
+
+	effective := s.EffectiveConfig()
+
+	// The clamped and defaulted values
+	assert.Equal(t, uint64(64), effective.DeletePoolTxsL1BlockConfirmations)
+	assert.Equal(t, uint64(1), effective.SenderRateLimitBurst)
+	assert.Equal(t, ModeActive, effective.Mode)
+	assert.Equal(t, TxTrackerErrorPolicyFail, effective.TxTrackerErrorPolicy)
+	assert.Equal(t, bc.MaxTxsPerBatch*datastreamChannelMultiplier, effective.StreamServer.ChannelBufferSize)
+	assert.Equal(t, StreamEncodingBinary, effective.StreamServer.Encoding)
+	assert.Equal(t, PauseBufferFullPolicyBlock, effective.StreamServer.PauseBufferFullPolicy)
+	assert.Equal(t, TimestampSkewPolicyClamp, effective.StreamServer.TimestampSkewPolicy)
+	assert.Equal(t, uint64(1), effective.StreamServer.BlocksPerAtomicOp)
+
+	// The values set are kept, and the config of the sequencer is not modified
+	assert.Equal(t, uint64(64), effective.MinDeleteConfirmations)
+	assert.Equal(t, "secret", effective.StreamServer.Archive.SecretAccessKey)
+	effective.StreamServer.BlockStartExcludedFields[0] = BlockStartFieldForkID
+	assert.Equal(t, cfg, s.cfg)
+}
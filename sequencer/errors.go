@@ -1,6 +1,9 @@
 package sequencer
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrExpiredTransaction happens when the transaction is expired
@@ -29,4 +32,65 @@ var (
 	ErrNoFittingTransaction = errors.New("no fit transaction")
 	// ErrTransactionsListEmpty happens when txSortedList is empty
 	ErrTransactionsListEmpty = errors.New("transactions list empty")
+	// ErrWorkerFull happens when a tx is rejected because the worker has reached its max number of txs
+	ErrWorkerFull = errors.New("worker is full")
+	// ErrWorkerBytesLimit happens when a tx is rejected because adding it would exceed the max number of bytes held by the worker
+	ErrWorkerBytesLimit = errors.New("worker bytes limit exceeded")
+	// ErrGasLimitAboveCeiling happens when a tx is rejected because its gas limit is higher than the max accepted gas limit
+	ErrGasLimitAboveCeiling = errors.New("gas limit above the max accepted gas limit")
+	// ErrTxExceedsBatchConstraints happens when a tx is rejected because its ZK counters exceed the batch constraints, so it can't fit in any batch
+	ErrTxExceedsBatchConstraints = errors.New("tx exceeds the batch constraints")
+	// ErrSenderRateLimited happens when a pool tx is left pending because its sender exceeded SenderRateLimit
+	ErrSenderRateLimited = errors.New("sender rate limit exceeded")
+	// ErrTxDeferred happens when a pool tx that is not high priority is left pending because the wip batch is above AdmissionBatchFullnessThreshold
+	ErrTxDeferred = errors.New("tx deferred, wip batch above the admission fullness threshold")
+	// ErrStreamServerNotReady happens when the data stream server doesn't accept connections within ReadyTimeout after being started
+	ErrStreamServerNotReady = errors.New("stream server not ready")
+	// ErrStreamingDisabled happens when trying to pause, resume or read the streaming and the data stream server is not enabled
+	ErrStreamingDisabled = errors.New("streaming is disabled")
+	// ErrStreamFileRegenerating happens when trying to swap the data stream file while it's updated in the background
+	ErrStreamFileRegenerating = errors.New("data stream file is being regenerated")
+	// ErrStreamFileHeadMismatch happens when swapping the data stream file and the new file doesn't end with the last L2 block of the current one
+	ErrStreamFileHeadMismatch = errors.New("data stream file head mismatch")
+	// ErrInvalidStreamHeadEntries happens when the number of entries of the data stream head to fingerprint is not greater than 0
+	ErrInvalidStreamHeadEntries = errors.New("invalid number of data stream head entries, it must be greater than 0")
+	// ErrNotSynced happens when the sequencer declines an operation because the state is not synced with L1
+	ErrNotSynced = errors.New("sequencer not synced")
+	// ErrStateInconsistencyNotCleared happens when trying to resume the finalizer and the state inconsistency that halted it is still detected.
+	// It's returned wrapped in a StateInconsistencyNotClearedError
+	ErrStateInconsistencyNotCleared = errors.New("state inconsistency not cleared")
+	// ErrTooFarAheadOfL1 happens when the finalizer is halted because the last trusted batch is more than MaxBatchesAheadOfL1 batches ahead of the last virtual batch
+	ErrTooFarAheadOfL1 = errors.New("sequencer too far ahead of L1")
+	// ErrStaleNonce happens when the nonce of a pending tx is lower than the nonce of its sender in the state
+	ErrStaleNonce = errors.New("nonce lower than the sender nonce in the state")
+	// ErrSequencerPaused happens when trying to load txs from the pool on demand while the sequencer is paused
+	ErrSequencerPaused = errors.New("sequencer is paused")
+	// ErrInvalidBlockStartField happens when a field of BlockStartExcludedFields is not a field of the L2 block start entries
+	ErrInvalidBlockStartField = errors.New("invalid l2 block start field")
+	// ErrIncompatibleStreamSchema happens when the schema version declared by the data stream file is not the one written by the running build
+	ErrIncompatibleStreamSchema = errors.New("incompatible data stream schema version")
+	// ErrUnknownLoop happens when trying to enable or disable a sequencer loop that doesn't exist
+	ErrUnknownLoop = errors.New("unknown sequencer loop")
+	// ErrInvalidConfig happens when the config has an invalid combination of values (see Config.Validate)
+	ErrInvalidConfig = errors.New("invalid sequencer config")
+	// ErrInvalidStreamChannelBufferSize happens when the size of the channel buffer of the L2 blocks sent to the data stream is 0
+	ErrInvalidStreamChannelBufferSize = errors.New("invalid data stream channel buffer size, it must be greater than 0")
 )
+
+// StateInconsistencyNotClearedError happens when trying to resume the finalizer with ResumeFinalizer and the state inconsistency that
+// halted it is still detected. The finalizer can be resumed anyway with ForceResumeFinalizer
+type StateInconsistencyNotClearedError struct {
+	// Detected is the number of state inconsistencies detected and Accepted the number of them accepted before the halt
+	Detected uint64
+	Accepted uint64
+}
+
+// Error returns the error message
+func (e *StateInconsistencyNotClearedError) Error() string {
+	return fmt.Sprintf("%s, %d state inconsistencies detected, %d accepted", ErrStateInconsistencyNotCleared, e.Detected, e.Accepted)
+}
+
+// Unwrap returns ErrStateInconsistencyNotCleared
+func (e *StateInconsistencyNotClearedError) Unwrap() error {
+	return ErrStateInconsistencyNotCleared
+}
@@ -0,0 +1,83 @@
+package sequencer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+)
+
+const (
+	// eventLogTimeout is the max time waited for an event to be stored in the event log
+	eventLogTimeout = time.Second
+)
+
+// eventLevelSeverities are the severities of the event levels, from the least to the most severe
+var eventLevelSeverities = map[event.Level]int32{
+	event.Level_Debug:     0,
+	event.Level_Info:      1,
+	event.Level_Notice:    2,
+	event.Level_Warning:   3,
+	event.Level_Error:     4,
+	event.Level_Critical:  5,
+	event.Level_Alert:     6,
+	event.Level_Emergency: 7,
+}
+
+// eventLogMinSeverity is the severity of EventLogMinLevel, the events less severe are not stored by logEvent
+var eventLogMinSeverity atomic.Int32
+
+// setEventLogMinLevel sets the min level of the events stored by logEvent. If it's empty all the events are stored
+func setEventLogMinLevel(level string) {
+	eventLogMinSeverity.Store(eventLevelSeverities[event.Level(level)])
+}
+
+// eventLevelFiltered returns true if the level is less severe than the min level of the events stored. The events with
+// an unknown level are never filtered
+func eventLevelFiltered(level event.Level) bool {
+	severity, ok := eventLevelSeverities[level]
+	return ok && severity < eventLogMinSeverity.Load()
+}
+
+// logEvent stores an event in the event log. If it fails to be stored (error, panic or timeout) the failure is logged and
+// counted in the event log failures metric, but it's not returned. The caller waits at most eventLogTimeout, so an
+// unavailable event log doesn't block the sequencer loops.
+// The events less severe than EventLogMinLevel are discarded
+func logEvent(ctx context.Context, eventLog *event.EventLog, e *event.Event) {
+	if eventLog == nil {
+		return
+	}
+	if eventLevelFiltered(e.Level) {
+		log.Debugf("event %s not stored, its level %s is below EventLogMinLevel", e.EventID, e.Level)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, eventLogTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic storing event: %v", r)
+			}
+		}()
+		done <- eventLog.LogEvent(ctx, e)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		log.Errorf("error storing event %s, error: %w", e.EventID, err)
+		metrics.EventLogFailure()
+	}
+}
@@ -0,0 +1,83 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	zkmetrics "github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingEventStorage is an event storage that fails storing the events with the given function
+type failingEventStorage func(ctx context.Context) error
+
+func (f failingEventStorage) LogEvent(ctx context.Context, event *event.Event) error {
+	return f(ctx)
+}
+
+func TestSequencer_loadPoolTxs_EventLogUnavailable(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	failuresCounter, ok := zkmetrics.Counter(metrics.EventLogFailuresName)
+	require.True(t, ok)
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	for _, tc := range []struct {
+		name    string
+		storage failingEventStorage
+	}{
+		{"error", func(ctx context.Context) error { return errors.New("event log down") }},
+		{"panic", func(ctx context.Context) error { panic("event log down") }},
+		{"blocked", func(ctx context.Context) error { <-unblock; return nil }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, txPoolMock, stMock := newTestSequencer(t, Config{MaxAcceptedGasLimit: 30000, LogDropsToEventLog: true})
+			s.eventLog = event.NewEventLog(event.Config{}, tc.storage)
+			mockTestSenderAccount(t, stMock, 0)
+
+			// The drop of tx1 fails to be logged, tx2 must be added to the worker anyway
+			tx1 := newTestPoolTx(t, 0, 30001)
+			tx2 := newTestPoolTx(t, 0, 21000)
+			failedReason := ErrGasLimitAboveCeiling.Error() + ", tx gas limit: 30001, max accepted gas limit: 30000"
+			txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx2}, nil).Once()
+			txPoolMock.On("UpdateTxStatus", ctx, tx1.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+			txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+
+			failuresBefore := testutil.ToFloat64(failuresCounter)
+			start := time.Now()
+			loaded, err := s.loadPoolTxs(ctx)
+			require.NoError(t, err)
+			assert.Less(t, time.Since(start), 2*eventLogTimeout)
+			assert.Equal(t, 1, loaded)
+			assert.Equal(t, 1, s.worker.CountTxs())
+			assert.Equal(t, failuresBefore+1, testutil.ToFloat64(failuresCounter))
+		})
+	}
+}
+
+func TestLogEvent_EventLogMinLevel(t *testing.T) {
+	setEventLogMinLevel(string(event.Level_Warning))
+	defer setEventLogMinLevel("")
+
+	events := make(eventStorageChan, 4)
+	eventLog := event.NewEventLog(event.Config{}, events)
+	for _, level := range []event.Level{event.Level_Info, event.Level_Debug, event.Level_Warning, event.Level_Critical} {
+		logEvent(context.Background(), eventLog, &event.Event{EventID: event.EventID_SequencerLoopRestart, Level: level})
+	}
+
+	// The info and debug events are discarded
+	require.Len(t, events, 2)
+	assert.Equal(t, event.Level_Warning, (<-events).Level)
+	assert.Equal(t, event.Level_Critical, (<-events).Level)
+}
@@ -57,6 +57,9 @@ type finalizer struct {
 	wipL2Block       *L2Block
 	batchConstraints state.BatchConstraintsCfg
 	haltFinalizer    atomic.Bool
+	haltRequested    atomic.Bool
+	// resumeCh wakes up the finalizer waiting for the halt requested by the sequencer to be resumed
+	resumeCh chan struct{}
 	// forced batches
 	nextForcedBatches       []state.ForcedBatch
 	nextForcedBatchDeadline int64
@@ -86,6 +89,13 @@ type finalizer struct {
 	// stream server
 	streamServer *datastreamer.StreamServer
 	dataToStream chan state.DSL2FullBlock
+	// traceStorageChanges makes the txs to be processed with the storage trace, to stream the storage changes of the L2 blocks
+	traceStorageChanges bool
+	// streamBlockLogs makes the logs of the L2 blocks to be sent to the data stream, to stream their bloom filters
+	streamBlockLogs bool
+	// wip batch usage, updated by the finalizeBatches loop to be read from other goroutines
+	wipBatchUsage    BatchUsage
+	wipBatchUsageMux sync.Mutex
 }
 
 // newFinalizer returns a new instance of Finalizer.
@@ -139,9 +149,12 @@ func newFinalizer(
 		// stream server
 		streamServer: streamServer,
 		dataToStream: dataToStream,
+		// halt requested by the sequencer
+		resumeCh: make(chan struct{}, 1),
 	}
 
 	f.haltFinalizer.Store(false)
+	f.haltRequested.Store(false)
 
 	return &f
 }
@@ -162,6 +175,8 @@ func (f *finalizer) Start(ctx context.Context) {
 	// Initializes the wip L2 block
 	f.initWIPL2Block(ctx)
 
+	f.updateWIPBatchUsage()
+
 	// Update the prover id and flush id
 	go f.updateProverIdAndFlushId(ctx)
 
@@ -291,6 +306,8 @@ func (f *finalizer) finalizeBatches(ctx context.Context) {
 
 			firstTxProcess := true
 
+			// The tx is not expired while it's processed, it's deleted from the worker once it's included in the L2 block
+			f.workerIntf.SetTxInFlight(tx.Hash, tx.From, true)
 			for {
 				_, err := f.processTransaction(ctx, tx, firstTxProcess)
 				if err != nil {
@@ -305,6 +322,7 @@ func (f *finalizer) finalizeBatches(ctx context.Context) {
 				}
 				break
 			}
+			f.workerIntf.SetTxInFlight(tx.Hash, tx.From, false)
 		} else {
 			// wait for new txs
 			if showNotFoundTxLog {
@@ -323,12 +341,20 @@ func (f *finalizer) finalizeBatches(ctx context.Context) {
 			}
 		}
 
+		// The halt was requested by the sequencer, we stop processing new txs until it's resumed
+		if !f.waitResume(ctx) {
+			log.Infof("stopping finalizer halted by the sequencer because of context, error: %w", ctx.Err())
+			return
+		}
+
 		if f.isDeadlineEncountered() {
 			f.finalizeBatch(ctx)
 		} else if f.maxTxsPerBatchReached() || f.isBatchResourcesExhausted() {
 			f.finalizeBatch(ctx)
 		}
 
+		f.updateWIPBatchUsage()
+
 		if err := ctx.Err(); err != nil {
 			log.Infof("stopping finalizer because of context, error: %w", err)
 			return
@@ -374,6 +400,9 @@ func (f *finalizer) processTransaction(ctx context.Context, tx *TxTracker, first
 	if tx != nil {
 		executorBatchRequest.Transactions = append(executorBatchRequest.Transactions, tx.RawTx...)
 		hashStr = tx.HashStr
+		if f.traceStorageChanges {
+			executorBatchRequest.StorageTraceTxHash_V2 = &tx.Hash
+		}
 
 		txGasPrice := tx.GasPrice
 
@@ -471,7 +500,7 @@ func (f *finalizer) processTransaction(ctx context.Context, tx *TxTracker, first
 		if err != nil {
 			log.Errorf("failed to update status to invalid in the pool for tx %s, error: %w", tx.Hash.String(), err)
 		} else {
-			metrics.TxProcessed(metrics.TxProcessedLabelInvalid, 1)
+			metrics.TxProcessed(metrics.TxProcessedLabelInvalid, executorBatchRequest.ForkID, 1)
 		}
 		return nil, err
 	}
@@ -563,6 +592,10 @@ func (f *finalizer) handleProcessTransactionResponse(ctx context.Context, tx *Tx
 		tx.EGPLog.ValueFinal, tx.EGPLog.ValueFirst, tx.EGPLog.ValueSecond, tx.EGPLog.Percentage, tx.EGPLog.FinalDeviation, tx.EGPLog.MaxDeviation, tx.EGPLog.GasUsedFirst, tx.EGPLog.GasUsedSecond,
 		tx.EGPLog.GasPrice, tx.EGPLog.L1GasPrice, tx.EGPLog.L2GasPrice, tx.EGPLog.Reprocess, tx.EGPLog.GasPriceOC, tx.EGPLog.BalanceOC, egpEnabled, len(tx.RawTx), tx.HashStr, tx.EGPLog.Error)
 
+	if f.traceStorageChanges {
+		tx.StorageChanges = state.NewDSStorageChanges(result.BlockResponses[0].TransactionResponses[0])
+	}
+
 	f.wipL2Block.addTx(tx)
 
 	f.wipBatch.countOfTxs++
@@ -635,7 +668,7 @@ func (f *finalizer) updateWorkerAfterSuccessfulProcessing(ctx context.Context, t
 			log.Errorf("failed to update status to failed in the pool for tx %s, error: %w", txToDelete.Hash.String(), err)
 			continue
 		}
-		metrics.TxProcessed(metrics.TxProcessedLabelFailed, 1)
+		metrics.TxProcessed(metrics.TxProcessedLabelFailed, result.ForkID, 1)
 	}
 	metrics.WorkerProcessingTime(time.Since(start))
 }
@@ -661,7 +694,7 @@ func (f *finalizer) handleProcessTransactionError(ctx context.Context, result *s
 			if err != nil {
 				log.Errorf("failed to update status to invalid in the pool for tx %s, error: %w", tx.HashStr, err)
 			} else {
-				metrics.TxProcessed(metrics.TxProcessedLabelInvalid, 1)
+				metrics.TxProcessed(metrics.TxProcessedLabelInvalid, result.ForkID, 1)
 			}
 		}()
 	} else if executor.IsInvalidNonceError(errorCode) || executor.IsInvalidBalanceError(errorCode) {
@@ -682,7 +715,7 @@ func (f *finalizer) handleProcessTransactionError(ctx context.Context, result *s
 			go func() {
 				defer wg.Done()
 				err := f.poolIntf.UpdateTxStatus(ctx, txToDelete.Hash, pool.TxStatusFailed, false, &failedReason)
-				metrics.TxProcessed(metrics.TxProcessedLabelFailed, 1)
+				metrics.TxProcessed(metrics.TxProcessedLabelFailed, result.ForkID, 1)
 				if err != nil {
 					log.Errorf("failed to update status to failed in the pool for tx %s, error: %w", txToDelete.Hash.String(), err)
 				}
@@ -702,7 +735,7 @@ func (f *finalizer) handleProcessTransactionError(ctx context.Context, result *s
 			if err != nil {
 				log.Errorf("failed to update status to failed in the pool for tx %s, error: %w", tx.Hash.String(), err)
 			} else {
-				metrics.TxProcessed(metrics.TxProcessedLabelFailed, 1)
+				metrics.TxProcessed(metrics.TxProcessedLabelFailed, result.ForkID, 1)
 			}
 		}()
 	}
@@ -738,16 +771,13 @@ func (f *finalizer) checkIfProverRestarted(proverID string) {
 			Description: fmt.Sprintf("proverID changed from %s to %s, restarting sequencer to discard current WIP batch and work with new executor", f.proverID, proverID),
 		}
 
-		err := f.eventLog.LogEvent(context.Background(), event)
-		if err != nil {
-			log.Errorf("error storing payload, error: %w", err)
-		}
+		logEvent(context.Background(), f.eventLog, event)
 
 		log.Fatal("proverID changed from %s to %s, restarting sequencer to discard current WIP batch and work with new executor")
 	}
 }
 
-// Halt halts the finalizer
+// Halt halts the finalizer due to a fatal error. The finalizer can't be resumed
 func (f *finalizer) Halt(ctx context.Context, err error) {
 	f.haltFinalizer.Store(true)
 
@@ -760,13 +790,55 @@ func (f *finalizer) Halt(ctx context.Context, err error) {
 		Description: fmt.Sprintf("finalizer halted due to error, error: %s", err),
 	}
 
-	eventErr := f.eventLog.LogEvent(ctx, event)
-	if eventErr != nil {
-		log.Errorf("error storing finalizer halt event, error: %w", eventErr)
-	}
+	logEvent(ctx, f.eventLog, event)
 
 	for {
 		log.Errorf("halting finalizer, fatal error: %w", err)
 		time.Sleep(5 * time.Second) //nolint:gomnd
 	}
 }
+
+// RequestHalt halts the finalizer on behalf of the sequencer. The finalizer stops processing new txs until it's resumed with Resume
+func (f *finalizer) RequestHalt(ctx context.Context, err error) {
+	f.haltRequested.Store(true)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Critical,
+		EventID:     event.EventID_FinalizerHalt,
+		Description: fmt.Sprintf("finalizer halted by the sequencer, reason: %s", err),
+	}
+
+	// The sequencer requests the halt holding its halt mutex, the event is stored without blocking it
+	go logEvent(ctx, f.eventLog, event)
+
+	log.Errorf("halting finalizer, reason: %v", err)
+}
+
+// waitResume waits until the halt requested by the sequencer, if any, is resumed. It returns false if ctx is done meanwhile
+func (f *finalizer) waitResume(ctx context.Context) bool {
+	for f.haltRequested.Load() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-f.resumeCh:
+		}
+	}
+	return true
+}
+
+// Resume resumes the finalizer halted by RequestHalt. A finalizer halted by Halt due to a fatal error is not resumed
+func (f *finalizer) Resume(ctx context.Context) {
+	f.haltRequested.Store(false)
+	select {
+	case f.resumeCh <- struct{}{}:
+	default:
+	}
+	if f.haltFinalizer.Load() {
+		log.Warn("finalizer not resumed, it's halted due to a fatal error")
+		return
+	}
+	log.Info("finalizer resumed")
+}
@@ -2088,6 +2088,70 @@ func TestFinalizer_getConstraintThresholdUint64(t *testing.T) {
 	assert.Equal(t, result, expect)
 }
 
+func TestFinalizer_RequestHaltAndResume(t *testing.T) {
+	// arrange
+	f = setupFinalizer(false)
+
+	// act
+	f.RequestHalt(context.Background(), errors.New("halt reason"))
+
+	// assert
+	assert.True(t, f.haltRequested.Load())
+
+	// act
+	f.Resume(context.Background())
+
+	// assert
+	assert.False(t, f.haltRequested.Load())
+
+	// arrange
+	f.haltFinalizer.Store(true)
+	f.RequestHalt(context.Background(), errors.New("halt reason"))
+
+	// act
+	f.Resume(context.Background())
+
+	// assert: the fatal halt is not cleared by Resume
+	assert.False(t, f.haltRequested.Load())
+	assert.True(t, f.haltFinalizer.Load())
+}
+
+func TestFinalizer_waitResume(t *testing.T) {
+	// arrange
+	f = setupFinalizer(false)
+	f.RequestHalt(context.Background(), errors.New("halt reason"))
+	resumed := make(chan bool)
+
+	// act
+	go func() { resumed <- f.waitResume(context.Background()) }()
+	f.Resume(context.Background())
+
+	// assert: the finalizer is woken up as soon as it's resumed
+	select {
+	case ok := <-resumed:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("finalizer not resumed")
+	}
+
+	// arrange
+	f.RequestHalt(context.Background(), errors.New("halt reason"))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// act
+	go func() { resumed <- f.waitResume(ctx) }()
+	cancel()
+
+	// assert: the halted finalizer stops once the context is done
+	select {
+	case ok := <-resumed:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("halted finalizer not stopped")
+	}
+	assert.True(t, f.haltRequested.Load())
+}
+
 func TestFinalizer_getConstraintThresholdUint32(t *testing.T) {
 	// arrange
 	f = setupFinalizer(false)
@@ -2237,5 +2301,6 @@ func setupFinalizer(withWipBatch bool) *finalizer {
 		proverID:                   "",
 		lastPendingFlushID:         0,
 		pendingFlushIDCond:         sync.NewCond(new(sync.Mutex)),
+		resumeCh:                   make(chan struct{}, 1),
 	}
 }
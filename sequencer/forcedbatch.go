@@ -135,7 +135,7 @@ func (f *finalizer) processForcedBatch(ctx context.Context, forcedBatch state.Fo
 	}
 
 	if len(batchResponse.BlockResponses) > 0 && !batchResponse.IsRomOOCError {
-		err = f.handleProcessForcedBatchResponse(ctx, batchResponse, dbTx)
+		err = f.handleProcessForcedBatchResponse(ctx, batchResponse, forcedBatch.GlobalExitRoot, dbTx)
 		return rollbackOnError(fmt.Errorf("error when handling batch response for forced batch %d, error: %w", forcedBatch.ForcedBatchNumber, err))
 	}
 
@@ -157,7 +157,7 @@ func (f *finalizer) addForcedTxToWorker(forcedBatchResponse *state.ProcessBatchR
 }
 
 // handleProcessForcedTxsResponse handles the block/transactions responses for the processed forced batch.
-func (f *finalizer) handleProcessForcedBatchResponse(ctx context.Context, batchResponse *state.ProcessBatchResponse, dbTx pgx.Tx) error {
+func (f *finalizer) handleProcessForcedBatchResponse(ctx context.Context, batchResponse *state.ProcessBatchResponse, l1InfoRoot common.Hash, dbTx pgx.Tx) error {
 	f.addForcedTxToWorker(batchResponse)
 
 	f.updateFlushIDs(batchResponse.FlushID, batchResponse.StoredFlushID)
@@ -195,7 +195,7 @@ func (f *finalizer) handleProcessForcedBatchResponse(ctx context.Context, batchR
 		}
 
 		// Send L2 block to data streamer
-		err = f.DSSendL2Block(batchResponse.NewBatchNumber, forcedL2BlockResponse)
+		err = f.DSSendL2Block(batchResponse.NewBatchNumber, forcedL2BlockResponse, l1InfoRoot, nil)
 		if err != nil {
 			//TODO: we need to halt/rollback the L2 block if we had an error sending to the data streamer?
 			log.Errorf("error sending L2 block %d to data streamer, error: %w", forcedL2BlockResponse.BlockNumber, err)
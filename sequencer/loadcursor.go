@@ -0,0 +1,124 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// loadCursorVersion is the version of the format of the load cursor file
+	loadCursorVersion = 1
+)
+
+// loadCursor is the durable record of the pool txs already processed by the load from the pool that were left pending in
+// the pool (e.g. dropped without being able to set them as failed), so they are not processed again after a restart
+type loadCursor struct {
+	Version int `json:"version"`
+	// Txs is the time each tx was processed
+	Txs map[common.Hash]time.Time `json:"txs"`
+}
+
+// readLoadCursor reads the load cursor from the file. If the file doesn't exist the cursor is empty
+func readLoadCursor(path string) (loadCursor, error) {
+	cursor := loadCursor{Version: loadCursorVersion, Txs: map[common.Hash]time.Time{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursor, nil
+	} else if err != nil {
+		return cursor, err
+	}
+
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return cursor, err
+	}
+	if cursor.Version != loadCursorVersion {
+		return cursor, fmt.Errorf("unsupported load cursor version %d", cursor.Version)
+	}
+	return cursor, nil
+}
+
+// writeLoadCursor writes the load cursor to the file. It's written to a temporary file renamed afterwards, so the file
+// is never left half written
+func writeLoadCursor(path string, cursor loadCursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// restoreLoadCursor restores the pool txs already processed from LoadPoolTxsCursorFile, so they are skipped by the load
+// from the pool until LoadPoolTxsDedupTTL. The txs that are not non WIP pending in the pool anymore are discarded
+func (s *Sequencer) restoreLoadCursor(ctx context.Context) {
+	if s.cfg.LoadPoolTxsCursorFile == "" {
+		return
+	}
+
+	cursor, err := readLoadCursor(s.cfg.LoadPoolTxsCursorFile)
+	if err != nil {
+		log.Warnf("failed to read load cursor from %s, all the pending txs will be loaded, error: %w", s.cfg.LoadPoolTxsCursorFile, err)
+		return
+	}
+	if len(cursor.Txs) == 0 {
+		return
+	}
+
+	poolTransactions, err := s.pool.GetNonWIPPendingTxs(ctx)
+	if err != nil && !errors.Is(err, pool.ErrNotFound) {
+		log.Warnf("failed to get pending txs to validate the load cursor, all the pending txs will be loaded, error: %w", err)
+		return
+	}
+
+	pendingTxs := make(map[common.Hash]time.Time)
+	for _, tx := range poolTransactions {
+		if processedAt, found := cursor.Txs[tx.Hash()]; found {
+			pendingTxs[tx.Hash()] = processedAt
+		}
+	}
+	s.recentPoolTxs.restore(pendingTxs)
+	log.Infof("load cursor restored from %s, %d of %d txs still pending", s.cfg.LoadPoolTxsCursorFile, len(pendingTxs), len(cursor.Txs))
+}
+
+// persistLoadCursor writes the pool txs already processed left pending to LoadPoolTxsCursorFile, if they changed
+func (s *Sequencer) persistLoadCursor() {
+	if s.cfg.LoadPoolTxsCursorFile == "" {
+		return
+	}
+
+	txs, changed := s.recentPoolTxs.pendingTxs()
+	if !changed {
+		return
+	}
+
+	err := writeLoadCursor(s.cfg.LoadPoolTxsCursorFile, loadCursor{Version: loadCursorVersion, Txs: txs})
+	if err != nil {
+		log.Errorf("failed to write load cursor to %s, error: %w", s.cfg.LoadPoolTxsCursorFile, err)
+		// It's written again after the next load from the pool
+		s.recentPoolTxs.changed = true
+	}
+}
@@ -0,0 +1,75 @@
+package sequencer
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	zkmetrics "github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_restoreLoadCursor(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	counter, ok := zkmetrics.Counter(metrics.PoolTxsDeduplicatedName)
+	require.True(t, ok)
+
+	// The txs are not signed so they are kept as pending in the pool with the retry policy
+	newUnsignedTx := func(nonce uint64) pool.Transaction {
+		return *pool.NewTransaction(*types.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil), "", false)
+	}
+	tx1, tx2, tx3 := newUnsignedTx(0), newUnsignedTx(1), newUnsignedTx(2)
+
+	ctx := context.Background()
+	cursorFile := filepath.Join(t.TempDir(), "cursor.json")
+	cfg := Config{TxTrackerErrorPolicy: TxTrackerErrorPolicyRetry, LoadPoolTxsDedupTTL: cfgTypes.NewDuration(time.Hour), LoadPoolTxsCursorFile: cursorFile}
+
+	s, txPoolMock, _ := newTestSequencer(t, cfg)
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx2}, nil).Once()
+	s.loadPoolTxs(ctx)
+
+	cursor, err := readLoadCursor(cursorFile)
+	require.NoError(t, err)
+	assert.Len(t, cursor.Txs, 2)
+
+	// After the restart tx2 is not pending anymore, so only tx1 is restored and skipped by the load
+	s, txPoolMock, _ = newTestSequencer(t, cfg)
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1}, nil).Once()
+	s.restoreLoadCursor(ctx)
+	assert.True(t, s.recentPoolTxs.contains(tx1.Hash()))
+	assert.False(t, s.recentPoolTxs.contains(tx2.Hash()))
+
+	deduplicated := testutil.ToFloat64(counter)
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx3}, nil).Once()
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, deduplicated+1, testutil.ToFloat64(counter))
+
+	cursor, err = readLoadCursor(cursorFile)
+	require.NoError(t, err)
+	assert.Len(t, cursor.Txs, 2)
+	assert.Contains(t, cursor.Txs, tx1.Hash())
+	assert.Contains(t, cursor.Txs, tx3.Hash())
+}
+
+func TestSequencer_restoreLoadCursor_InvalidFile(t *testing.T) {
+	ctx := context.Background()
+	cursorFile := filepath.Join(t.TempDir(), "cursor.json")
+	require.NoError(t, os.WriteFile(cursorFile, []byte(`{"version":2,"txs":{}}`), 0600))
+
+	// The cursor of an unknown version is ignored without reading the pool
+	s, txPoolMock, _ := newTestSequencer(t, Config{LoadPoolTxsDedupTTL: cfgTypes.NewDuration(time.Hour), LoadPoolTxsCursorFile: cursorFile})
+	s.restoreLoadCursor(ctx)
+	txPoolMock.AssertNotCalled(t, "GetNonWIPPendingTxs", ctx)
+}
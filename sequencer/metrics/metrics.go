@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/0xPolygonHermez/zkevm-node/metrics"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const (
@@ -18,6 +21,18 @@ const (
 	TxProcessedName = Prefix + "transaction_processed"
 	// SequencesOversizedDataErrorName is the name of the metric that counts the sequences with oversized data error.
 	SequencesOversizedDataErrorName = Prefix + "sequences_oversized_data_error"
+	// PoolTxsDeduplicatedName is the name of the metric that counts the pool txs skipped because they were recently processed.
+	PoolTxsDeduplicatedName = Prefix + "pool_txs_deduplicated"
+	// PoolOldestPendingTxAgeName is the name of the metric that shows the age of the oldest non WIP pending tx in the pool.
+	PoolOldestPendingTxAgeName = Prefix + "pool_oldest_pending_tx_age"
+	// LoadPoolIdleIterationsName is the name of the metric that counts the iterations loading txs from the pool that found no txs.
+	LoadPoolIdleIterationsName = Prefix + "load_pool_idle_iterations"
+	// LoadPoolProductiveIterationsName is the name of the metric that counts the iterations loading txs from the pool that found txs.
+	LoadPoolProductiveIterationsName = Prefix + "load_pool_productive_iterations"
+	// LoadPoolIdleRatioName is the name of the metric that shows the ratio of the iterations loading txs from the pool that found no txs.
+	LoadPoolIdleRatioName = Prefix + "load_pool_idle_ratio"
+	// EventLogFailuresName is the name of the metric that counts the events that failed to be stored in the event log.
+	EventLogFailuresName = Prefix + "event_log_failures"
 	// EthToPolPriceName is the name of the metric that shows the Ethereum to Pol price.
 	EthToPolPriceName = Prefix + "eth_to_pol_price"
 	// SequenceRewardInPolName is the name of the metric that shows the reward in Pol of a sequence.
@@ -28,8 +43,56 @@ const (
 	WorkerPrefix = Prefix + "worker_"
 	// WorkerProcessingTimeName is the name of the metric that shows the worker processing time.
 	WorkerProcessingTimeName = WorkerPrefix + "processing_time"
+	// WorkerFullnessName is the name of the metric that shows the ratio between the txs in the worker and its max capacity.
+	WorkerFullnessName = WorkerPrefix + "fullness"
+	// WorkerBytesName is the name of the metric that shows the approximate number of bytes held by the txs in the worker.
+	WorkerBytesName = WorkerPrefix + "bytes"
+	// WorkerOldTxsName is the name of the metric that shows the number of txs in the worker older than the tx age warn threshold.
+	WorkerOldTxsName = WorkerPrefix + "old_txs"
 	// TxProcessedLabelName is the name of the label for the processed transactions.
 	TxProcessedLabelName = "status"
+	// TxReplacedName is the name of the metric that counts the txs in the worker replaced by a new tx with the same nonce.
+	TxReplacedName = Prefix + "transaction_replaced"
+	// TxReplacedLabelName is the name of the label for the replaced transactions.
+	TxReplacedLabelName = "reason"
+	// DataStreamAtomicOpTimeName is the name of the metric that shows the time spent in each phase of the data stream atomic ops.
+	DataStreamAtomicOpTimeName = Prefix + "datastream_atomic_op_time"
+	// DataStreamAtomicOpPhaseLabelName is the name of the label for the phase of the data stream atomic ops.
+	DataStreamAtomicOpPhaseLabelName = "phase"
+	// DataStreamL2BlocksPerBatchName is the name of the metric that shows the number of L2 blocks streamed per batch.
+	DataStreamL2BlocksPerBatchName = Prefix + "datastream_l2blocks_per_batch"
+	// DataStreamL2BlocksStreamedName is the name of the metric that counts the L2 blocks streamed.
+	DataStreamL2BlocksStreamedName = Prefix + "datastream_l2blocks_streamed"
+	// StateInconsistenciesName is the name of the metric that shows the number of state inconsistencies (reorgs) detected.
+	StateInconsistenciesName = Prefix + "state_inconsistencies"
+	// DataStreamChannelCapacityName is the name of the metric that shows the capacity of the channel of L2 blocks to stream.
+	DataStreamChannelCapacityName = Prefix + "datastream_channel_capacity"
+	// DataStreamChannelLengthName is the name of the metric that shows the number of L2 blocks in the channel pending to be streamed.
+	DataStreamChannelLengthName = Prefix + "datastream_channel_length"
+	// WorkerTxsAddedName is the name of the metric that counts the txs added to the worker.
+	WorkerTxsAddedName = WorkerPrefix + "txs_added"
+	// TxsThrottledName is the name of the metric that counts the pool txs left pending because their sender exceeded its rate limit.
+	TxsThrottledName = Prefix + "txs_throttled"
+	// TxsDeferredName is the name of the metric that counts the pool txs left pending because the wip batch is above the admission fullness threshold.
+	TxsDeferredName = Prefix + "txs_deferred"
+	// PoolTxsDeletedName is the name of the metric that counts the confirmed txs deleted from the pool.
+	PoolTxsDeletedName = Prefix + "pool_txs_deleted"
+	// PoolTxsDeleteFailuresName is the name of the metric that counts the failed deletions of old txs from the pool.
+	PoolTxsDeleteFailuresName = Prefix + "pool_txs_delete_failures"
+	// PoolTxsDeleteKindLabelName is the name of the label for the kind of txs deleted from the pool.
+	PoolTxsDeleteKindLabelName = "kind"
+	// ForkIDLabelName is the name of the label for the fork ID of the metrics labeled by fork ID.
+	ForkIDLabelName = "forkid"
+	// ForkIDSuffix is the suffix of the name of the metrics labeled by fork ID.
+	ForkIDSuffix = "_by_forkid"
+	// TxProcessedByForkIDName is the name of the metric that counts the processed transactions by fork ID.
+	TxProcessedByForkIDName = TxProcessedName + ForkIDSuffix
+	// DataStreamL2BlocksStreamedByForkIDName is the name of the metric that counts the L2 blocks streamed by fork ID.
+	DataStreamL2BlocksStreamedByForkIDName = DataStreamL2BlocksStreamedName + ForkIDSuffix
+	// DataStreamL2BlocksPerBatchByForkIDName is the name of the metric that shows the number of L2 blocks streamed per batch by fork ID.
+	DataStreamL2BlocksPerBatchByForkIDName = DataStreamL2BlocksPerBatchName + ForkIDSuffix
+	// WorkerTxsAddedByForkIDName is the name of the metric that counts the txs added to the worker by fork ID.
+	WorkerTxsAddedByForkIDName = WorkerTxsAddedName + ForkIDSuffix
 )
 
 // TxProcessedLabel represents the possible values for the
@@ -45,13 +108,49 @@ const (
 	TxProcessedLabelFailed TxProcessedLabel = "failed"
 )
 
+// TxReplacedLabel represents the possible values for the
+// `sequencer_transaction_replaced` metric `reason` label.
+type TxReplacedLabel string
+
+const (
+	// TxReplacedLabelHigherGasPrice represents a tx replaced by a tx with a higher gas price
+	TxReplacedLabelHigherGasPrice TxReplacedLabel = "higher_gas_price"
+	// TxReplacedLabelSameGasPrice represents a tx replaced by a tx with the same gas price
+	TxReplacedLabelSameGasPrice TxReplacedLabel = "same_gas_price"
+)
+
+// DataStreamAtomicOpPhaseLabel represents the possible values for the
+// `sequencer_datastream_atomic_op_time` metric `phase` label.
+type DataStreamAtomicOpPhaseLabel string
+
+const (
+	// DataStreamAtomicOpPhaseStart represents the StartAtomicOp phase
+	DataStreamAtomicOpPhaseStart DataStreamAtomicOpPhaseLabel = "start"
+	// DataStreamAtomicOpPhaseAddEntries represents the phase adding the entries (AddStreamEntry/AddStreamBookmark)
+	DataStreamAtomicOpPhaseAddEntries DataStreamAtomicOpPhaseLabel = "add_entries"
+	// DataStreamAtomicOpPhaseCommit represents the CommitAtomicOp phase
+	DataStreamAtomicOpPhaseCommit DataStreamAtomicOpPhaseLabel = "commit"
+)
+
+// PoolTxsDeleteKindLabel represents the possible values for the
+// `sequencer_pool_txs_delete_failures` metric `kind` label.
+type PoolTxsDeleteKindLabel string
+
+const (
+	// PoolTxsDeleteKindConfirmed represents the deletion of the txs confirmed in L1
+	PoolTxsDeleteKindConfirmed PoolTxsDeleteKindLabel = "confirmed"
+	// PoolTxsDeleteKindFailed represents the deletion of the old failed txs
+	PoolTxsDeleteKindFailed PoolTxsDeleteKindLabel = "failed"
+)
+
 // Register the metrics for the sequencer package.
 func Register() {
 	var (
-		counters    []prometheus.CounterOpts
-		counterVecs []metrics.CounterVecOpts
-		gauges      []prometheus.GaugeOpts
-		histograms  []prometheus.HistogramOpts
+		counters      []prometheus.CounterOpts
+		counterVecs   []metrics.CounterVecOpts
+		gauges        []prometheus.GaugeOpts
+		histograms    []prometheus.HistogramOpts
+		histogramVecs []metrics.HistogramVecOpts
 	)
 
 	counters = []prometheus.CounterOpts{
@@ -63,6 +162,42 @@ func Register() {
 			Name: SequencesOversizedDataErrorName,
 			Help: "[SEQUENCER] total count of sequences with oversized data error",
 		},
+		{
+			Name: PoolTxsDeduplicatedName,
+			Help: "[SEQUENCER] total count of pool txs skipped because they were recently processed",
+		},
+		{
+			Name: DataStreamL2BlocksStreamedName,
+			Help: "[SEQUENCER] total count of L2 blocks streamed",
+		},
+		{
+			Name: LoadPoolIdleIterationsName,
+			Help: "[SEQUENCER] total count of iterations loading txs from the pool that found no txs",
+		},
+		{
+			Name: LoadPoolProductiveIterationsName,
+			Help: "[SEQUENCER] total count of iterations loading txs from the pool that found txs",
+		},
+		{
+			Name: EventLogFailuresName,
+			Help: "[SEQUENCER] total count of events that failed to be stored in the event log",
+		},
+		{
+			Name: WorkerTxsAddedName,
+			Help: "[SEQUENCER] total count of txs added to the worker",
+		},
+		{
+			Name: TxsThrottledName,
+			Help: "[SEQUENCER] total count of pool txs left pending because their sender exceeded its rate limit",
+		},
+		{
+			Name: TxsDeferredName,
+			Help: "[SEQUENCER] total count of pool txs left pending because the wip batch is above the admission fullness threshold",
+		},
+		{
+			Name: PoolTxsDeletedName,
+			Help: "[SEQUENCER] total count of confirmed txs deleted from the pool",
+		},
 	}
 
 	counterVecs = []metrics.CounterVecOpts{
@@ -73,6 +208,20 @@ func Register() {
 			},
 			Labels: []string{TxProcessedLabelName},
 		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: TxReplacedName,
+				Help: "[SEQUENCER] number of transactions in the worker replaced by a new transaction with the same nonce",
+			},
+			Labels: []string{TxReplacedLabelName},
+		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: PoolTxsDeleteFailuresName,
+				Help: "[SEQUENCER] number of failed deletions of old txs from the pool",
+			},
+			Labels: []string{PoolTxsDeleteKindLabelName},
+		},
 	}
 
 	gauges = []prometheus.GaugeOpts{
@@ -88,6 +237,38 @@ func Register() {
 			Name: SequenceRewardInPolName,
 			Help: "[SEQUENCER] reward for a sequence in pol",
 		},
+		{
+			Name: WorkerFullnessName,
+			Help: "[SEQUENCER] worker fullness (txs in the worker / max worker txs)",
+		},
+		{
+			Name: WorkerBytesName,
+			Help: "[SEQUENCER] approximate number of bytes held by the txs in the worker",
+		},
+		{
+			Name: WorkerOldTxsName,
+			Help: "[SEQUENCER] number of txs in the worker older than the tx age warn threshold",
+		},
+		{
+			Name: PoolOldestPendingTxAgeName,
+			Help: "[SEQUENCER] age in seconds of the oldest non WIP pending tx in the pool",
+		},
+		{
+			Name: StateInconsistenciesName,
+			Help: "[SEQUENCER] number of state inconsistencies (reorgs) detected",
+		},
+		{
+			Name: LoadPoolIdleRatioName,
+			Help: "[SEQUENCER] ratio of the iterations loading txs from the pool that found no txs (idle iterations / total iterations)",
+		},
+		{
+			Name: DataStreamChannelCapacityName,
+			Help: "[SEQUENCER] capacity of the channel of L2 blocks to stream",
+		},
+		{
+			Name: DataStreamChannelLengthName,
+			Help: "[SEQUENCER] number of L2 blocks in the channel pending to be streamed",
+		},
 	}
 
 	histograms = []prometheus.HistogramOpts{
@@ -99,12 +280,70 @@ func Register() {
 			Name: WorkerProcessingTimeName,
 			Help: "[SEQUENCER] worker processing time",
 		},
+		{
+			Name:    DataStreamL2BlocksPerBatchName,
+			Help:    "[SEQUENCER] number of L2 blocks streamed per batch",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10), //nolint:gomnd
+		},
+	}
+
+	histogramVecs = []metrics.HistogramVecOpts{
+		{
+			HistogramOpts: prometheus.HistogramOpts{
+				Name: DataStreamAtomicOpTimeName,
+				Help: "[SEQUENCER] time spent in each phase of the data stream atomic ops",
+			},
+			Labels: []string{DataStreamAtomicOpPhaseLabelName},
+		},
 	}
 
 	metrics.RegisterCounters(counters...)
 	metrics.RegisterCounterVecs(counterVecs...)
 	metrics.RegisterGauges(gauges...)
 	metrics.RegisterHistograms(histograms...)
+	metrics.RegisterHistogramVecs(histogramVecs...)
+}
+
+// RegisterForkIDMetrics registers the metrics labeled by the fork ID active when they are observed, so the behavior can be
+// compared across forks. Once registered, the metric helpers receiving a fork ID also update them.
+func RegisterForkIDMetrics() {
+	counterVecs := []metrics.CounterVecOpts{
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: TxProcessedByForkIDName,
+				Help: "[SEQUENCER] number of transactions processed by fork ID",
+			},
+			Labels: []string{TxProcessedLabelName, ForkIDLabelName},
+		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: DataStreamL2BlocksStreamedByForkIDName,
+				Help: "[SEQUENCER] total count of L2 blocks streamed by fork ID",
+			},
+			Labels: []string{ForkIDLabelName},
+		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: WorkerTxsAddedByForkIDName,
+				Help: "[SEQUENCER] total count of txs added to the worker by fork ID",
+			},
+			Labels: []string{ForkIDLabelName},
+		},
+	}
+
+	histogramVecs := []metrics.HistogramVecOpts{
+		{
+			HistogramOpts: prometheus.HistogramOpts{
+				Name:    DataStreamL2BlocksPerBatchByForkIDName,
+				Help:    "[SEQUENCER] number of L2 blocks streamed per batch by fork ID",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 10), //nolint:gomnd
+			},
+			Labels: []string{ForkIDLabelName},
+		},
+	}
+
+	metrics.RegisterCounterVecs(counterVecs...)
+	metrics.RegisterHistogramVecs(histogramVecs...)
 }
 
 // AverageGasPrice sets the gauge to the given average gas price.
@@ -119,9 +358,26 @@ func SequencesSentToL1(numSequences float64) {
 }
 
 // TxProcessed increases the counter vector by the provided transactions count
-// and for the given label (status).
-func TxProcessed(status TxProcessedLabel, count float64) {
+// and for the given label (status), also labeled by forkID if the fork ID
+// metrics are registered.
+func TxProcessed(status TxProcessedLabel, forkID uint64, count float64) {
 	metrics.CounterVecAdd(TxProcessedName, string(status), count)
+	if counterVec, ok := metrics.CounterVec(TxProcessedByForkIDName); ok {
+		counterVec.WithLabelValues(string(status), forkIDLabel(forkID)).Add(count)
+	}
+}
+
+// WorkerTxAdded increases the counter of txs added to the worker, also
+// labeled by forkID if the fork ID metrics are registered.
+func WorkerTxAdded(forkID uint64) {
+	metrics.CounterInc(WorkerTxsAddedName)
+	metrics.CounterVecInc(WorkerTxsAddedByForkIDName, forkIDLabel(forkID))
+}
+
+// TxReplaced increases the counter vector of replaced transactions for the
+// given label (reason).
+func TxReplaced(reason TxReplacedLabel) {
+	metrics.CounterVecInc(TxReplacedName, string(reason))
 }
 
 // SequencesOvesizedDataError increases the counter for sequences that
@@ -130,6 +386,54 @@ func SequencesOvesizedDataError() {
 	metrics.CounterInc(SequencesOversizedDataErrorName)
 }
 
+// PoolTxsDeduplicated increases the counter for pool txs skipped because
+// they were recently processed.
+func PoolTxsDeduplicated() {
+	metrics.CounterInc(PoolTxsDeduplicatedName)
+}
+
+// TxThrottled increases the counter for pool txs left pending because their sender exceeded its rate limit.
+func TxThrottled() {
+	metrics.CounterInc(TxsThrottledName)
+}
+
+// TxDeferred increases the counter for pool txs left pending because the wip batch is above the admission fullness threshold.
+func TxDeferred() {
+	metrics.CounterInc(TxsDeferredName)
+}
+
+// PoolTxsDeleted increases the counter of confirmed txs deleted from the pool.
+func PoolTxsDeleted(deleted int) {
+	metrics.CounterAdd(PoolTxsDeletedName, float64(deleted))
+}
+
+// PoolTxsDeleteFailure increases the counter vector of failed deletions of old txs from the pool for the given
+// label (kind).
+func PoolTxsDeleteFailure(kind PoolTxsDeleteKindLabel) {
+	metrics.CounterVecInc(PoolTxsDeleteFailuresName, string(kind))
+}
+
+// LoadPoolIteration increases the counter of idle or productive iterations loading txs from the pool
+// and updates the gauge with the ratio of idle iterations.
+func LoadPoolIteration(idle bool) {
+	if idle {
+		metrics.CounterInc(LoadPoolIdleIterationsName)
+	} else {
+		metrics.CounterInc(LoadPoolProductiveIterationsName)
+	}
+
+	idleIterations := counterValue(LoadPoolIdleIterationsName)
+	totalIterations := idleIterations + counterValue(LoadPoolProductiveIterationsName)
+	if totalIterations > 0 {
+		metrics.GaugeSet(LoadPoolIdleRatioName, idleIterations/totalIterations)
+	}
+}
+
+// EventLogFailure increases the counter for the events that failed to be stored in the event log.
+func EventLogFailure() {
+	metrics.CounterInc(EventLogFailuresName)
+}
+
 // EthToPolPrice sets the gauge for the Ethereum to Pol price.
 func EthToPolPrice(price float64) {
 	metrics.GaugeSet(EthToPolPriceName, price)
@@ -151,3 +455,130 @@ func WorkerProcessingTime(lastProcessTime time.Duration) {
 	execTimeInSeconds := float64(lastProcessTime) / float64(time.Second)
 	metrics.HistogramObserve(WorkerProcessingTimeName, execTimeInSeconds)
 }
+
+// WorkerFullness sets the gauge for the worker fullness.
+func WorkerFullness(fullness float64) {
+	metrics.GaugeSet(WorkerFullnessName, fullness)
+}
+
+// WorkerBytes sets the gauge for the approximate number of bytes held by the txs in the worker.
+func WorkerBytes(bytes uint64) {
+	metrics.GaugeSet(WorkerBytesName, float64(bytes))
+}
+
+// WorkerOldTxs sets the gauge for the number of old txs in the worker.
+func WorkerOldTxs(count float64) {
+	metrics.GaugeSet(WorkerOldTxsName, count)
+}
+
+// PoolOldestPendingTxAge sets the gauge for the age of the oldest non WIP pending tx in the pool.
+func PoolOldestPendingTxAge(age time.Duration) {
+	metrics.GaugeSet(PoolOldestPendingTxAgeName, age.Seconds())
+}
+
+// DataStreamL2BlocksPerBatch observes the number of L2 blocks streamed of a completed batch, also labeled by
+// forkID if the fork ID metrics are registered.
+func DataStreamL2BlocksPerBatch(forkID uint64, l2Blocks uint64) {
+	metrics.HistogramObserve(DataStreamL2BlocksPerBatchName, float64(l2Blocks))
+	metrics.HistogramVecObserve(DataStreamL2BlocksPerBatchByForkIDName, forkIDLabel(forkID), float64(l2Blocks))
+}
+
+// DataStreamL2BlocksStreamed increases the counter by the provided number of L2 blocks streamed, also labeled by
+// forkID if the fork ID metrics are registered.
+func DataStreamL2BlocksStreamed(forkID uint64, l2Blocks float64) {
+	metrics.CounterAdd(DataStreamL2BlocksStreamedName, l2Blocks)
+	metrics.CounterVecAdd(DataStreamL2BlocksStreamedByForkIDName, forkIDLabel(forkID), l2Blocks)
+}
+
+// StateInconsistencies sets the gauge for the number of state inconsistencies detected.
+func StateInconsistencies(count float64) {
+	metrics.GaugeSet(StateInconsistenciesName, count)
+}
+
+// DataStreamChannelCapacity sets the gauge for the capacity of the channel of L2 blocks to stream.
+func DataStreamChannelCapacity(capacity int) {
+	metrics.GaugeSet(DataStreamChannelCapacityName, float64(capacity))
+}
+
+// DataStreamChannelLength sets the gauge for the number of L2 blocks in the channel pending to be streamed.
+func DataStreamChannelLength(length int) {
+	metrics.GaugeSet(DataStreamChannelLengthName, float64(length))
+}
+
+// DataStreamAtomicOpTime observes the time spent in the given phase of a data stream atomic op.
+func DataStreamAtomicOpTime(phase DataStreamAtomicOpPhaseLabel, lastProcessTime time.Duration) {
+	execTimeInSeconds := float64(lastProcessTime) / float64(time.Second)
+	metrics.HistogramVecObserve(DataStreamAtomicOpTimeName, string(phase), execTimeInSeconds)
+}
+
+// Snapshot is a copy of the current value of the key metrics of the sequencer
+type Snapshot struct {
+	TxsSuccessful           float64
+	TxsInvalid              float64
+	TxsFailed               float64
+	L2BlocksStreamed        float64
+	DataStreamChannelLength float64
+	StateInconsistencies    float64
+}
+
+// String returns a representation of the snapshot to be logged
+func (s Snapshot) String() string {
+	return fmt.Sprintf("txs successful: %.0f, txs invalid: %.0f, txs failed: %.0f, l2blocks streamed: %.0f, datastream channel length: %.0f, state inconsistencies: %.0f",
+		s.TxsSuccessful, s.TxsInvalid, s.TxsFailed, s.L2BlocksStreamed, s.DataStreamChannelLength, s.StateInconsistencies)
+}
+
+// TakeSnapshot returns the current value of the key metrics of the sequencer. The metrics not registered are 0
+func TakeSnapshot() Snapshot {
+	return Snapshot{
+		TxsSuccessful:           txProcessedValue(TxProcessedLabelSuccessful),
+		TxsInvalid:              txProcessedValue(TxProcessedLabelInvalid),
+		TxsFailed:               txProcessedValue(TxProcessedLabelFailed),
+		L2BlocksStreamed:        counterValue(DataStreamL2BlocksStreamedName),
+		DataStreamChannelLength: gaugeValue(DataStreamChannelLengthName),
+		StateInconsistencies:    gaugeValue(StateInconsistenciesName),
+	}
+}
+
+// forkIDLabel returns the value of the fork ID label
+func forkIDLabel(forkID uint64) string {
+	return strconv.FormatUint(forkID, 10) //nolint:gomnd
+}
+
+// txProcessedValue returns the number of processed transactions with the given label (status)
+func txProcessedValue(status TxProcessedLabel) float64 {
+	counterVec, ok := metrics.CounterVec(TxProcessedName)
+	if !ok {
+		return 0
+	}
+	return metricValue(counterVec.WithLabelValues(string(status)))
+}
+
+// counterValue returns the value of the counter with the given name
+func counterValue(name string) float64 {
+	counter, ok := metrics.Counter(name)
+	if !ok {
+		return 0
+	}
+	return metricValue(counter)
+}
+
+// gaugeValue returns the value of the gauge with the given name
+func gaugeValue(name string) float64 {
+	gauge, ok := metrics.Gauge(name)
+	if !ok {
+		return 0
+	}
+	return metricValue(gauge)
+}
+
+// metricValue returns the value of a counter or gauge metric
+func metricValue(metric prometheus.Metric) float64 {
+	m := &dto.Metric{}
+	if err := metric.Write(m); err != nil {
+		return 0
+	}
+	if m.Counter != nil {
+		return m.Counter.GetValue()
+	}
+	return m.Gauge.GetValue()
+}
@@ -21,6 +21,34 @@ type PoolMock struct {
 	mock.Mock
 }
 
+// CountPendingTxsByWIPStatus provides a mock function with given fields: ctx, isWIP
+func (_m *PoolMock) CountPendingTxsByWIPStatus(ctx context.Context, isWIP bool) (uint64, error) {
+	ret := _m.Called(ctx, isWIP)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPendingTxsByWIPStatus")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) (uint64, error)); ok {
+		return rf(ctx, isWIP)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, bool) uint64); ok {
+		r0 = rf(ctx, isWIP)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(ctx, isWIP)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteFailedTransactionsOlderThan provides a mock function with given fields: ctx, date
 func (_m *PoolMock) DeleteFailedTransactionsOlderThan(ctx context.Context, date time.Time) error {
 	ret := _m.Called(ctx, date)
@@ -179,6 +207,34 @@ func (_m *PoolMock) GetNonWIPPendingTxs(ctx context.Context) ([]pool.Transaction
 	return r0, r1
 }
 
+// GetOldestNonWIPPendingTxTime provides a mock function with given fields: ctx
+func (_m *PoolMock) GetOldestNonWIPPendingTxTime(ctx context.Context) (time.Time, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOldestNonWIPPendingTxTime")
+	}
+
+	var r0 time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (time.Time, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) time.Time); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetTxZkCountersByHash provides a mock function with given fields: ctx, hash
 func (_m *PoolMock) GetTxZkCountersByHash(ctx context.Context, hash common.Hash) (*state.ZKCounters, error) {
 	ret := _m.Called(ctx, hash)
@@ -12,6 +12,8 @@ import (
 
 	state "github.com/0xPolygonHermez/zkevm-node/state"
 
+	time "time"
+
 	types "github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -130,9 +132,9 @@ func (_m *WorkerMock) MoveTxToNotReady(txHash common.Hash, from common.Address,
 	return r0
 }
 
-// NewTxTracker provides a mock function with given fields: tx, counters, ip
-func (_m *WorkerMock) NewTxTracker(tx types.Transaction, counters state.ZKCounters, ip string) (*TxTracker, error) {
-	ret := _m.Called(tx, counters, ip)
+// NewTxTracker provides a mock function with given fields: tx, counters, ip, inclusionDeadline
+func (_m *WorkerMock) NewTxTracker(tx types.Transaction, counters state.ZKCounters, ip string, inclusionDeadline *time.Time) (*TxTracker, error) {
+	ret := _m.Called(tx, counters, ip, inclusionDeadline)
 
 	if len(ret) == 0 {
 		panic("no return value specified for NewTxTracker")
@@ -140,19 +142,19 @@ func (_m *WorkerMock) NewTxTracker(tx types.Transaction, counters state.ZKCounte
 
 	var r0 *TxTracker
 	var r1 error
-	if rf, ok := ret.Get(0).(func(types.Transaction, state.ZKCounters, string) (*TxTracker, error)); ok {
-		return rf(tx, counters, ip)
+	if rf, ok := ret.Get(0).(func(types.Transaction, state.ZKCounters, string, *time.Time) (*TxTracker, error)); ok {
+		return rf(tx, counters, ip, inclusionDeadline)
 	}
-	if rf, ok := ret.Get(0).(func(types.Transaction, state.ZKCounters, string) *TxTracker); ok {
-		r0 = rf(tx, counters, ip)
+	if rf, ok := ret.Get(0).(func(types.Transaction, state.ZKCounters, string, *time.Time) *TxTracker); ok {
+		r0 = rf(tx, counters, ip, inclusionDeadline)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*TxTracker)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(types.Transaction, state.ZKCounters, string) error); ok {
-		r1 = rf(tx, counters, ip)
+	if rf, ok := ret.Get(1).(func(types.Transaction, state.ZKCounters, string, *time.Time) error); ok {
+		r1 = rf(tx, counters, ip, inclusionDeadline)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -160,6 +162,11 @@ func (_m *WorkerMock) NewTxTracker(tx types.Transaction, counters state.ZKCounte
 	return r0, r1
 }
 
+// SetTxInFlight provides a mock function with given fields: txHash, addr, inFlight
+func (_m *WorkerMock) SetTxInFlight(txHash common.Hash, addr common.Address, inFlight bool) {
+	_m.Called(txHash, addr, inFlight)
+}
+
 // UpdateAfterSingleSuccessfulTxExecution provides a mock function with given fields: from, touchedAddresses
 func (_m *WorkerMock) UpdateAfterSingleSuccessfulTxExecution(from common.Address, touchedAddresses map[common.Address]*state.InfoReadWrite) []*TxTracker {
 	ret := _m.Called(from, touchedAddresses)
@@ -0,0 +1,240 @@
+package sequencer
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// priorityStream sends to a secondary data stream the txs committed to the data stream whose value or gas is above the
+// thresholds, along with the start and end entries of their L2 blocks and the bookmarks of their batches. The L2 blocks
+// without qualifying txs are not sent. The L2 blocks are sent from its own goroutine, so the data stream is never blocked
+type priorityStream struct {
+	cfg          PriorityStreamCfg
+	streamServer dataStreamServer
+	encoder      state.StreamEncoder
+
+	// committed receives the L2 blocks committed to the data stream
+	committed chan []state.DSL2FullBlock
+	// sendEnabled returns false while the sending is paused, the L2 blocks are kept queued meanwhile. If nil it's always enabled
+	sendEnabled func() bool
+	// currentBatchNumber is the batch of the last L2 block sent, the batch bookmark is added before the first L2 block of each batch
+	currentBatchNumber uint64
+}
+
+// newPriorityStream creates a new priorityStream that writes to streamServer with the encoder
+func newPriorityStream(cfg PriorityStreamCfg, streamServer dataStreamServer, encoder state.StreamEncoder) *priorityStream {
+	return &priorityStream{
+		cfg:          cfg,
+		streamServer: streamServer,
+		encoder:      encoder,
+		committed:    make(chan []state.DSL2FullBlock, max(cfg.QueueSize, 1)),
+	}
+}
+
+// isPriorityTx returns true if the value of the tx is at least MinValue or its gas at least MinGas. The thresholds set to 0 are not checked
+func (p *priorityStream) isPriorityTx(tx *types.Transaction) bool {
+	minValue := priorityStreamMinValue(p.cfg)
+	if minValue.Sign() > 0 && tx.Value().Cmp(minValue) >= 0 {
+		return true
+	}
+	return p.cfg.MinGas > 0 && tx.Gas() >= p.cfg.MinGas
+}
+
+// priorityTxs returns the txs of the L2 block that qualify for the priority stream
+func (p *priorityStream) priorityTxs(l2Block state.DSL2FullBlock) []state.DSL2Transaction {
+	txs := []state.DSL2Transaction{}
+	for _, l2Transaction := range l2Block.Txs {
+		tx := types.Transaction{}
+		err := tx.UnmarshalBinary(l2Transaction.Encoded)
+		if err != nil {
+			log.Warnf("failed to decode tx of l2block %d for the priority stream, error: %w", l2Block.L2BlockNumber, err)
+			continue
+		}
+		if p.isPriorityTx(&tx) {
+			txs = append(txs, l2Transaction)
+		}
+	}
+	return txs
+}
+
+// commit queues the L2 blocks committed to the data stream to send their qualifying txs. It never blocks, if the queue is
+// full the L2 blocks are not sent. If the priority stream is disabled (nil) it does nothing
+func (p *priorityStream) commit(l2Blocks []state.DSL2FullBlock) {
+	if p == nil || len(l2Blocks) == 0 {
+		return
+	}
+
+	select {
+	case p.committed <- l2Blocks:
+	default:
+		log.Errorf("priority stream queue is full (size: %d), %s are not sent to the priority stream", cap(p.committed), describeL2Blocks(l2Blocks))
+	}
+}
+
+// start keeps sending the qualifying txs of the committed L2 blocks until ctx is done
+func (p *priorityStream) start(ctx context.Context) {
+	for {
+		if p.sendEnabled != nil && !p.sendEnabled() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(loopDisabledCheckInterval):
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case l2Blocks := <-p.committed:
+			p.sendWithRetries(l2Blocks)
+		}
+	}
+}
+
+// sendWithRetries sends the qualifying txs of the L2 blocks, rolling back the atomic op and retrying it up to
+// streamAtomicOpMaxRetries times if it fails. If all the retries fail the L2 blocks are not sent
+func (p *priorityStream) sendWithRetries(l2Blocks []state.DSL2FullBlock) {
+	for retry := 0; ; retry++ {
+		err := p.send(l2Blocks)
+		if err == nil {
+			return
+		}
+		log.Errorf("failed to send %s to the priority stream, error: %w", describeL2Blocks(l2Blocks), err)
+
+		err = p.streamServer.RollbackAtomicOp()
+		if err != nil {
+			log.Errorf("failed to rollback priority stream atomic op, error: %w", err)
+		}
+
+		if retry >= streamAtomicOpMaxRetries {
+			log.Errorf("failed to send %s to the priority stream after %d retries", describeL2Blocks(l2Blocks), retry)
+			return
+		}
+	}
+}
+
+// send sends the qualifying txs of the L2 blocks in a single atomic op. If no L2 block has qualifying txs it does nothing
+func (p *priorityStream) send(l2Blocks []state.DSL2FullBlock) error {
+	started := false
+	batchNumber := p.currentBatchNumber
+	for _, l2Block := range l2Blocks {
+		txs := p.priorityTxs(l2Block)
+		if len(txs) == 0 {
+			continue
+		}
+
+		if !started {
+			err := p.streamServer.StartAtomicOp()
+			if err != nil {
+				return err
+			}
+			started = true
+		}
+
+		// Add the batch bookmark before the first L2 block of a new batch
+		if l2Block.BatchNumber != batchNumber {
+			bookMark := state.DSBookMark{
+				Type:          state.BookMarkTypeBatch,
+				L2BlockNumber: l2Block.BatchNumber,
+			}
+			_, err := p.streamServer.AddStreamBookmark(bookMark.Encode())
+			if err != nil {
+				return err
+			}
+			batchNumber = l2Block.BatchNumber
+		}
+
+		err := p.addL2BlockEntries(l2Block, txs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !started {
+		return nil
+	}
+	err := p.streamServer.CommitAtomicOp()
+	if err != nil {
+		return err
+	}
+	p.currentBatchNumber = batchNumber
+	return nil
+}
+
+// addL2BlockEntries adds the bookmark, start and end entries of the L2 block with the given txs to the current atomic op
+func (p *priorityStream) addL2BlockEntries(l2Block state.DSL2FullBlock, txs []state.DSL2Transaction) error {
+	bookMark := state.DSBookMark{
+		Type:          state.BookMarkTypeL2Block,
+		L2BlockNumber: l2Block.L2BlockNumber,
+	}
+	_, err := p.streamServer.AddStreamBookmark(bookMark.Encode())
+	if err != nil {
+		return err
+	}
+
+	blockStart := state.DSL2BlockStart{
+		BatchNumber:    l2Block.BatchNumber,
+		L2BlockNumber:  l2Block.L2BlockNumber,
+		Timestamp:      l2Block.Timestamp,
+		GlobalExitRoot: l2Block.GlobalExitRoot,
+		Coinbase:       l2Block.Coinbase,
+		ForkID:         l2Block.ForkID,
+	}
+	_, err = p.streamServer.AddStreamEntry(p.encoder.L2BlockStartEntryType(), p.encoder.EncodeL2BlockStart(blockStart))
+	if err != nil {
+		return err
+	}
+
+	for _, l2Transaction := range txs {
+		_, err = p.streamServer.AddStreamEntry(p.encoder.L2TransactionEntryType(), p.encoder.EncodeL2Transaction(l2Transaction))
+		if err != nil {
+			return err
+		}
+	}
+
+	blockEnd := state.DSL2BlockEnd{
+		L2BlockNumber: l2Block.L2BlockNumber,
+		BlockHash:     l2Block.BlockHash,
+		StateRoot:     l2Block.StateRoot,
+	}
+	_, err = p.streamServer.AddStreamEntry(p.encoder.L2BlockEndEntryType(), p.encoder.EncodeL2BlockEnd(blockEnd))
+	return err
+}
+
+// setupPriorityStream creates and starts the priority stream server. The priority stream is optional, if it can't be
+// started the sequencer keeps running without it
+func (s *Sequencer) setupPriorityStream() {
+	cfg := s.cfg.StreamServer.PriorityStream
+	initStreamServerLog(s.cfg.StreamServer.Log)
+	streamServer, err := datastreamer.NewServer(cfg.Port, state.StreamTypeSequencer, cfg.Filename, nil)
+	if err == nil {
+		err = streamServer.Start()
+	}
+	if err != nil {
+		log.Errorf("priority stream disabled, failed to start priority stream server, error: %w", err)
+		return
+	}
+
+	s.priorityStream = newPriorityStream(cfg, streamServer, newStreamEncoder(s.cfg.StreamServer.Encoding))
+	s.priorityStream.sendEnabled = func() bool { return s.isLoopEnabled(LoopPriorityStream) }
+	// The batch bookmark of the last batch in the priority stream is already added
+	s.priorityStream.currentBatchNumber, err = getLastStreamedBatchNumber(streamServer)
+	if err != nil {
+		log.Errorf("failed to get the last batch number in the priority stream, error: %w", err)
+	}
+}
+
+// priorityStreamMinValue returns the min value of the txs of the priority stream, 0 if it's not set
+func priorityStreamMinValue(cfg PriorityStreamCfg) *big.Int {
+	if cfg.MinValue == nil {
+		return big.NewInt(0)
+	}
+	return cfg.MinValue
+}
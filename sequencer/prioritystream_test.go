@@ -0,0 +1,131 @@
+package sequencer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestL2FullBlockWithTxs returns a L2 block with txs of the given values and gas limits to send to the streamer
+func newTestL2FullBlockWithTxs(t *testing.T, batchNumber, l2BlockNumber uint64, values []int64, gas []uint64) state.DSL2FullBlock {
+	l2Block := newTestL2FullBlock(batchNumber, l2BlockNumber, 0)
+	for i := range values {
+		tx := types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(values[i]), gas[i], big.NewInt(1), nil)
+		encoded, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		l2Block.Txs = append(l2Block.Txs, state.DSL2Transaction{L2BlockNumber: l2BlockNumber, IsValid: 1, EncodedLength: uint32(len(encoded)), Encoded: encoded})
+	}
+	return l2Block
+}
+
+// streamEntries returns the entries of the stream server
+func streamEntries(t *testing.T, streamServer *datastreamer.StreamServer) []datastreamer.FileEntry {
+	entries := []datastreamer.FileEntry{}
+	for entryNum := uint64(0); entryNum < streamServer.GetHeader().TotalEntries; entryNum++ {
+		entry, err := streamServer.GetEntry(entryNum)
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestStreamPipeline_sendL2Blocks_PriorityStream(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	priorityStreamServer := newTestStreamServer(t)
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+	committed := make(chan struct{}, 1)
+	p.priorityStream = newPriorityStream(PriorityStreamCfg{MinValue: big.NewInt(1000), MinGas: 500000, QueueSize: 10}, committedStreamServer{priorityStreamServer, committed}, state.DSBinaryEncoder{})
+	go p.priorityStream.start(ctx)
+
+	// The first tx qualifies by value and the third one by gas, the second L2 block has no qualifying txs
+	l2Block1 := newTestL2FullBlockWithTxs(t, 1, 1, []int64{1000, 999, 1}, []uint64{21000, 21000, 500000})
+	l2Block2 := newTestL2FullBlockWithTxs(t, 1, 2, []int64{1}, []uint64{21000})
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block1, l2Block2}))
+
+	// The data stream keeps all the txs: batch bookmark + 2 L2 blocks (block bookmark + block start + block end) + 4 txs
+	assert.Equal(t, uint64(11), streamServer.GetHeader().TotalEntries)
+
+	// The L2 blocks are sent to the priority stream in the background
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("l2blocks not sent to the priority stream")
+	}
+	entries := streamEntries(t, priorityStreamServer)
+	require.Len(t, entries, 6)
+	assert.Equal(t, datastreamer.EntryType(datastreamer.EtBookmark), entries[0].Type)
+	assert.Equal(t, state.DSBookMark{Type: state.BookMarkTypeBatch, L2BlockNumber: 1}.Encode(), entries[0].Data)
+	entries = entries[1:]
+	assert.Equal(t, datastreamer.EntryType(datastreamer.EtBookmark), entries[0].Type)
+	assert.Equal(t, state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: 1}.Encode(), entries[0].Data)
+
+	assert.Equal(t, state.EntryTypeL2BlockStart, entries[1].Type)
+	blockStart, err := state.DSBinaryEncoder{}.DecodeL2BlockStart(entries[1].Data)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), blockStart.L2BlockNumber)
+
+	assert.Equal(t, state.EntryTypeL2Tx, entries[2].Type)
+	assert.Equal(t, state.DSBinaryEncoder{}.EncodeL2Transaction(l2Block1.Txs[0]), entries[2].Data)
+	assert.Equal(t, state.EntryTypeL2Tx, entries[3].Type)
+	assert.Equal(t, state.DSBinaryEncoder{}.EncodeL2Transaction(l2Block1.Txs[2]), entries[3].Data)
+
+	assert.Equal(t, state.EntryTypeL2BlockEnd, entries[4].Type)
+	blockEnd, err := state.DSBinaryEncoder{}.DecodeL2BlockEnd(entries[4].Data)
+	require.NoError(t, err)
+	assert.Equal(t, l2Block1.BlockHash, blockEnd.BlockHash)
+}
+
+func TestPriorityStream_BatchBookmarks(t *testing.T) {
+	priorityStreamServer := newTestStreamServer(t)
+	p := newPriorityStream(PriorityStreamCfg{MinGas: 500000}, priorityStreamServer, state.DSBinaryEncoder{})
+
+	// The batch bookmark is only added before the first L2 block sent of each batch, the batch 2 has no qualifying txs
+	p.sendWithRetries([]state.DSL2FullBlock{
+		newTestL2FullBlockWithTxs(t, 1, 1, []int64{1}, []uint64{500000}),
+		newTestL2FullBlockWithTxs(t, 1, 2, []int64{1}, []uint64{500000}),
+		newTestL2FullBlockWithTxs(t, 2, 3, []int64{1}, []uint64{21000}),
+	})
+	p.sendWithRetries([]state.DSL2FullBlock{newTestL2FullBlockWithTxs(t, 3, 4, []int64{1}, []uint64{500000})})
+
+	bookmarks := []state.DSBookMark{}
+	for _, entry := range streamEntries(t, priorityStreamServer) {
+		if entry.Type == datastreamer.EtBookmark {
+			bookmarks = append(bookmarks, state.DSBookMark{}.Decode(entry.Data))
+		}
+	}
+	assert.Equal(t, []state.DSBookMark{
+		{Type: state.BookMarkTypeBatch, L2BlockNumber: 1}, {Type: state.BookMarkTypeL2Block, L2BlockNumber: 1}, {Type: state.BookMarkTypeL2Block, L2BlockNumber: 2},
+		{Type: state.BookMarkTypeBatch, L2BlockNumber: 3}, {Type: state.BookMarkTypeL2Block, L2BlockNumber: 4},
+	}, bookmarks)
+
+	// The consumers can position in the priority stream by batch
+	entry, err := priorityStreamServer.GetFirstEventAfterBookmark(state.DSBookMark{Type: state.BookMarkTypeBatch, L2BlockNumber: 3}.Encode())
+	require.NoError(t, err)
+	batchNumber, err := state.DecodeL2BlockStartBatchNumber(entry)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), batchNumber)
+	lastBatchNumber, err := getLastStreamedBatchNumber(priorityStreamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), lastBatchNumber)
+}
+
+func TestPriorityStream_QueueFull(t *testing.T) {
+	p := newPriorityStream(PriorityStreamCfg{MinGas: 500000, QueueSize: 1}, nil, state.DSBinaryEncoder{})
+
+	// The priority stream is not started, so the second commit finds the queue full and its L2 blocks are dropped without blocking
+	p.commit([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 0)})
+	p.commit([]state.DSL2FullBlock{newTestL2FullBlock(1, 2, 0)})
+	require.Len(t, p.committed, 1)
+	assert.Equal(t, uint64(1), (<-p.committed)[0].L2BlockNumber)
+}
@@ -0,0 +1,68 @@
+package sequencer
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// recentBlockHashes is a fixed size ring buffer with the hashes of the most recent L2 blocks streamed
+type recentBlockHashes struct {
+	numbers []uint64
+	next    int
+	hashes  map[uint64]common.Hash
+	mutex   sync.Mutex
+}
+
+// newRecentBlockHashes creates a new recentBlockHashes that keeps up to size L2 blocks. If size is 0 no hashes are kept
+func newRecentBlockHashes(size uint64) *recentBlockHashes {
+	return &recentBlockHashes{
+		numbers: make([]uint64, 0, size),
+		hashes:  make(map[uint64]common.Hash),
+	}
+}
+
+// add adds the hash of a L2 block, evicting the oldest one if the buffer is full. If the L2 block is already kept its hash is replaced
+func (r *recentBlockHashes) add(l2BlockNumber uint64, hash common.Hash) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cap(r.numbers) == 0 {
+		return
+	}
+
+	if _, found := r.hashes[l2BlockNumber]; found {
+		r.hashes[l2BlockNumber] = hash
+		return
+	}
+
+	if len(r.numbers) < cap(r.numbers) {
+		r.numbers = append(r.numbers, l2BlockNumber)
+	} else {
+		delete(r.hashes, r.numbers[r.next])
+		r.numbers[r.next] = l2BlockNumber
+	}
+
+	r.hashes[l2BlockNumber] = hash
+	r.next = (r.next + 1) % cap(r.numbers)
+}
+
+// getAll returns a copy of the hashes kept by L2 block number
+func (r *recentBlockHashes) getAll() map[uint64]common.Hash {
+	hashes := make(map[uint64]common.Hash)
+	if r == nil {
+		return hashes
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for l2BlockNumber, hash := range r.hashes {
+		hashes[l2BlockNumber] = hash
+	}
+	return hashes
+}
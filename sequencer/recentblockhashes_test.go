@@ -0,0 +1,43 @@
+package sequencer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_RecentBlockHashes(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{StreamServer: StreamServerCfg{RecentBlockHashesSize: 3}})
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, newTestStreamServer(t), nil, nil, nil)
+	p.recentBlockHashes = s.recentBlockHashes
+
+	assert.Empty(t, s.RecentBlockHashes())
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1), newTestL2FullBlock(1, 2, 1)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(2, 3, 1), newTestL2FullBlock(2, 4, 0)}))
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(2, 5, 1)}))
+
+	// Only the last 3 L2 blocks streamed are kept
+	hash := func(l2BlockNumber uint64) common.Hash {
+		return common.BigToHash(new(big.Int).SetUint64(l2BlockNumber))
+	}
+	recent := s.RecentBlockHashes()
+	assert.Equal(t, map[uint64]common.Hash{3: hash(3), 4: hash(4), 5: hash(5)}, recent)
+
+	// The returned map is a copy
+	delete(recent, 5)
+	assert.Len(t, s.RecentBlockHashes(), 3)
+}
+
+func TestSequencer_RecentBlockHashes_Disabled(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, newTestStreamServer(t), nil, nil, nil)
+	p.recentBlockHashes = s.recentBlockHashes
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1)}))
+	assert.Empty(t, s.RecentBlockHashes())
+}
@@ -0,0 +1,88 @@
+package sequencer
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// recentPoolTx is a pool tx processed in the last ttl
+type recentPoolTx struct {
+	processedAt time.Time
+	// leftPending is true if the tx was not added to the worker, so it's still returned by the pool as non WIP pending
+	leftPending bool
+}
+
+// recentPoolTxs is the set of the pool txs processed in the last ttl, used to skip the txs returned again by the pool
+type recentPoolTxs struct {
+	ttl time.Duration
+	txs map[common.Hash]recentPoolTx
+	// changed is true if the txs left pending changed since the last call to pendingTxs
+	changed bool
+}
+
+// newRecentPoolTxs creates a new recentPoolTxs that keeps the txs for ttl. If ttl is 0 no txs are kept
+func newRecentPoolTxs(ttl time.Duration) *recentPoolTxs {
+	return &recentPoolTxs{
+		ttl: ttl,
+		txs: make(map[common.Hash]recentPoolTx),
+	}
+}
+
+// add adds a processed tx to the set, leftPending is true if the tx was not added to the worker
+func (r *recentPoolTxs) add(hash common.Hash, leftPending bool) {
+	if r.ttl == 0 {
+		return
+	}
+	r.txs[hash] = recentPoolTx{processedAt: time.Now(), leftPending: leftPending}
+	r.changed = r.changed || leftPending
+}
+
+// contains returns true if the tx was processed in the last ttl
+func (r *recentPoolTxs) contains(hash common.Hash) bool {
+	tx, found := r.txs[hash]
+	return found && time.Since(tx.processedAt) < r.ttl
+}
+
+// forget deletes a tx from the set, so it's not skipped the next time it's returned by the pool
+func (r *recentPoolTxs) forget(hash common.Hash) {
+	if tx, found := r.txs[hash]; found {
+		delete(r.txs, hash)
+		r.changed = r.changed || tx.leftPending
+	}
+}
+
+// purge deletes the txs processed before the last ttl
+func (r *recentPoolTxs) purge() {
+	for hash, tx := range r.txs {
+		if time.Since(tx.processedAt) >= r.ttl {
+			delete(r.txs, hash)
+			r.changed = r.changed || tx.leftPending
+		}
+	}
+}
+
+// pendingTxs returns the time each tx left pending was processed, and true if they changed since the previous call
+func (r *recentPoolTxs) pendingTxs() (map[common.Hash]time.Time, bool) {
+	txs := make(map[common.Hash]time.Time)
+	for hash, tx := range r.txs {
+		if tx.leftPending {
+			txs[hash] = tx.processedAt
+		}
+	}
+	changed := r.changed
+	r.changed = false
+	return txs, changed
+}
+
+// restore adds the txs left pending processed at the given times, skipping the ones processed before the last ttl
+func (r *recentPoolTxs) restore(txs map[common.Hash]time.Time) {
+	if r.ttl == 0 {
+		return
+	}
+	for hash, processedAt := range txs {
+		if time.Since(processedAt) < r.ttl {
+			r.txs[hash] = recentPoolTx{processedAt: processedAt, leftPending: true}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package sequencer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ReplacementRecord is the record of a tx in the worker replaced by a new tx with the same nonce
+type ReplacementRecord struct {
+	Hash       common.Hash
+	ReplacedBy common.Hash
+	From       common.Address
+	Reason     metrics.TxReplacedLabel
+	Timestamp  time.Time
+}
+
+// replacementRecords is a fixed size ring buffer with the most recent replacement records
+type replacementRecords struct {
+	records []ReplacementRecord
+	next    int
+	mutex   sync.Mutex
+}
+
+// newReplacementRecords creates a new replacementRecords that keeps up to size records. If size is 0 no records are kept
+func newReplacementRecords(size uint64) *replacementRecords {
+	return &replacementRecords{
+		records: make([]ReplacementRecord, 0, size),
+	}
+}
+
+// add adds a record, evicting the oldest one if the buffer is full
+func (r *replacementRecords) add(record ReplacementRecord) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cap(r.records) == 0 {
+		return
+	}
+
+	if len(r.records) < cap(r.records) {
+		r.records = append(r.records, record)
+	} else {
+		r.records[r.next] = record
+	}
+
+	r.next = (r.next + 1) % cap(r.records)
+}
+
+// getAll returns the records from the oldest to the most recent
+func (r *replacementRecords) getAll() []ReplacementRecord {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	records := make([]ReplacementRecord, 0, len(r.records))
+	if len(r.records) < cap(r.records) {
+		return append(records, r.records...)
+	}
+
+	records = append(records, r.records[r.next:]...)
+	return append(records, r.records[:r.next]...)
+}
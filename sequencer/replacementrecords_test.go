@@ -0,0 +1,25 @@
+package sequencer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplacementRecords(t *testing.T) {
+	records := newReplacementRecords(2)
+	assert.Empty(t, records.getAll())
+
+	for i := int64(1); i <= 3; i++ {
+		records.add(ReplacementRecord{Hash: common.BigToHash(big.NewInt(i))})
+	}
+
+	// The oldest record is evicted
+	assert.Equal(t, []ReplacementRecord{{Hash: common.BigToHash(big.NewInt(2))}, {Hash: common.BigToHash(big.NewInt(3))}}, records.getAll())
+
+	noRecords := newReplacementRecords(0)
+	noRecords.add(ReplacementRecord{Hash: common.HexToHash("0x1")})
+	assert.Empty(t, noRecords.getAll())
+}
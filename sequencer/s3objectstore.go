@@ -0,0 +1,134 @@
+package sequencer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// s3RequestTimeout is the timeout of each request to the S3-compatible object store
+	s3RequestTimeout = time.Minute
+	// s3Service is the service name used to sign the requests
+	s3Service = "s3"
+)
+
+// s3ObjectStore is an S3-compatible object store accessed with path-style URLs (Endpoint/Bucket/key). The requests are signed
+// with AWS Signature Version 4 if AccessKeyID is set
+type s3ObjectStore struct {
+	cfg    ArchiveCfg
+	client *http.Client
+}
+
+// newS3ObjectStore creates the S3-compatible object store of the data stream archive
+func newS3ObjectStore(cfg ArchiveCfg) *s3ObjectStore {
+	return &s3ObjectStore{cfg: cfg, client: &http.Client{Timeout: s3RequestTimeout}}
+}
+
+// PutObject uploads the object with the given key
+func (s *s3ObjectStore) PutObject(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to put object %s, status: %s, response: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// GetObject downloads the object with the given key. If it doesn't exist errObjectNotFound is returned
+func (s *s3ObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, errObjectNotFound
+	}
+	return nil, fmt.Errorf("failed to get object %s, status: %s, response: %s", key, resp.Status, body)
+}
+
+// do sends a signed request for the object with the given key
+func (s *s3ObjectStore) do(ctx context.Context, method, key string, data []byte) (*http.Response, error) {
+	segments := append([]string{"", s.cfg.Bucket}, strings.Split(key, "/")...)
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	uri := strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.cfg.Endpoint, "/")+uri, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.AccessKeyID != "" {
+		s.sign(req, uri, data, time.Now().UTC())
+	}
+	return s.client.Do(req)
+}
+
+// sign signs the request with AWS Signature Version 4
+func (s *s3ObjectStore) sign(req *http.Request, uri string, data []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(data)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.cfg.Region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
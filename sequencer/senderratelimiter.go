@@ -0,0 +1,89 @@
+package sequencer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// senderBucket is the token bucket of a sender
+type senderBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// senderRateLimiter limits the number of txs per second of each sender with a token bucket per sender
+type senderRateLimiter struct {
+	rate    float64
+	burst   float64
+	buckets map[common.Address]*senderBucket
+	// grace is the number of txs of a new sender admitted regardless of its bucket
+	grace uint64
+	// graceTxs is the number of txs admitted with grace of each sender seen, they are kept to not grant the grace twice
+	graceTxs map[common.Address]uint64
+	mutex    sync.Mutex
+}
+
+// newSenderRateLimiter creates a new senderRateLimiter that allows rate txs per second per sender, up to burst txs at once.
+// The first grace txs of each sender not seen before are always admitted. If rate is 0 there is no limit
+func newSenderRateLimiter(rate float64, burst uint64, grace uint64) *senderRateLimiter {
+	return &senderRateLimiter{
+		rate:     rate,
+		burst:    float64(max(burst, 1)),
+		buckets:  make(map[common.Address]*senderBucket),
+		grace:    grace,
+		graceTxs: make(map[common.Address]uint64),
+	}
+}
+
+// allow returns true if a new tx of the sender can be admitted, taking a token of its bucket. The txs admitted with the
+// grace of a new sender also take a token if there is one, so the sender is limited once its grace is over
+func (r *senderRateLimiter) allow(sender common.Address) bool {
+	if r.rate == 0 {
+		return true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	bucket, found := r.buckets[sender]
+	if !found {
+		bucket = &senderBucket{tokens: r.burst, updatedAt: now}
+		r.buckets[sender] = bucket
+	}
+	r.refill(bucket, now)
+
+	if graceTxs := r.graceTxs[sender]; graceTxs < r.grace {
+		r.graceTxs[sender] = graceTxs + 1
+		bucket.tokens = max(bucket.tokens-1, 0)
+		return true
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// refill adds the tokens accumulated since the last update of the bucket, up to burst
+func (r *senderRateLimiter) refill(bucket *senderBucket, now time.Time) {
+	bucket.tokens = min(r.burst, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*r.rate)
+	bucket.updatedAt = now
+}
+
+// purge deletes the buckets that are full, as they are the same as the bucket of a new sender
+func (r *senderRateLimiter) purge() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for sender, bucket := range r.buckets {
+		r.refill(bucket, now)
+		if bucket.tokens >= r.burst {
+			delete(r.buckets, sender)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package sequencer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	zkmetrics "github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSenderRateLimiter(t *testing.T) {
+	sender := common.HexToAddress("0x1")
+
+	// Without rate there is no limit
+	r := newSenderRateLimiter(0, 1, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, r.allow(sender))
+	}
+
+	// The bucket allows the burst and then it's refilled at rate
+	r = newSenderRateLimiter(100, 2, 0)
+	assert.True(t, r.allow(sender))
+	assert.True(t, r.allow(sender))
+	assert.False(t, r.allow(sender))
+	assert.True(t, r.allow(common.HexToAddress("0x2")))
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, r.allow(sender))
+
+	// The full buckets are purged
+	time.Sleep(50 * time.Millisecond)
+	r.purge()
+	assert.Empty(t, r.buckets)
+}
+
+func TestSenderRateLimiter_NewSenderGrace(t *testing.T) {
+	sender := common.HexToAddress("0x1")
+	r := newSenderRateLimiter(0.001, 2, 3)
+
+	// The first 3 txs of the new sender are admitted although they exceed the burst, then it's throttled
+	for i := 0; i < 3; i++ {
+		assert.True(t, r.allow(sender))
+	}
+	assert.False(t, r.allow(sender))
+
+	// The grace is not granted again once the bucket of the sender is purged
+	r.buckets = map[common.Address]*senderBucket{}
+	assert.True(t, r.allow(sender))
+	assert.True(t, r.allow(sender))
+	assert.False(t, r.allow(sender))
+}
+
+func TestSequencer_loadPoolTxs_SenderRateLimit(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{SenderRateLimit: 0.001, SenderRateLimitBurst: 2, LoadPoolTxsDedupTTL: cfgTypes.NewDuration(time.Minute)})
+	stMock.On("GetLastStateRoot", ctx, nil).Return(common.Hash{}, nil)
+	stMock.On("GetNonceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(big.NewInt(0), nil)
+	stMock.On("GetBalanceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(new(big.Int).SetUint64(1e18), nil)
+
+	counter, ok := zkmetrics.Counter(metrics.TxsThrottledName)
+	require.True(t, ok)
+	initial := testutil.ToFloat64(counter)
+
+	// The flooding sender sends 5 txs, the other sender 1
+	floodingKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	txs := []pool.Transaction{}
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		txs = append(txs, newTestPoolTxWithKey(t, floodingKey, nonce, 21000))
+	}
+	txs = append(txs, newTestPoolTxWithKey(t, otherKey, 0, 21000))
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(txs, nil).Twice()
+
+	// Only the burst of the flooding sender is added, the excess is left pending while the other sender proceeds
+	for _, tx := range []pool.Transaction{txs[0], txs[1], txs[5]} {
+		txPoolMock.On("UpdateTxWIPStatus", ctx, tx.Hash(), true).Return(nil).Once()
+	}
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, 3, s.worker.CountTxs())
+	assert.Equal(t, initial+3, testutil.ToFloat64(counter))
+	txPoolMock.AssertNotCalled(t, "UpdateTxStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// The deferred txs are not deduplicated, they are tried again in the next load
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, 3, s.worker.CountTxs())
+	assert.Equal(t, initial+6, testutil.ToFloat64(counter))
+}
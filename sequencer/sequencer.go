@@ -2,11 +2,22 @@ package sequencer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"net"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
 	"github.com/0xPolygonHermez/zkevm-node/event"
 	"github.com/0xPolygonHermez/zkevm-node/log"
 	"github.com/0xPolygonHermez/zkevm-node/pool"
@@ -17,8 +28,110 @@ import (
 
 const (
 	datastreamChannelMultiplier = 2
+	// finalizerWarmupCheckInterval is the time between the checks of the number of txs in the worker during the finalizer warmup
+	finalizerWarmupCheckInterval = 100 * time.Millisecond
+	// dataToStreamMonitorInterval is the time between the samples of the capacity and length of the dataToStream channel
+	dataToStreamMonitorInterval = time.Second
+	// l1BlockTime is the approximate time between L1 blocks, used to convert DeletePoolTxsL1BlockConfirmations into the age of the failed txs deleted from the pool
+	l1BlockTime = 14 * time.Second
+	// streamServerReadyPollInterval is the time between the checks of the readiness of the data stream server when it's started
+	streamServerReadyPollInterval = 100 * time.Millisecond
+
+	// WorkerFullPolicyReject is the value for WorkerFullPolicy to drop the incoming txs when the worker is full
+	WorkerFullPolicyReject = "reject"
+	// WorkerFullPolicyBlock is the value for WorkerFullPolicy to stop loading txs from the pool when the worker is full
+	WorkerFullPolicyBlock = "block"
+
+	// TxTrackerErrorPolicyFail is the value for TxTrackerErrorPolicy to set as failed the txs whose tx tracker can't be created
+	TxTrackerErrorPolicyFail = "fail"
+	// TxTrackerErrorPolicyRetry is the value for TxTrackerErrorPolicy to keep as pending the txs whose tx tracker can't be created
+	TxTrackerErrorPolicyRetry = "retry"
+
+	// ModeActive is the value for Mode to load txs from the pool and produce L2 blocks
+	ModeActive = "active"
+	// ModeStandby is the value for Mode to only keep the data stream updated with the state, without producing L2 blocks
+	ModeStandby = "standby"
+
+	// PauseBufferFullPolicyBlock is the value for PauseBufferFullPolicy to stop reading L2 blocks when the pause buffer is full
+	PauseBufferFullPolicyBlock = "block"
+	// PauseBufferFullPolicyDrop is the value for PauseBufferFullPolicy to drop the L2 blocks when the pause buffer is full
+	PauseBufferFullPolicyDrop = "drop"
+
+	// TimestampSkewPolicyClamp is the value for TimestampSkewPolicy to stream the L2 block with the timestamp of the previous L2 block + 1
+	TimestampSkewPolicyClamp = "clamp"
+	// TimestampSkewPolicySkip is the value for TimestampSkewPolicy to not stream the L2 block
+	TimestampSkewPolicySkip = "skip"
+
+	// StreamEncodingBinary is the value for Encoding to use the fixed size binary encoding of the data stream entries
+	StreamEncodingBinary = "binary"
+	// StreamEncodingProtobuf is the value for Encoding to use the protobuf encoding of the data stream entries
+	StreamEncodingProtobuf = "protobuf"
+
+	// BlockStartFieldGlobalExitRoot is the value for BlockStartExcludedFields to exclude the global exit root of the L2 block start entries
+	BlockStartFieldGlobalExitRoot = "globalExitRoot"
+	// BlockStartFieldCoinbase is the value for BlockStartExcludedFields to exclude the coinbase of the L2 block start entries
+	BlockStartFieldCoinbase = "coinbase"
+	// BlockStartFieldForkID is the value for BlockStartExcludedFields to exclude the fork ID of the L2 block start entries
+	BlockStartFieldForkID = "forkID"
+)
+
+const (
+	// HaltReasonManual is the halt reason of the finalizer halted with HaltFinalizer
+	HaltReasonManual = "manual"
+	// HaltReasonStateInconsistency is the halt reason of the finalizer halted because a state inconsistency is detected
+	HaltReasonStateInconsistency = "stateInconsistency"
+	// HaltReasonAheadOfL1 is the halt reason of the finalizer halted because the trusted batches are too far ahead of L1
+	HaltReasonAheadOfL1 = "aheadOfL1"
+)
+
+const (
+	// LoopLoadFromPool is the name of the loop loading the pending txs from the pool into the worker
+	LoopLoadFromPool = "loadFromPool"
+	// LoopDeleteOldPoolTxs is the name of the loop deleting from the pool the txs older than DeletePoolTxsL1BlockConfirmations
+	LoopDeleteOldPoolTxs = "deleteOldPoolTxs"
+	// LoopExpireOldWorkerTxs is the name of the loop expiring the txs in the worker older than TxLifetimeMax
+	LoopExpireOldWorkerTxs = "expireOldWorkerTxs"
+	// LoopCheckStateInconsistency is the name of the loop halting the finalizer when a state inconsistency is detected
+	LoopCheckStateInconsistency = "checkStateInconsistency"
+	// LoopCheckBatchesAheadOfL1 is the name of the loop halting the finalizer when it's more than MaxBatchesAheadOfL1 batches ahead of L1
+	LoopCheckBatchesAheadOfL1 = "checkBatchesAheadOfL1"
+	// LoopTrackL2BlockFinality is the name of the loop tracking the finality of the L2 blocks streamed
+	LoopTrackL2BlockFinality = "trackL2BlockFinality"
+	// LoopMonitorDataToStream is the name of the loop sampling the metrics of the channel of L2 blocks to stream
+	LoopMonitorDataToStream = "monitorDataToStream"
+	// LoopLogMetrics is the name of the loop logging a snapshot of the key metrics every MetricsLogInterval
+	LoopLogMetrics = "logMetrics"
+	// LoopUpdateDataStreamerFile is the name of the loop updating the data stream with the new batches of the state in standby mode
+	LoopUpdateDataStreamerFile = "updateDataStreamerFile"
+	// LoopArchive is the name of the loop archiving the data stream to the object store. While it's disabled the entries are
+	// still accumulated, but not uploaded
+	LoopArchive = "archive"
+	// LoopPriorityStream is the name of the loop sending the qualifying txs of the L2 blocks streamed to the priority stream.
+	// While it's disabled the L2 blocks are kept queued, but not sent
+	LoopPriorityStream = "priorityStream"
+
+	// loopDisabledCheckInterval is the min time a disabled loop waits before checking again if it's enabled
+	loopDisabledCheckInterval = 100 * time.Millisecond
 )
 
+// loopNames are the names of the loops that can be disabled with SetLoopEnabled
+var loopNames = []string{
+	LoopLoadFromPool, LoopDeleteOldPoolTxs, LoopExpireOldWorkerTxs, LoopCheckStateInconsistency, LoopCheckBatchesAheadOfL1,
+	LoopTrackL2BlockFinality, LoopMonitorDataToStream, LoopLogMetrics, LoopUpdateDataStreamerFile, LoopArchive,
+	LoopPriorityStream,
+}
+
+// FinalizerHaltState is the halt state of the finalizer
+type FinalizerHaltState struct {
+	Halted bool
+	// Reason is the error of the first halt reason, the one the finalizer was halted with
+	Reason string
+	// Reasons are the active halt reasons, sorted. The finalizer is resumed once all of them are cleared
+	Reasons []string
+	// Timestamp is the time of the last change of the halt state
+	Timestamp time.Time
+}
+
 // Sequencer represents a sequencer
 type Sequencer struct {
 	cfg      Config
@@ -30,18 +143,99 @@ type Sequencer struct {
 	eventLog  *event.EventLog
 	etherman  etherman
 	worker    *Worker
-	finalizer *finalizer
+	finalizer finalizerInterface
+
+	// finalizerFactory creates the finalizer when the sequencer starts
+	finalizerFactory func(s *Sequencer) finalizerInterface
+	// streamServerFactory creates and starts the data stream server with the data stream file when the sequencer starts
+	// and when the file is swapped
+	streamServerFactory func(s *Sequencer, filename string) (*datastreamer.StreamServer, error)
+
+	txTransformer TxTransformer
+	dropRecords   *dropRecords
+	recentPoolTxs *recentPoolTxs
+
+	dropEventsThrottle *dropEventsThrottle
+
+	// senderRateLimiter limits the txs per second of each sender added to the worker
+	senderRateLimiter *senderRateLimiter
+
+	replacementRecords *replacementRecords
+
+	streamServer   *datastreamer.StreamServer
+	streamPipeline *streamPipeline
+	dataToStream   chan state.DSL2FullBlock
+	// swapStreamFileMutex serializes the swaps of the data stream file
+	swapStreamFileMutex sync.Mutex
 
-	streamServer *datastreamer.StreamServer
-	dataToStream chan state.DSL2FullBlock
+	// debugStream is the optional debug stream, nil if it's disabled
+	debugStream *debugStream
+
+	// sessionCounters are the counters accumulated since the last call to SnapshotAndResetCounters
+	sessionCounters *sessionCounters
+
+	// recentBlockHashes are the hashes of the last L2 blocks streamed
+	recentBlockHashes *recentBlockHashes
+	// priorityStream is the optional priority stream, nil if it's disabled
+	priorityStream *priorityStream
 
 	address common.Address
 
-	numberOfStateInconsistencies uint64
+	// numberOfStateInconsistencies is the number of state inconsistencies accepted, the finalizer is halted if more are detected.
+	// It's updated when the finalizer is resumed with ForceResumeFinalizer
+	numberOfStateInconsistencies atomic.Uint64
+
+	// haltState is the halt state of the finalizer, halted while there are active haltReasons
+	haltState   FinalizerHaltState
+	haltReasons map[string]error
+	haltMutex   sync.Mutex
+	// lastHaltTime is the time the finalizer was last halted and haltTimer the halt deferred until MinHaltInterval elapses since then
+	lastHaltTime time.Time
+	haltTimer    *time.Timer
+
+	// loadPoolTxsRampLimit is the current max number of txs loaded from the pool while ramping up
+	loadPoolTxsRampLimit uint64
+	loadPoolTxsRampDone  bool
+	// loadPoolTxsMutex serializes the periodic loads of txs from the pool and the ones requested with LoadFromPoolNow
+	loadPoolTxsMutex sync.Mutex
+
+	// paused is true while the sequencer doesn't load txs from the pool
+	paused atomic.Bool
+
+	// lastEthBatchNum is the last batch number got from L1, used when L1 can't be reached checking if the state is synced
+	lastEthBatchNum      uint64
+	lastEthBatchNumKnown bool
+	// lastEthBatchNumStale is true while lastEthBatchNum is used because L1 can't be reached
+	lastEthBatchNumStale bool
+	lastEthBatchNumMutex sync.Mutex
+
+	// expiredTxsToRetry are the expired txs whose status update in the pool failed, retried in the next expiration check,
+	// with the number of retries already done for each of them
+	expiredTxsToRetry map[common.Hash]uint64
+
+	// lastTxAgeWarnEvent is the time of the last event logged because of old txs in the worker
+	lastTxAgeWarnEvent time.Time
+
+	// disabledLoops are the names of the loops disabled with SetLoopEnabled, they skip their iterations until enabled again
+	disabledLoops sync.Map
 }
 
 // New init sequencer
 func New(cfg Config, batchCfg state.BatchConfig, poolCfg pool.Config, txPool txPool, stateIntf stateInterface, etherman etherman, eventLog *event.EventLog) (*Sequencer, error) {
+	var configErrors []string
+	for _, warning := range cfg.Validate() {
+		if warning.Severity == ConfigWarningSeverityError {
+			log.Errorf("invalid sequencer config, %s", warning)
+			configErrors = append(configErrors, warning.String())
+		} else {
+			log.Warnf("sequencer config warning, %s", warning)
+		}
+	}
+	if len(configErrors) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(configErrors, "; "))
+	}
+	setEventLogMinLevel(cfg.EventLogMinLevel)
+
 	addr, err := etherman.TrustedSequencer()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get trusted sequencer address, error: %w", err)
@@ -56,272 +250,1859 @@ func New(cfg Config, batchCfg state.BatchConfig, poolCfg pool.Config, txPool txP
 		etherman:  etherman,
 		address:   addr,
 		eventLog:  eventLog,
+
+		txTransformer: identityTxTransformer{},
+		dropRecords:   newDropRecords(cfg.DropRecordsSize),
+		recentPoolTxs: newRecentPoolTxs(cfg.LoadPoolTxsDedupTTL.Duration),
+
+		replacementRecords: newReplacementRecords(cfg.ReplacementRecordsSize),
+		dropEventsThrottle: newDropEventsThrottle(cfg.DropEventsMaxPerSecond),
+		senderRateLimiter:  newSenderRateLimiter(cfg.SenderRateLimit, cfg.SenderRateLimitBurst, cfg.SenderRateLimitNewSenderGrace),
+		sessionCounters:    &sessionCounters{},
+		recentBlockHashes:  newRecentBlockHashes(cfg.StreamServer.RecentBlockHashesSize),
+
+		finalizerFactory:    newSequencerFinalizer,
+		streamServerFactory: newSequencerStreamServer,
+		haltReasons:         map[string]error{},
 	}
 
-	sequencer.dataToStream = make(chan state.DSL2FullBlock, batchCfg.Constraints.MaxTxsPerBatch*datastreamChannelMultiplier)
+	dataToStreamBufferSize := cfg.StreamServer.ChannelBufferSize
+	if dataToStreamBufferSize == 0 {
+		dataToStreamBufferSize = batchCfg.Constraints.MaxTxsPerBatch * datastreamChannelMultiplier
+	}
+	if dataToStreamBufferSize == 0 {
+		return nil, ErrInvalidStreamChannelBufferSize
+	}
+	if _, err := blockStartFields(cfg.StreamServer.BlockStartExcludedFields); err != nil {
+		return nil, err
+	}
+	sequencer.dataToStream = make(chan state.DSL2FullBlock, dataToStreamBufferSize)
 
 	return sequencer, nil
 }
 
+// SetTxTransformer sets the TxTransformer applied to the pool txs before adding them to the worker.
+// It must be called before Start
+func (s *Sequencer) SetTxTransformer(txTransformer TxTransformer) {
+	s.txTransformer = txTransformer
+}
+
+// WhyDropped returns the most recent record of the decision that dropped the tx, if it's still kept
+func (s *Sequencer) WhyDropped(hash common.Hash) (DropRecord, bool) {
+	return s.dropRecords.get(hash)
+}
+
+// RecentBlockHashes returns the hashes of the last L2 blocks streamed by number, up to StreamServer.RecentBlockHashesSize
+func (s *Sequencer) RecentBlockHashes() map[uint64]common.Hash {
+	return s.recentBlockHashes.getAll()
+}
+
+// WorkerSnapshot returns a copy of the state of the worker. If the sequencer is not started the snapshot is empty
+func (s *Sequencer) WorkerSnapshot() WorkerSnapshot {
+	if s.worker == nil {
+		return WorkerSnapshot{}
+	}
+	return s.worker.Snapshot()
+}
+
+// PendingNonces returns the lowest nonce of the txs stored in the worker for each sender, to detect the senders whose
+// next nonce never clears. If the sequencer is not started the map is empty
+func (s *Sequencer) PendingNonces() map[common.Address]uint64 {
+	if s.worker == nil {
+		return map[common.Address]uint64{}
+	}
+	return s.worker.PendingNonces()
+}
+
+// recordDrop keeps the record of a tx dropped by the sequencer and logs it to the event log if LogDropsToEventLog is enabled
+func (s *Sequencer) recordDrop(hash common.Hash, phase DropPhase, reason string) {
+	record := DropRecord{
+		Hash:      hash,
+		Reason:    reason,
+		Phase:     phase,
+		Timestamp: time.Now(),
+	}
+	s.dropRecords.add(record)
+	s.sessionCounters.addDroppedTx(phase)
+	s.debugStream.addEntry(DebugEntryTypeTxDropped, record)
+	logStructuredEvent(LogEventTxDropped, "tx dropped", LogFieldTxHash, hash.String(), LogFieldPhase, string(phase), LogFieldReason, reason)
+
+	if !s.cfg.LogDropsToEventLog || !s.dropEventsThrottle.allow() {
+		return
+	}
+
+	event := &event.Event{
+		ReceivedAt:  record.Timestamp,
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Info,
+		EventID:     event.EventID_SequencerTxDropped,
+		Description: fmt.Sprintf("tx %s dropped, phase: %s, reason: %s", hash.String(), phase, reason),
+		Json:        record,
+	}
+
+	logEvent(context.Background(), s.eventLog, event)
+}
+
+// RecentReplacements returns the most recent records of txs replaced in the worker, from the oldest to the most recent
+func (s *Sequencer) RecentReplacements() []ReplacementRecord {
+	return s.replacementRecords.getAll()
+}
+
+// recordReplacement counts and keeps the record of a tx in the worker replaced by a new tx with the same nonce
+func (s *Sequencer) recordReplacement(replacedTx *TxTracker, newTx *TxTracker) {
+	reason := metrics.TxReplacedLabelHigherGasPrice
+	if newTx.GasPrice.Cmp(replacedTx.GasPrice) == 0 {
+		reason = metrics.TxReplacedLabelSameGasPrice
+	}
+	metrics.TxReplaced(reason)
+
+	record := ReplacementRecord{
+		Hash:       replacedTx.Hash,
+		ReplacedBy: newTx.Hash,
+		From:       replacedTx.From,
+		Reason:     reason,
+		Timestamp:  time.Now(),
+	}
+	s.replacementRecords.add(record)
+	s.debugStream.addEntry(DebugEntryTypeTxReplaced, record)
+}
+
 // Start starts the sequencer
 func (s *Sequencer) Start(ctx context.Context) {
 	for !s.isSynced(ctx) {
 		log.Infof("waiting for synchronizer to sync...")
 		time.Sleep(time.Second)
 	}
+	logStructuredEvent(LogEventSynced, "state synced, starting the sequencer", LogFieldMode, s.cfg.Mode)
+	s.logEffectiveConfig()
 	metrics.Register()
+	if s.cfg.MetricsForkIDLabel {
+		metrics.RegisterForkIDMetrics()
+	}
+
+	if s.cfg.Mode == ModeStandby {
+		s.startStandby(ctx)
+		return
+	}
 
 	err := s.pool.MarkWIPTxsAsPending(ctx)
 	if err != nil {
 		log.Fatalf("failed to mark WIP txs as pending, error: %w", err)
 	}
 
+	if s.cfg.ReconcilePendingTxsAtStartup {
+		s.reconcilePendingTxs(ctx)
+	}
+	s.restoreLoadCursor(ctx)
+
 	// Start stream server if enabled
 	if s.cfg.StreamServer.Enabled {
-		s.streamServer, err = datastreamer.NewServer(s.cfg.StreamServer.Port, state.StreamTypeSequencer, s.cfg.StreamServer.Filename, &s.cfg.StreamServer.Log)
+		err = s.setupStreamServer(ctx)
 		if err != nil {
-			log.Fatalf("failed to create stream server, error: %w", err)
+			log.Fatal(err)
 		}
-
-		err = s.streamServer.Start()
-		if err != nil {
-			log.Fatalf("failed to start stream server, error: %w", err)
+		if s.streamServer != nil && s.cfg.StreamServer.PriorityStream.Enabled {
+			s.setupPriorityStream()
 		}
+	}
 
-		s.updateDataStreamerFile(ctx)
+	// Start debug stream server if enabled
+	if s.cfg.DebugStreamServer.Enabled {
+		s.setupDebugStream()
 	}
 
-	go s.loadFromPool(ctx)
+	// The worker and the finalizer must be created before starting the loops that access them
+	s.worker = NewWorker(s.stateIntf, s.batchCfg.Constraints)
+	s.finalizer = s.finalizerFactory(s)
+
+	go s.superviseLoop(ctx, LoopLoadFromPool, s.loadFromPool)
 
 	if s.streamServer != nil {
+		var streamServer dataStreamServer = s.streamServer
+		if s.cfg.StreamServer.Archive.Enabled {
+			sink := newObjectStoreSink(s.cfg.StreamServer.Archive, newS3ObjectStore(s.cfg.StreamServer.Archive), s.eventLog)
+			sink.uploadEnabled = func() bool { return s.isLoopEnabled(LoopArchive) }
+			// The entries written by the regeneration in background are backfilled by the sink once the next entry is committed
+			totalEntries := s.streamServer.GetHeader().TotalEntries
+			go s.superviseLoop(ctx, LoopArchive, func(ctx context.Context) {
+				sink.start(ctx, s.streamServer, totalEntries)
+			})
+			streamServer = newArchivedStreamServer(s.streamServer, sink)
+		}
+		s.streamPipeline = newStreamPipeline(s.cfg.StreamServer, streamServer, s.stateIntf, s.eventLog, s.dataToStream)
+		s.streamPipeline.debugStream = s.debugStream
+		s.streamPipeline.sessionCounters = s.sessionCounters
+		s.streamPipeline.recentBlockHashes = s.recentBlockHashes
+		s.streamPipeline.priorityStream = s.priorityStream
+		if s.priorityStream != nil {
+			go s.superviseLoop(ctx, LoopPriorityStream, s.priorityStream.start)
+		}
+		if s.regenerateInBackground() {
+			// The L2 blocks produced meanwhile are held by the stream pipeline until the data streamer file is updated
+			s.streamPipeline.regenerating.Store(true)
+			go s.regenerateDataStreamerFile(ctx)
+		} else {
+			s.streamPipeline.restoreLastStreamed(s.streamServer)
+		}
 		go s.sendDataToStreamer()
+		go s.superviseLoop(ctx, LoopMonitorDataToStream, s.monitorDataToStream)
+
+		if s.cfg.StreamServer.FinalityCheckInterval.Duration > 0 {
+			go s.superviseLoop(ctx, LoopTrackL2BlockFinality, s.trackL2BlockFinalityLoop)
+		}
 	}
 
-	s.worker = NewWorker(s.stateIntf, s.batchCfg.Constraints)
-	s.finalizer = newFinalizer(s.cfg.Finalizer, s.poolCfg, s.worker, s.pool, s.stateIntf, s.etherman, s.address, s.isSynced, s.batchCfg.Constraints, s.eventLog, s.streamServer, s.dataToStream)
-	go s.finalizer.Start(ctx)
+	s.startFinalizer(ctx)
+
+	go s.superviseLoop(ctx, LoopDeleteOldPoolTxs, s.deleteOldPoolTxs)
+
+	go s.superviseLoop(ctx, LoopExpireOldWorkerTxs, s.expireOldWorkerTxs)
+
+	go s.superviseLoop(ctx, LoopCheckStateInconsistency, s.checkStateInconsistency)
+
+	if s.cfg.MaxBatchesAheadOfL1 > 0 {
+		go s.superviseLoop(ctx, LoopCheckBatchesAheadOfL1, s.checkBatchesAheadOfL1Loop)
+	}
+
+	if s.cfg.MetricsLogInterval.Duration > 0 {
+		go s.superviseLoop(ctx, LoopLogMetrics, func(ctx context.Context) {
+			ticker := time.NewTicker(s.cfg.MetricsLogInterval.Duration)
+			defer ticker.Stop()
+			s.logMetricsLoop(ctx, ticker.C)
+		})
+	}
+
+	// Wait until context is done
+	<-ctx.Done()
+}
 
-	go s.deleteOldPoolTxs(ctx)
+// startStandby starts the sequencer in standby mode. Only the data stream is kept updated with the closed batches of the state
+func (s *Sequencer) startStandby(ctx context.Context) {
+	log.Infof("sequencer started in standby mode, L2 blocks are not produced")
 
-	go s.expireOldWorkerTxs(ctx)
+	if s.cfg.StreamServer.Enabled {
+		err := s.setupStreamServer(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	go s.checkStateInconsistency(ctx)
+	if s.streamServer != nil {
+		go s.superviseLoop(ctx, LoopUpdateDataStreamerFile, s.updateDataStreamerFileLoop)
+	}
 
 	// Wait until context is done
 	<-ctx.Done()
 }
 
+// updateDataStreamerFileLoop keeps updating the data streamer file with the new batches of the state in standby mode
+func (s *Sequencer) updateDataStreamerFileLoop(ctx context.Context) {
+	for s.nextLoopIteration(ctx, LoopUpdateDataStreamerFile, s.cfg.StandbyStreamUpdateInterval.Duration) {
+		update, err := s.updateDataStreamerFile(ctx)
+		if err != nil {
+			log.Errorf("failed to update data streamer file in standby mode, error: %w", err)
+			continue
+		}
+		log.Debugf("data streamer file updated, %s", update)
+	}
+}
+
+// startFinalizer starts the finalizer, already created with the finalizer factory, after the warmup
+func (s *Sequencer) startFinalizer(ctx context.Context) {
+	go func() {
+		s.waitFinalizerWarmup(ctx)
+		s.finalizer.Start(ctx)
+	}()
+}
+
+// waitFinalizerWarmup waits until FinalizerWarmupDelay elapses or the worker has FinalizerWarmupMinTxs txs
+func (s *Sequencer) waitFinalizerWarmup(ctx context.Context) {
+	if s.cfg.FinalizerWarmupDelay.Duration == 0 {
+		return
+	}
+
+	log.Infof("waiting finalizer warmup, delay: %s, min worker txs: %d", s.cfg.FinalizerWarmupDelay.Duration, s.cfg.FinalizerWarmupMinTxs)
+	deadline := time.Now().Add(s.cfg.FinalizerWarmupDelay.Duration)
+	for time.Now().Before(deadline) {
+		if s.cfg.FinalizerWarmupMinTxs > 0 && uint64(s.worker.CountTxs()) >= s.cfg.FinalizerWarmupMinTxs {
+			log.Infof("finalizer warmup finished, worker reached %d txs", s.cfg.FinalizerWarmupMinTxs)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(min(finalizerWarmupCheckInterval, time.Until(deadline))):
+		}
+	}
+	log.Infof("finalizer warmup finished, delay %s elapsed", s.cfg.FinalizerWarmupDelay.Duration)
+}
+
+// newSequencerFinalizer creates the finalizer of the sequencer
+func newSequencerFinalizer(s *Sequencer) finalizerInterface {
+	f := newFinalizer(s.cfg.Finalizer, s.poolCfg, s.worker, s.pool, s.stateIntf, s.etherman, s.address, s.isSynced, s.batchCfg.Constraints, s.eventLog, s.streamServer, s.dataToStream)
+	f.traceStorageChanges = s.streamServer != nil && s.cfg.StreamServer.IncludeStorageDiffs
+	f.streamBlockLogs = s.streamServer != nil && s.cfg.StreamServer.IncludeBlockBlooms
+	return f
+}
+
 // checkStateInconsistency checks if state inconsistency happened
 func (s *Sequencer) checkStateInconsistency(ctx context.Context) {
-	for {
-		time.Sleep(s.cfg.StateConsistencyCheckInterval.Duration)
+	for s.nextLoopIteration(ctx, LoopCheckStateInconsistency, s.cfg.StateConsistencyCheckInterval.Duration) {
 		stateInconsistenciesDetected, err := s.stateIntf.CountReorgs(ctx, nil)
 		if err != nil {
 			log.Error("failed to get number of reorgs, error: %w", err)
 			return
 		}
+		metrics.StateInconsistencies(float64(stateInconsistenciesDetected))
 
-		if stateInconsistenciesDetected != s.numberOfStateInconsistencies {
-			s.finalizer.Halt(ctx, fmt.Errorf("state inconsistency detected, halting finalizer"))
+		if stateInconsistenciesDetected != s.numberOfStateInconsistencies.Load() {
+			s.haltFinalizer(HaltReasonStateInconsistency, fmt.Errorf("state inconsistency detected, halting finalizer"))
 		}
 	}
 }
 
-func (s *Sequencer) updateDataStreamerFile(ctx context.Context) {
-	err := state.GenerateDataStreamerFile(ctx, s.streamServer, s.stateIntf, true, nil)
-	if err != nil {
-		log.Fatalf("failed to generate data streamer file, error: %w", err)
-	}
-	log.Info("data streamer file updated")
-}
-
-func (s *Sequencer) deleteOldPoolTxs(ctx context.Context) {
+// logMetricsLoop logs a snapshot of the key metrics of the sequencer each time tick fires
+func (s *Sequencer) logMetricsLoop(ctx context.Context, tick <-chan time.Time) {
 	for {
-		time.Sleep(s.cfg.DeletePoolTxsCheckInterval.Duration)
-		log.Infof("trying to get txs to delete from the pool...")
-		txHashes, err := s.stateIntf.GetTxsOlderThanNL1Blocks(ctx, s.cfg.DeletePoolTxsL1BlockConfirmations, nil)
-		if err != nil {
-			log.Errorf("failed to get txs hashes to delete, error: %w", err)
-			continue
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
 		}
-		log.Infof("trying to delete %d selected txs", len(txHashes))
-		err = s.pool.DeleteTransactionsByHashes(ctx, txHashes)
-		if err != nil {
-			log.Errorf("failed to delete selected txs from the pool, error: %w", err)
+		if !s.isLoopEnabled(LoopLogMetrics) {
 			continue
 		}
-		log.Infof("deleted %d selected txs from the pool", len(txHashes))
 
-		log.Infof("trying to delete failed txs from the pool")
-		// Delete failed txs older than a certain date (14 seconds per L1 block)
-		err = s.pool.DeleteFailedTransactionsOlderThan(ctx, time.Now().Add(-time.Duration(s.cfg.DeletePoolTxsL1BlockConfirmations*14)*time.Second)) //nolint:gomnd
-		if err != nil {
-			log.Errorf("failed to delete failed txs from the pool, error: %w", err)
-			continue
-		}
-		log.Infof("failed txs deleted from the pool")
+		s.updateDataToStreamMetrics()
+		log.Infof("metrics snapshot, %s", metrics.TakeSnapshot())
 	}
 }
 
-func (s *Sequencer) expireOldWorkerTxs(ctx context.Context) {
-	for {
-		time.Sleep(s.cfg.TxLifetimeCheckInterval.Duration)
-		txTrackers := s.worker.ExpireTransactions(s.cfg.TxLifetimeMax.Duration)
-		failedReason := ErrExpiredTransaction.Error()
-		for _, txTracker := range txTrackers {
-			err := s.pool.UpdateTxStatus(ctx, txTracker.Hash, pool.TxStatusFailed, false, &failedReason)
-			metrics.TxProcessed(metrics.TxProcessedLabelFailed, 1)
-			if err != nil {
-				log.Errorf("failed to update tx status, error: %w", err)
-			}
-		}
+// trackL2BlockFinalityLoop tracks every FinalityCheckInterval the safe and finalized L2 blocks sent to the stream
+func (s *Sequencer) trackL2BlockFinalityLoop(ctx context.Context) {
+	for s.nextLoopIteration(ctx, LoopTrackL2BlockFinality, s.cfg.StreamServer.FinalityCheckInterval.Duration) {
+		s.trackL2BlockFinality(ctx)
 	}
 }
 
-// loadFromPool keeps loading transactions from the pool
-func (s *Sequencer) loadFromPool(ctx context.Context) {
-	for {
-		time.Sleep(s.cfg.LoadPoolTxsCheckInterval.Duration)
+// trackL2BlockFinality gets the last L2 blocks virtualized (safe) and consolidated (finalized) in L1, so the data stream
+// pipeline streams their finality updates
+func (s *Sequencer) trackL2BlockFinality(ctx context.Context) {
+	safeL2BlockNumber, err := s.stateIntf.GetLastVirtualizedL2BlockNumber(ctx, nil)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		log.Errorf("failed to get the last virtualized l2block number, error: %v", err)
+		return
+	}
 
-		poolTransactions, err := s.pool.GetNonWIPPendingTxs(ctx)
-		if err != nil && err != pool.ErrNotFound {
-			log.Errorf("error loading txs from pool, error: %w", err)
-		}
+	finalizedL2BlockNumber, err := s.stateIntf.GetLastConsolidatedL2BlockNumber(ctx, nil)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		log.Errorf("failed to get the last consolidated l2block number, error: %v", err)
+		return
+	}
 
-		for _, tx := range poolTransactions {
-			err := s.addTxToWorker(ctx, tx)
-			if err != nil {
-				log.Errorf("error adding transaction to worker, error: %w", err)
-			}
-		}
+	s.streamPipeline.setL2BlockFinality(safeL2BlockNumber, finalizedL2BlockNumber)
+}
+
+// checkBatchesAheadOfL1Loop checks every BatchesAheadOfL1CheckInterval the number of trusted batches ahead of L1
+func (s *Sequencer) checkBatchesAheadOfL1Loop(ctx context.Context) {
+	for s.nextLoopIteration(ctx, LoopCheckBatchesAheadOfL1, s.cfg.BatchesAheadOfL1CheckInterval.Duration) {
+		s.checkBatchesAheadOfL1(ctx)
 	}
 }
 
-func (s *Sequencer) addTxToWorker(ctx context.Context, tx pool.Transaction) error {
-	txTracker, err := s.worker.NewTxTracker(tx.Transaction, tx.ZKCounters, tx.IP)
+// checkBatchesAheadOfL1 halts the finalizer if the last trusted batch is more than MaxBatchesAheadOfL1 batches ahead of the
+// last virtual batch, and resumes it when the gap drops back to MaxBatchesAheadOfL1
+func (s *Sequencer) checkBatchesAheadOfL1(ctx context.Context) {
+	lastVirtualBatchNum, lastTrustedBatchNum, err := s.getLastBatchNumbers(ctx)
 	if err != nil {
-		return err
+		log.Errorf("failed to check batches ahead of L1, error: %w", err)
+		return
 	}
-	replacedTx, dropReason := s.worker.AddTxTracker(ctx, txTracker)
-	if dropReason != nil {
-		failedReason := dropReason.Error()
-		return s.pool.UpdateTxStatus(ctx, txTracker.Hash, pool.TxStatusFailed, false, &failedReason)
-	} else {
-		if replacedTx != nil {
-			failedReason := ErrReplacedTransaction.Error()
-			err := s.pool.UpdateTxStatus(ctx, replacedTx.Hash, pool.TxStatusFailed, false, &failedReason)
-			if err != nil {
-				log.Warnf("error when setting as failed replacedTx %s, error: %w", replacedTx.HashStr, err)
-			}
+
+	var batchesAhead uint64
+	if lastTrustedBatchNum > lastVirtualBatchNum {
+		batchesAhead = lastTrustedBatchNum - lastVirtualBatchNum
+	}
+
+	if batchesAhead > s.cfg.MaxBatchesAheadOfL1 {
+		if s.haltFinalizer(HaltReasonAheadOfL1, fmt.Errorf("%w, %d batches ahead of the last virtual batch %d", ErrTooFarAheadOfL1, batchesAhead, lastVirtualBatchNum)) {
+			log.Warnf("halting finalizer, %d batches ahead of L1, lastTrustedBatchNum: %d, lastVirtualBatchNum: %d", batchesAhead, lastTrustedBatchNum, lastVirtualBatchNum)
 		}
-		return s.pool.UpdateTxWIPStatus(ctx, tx.Hash(), true)
+		return
+	}
+
+	if s.clearFinalizerHaltReasons(HaltReasonAheadOfL1) {
+		log.Infof("finalizer halt reason cleared, %d batches ahead of L1", batchesAhead)
 	}
 }
 
-// sendDataToStreamer sends data to the data stream server
-func (s *Sequencer) sendDataToStreamer() {
+// setupStreamServer creates and starts the stream server and updates the data streamer file. If it fails and the
+// stream server is not required at startup, the streaming is disabled and the error is only returned when it's required
+func (s *Sequencer) setupStreamServer(ctx context.Context) error {
+	err := s.startStreamServer(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if s.cfg.StreamServer.RequiredAtStartup {
+		return err
+	}
+
+	s.streamServer = nil
+	logStreamingDisabled(ctx, s.eventLog, err)
+
+	return nil
+}
+
+// logStreamingDisabled logs an event signaling that the streaming is disabled because of err
+func logStreamingDisabled(ctx context.Context, eventLog *event.EventLog, err error) {
+	description := fmt.Sprintf("streaming disabled, %s", err)
+	log.Error(description)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Error,
+		EventID:     event.EventID_DataStreamerDisabled,
+		Description: description,
+	}
+
+	logEvent(ctx, eventLog, event)
+}
+
+// startStreamServer creates and starts the stream server and updates the data streamer file
+func (s *Sequencer) startStreamServer(ctx context.Context) error {
 	var err error
-	for {
-		// Read error from previous iteration
+	s.streamServer, err = s.createStreamServer(ctx, s.cfg.StreamServer.Filename)
+	if err != nil {
+		return err
+	}
+
+	port := s.cfg.StreamServer.Port
+	err = s.waitStreamServerReady(ctx, func() bool { return isStreamServerListening(port) })
+	if err != nil {
+		return err
+	}
+
+	err = checkStreamSchema(s.streamServer, s.cfg.StreamServer)
+	if err != nil {
+		return err
+	}
+
+	// The data streamer file is updated in the background once the stream pipeline is created
+	if !s.regenerateInBackground() {
+		update, err := s.updateDataStreamerFile(ctx)
 		if err != nil {
-			err = s.streamServer.RollbackAtomicOp()
-			if err != nil {
-				log.Errorf("failed to rollback atomic op, error: %w", err)
-			}
-			s.streamServer = nil
+			return err
 		}
+		log.Infof("data streamer file updated, %s", update)
+	}
+	logStructuredEvent(LogEventStreamingStarted, "streaming started", LogFieldPort, s.cfg.StreamServer.Port, LogFieldEntries, s.streamServer.GetHeader().TotalEntries)
 
-		// Read data from channel
-		fullL2Block := <-s.dataToStream
-
-		l2Block := fullL2Block
-		l2Transactions := fullL2Block.Txs
+	return nil
+}
 
-		if s.streamServer != nil {
-			err = s.streamServer.StartAtomicOp()
-			if err != nil {
-				log.Errorf("failed to start atomic op for l2block %d, error: %w ", l2Block.L2BlockNumber, err)
-				continue
-			}
+// regenerateInBackground returns true if the data streamer file is updated at startup in the background (RegenerateInBackground).
+// In standby mode it's always updated before streaming, as no L2 blocks are produced
+func (s *Sequencer) regenerateInBackground() bool {
+	return s.cfg.StreamServer.RegenerateInBackground && s.cfg.Mode != ModeStandby
+}
 
-			bookMark := state.DSBookMark{
-				Type:          state.BookMarkTypeL2Block,
-				L2BlockNumber: l2Block.L2BlockNumber,
-			}
+// regenerateDataStreamerFile updates the data streamer file with the batches of the state while the finalizer produces
+// L2 blocks, ending the regeneration of the stream pipeline afterwards so the L2 blocks held meanwhile are streamed. If the
+// update fails the sequencer exits if the stream server is required at startup, otherwise the streaming is disabled
+func (s *Sequencer) regenerateDataStreamerFile(ctx context.Context) {
+	update, err := s.updateDataStreamerFile(ctx)
+	if err != nil {
+		if s.cfg.StreamServer.RequiredAtStartup {
+			log.Fatal(err)
+		}
+		// The stream pipeline discards the L2 blocks without stream server
+		s.streamPipeline.streamServer = nil
+		logStreamingDisabled(ctx, s.eventLog, err)
+	} else {
+		log.Infof("data streamer file updated in the background, %s", update)
+		s.streamPipeline.restoreLastStreamed(s.streamServer)
+	}
+	s.streamPipeline.endRegeneration()
+}
 
-			_, err = s.streamServer.AddStreamBookmark(bookMark.Encode())
-			if err != nil {
-				log.Errorf("failed to add stream bookmark for l2block %d, error: %w", l2Block.L2BlockNumber, err)
-				continue
-			}
+// createStreamServer creates and starts the stream server with the data stream file. If it fails it's retried up to StartMaxRetries times, waiting
+// StartRetryInterval before the first retry and doubling it on each retry
+func (s *Sequencer) createStreamServer(ctx context.Context, filename string) (*datastreamer.StreamServer, error) {
+	interval := s.cfg.StreamServer.StartRetryInterval.Duration
+	for retry := uint64(0); ; retry++ {
+		streamServer, err := s.streamServerFactory(s, filename)
+		if err == nil {
+			return streamServer, nil
+		}
+		if retry >= s.cfg.StreamServer.StartMaxRetries {
+			return nil, err
+		}
 
-			blockStart := state.DSL2BlockStart{
-				BatchNumber:    l2Block.BatchNumber,
-				L2BlockNumber:  l2Block.L2BlockNumber,
-				Timestamp:      l2Block.Timestamp,
-				GlobalExitRoot: l2Block.GlobalExitRoot,
-				Coinbase:       l2Block.Coinbase,
-				ForkID:         l2Block.ForkID,
-			}
+		log.Warnf("%s, retrying in %s (retry %d of %d)", err, interval, retry+1, s.cfg.StreamServer.StartMaxRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
 
-			_, err = s.streamServer.AddStreamEntry(state.EntryTypeL2BlockStart, blockStart.Encode())
-			if err != nil {
-				log.Errorf("failed to add stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
-				continue
-			}
+// streamServerLogOnce initializes the logger of the data streamer once. The logger is global, so it can't be initialized
+// again while other stream servers, e.g. the priority stream or the ones retried at startup, are running
+var streamServerLogOnce sync.Once
 
-			for _, l2Transaction := range l2Transactions {
-				// Populate intermediate state root
-				position := state.GetSystemSCPosition(blockStart.L2BlockNumber)
-				imStateRoot, err := s.stateIntf.GetStorageAt(context.Background(), common.HexToAddress(state.SystemSC), big.NewInt(0).SetBytes(position), l2Block.StateRoot)
-				if err != nil {
-					log.Errorf("failed to get storage at for l2block %d, error: %w", l2Block.L2BlockNumber, err)
-				}
-				l2Transaction.StateRoot = common.BigToHash(imStateRoot)
+// initStreamServerLog initializes the logger of the data streamer with the config, if it's not initialized yet
+func initStreamServerLog(cfg dslog.Config) {
+	streamServerLogOnce.Do(func() {
+		dslog.Init(cfg)
+	})
+}
 
-				_, err = s.streamServer.AddStreamEntry(state.EntryTypeL2Tx, l2Transaction.Encode())
-				if err != nil {
-					log.Errorf("failed to add l2tx stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
-					continue
-				}
-			}
+// newSequencerStreamServer creates and starts the data stream server of the data stream file with the config of the sequencer
+func newSequencerStreamServer(s *Sequencer, filename string) (*datastreamer.StreamServer, error) {
+	initStreamServerLog(s.cfg.StreamServer.Log)
+	streamServer, err := datastreamer.NewServer(s.cfg.StreamServer.Port, state.StreamTypeSequencer, filename, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream server, error: %w", err)
+	}
 
-			blockEnd := state.DSL2BlockEnd{
-				L2BlockNumber: l2Block.L2BlockNumber,
-				BlockHash:     l2Block.BlockHash,
-				StateRoot:     l2Block.StateRoot,
-			}
+	err = streamServer.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start stream server, error: %w", err)
+	}
+	return streamServer, nil
+}
 
-			_, err = s.streamServer.AddStreamEntry(state.EntryTypeL2BlockEnd, blockEnd.Encode())
-			if err != nil {
-				log.Errorf("failed to add stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
-				continue
-			}
+// waitStreamServerReady waits StartupDelay and then, if ReadyTimeout is set, until ready returns true, so the data stream server
+// is fully initialized before it's written for the first time. It returns ErrStreamServerNotReady if it's not ready in ReadyTimeout
+func (s *Sequencer) waitStreamServerReady(ctx context.Context, ready func() bool) error {
+	if s.cfg.StreamServer.StartupDelay.Duration > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.cfg.StreamServer.StartupDelay.Duration):
+		}
+	}
 
-			err = s.streamServer.CommitAtomicOp()
-			if err != nil {
-				log.Errorf("failed to commit atomic op for l2block %d, error: %w ", l2Block.L2BlockNumber, err)
-				continue
-			}
+	if s.cfg.StreamServer.ReadyTimeout.Duration == 0 {
+		return nil
+	}
+	deadline := time.Now().Add(s.cfg.StreamServer.ReadyTimeout.Duration)
+	for !ready() {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w after %s", ErrStreamServerNotReady, s.cfg.StreamServer.ReadyTimeout.Duration)
+		}
+		log.Infof("waiting for the stream server to be ready")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(streamServerReadyPollInterval):
 		}
 	}
+	return nil
 }
 
-func (s *Sequencer) isSynced(ctx context.Context) bool {
-	lastVirtualBatchNum, err := s.stateIntf.GetLastVirtualBatchNum(ctx, nil)
-	if err != nil && err != state.ErrNotFound {
-		log.Errorf("failed to get last isSynced batch, error: %w", err)
-		return false
-	}
-	lastTrustedBatchNum, err := s.stateIntf.GetLastBatchNumber(ctx, nil)
-	if err != nil && err != state.ErrNotFound {
-		log.Errorf("failed to get last batch num, error: %w", err)
+// isStreamServerListening returns true if the data stream server accepts connections on the given port
+func isStreamServerListening(port uint16) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(int(port))), streamServerReadyPollInterval)
+	if err != nil {
 		return false
 	}
-	if lastTrustedBatchNum > lastVirtualBatchNum {
-		return true
-	}
-	lastEthBatchNum, err := s.etherman.GetLatestBatchNumber()
+	conn.Close()
+	return true
+}
+
+// DataStreamerFileUpdate is the summary of an update of the data streamer file with the batches of the state
+type DataStreamerFileUpdate struct {
+	// EntriesWritten is the number of entries added to the data streamer file
+	EntriesWritten uint64
+	// FromBatchNumber and ToBatchNumber are the first and last batches added to the data streamer file. They are 0 if no batches were added
+	FromBatchNumber uint64
+	ToBatchNumber   uint64
+	// Duration is the time spent updating the data streamer file
+	Duration time.Duration
+}
+
+// String returns the summary of the update as a string
+func (u DataStreamerFileUpdate) String() string {
+	return fmt.Sprintf("entries written: %d, batches: %d to %d, duration: %v", u.EntriesWritten, u.FromBatchNumber, u.ToBatchNumber, u.Duration)
+}
+
+// updateDataStreamerFile adds to the data streamer file the batches of the state not streamed yet. In standby mode
+// the WIP batch is not streamed, as its L2 blocks are not streamed by the finalizer. If it fails it's retried up to
+// FileUpdateMaxRetries times, resuming from the last entry committed
+func (s *Sequencer) updateDataStreamerFile(ctx context.Context) (DataStreamerFileUpdate, error) {
+	start := time.Now()
+	initialEntries := s.streamServer.GetHeader().TotalEntries
+	initialBatchNumber, err := getLastStreamedBatchNumber(s.streamServer)
+	if err != nil {
+		return DataStreamerFileUpdate{}, fmt.Errorf("failed to get the last batch number in the data stream, error: %w", err)
+	}
+
+	readWIPBatch := s.cfg.Mode != ModeStandby
+	err = state.GenerateDataStreamerFile(ctx, s.streamServer, s.stateIntf, readWIPBatch, nil, newL2BlockEntriesConfig(s.cfg.StreamServer))
+	for retry := uint64(0); err != nil && retry < s.cfg.StreamServer.FileUpdateMaxRetries; retry++ {
+		log.Warnf("failed to generate data streamer file, retrying in %v, error: %w", s.cfg.StreamServer.FileUpdateRetryInterval.Duration, err)
+		select {
+		case <-ctx.Done():
+			return DataStreamerFileUpdate{}, ctx.Err()
+		case <-time.After(s.cfg.StreamServer.FileUpdateRetryInterval.Duration):
+		}
+		err = state.GenerateDataStreamerFile(ctx, s.streamServer, s.stateIntf, readWIPBatch, nil, newL2BlockEntriesConfig(s.cfg.StreamServer))
+	}
+	if err != nil {
+		return DataStreamerFileUpdate{}, fmt.Errorf("failed to generate data streamer file, error: %w", err)
+	}
+
+	update := DataStreamerFileUpdate{
+		EntriesWritten: s.streamServer.GetHeader().TotalEntries - initialEntries,
+	}
+	lastBatchNumber, err := getLastStreamedBatchNumber(s.streamServer)
+	if err != nil {
+		return DataStreamerFileUpdate{}, fmt.Errorf("failed to get the last batch number in the data stream, error: %w", err)
+	}
+	if lastBatchNumber > initialBatchNumber {
+		update.FromBatchNumber = initialBatchNumber + 1
+		update.ToBatchNumber = lastBatchNumber
+	}
+	update.Duration = time.Since(start)
+
+	return update, nil
+}
+
+// getLastStreamedEntry returns the last entry of the data stream, skipping the L2 block finality updates. If the data stream
+// has no entries an empty entry is returned
+func getLastStreamedEntry(streamServer *datastreamer.StreamServer) (datastreamer.FileEntry, error) {
+	header := streamServer.GetHeader()
+	if header.TotalEntries == 0 {
+		return datastreamer.FileEntry{}, nil
+	}
+
+	latestEntry, err := streamServer.GetEntry(header.TotalEntries - 1)
+	for err == nil && latestEntry.Type == state.EntryTypeL2BlockFinality && latestEntry.Number > 0 {
+		latestEntry, err = streamServer.GetEntry(latestEntry.Number - 1)
+	}
+	return latestEntry, err
+}
+
+// getLastStreamedL2BlockNumber returns the number of the last L2 block stored in the data stream. If the last entry, skipping
+// the batch end, is not a L2 block end it returns 0
+func getLastStreamedL2BlockNumber(streamServer *datastreamer.StreamServer) (uint64, error) {
+	latestEntry, err := getLastStreamedEntry(streamServer)
+	if err == nil && latestEntry.Type == state.EntryTypeBatchEnd && latestEntry.Number > 0 {
+		latestEntry, err = streamServer.GetEntry(latestEntry.Number - 1)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if state.IsL2BlockEndEntry(latestEntry.Type) {
+		blockEnd, err := state.DecodeL2BlockEndEntry(latestEntry)
+		return blockEnd.L2BlockNumber, err
+	}
+	return 0, nil
+}
+
+// getLastStreamedBatchNumber returns the batch number of the last L2 block or GER update stored in the data stream
+func getLastStreamedBatchNumber(streamServer *datastreamer.StreamServer) (uint64, error) {
+	latestEntry, err := getLastStreamedEntry(streamServer)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case latestEntry.Type == state.EntryTypeUpdateGER:
+		return state.DSUpdateGER{}.Decode(latestEntry.Data).BatchNumber, nil
+	case state.IsL2BlockEndEntry(latestEntry.Type):
+		blockEnd, err := state.DecodeL2BlockEndEntry(latestEntry)
+		if err != nil {
+			return 0, err
+		}
+		bookMark := state.DSBookMark{
+			Type:          state.BookMarkTypeL2Block,
+			L2BlockNumber: blockEnd.L2BlockNumber,
+		}
+
+		firstEntry, err := streamServer.GetFirstEventAfterBookmark(bookMark.Encode())
+		if err != nil {
+			return 0, err
+		}
+		return state.DecodeL2BlockStartBatchNumber(firstEntry)
+	}
+
+	return 0, nil
+}
+
+// getLastStreamedStateRoot returns the state root of the last L2 block end or GER update entry in the data stream
+func getLastStreamedStateRoot(streamServer *datastreamer.StreamServer) (common.Hash, error) {
+	latestEntry, err := getLastStreamedEntry(streamServer)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	switch {
+	case latestEntry.Type == state.EntryTypeUpdateGER:
+		return state.DSUpdateGER{}.Decode(latestEntry.Data).StateRoot, nil
+	case state.IsL2BlockEndEntry(latestEntry.Type):
+		blockEnd, err := state.DecodeL2BlockEndEntry(latestEntry)
+		return blockEnd.StateRoot, err
+	}
+
+	return common.Hash{}, nil
+}
+
+// deleteOldPoolTxs periodically deletes from the pool the txs confirmed in L1 and the old failed txs. Both deletions are
+// independent: if the deletion of the failed txs fails it's retried after DeleteFailedPoolTxsRetryInterval without
+// deleting the confirmed txs again
+func (s *Sequencer) deleteOldPoolTxs(ctx context.Context) {
+	retryFailedTxs := false
+	for {
+		interval := s.cfg.DeletePoolTxsCheckInterval.Duration
+		if retryFailedTxs {
+			interval = s.cfg.DeleteFailedPoolTxsRetryInterval.Duration
+		}
+		if !s.nextLoopIteration(ctx, LoopDeleteOldPoolTxs, interval) {
+			return
+		}
+
+		confirmations := s.deletePoolTxsConfirmations()
+		if !retryFailedTxs {
+			s.deleteConfirmedPoolTxs(ctx, confirmations)
+		}
+		retryFailedTxs = !s.deleteFailedPoolTxs(ctx, confirmations) && s.cfg.DeleteFailedPoolTxsRetryInterval.Duration > 0
+	}
+}
+
+// deleteConfirmedPoolTxs deletes from the pool the txs older than the given number of L1 block confirmations. It returns
+// the number of txs deleted
+func (s *Sequencer) deleteConfirmedPoolTxs(ctx context.Context, confirmations uint64) int {
+	log.Infof("trying to get txs to delete from the pool...")
+	txHashes, err := s.stateIntf.GetTxsOlderThanNL1Blocks(ctx, confirmations, nil)
+	if err != nil {
+		log.Errorf("failed to get txs hashes to delete, error: %w", err)
+		metrics.PoolTxsDeleteFailure(metrics.PoolTxsDeleteKindConfirmed)
+		return 0
+	}
+	log.Infof("trying to delete %d selected txs", len(txHashes))
+	deleted := s.deletePoolTxs(ctx, txHashes)
+	log.Infof("deleted %d of %d selected txs from the pool", deleted, len(txHashes))
+	if deleted < len(txHashes) {
+		metrics.PoolTxsDeleteFailure(metrics.PoolTxsDeleteKindConfirmed)
+	}
+	metrics.PoolTxsDeleted(deleted)
+	return deleted
+}
+
+// deleteFailedPoolTxs deletes from the pool the failed txs older than the given number of L1 block confirmations
+// (l1BlockTime per L1 block). It returns false if the deletion fails
+func (s *Sequencer) deleteFailedPoolTxs(ctx context.Context, confirmations uint64) bool {
+	log.Infof("trying to delete failed txs from the pool")
+	err := s.pool.DeleteFailedTransactionsOlderThan(ctx, time.Now().Add(-time.Duration(confirmations)*l1BlockTime))
+	if err != nil {
+		log.Errorf("failed to delete failed txs from the pool, error: %w", err)
+		metrics.PoolTxsDeleteFailure(metrics.PoolTxsDeleteKindFailed)
+		return false
+	}
+	log.Infof("failed txs deleted from the pool")
+	return true
+}
+
+// deletePoolTxsConfirmations returns the number of L1 block confirmations used to delete the txs from the pool, which is
+// DeletePoolTxsL1BlockConfirmations clamped up to MinDeleteConfirmations
+func (s *Sequencer) deletePoolTxsConfirmations() uint64 {
+	if s.cfg.DeletePoolTxsL1BlockConfirmations >= s.cfg.MinDeleteConfirmations {
+		return s.cfg.DeletePoolTxsL1BlockConfirmations
+	}
+	log.Warnf("DeletePoolTxsL1BlockConfirmations (%d) is lower than MinDeleteConfirmations, using %d confirmations to delete the pool txs",
+		s.cfg.DeletePoolTxsL1BlockConfirmations, s.cfg.MinDeleteConfirmations)
+	return s.cfg.MinDeleteConfirmations
+}
+
+// deletePoolTxs deletes the txs from the pool in chunks of DeletePoolTxsBatchSize txs. A chunk that fails to be deleted is
+// logged and skipped, its txs are selected again in the next check. It returns the number of txs deleted
+func (s *Sequencer) deletePoolTxs(ctx context.Context, txHashes []common.Hash) int {
+	batchSize := len(txHashes)
+	if s.cfg.DeletePoolTxsBatchSize > 0 {
+		batchSize = int(s.cfg.DeletePoolTxsBatchSize)
+	}
+
+	deleted := 0
+	for start := 0; start < len(txHashes); start += batchSize {
+		chunk := txHashes[start:min(start+batchSize, len(txHashes))]
+		err := s.pool.DeleteTransactionsByHashes(ctx, chunk)
+		if err != nil {
+			log.Errorf("failed to delete chunk of %d selected txs (%d to %d) from the pool, error: %w", len(chunk), start, start+len(chunk)-1, err)
+			continue
+		}
+		deleted += len(chunk)
+	}
+	return deleted
+}
+
+func (s *Sequencer) expireOldWorkerTxs(ctx context.Context) {
+	for s.nextLoopIteration(ctx, LoopExpireOldWorkerTxs, s.cfg.TxLifetimeCheckInterval.Duration) {
+		s.expireWorkerTxs(ctx)
+
+		if s.cfg.TxAgeWarnThreshold.Duration > 0 {
+			s.checkOldWorkerTxs(ctx)
+		}
+	}
+}
+
+// expireWorkerTxs expires the txs in the worker older than TxLifetimeMax or past their inclusion deadline and sets them as failed in the pool. The expired
+// txs whose status update failed in a previous call are retried
+func (s *Sequencer) expireWorkerTxs(ctx context.Context) {
+	failedReason := ErrExpiredTransaction.Error()
+
+	txsRetries := s.expiredTxsToRetry
+	if txsRetries == nil {
+		txsRetries = make(map[common.Hash]uint64)
+	}
+	s.expiredTxsToRetry = make(map[common.Hash]uint64)
+	for _, txTracker := range s.worker.ExpireTransactions(s.cfg.TxLifetimeMax.Duration, s.cfg.TxLifetimeSkipInFlight) {
+		s.recordDrop(txTracker.Hash, DropPhaseExpire, failedReason)
+		if _, found := txsRetries[txTracker.Hash]; !found {
+			txsRetries[txTracker.Hash] = 0
+		}
+	}
+
+	for txHash, retries := range txsRetries {
+		err := s.pool.UpdateTxStatus(ctx, txHash, pool.TxStatusFailed, false, &failedReason)
+		if err != nil {
+			if retries < s.cfg.ExpiredTxsMaxRetries {
+				log.Errorf("failed to update status of expired tx %s, retrying in the next check (retry %d of %d), error: %w", txHash.String(), retries+1, s.cfg.ExpiredTxsMaxRetries, err)
+				s.expiredTxsToRetry[txHash] = retries + 1
+			} else {
+				log.Errorf("failed to update status of expired tx %s, max retries (%d) reached, error: %w", txHash.String(), s.cfg.ExpiredTxsMaxRetries, err)
+			}
+			continue
+		}
+		metrics.TxProcessed(metrics.TxProcessedLabelFailed, s.metricsForkID(), 1)
+	}
+}
+
+// checkOldWorkerTxs updates the number of txs in the worker older than TxAgeWarnThreshold and logs
+// an event if there are old txs, at most once every TxAgeWarnEventInterval
+func (s *Sequencer) checkOldWorkerTxs(ctx context.Context) {
+	oldTxs := s.worker.CountTxsOlderThan(s.cfg.TxAgeWarnThreshold.Duration)
+	metrics.WorkerOldTxs(float64(oldTxs))
+
+	if oldTxs == 0 {
+		return
+	}
+
+	description := fmt.Sprintf("%d txs in the worker are older than %s", oldTxs, s.cfg.TxAgeWarnThreshold.Duration)
+	log.Warn(description)
+
+	if s.cfg.TxAgeWarnEventInterval.Duration == 0 || time.Since(s.lastTxAgeWarnEvent) < s.cfg.TxAgeWarnEventInterval.Duration {
+		return
+	}
+	s.lastTxAgeWarnEvent = time.Now()
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Warning,
+		EventID:     event.EventID_WorkerTxAgeWarning,
+		Description: description,
+	}
+
+	logEvent(ctx, s.eventLog, event)
+}
+
+// loadFromPool keeps loading transactions from the pool
+func (s *Sequencer) loadFromPool(ctx context.Context) {
+	for s.nextLoopIteration(ctx, LoopLoadFromPool, s.cfg.LoadPoolTxsCheckInterval.Duration) {
+		s.updateOldestPendingTxAge(ctx)
+		s.loadPoolTxsMutex.Lock()
+		s.loadPoolTxs(ctx) //nolint:errcheck
+		s.loadPoolTxsMutex.Unlock()
+		metrics.WorkerBytes(s.worker.CountBytes())
+	}
+}
+
+// LoadFromPoolNow runs a single pass loading the non WIP pending txs from the pool into the worker, without waiting
+// for LoadPoolTxsCheckInterval. It returns the number of txs added to the worker. The periodic loads are not affected
+func (s *Sequencer) LoadFromPoolNow(ctx context.Context) (int, error) {
+	if s.paused.Load() {
+		return 0, ErrSequencerPaused
+	}
+
+	s.loadPoolTxsMutex.Lock()
+	defer s.loadPoolTxsMutex.Unlock()
+
+	loaded, err := s.loadPoolTxs(ctx)
+	metrics.WorkerBytes(s.worker.CountBytes())
+	return loaded, err
+}
+
+// RebuildWorker clears the worker and loads again the txs from the pool through the normal admission path, e.g. after a
+// reconciliation detects that the worker drifted from the pool. The txs deleted from the worker (WIP in the pool) are set as
+// non WIP pending, so they are loaded along with the rest of non WIP pending txs. The txs being processed by the finalizer are
+// kept. It's serialized with the periodic loads from the pool and LoadFromPoolNow
+func (s *Sequencer) RebuildWorker(ctx context.Context) error {
+	if s.paused.Load() {
+		return ErrSequencerPaused
+	}
+
+	s.loadPoolTxsMutex.Lock()
+	defer s.loadPoolTxsMutex.Unlock()
+
+	clearedTxs := s.worker.Clear()
+	for _, txTracker := range clearedTxs {
+		// The tx was processed by a previous load, it must not be skipped by the next one
+		s.recentPoolTxs.forget(txTracker.Hash)
+		err := s.pool.UpdateTxWIPStatus(ctx, txTracker.Hash, false)
+		if err != nil {
+			// The tx is loaded again when the WIP txs are set as pending at the next startup
+			log.Errorf("failed to set tx %s as non WIP pending to rebuild the worker, error: %w", txTracker.HashStr, err)
+		}
+	}
+
+	loaded, err := s.loadPoolTxs(ctx)
+	metrics.WorkerBytes(s.worker.CountBytes())
+	if err != nil {
+		return fmt.Errorf("failed to load txs from the pool to rebuild the worker, error: %w", err)
+	}
+
+	log.Infof("worker rebuilt, txs cleared: %d, txs loaded: %d", len(clearedTxs), loaded)
+	return nil
+}
+
+// updateOldestPendingTxAge updates the gauge with the age of the oldest non WIP pending tx in the pool
+func (s *Sequencer) updateOldestPendingTxAge(ctx context.Context) {
+	oldestTxTime, err := s.pool.GetOldestNonWIPPendingTxTime(ctx)
+	if err == pool.ErrNotFound {
+		metrics.PoolOldestPendingTxAge(0)
+		return
+	} else if err != nil {
+		log.Errorf("error getting the oldest non WIP pending tx time from pool, error: %w", err)
+		return
+	}
+
+	metrics.PoolOldestPendingTxAge(time.Since(oldestTxTime))
+}
+
+// loadPoolTxs loads the non WIP pending txs from the pool and adds them to the worker. It returns the number of txs
+// added to the worker and the error loading the txs from the pool, if any. If LoadPoolTxsOnlyWhenSynced is set and the state
+// is not synced no txs are loaded and ErrNotSynced is returned
+func (s *Sequencer) loadPoolTxs(ctx context.Context) (int, error) {
+	if s.paused.Load() {
+		return 0, nil
+	}
+
+	if s.cfg.LoadPoolTxsOnlyWhenSynced && !s.isSynced(ctx) {
+		log.Infof("state not synced, waiting for it to be synced to load txs from the pool")
+		return 0, ErrNotSynced
+	}
+
+	if s.cfg.WorkerFullPolicy == WorkerFullPolicyBlock && s.isWorkerFull() {
+		log.Infof("worker is full (max txs: %d), waiting for free space to load txs from the pool", s.cfg.MaxWorkerTxs)
+		return 0, nil
+	}
+
+	poolTransactions, err := s.pool.GetNonWIPPendingTxs(ctx)
+	if err == pool.ErrNotFound {
+		err = nil
+	}
+	if err != nil {
+		log.Errorf("error loading txs from pool, error: %w", err)
+	} else {
+		metrics.LoadPoolIteration(len(poolTransactions) == 0)
+	}
+
+	if s.cfg.LoadPoolTxsRoundRobin {
+		poolTransactions = sortPoolTxsRoundRobin(poolTransactions)
+	}
+
+	limit := s.loadPoolTxsLimit()
+	if limit > 0 && uint64(len(poolTransactions)) > limit {
+		poolTransactions = poolTransactions[:limit]
+		s.increaseLoadPoolTxsRamp()
+	}
+
+	s.recentPoolTxs.purge()
+	s.senderRateLimiter.purge()
+	defer s.persistLoadCursor()
+
+	loaded := 0
+	for _, tx := range poolTransactions {
+		if s.cfg.WorkerFullPolicy == WorkerFullPolicyBlock && s.isWorkerFull() {
+			log.Infof("worker is full (max txs: %d), stop loading txs from the pool", s.cfg.MaxWorkerTxs)
+			return loaded, err
+		}
+
+		if s.recentPoolTxs.contains(tx.Hash()) {
+			log.Debugf("skipping tx %s, already processed in the last %s", tx.Hash().String(), s.cfg.LoadPoolTxsDedupTTL.Duration)
+			metrics.PoolTxsDeduplicated()
+			continue
+		}
+
+		added, addErr := s.tryAddTxToWorker(ctx, tx)
+		if errors.Is(addErr, ErrSenderRateLimited) || errors.Is(addErr, ErrTxDeferred) {
+			// The tx is left pending and not deduplicated, so it's loaded again once the sender is below its rate limit
+			// or the wip batch is below the admission threshold
+			continue
+		} else if addErr != nil {
+			log.Errorf("error adding transaction to worker, error: %w", addErr)
+		}
+		if added {
+			loaded++
+		}
+		s.recentPoolTxs.add(tx.Hash(), !added)
+	}
+
+	return loaded, err
+}
+
+// reconcilePendingTxs sets as failed the non WIP pending txs of the pool whose sender can't be recovered or whose nonce
+// is lower than the current nonce of the sender in the state, so they are not loaded in the worker
+func (s *Sequencer) reconcilePendingTxs(ctx context.Context) {
+	poolTransactions, err := s.pool.GetNonWIPPendingTxs(ctx)
+	if err == pool.ErrNotFound {
+		return
+	} else if err != nil {
+		log.Errorf("error loading txs from pool to reconcile, error: %w", err)
+		return
+	}
+
+	root, err := s.stateIntf.GetLastStateRoot(ctx, nil)
+	if err != nil {
+		log.Errorf("error getting last state root to reconcile pending txs, error: %w", err)
+		return
+	}
+
+	nonces := make(map[common.Address]uint64)
+	failedTxs := 0
+	for _, tx := range poolTransactions {
+		var failedReason string
+
+		sender, err := state.GetSender(tx.Transaction)
+		if err != nil {
+			failedReason = fmt.Sprintf("failed to recover sender, error: %s", err)
+		} else {
+			nonce, found := nonces[sender]
+			if !found {
+				nonce, err = s.stateIntf.GetNonce(ctx, sender, root)
+				if err != nil {
+					log.Errorf("error getting nonce of sender %s to reconcile tx %s, error: %w", sender.String(), tx.Hash().String(), err)
+					continue
+				}
+				nonces[sender] = nonce
+			}
+			if tx.Nonce() < nonce {
+				failedReason = fmt.Sprintf("%s, tx nonce: %d, sender nonce: %d", ErrStaleNonce.Error(), tx.Nonce(), nonce)
+			}
+		}
+
+		if failedReason == "" {
+			continue
+		}
+
+		log.Infof("dropped tx %s at startup reconciliation, reason: %s", tx.Hash().String(), failedReason)
+		s.recordDrop(tx.Hash(), DropPhaseReconcile, failedReason)
+		err = s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+		if err != nil {
+			log.Errorf("error setting as failed tx %s at startup reconciliation, error: %w", tx.Hash().String(), err)
+			continue
+		}
+		failedTxs++
+	}
+
+	log.Infof("startup reconciliation finished, pending txs checked: %d, failed: %d", len(poolTransactions), failedTxs)
+}
+
+// loadPoolTxsLimit returns the max number of txs to load from the pool in the current check. If it's 0 there is no limit
+func (s *Sequencer) loadPoolTxsLimit() uint64 {
+	if s.cfg.LoadPoolTxsRampStart == 0 || s.loadPoolTxsRampDone {
+		return s.cfg.LoadPoolTxsMaxPerIteration
+	}
+
+	if s.loadPoolTxsRampLimit == 0 {
+		s.loadPoolTxsRampLimit = s.cfg.LoadPoolTxsRampStart
+	}
+
+	if s.cfg.LoadPoolTxsMaxPerIteration > 0 && s.loadPoolTxsRampLimit >= s.cfg.LoadPoolTxsMaxPerIteration {
+		log.Infof("load pool txs ramp finished, max txs per check: %d", s.cfg.LoadPoolTxsMaxPerIteration)
+		s.loadPoolTxsRampDone = true
+		return s.cfg.LoadPoolTxsMaxPerIteration
+	}
+
+	return s.loadPoolTxsRampLimit
+}
+
+// increaseLoadPoolTxsRamp increases the max number of txs to load from the pool while ramping up
+func (s *Sequencer) increaseLoadPoolTxsRamp() {
+	if s.cfg.LoadPoolTxsRampStart == 0 || s.loadPoolTxsRampDone {
+		return
+	}
+
+	if s.cfg.LoadPoolTxsRampMultiplier <= 1 || s.loadPoolTxsRampLimit > math.MaxUint64/s.cfg.LoadPoolTxsRampMultiplier {
+		log.Infof("load pool txs ramp finished, max txs per check: %d", s.cfg.LoadPoolTxsMaxPerIteration)
+		s.loadPoolTxsRampDone = true
+		return
+	}
+
+	s.loadPoolTxsRampLimit *= s.cfg.LoadPoolTxsRampMultiplier
+	log.Debugf("load pool txs ramp increased, max txs per check: %d", s.loadPoolTxsRampLimit)
+}
+
+// sortPoolTxsRoundRobin sorts the pool txs taking the lowest nonce tx of each sender in turn. The senders are
+// visited in the order they first appear in the txs list. Txs whose sender can't be recovered are placed at the end
+func sortPoolTxsRoundRobin(txs []pool.Transaction) []pool.Transaction {
+	senders := []common.Address{}
+	txsBySender := make(map[common.Address][]pool.Transaction)
+	invalidTxs := []pool.Transaction{}
+
+	for _, tx := range txs {
+		sender, err := state.GetSender(tx.Transaction)
+		if err != nil {
+			invalidTxs = append(invalidTxs, tx)
+			continue
+		}
+		if _, found := txsBySender[sender]; !found {
+			senders = append(senders, sender)
+		}
+		txsBySender[sender] = append(txsBySender[sender], tx)
+	}
+
+	for _, sender := range senders {
+		senderTxs := txsBySender[sender]
+		sort.SliceStable(senderTxs, func(i, j int) bool {
+			return senderTxs[i].Nonce() < senderTxs[j].Nonce()
+		})
+	}
+
+	sortedTxs := make([]pool.Transaction, 0, len(txs))
+	for round := 0; len(sortedTxs) < len(txs)-len(invalidTxs); round++ {
+		for _, sender := range senders {
+			if round < len(txsBySender[sender]) {
+				sortedTxs = append(sortedTxs, txsBySender[sender][round])
+			}
+		}
+	}
+
+	return append(sortedTxs, invalidTxs...)
+}
+
+// metricsForkID returns the fork ID of the wip batch to label the metrics. If MetricsForkIDLabel is not set it returns 0, as
+// the metrics labeled by fork ID are not registered
+func (s *Sequencer) metricsForkID() uint64 {
+	if !s.cfg.MetricsForkIDLabel || s.finalizer == nil {
+		return 0
+	}
+	return s.stateIntf.GetForkIDByBatchNumber(s.finalizer.WIPBatchUsage().BatchNumber)
+}
+
+// isWorkerFull returns true if the worker has reached MaxWorkerTxs. It also updates the worker fullness metric
+func (s *Sequencer) isWorkerFull() bool {
+	if s.cfg.MaxWorkerTxs == 0 {
+		return false
+	}
+
+	count := uint64(s.worker.CountTxs())
+	metrics.WorkerFullness(float64(count) / float64(s.cfg.MaxWorkerTxs))
+
+	return count >= s.cfg.MaxWorkerTxs
+}
+
+// exceedsWorkerBytes returns true if adding the tx to the worker would exceed MaxWorkerBytes. It also updates the worker bytes metric
+func (s *Sequencer) exceedsWorkerBytes(txTracker *TxTracker) bool {
+	if s.cfg.MaxWorkerBytes == 0 {
+		return false
+	}
+
+	bytes := s.worker.CountBytes()
+	metrics.WorkerBytes(bytes)
+
+	return bytes+uint64(len(txTracker.RawTx)) > s.cfg.MaxWorkerBytes
+}
+
+// isTxAdmissionDeferred returns true if the tx is not high priority (AdmissionHighPriorityGasPrice) and the wip batch of the
+// finalizer is above AdmissionBatchFullnessThreshold, along with the fullness of the wip batch
+func (s *Sequencer) isTxAdmissionDeferred(tx pool.Transaction) (float64, bool) {
+	if s.cfg.AdmissionBatchFullnessThreshold <= 0 {
+		return 0, false
+	}
+	if s.cfg.AdmissionHighPriorityGasPrice > 0 && tx.GasPrice().Cmp(new(big.Int).SetUint64(s.cfg.AdmissionHighPriorityGasPrice)) >= 0 {
+		return 0, false
+	}
+
+	fullness := s.BatchUtilization().Fullness()
+	return fullness, fullness > s.cfg.AdmissionBatchFullnessThreshold
+}
+
+func (s *Sequencer) addTxToWorker(ctx context.Context, tx pool.Transaction) error {
+	_, err := s.tryAddTxToWorker(ctx, tx)
+	return err
+}
+
+// tryAddTxToWorker adds a tx to the worker like addTxToWorker, also returning true if the tx has been added
+func (s *Sequencer) tryAddTxToWorker(ctx context.Context, tx pool.Transaction) (bool, error) {
+	if s.cfg.WorkerFullPolicy == WorkerFullPolicyReject && s.isWorkerFull() {
+		log.Infof("dropped tx %s, worker is full (max txs: %d)", tx.Hash().String(), s.cfg.MaxWorkerTxs)
+		failedReason := ErrWorkerFull.Error()
+		s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+		return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+	}
+
+	if s.txTransformer != nil {
+		transformedTx, err := s.txTransformer.Transform(tx)
+		if err != nil {
+			log.Infof("dropped tx %s, failed to transform tx, error: %v", tx.Hash().String(), err)
+			failedReason := fmt.Sprintf("failed to transform tx, error: %s", err)
+			s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+			return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+		}
+		tx = transformedTx
+	}
+
+	if s.cfg.MaxAcceptedGasLimit > 0 && tx.Gas() > s.cfg.MaxAcceptedGasLimit {
+		log.Infof("dropped tx %s, gas limit %d above the max accepted gas limit %d", tx.Hash().String(), tx.Gas(), s.cfg.MaxAcceptedGasLimit)
+		failedReason := fmt.Sprintf("%s, tx gas limit: %d, max accepted gas limit: %d", ErrGasLimitAboveCeiling.Error(), tx.Gas(), s.cfg.MaxAcceptedGasLimit)
+		s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+		return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+	}
+
+	if s.cfg.RejectTxsExceedingBatchConstraints {
+		if exceeded := exceededBatchConstraints(tx.ZKCounters, s.batchCfg.Constraints); len(exceeded) > 0 {
+			log.Infof("dropped tx %s, ZK counters exceed the batch constraints: %s", tx.Hash().String(), strings.Join(exceeded, ", "))
+			failedReason := fmt.Sprintf("%s, %s", ErrTxExceedsBatchConstraints.Error(), strings.Join(exceeded, ", "))
+			s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+			return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+		}
+	}
+
+	if fullness, deferred := s.isTxAdmissionDeferred(tx); deferred {
+		log.Debugf("tx %s left pending, wip batch fullness %.2f%% above the admission threshold %.2f%%", tx.Hash().String(), fullness, s.cfg.AdmissionBatchFullnessThreshold)
+		metrics.TxDeferred()
+		return false, ErrTxDeferred
+	}
+
+	txTracker, err := s.worker.NewTxTracker(tx.Transaction, tx.ZKCounters, tx.IP, tx.InclusionDeadline)
+	if err != nil {
+		if s.cfg.TxTrackerErrorPolicy == TxTrackerErrorPolicyRetry {
+			return false, err
+		}
+		log.Infof("dropped tx %s, failed to create tx tracker, error: %v", tx.Hash().String(), err)
+		failedReason := fmt.Sprintf("failed to create tx tracker, error: %s", err)
+		s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+		return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+	}
+	if s.exceedsWorkerBytes(txTracker) {
+		log.Infof("dropped tx %s, worker bytes limit exceeded (max bytes: %d, tx bytes: %d)", tx.Hash().String(), s.cfg.MaxWorkerBytes, len(txTracker.RawTx))
+		failedReason := ErrWorkerBytesLimit.Error()
+		s.recordDrop(tx.Hash(), DropPhaseAdd, failedReason)
+		return false, s.pool.UpdateTxStatus(ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason)
+	}
+
+	if !s.senderRateLimiter.allow(txTracker.From) {
+		log.Debugf("tx %s left pending, sender %s exceeded its rate limit (%v txs/s)", tx.Hash().String(), txTracker.From.String(), s.cfg.SenderRateLimit)
+		metrics.TxThrottled()
+		return false, ErrSenderRateLimited
+	}
+
+	replacedTx, dropReason := s.worker.AddTxTracker(ctx, txTracker)
+	if dropReason != nil {
+		failedReason := dropReason.Error()
+		s.recordDrop(txTracker.Hash, DropPhaseAdd, failedReason)
+		return false, s.pool.UpdateTxStatus(ctx, txTracker.Hash, pool.TxStatusFailed, false, &failedReason)
+	} else {
+		if replacedTx != nil {
+			s.recordReplacement(replacedTx, txTracker)
+			failedReason := ErrReplacedTransaction.Error()
+			s.recordDrop(replacedTx.Hash, DropPhaseReplace, failedReason)
+			err := s.pool.UpdateTxStatus(ctx, replacedTx.Hash, pool.TxStatusFailed, false, &failedReason)
+			if err != nil {
+				log.Warnf("error when setting as failed replacedTx %s, error: %w", replacedTx.HashStr, err)
+			}
+		}
+		err := s.updateTxWIPStatus(ctx, tx.Hash(), txTracker)
+		if err == nil {
+			metrics.WorkerTxAdded(s.metricsForkID())
+		}
+		return err == nil, err
+	}
+}
+
+// exceededBatchConstraints returns the description of each ZK counter of the tx that exceeds the batch constraints. If the
+// tx fits in a batch the list is empty
+func exceededBatchConstraints(counters state.ZKCounters, constraints state.BatchConstraintsCfg) []string {
+	exceeded := []string{}
+	for _, counter := range []struct {
+		name  string
+		used  uint64
+		limit uint64
+	}{
+		{"gas", counters.GasUsed, constraints.MaxCumulativeGasUsed},
+		{"keccak hashes", uint64(counters.UsedKeccakHashes), uint64(constraints.MaxKeccakHashes)},
+		{"poseidon hashes", uint64(counters.UsedPoseidonHashes), uint64(constraints.MaxPoseidonHashes)},
+		{"poseidon paddings", uint64(counters.UsedPoseidonPaddings), uint64(constraints.MaxPoseidonPaddings)},
+		{"mem aligns", uint64(counters.UsedMemAligns), uint64(constraints.MaxMemAligns)},
+		{"arithmetics", uint64(counters.UsedArithmetics), uint64(constraints.MaxArithmetics)},
+		{"binaries", uint64(counters.UsedBinaries), uint64(constraints.MaxBinaries)},
+		{"steps", uint64(counters.UsedSteps), uint64(constraints.MaxSteps)},
+		{"sha256 hashes", uint64(counters.UsedSha256Hashes_V2), uint64(constraints.MaxSHA256Hashes)},
+	} {
+		if counter.used > counter.limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d (max: %d)", counter.name, counter.used, counter.limit))
+		}
+	}
+	return exceeded
+}
+
+// updateTxWIPStatus sets as WIP in the pool a tx added to the worker, retrying up to WIPStatusUpdateMaxRetries times if it fails.
+// If all the retries fail the tx is deleted from the worker to keep the worker and the pool consistent
+func (s *Sequencer) updateTxWIPStatus(ctx context.Context, poolTxHash common.Hash, txTracker *TxTracker) error {
+	var err error
+	for retry := uint64(0); ; retry++ {
+		err = s.pool.UpdateTxWIPStatus(ctx, poolTxHash, true)
+		if err == nil {
+			return nil
+		}
+
+		if retry >= s.cfg.WIPStatusUpdateMaxRetries {
+			break
+		}
+
+		log.Warnf("failed to update WIP status of tx %s, retrying in %s, error: %v", poolTxHash.String(), s.cfg.WIPStatusUpdateRetryInterval.Duration, err)
+		time.Sleep(s.cfg.WIPStatusUpdateRetryInterval.Duration)
+	}
+
+	log.Errorf("failed to update WIP status of tx %s after %d retries, deleting it from the worker, error: %v", poolTxHash.String(), s.cfg.WIPStatusUpdateMaxRetries, err)
+	s.worker.DeleteTx(txTracker.Hash, txTracker.From)
+	return err
+}
+
+// sendDataToStreamer sends data to the data stream server, restarting the stream pipeline if it exits unexpectedly
+func (s *Sequencer) sendDataToStreamer() {
+	s.superviseStreamer(s.streamPipeline.start)
+}
+
+// superviseStreamer runs the function sending data to the data stream server, which is not expected to return. If it returns
+// or panics a critical event is logged and it's restarted after RestartBackoff, doubled on each restart, up to MaxRestarts times
+func (s *Sequencer) superviseStreamer(start func()) {
+	backoff := s.cfg.StreamServer.RestartBackoff.Duration
+	for restarts := uint64(0); ; restarts++ {
+		reason := runStreamer(start)
+
+		var description string
+		if restarts >= s.cfg.StreamServer.MaxRestarts {
+			description = fmt.Sprintf("data streamer exited unexpectedly (%s), max restarts (%d) reached, the l2blocks are not streamed anymore", reason, s.cfg.StreamServer.MaxRestarts)
+		} else {
+			description = fmt.Sprintf("data streamer exited unexpectedly (%s), restarting it in %s (restart %d of %d)", reason, backoff, restarts+1, s.cfg.StreamServer.MaxRestarts)
+		}
+		log.Error(description)
+
+		event := &event.Event{
+			ReceivedAt:  time.Now(),
+			Source:      event.Source_Node,
+			Component:   event.Component_Sequencer,
+			Level:       event.Level_Critical,
+			EventID:     event.EventID_DataStreamerRestart,
+			Description: description,
+		}
+		logEvent(context.Background(), s.eventLog, event)
+
+		if restarts >= s.cfg.StreamServer.MaxRestarts {
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// runStreamer runs the function sending data to the data stream server, returning the reason it exited
+func runStreamer(start func()) (reason string) {
+	defer func() {
+		if r := recover(); r != nil {
+			reason = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+
+	start()
+	return "returned"
+}
+
+// SetLoopEnabled enables or disables the background loop with the given name. A disabled loop keeps running but skips its
+// iterations until it's enabled again, so e.g. deleteOldPoolTxs can be stopped during a forensic window without stopping
+// the loading of txs or the streaming. The loops that can be disabled are: loadFromPool, deleteOldPoolTxs,
+// expireOldWorkerTxs, checkStateInconsistency, checkBatchesAheadOfL1, trackL2BlockFinality, monitorDataToStream,
+// logMetrics, updateDataStreamerFile, archive, which keeps accumulating the entries but doesn't upload them, and
+// priorityStream, which keeps queueing the L2 blocks but doesn't send them (see the Loop constants). ErrUnknownLoop is
+// returned for any other name
+func (s *Sequencer) SetLoopEnabled(name string, enabled bool) error {
+	if !slices.Contains(loopNames, name) {
+		return fmt.Errorf("%w: %s", ErrUnknownLoop, name)
+	}
+
+	if enabled {
+		s.disabledLoops.Delete(name)
+	} else {
+		s.disabledLoops.Store(name, struct{}{})
+	}
+	log.Infof("sequencer loop %s enabled: %t", name, enabled)
+	return nil
+}
+
+// isLoopEnabled returns false if the loop with the given name has been disabled with SetLoopEnabled
+func (s *Sequencer) isLoopEnabled(name string) bool {
+	_, disabled := s.disabledLoops.Load(name)
+	return !disabled
+}
+
+// nextLoopIteration waits interval before the next iteration of the loop with the given name, waiting again while the loop
+// is disabled. It returns false if ctx is done
+func (s *Sequencer) nextLoopIteration(ctx context.Context, name string, interval time.Duration) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+
+		if s.isLoopEnabled(name) {
+			return true
+		}
+		interval = max(interval, loopDisabledCheckInterval)
+	}
+}
+
+// superviseLoop runs the background loop with the given name until it returns. If the loop panics the panic is logged with
+// its stack, a critical event is logged and the loop is restarted after LoopRestartBackoff, doubled on each restart, up to
+// LoopMaxRestarts times
+func (s *Sequencer) superviseLoop(ctx context.Context, name string, loop func(ctx context.Context)) {
+	backoff := s.cfg.LoopRestartBackoff.Duration
+	for restarts := uint64(0); ; restarts++ {
+		panicked, reason, stack := runLoop(ctx, loop)
+		if !panicked {
+			return
+		}
+		log.Errorf("sequencer loop %s panicked: %s\n%s", name, reason, stack)
+
+		var description string
+		if restarts >= s.cfg.LoopMaxRestarts {
+			description = fmt.Sprintf("sequencer loop %s panicked (%s), max restarts (%d) reached, the loop is not restarted anymore", name, reason, s.cfg.LoopMaxRestarts)
+		} else {
+			description = fmt.Sprintf("sequencer loop %s panicked (%s), restarting it in %s (restart %d of %d)", name, reason, backoff, restarts+1, s.cfg.LoopMaxRestarts)
+		}
+		log.Error(description)
+
+		event := &event.Event{
+			ReceivedAt:  time.Now(),
+			Source:      event.Source_Node,
+			Component:   event.Component_Sequencer,
+			Level:       event.Level_Critical,
+			EventID:     event.EventID_SequencerLoopRestart,
+			Description: description,
+		}
+		logEvent(context.Background(), s.eventLog, event)
+
+		if restarts >= s.cfg.LoopMaxRestarts {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// runLoop runs the background loop, recovering it if it panics
+func runLoop(ctx context.Context, loop func(ctx context.Context)) (panicked bool, reason string, stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, reason, stack = true, fmt.Sprint(r), debug.Stack()
+		}
+	}()
+
+	loop(ctx)
+	return false, "", nil
+}
+
+// monitorDataToStream samples periodically the capacity and length of the dataToStream channel
+func (s *Sequencer) monitorDataToStream(ctx context.Context) {
+	ticker := time.NewTicker(dataToStreamMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.isLoopEnabled(LoopMonitorDataToStream) {
+			s.updateDataToStreamMetrics()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateDataToStreamMetrics updates the gauges with the capacity and the number of L2 blocks pending to be read of the dataToStream channel
+func (s *Sequencer) updateDataToStreamMetrics() {
+	metrics.DataStreamChannelCapacity(cap(s.dataToStream))
+	metrics.DataStreamChannelLength(len(s.dataToStream))
+}
+
+// PauseStreaming pauses writing the L2 blocks to the data stream. The finalizer keeps producing L2 blocks,
+// which are buffered until the streaming is resumed
+func (s *Sequencer) PauseStreaming() error {
+	if s.streamPipeline == nil {
+		return ErrStreamingDisabled
+	}
+	s.streamPipeline.pause()
+	log.Infof("streaming paused")
+	return nil
+}
+
+// ResumeStreaming resumes writing the L2 blocks to the data stream, flushing first the L2 blocks buffered while paused
+func (s *Sequencer) ResumeStreaming() error {
+	if s.streamPipeline == nil {
+		return ErrStreamingDisabled
+	}
+	s.streamPipeline.resume()
+	log.Infof("streaming resumed")
+	return nil
+}
+
+// SwapStreamFile replaces the data stream server with a new one writing the data stream file newPath, e.g. a copy of the current
+// one moved to another disk. The streaming is paused meanwhile, so the L2 blocks produced are buffered, and the server is replaced
+// between atomic ops. The new data stream file must have the same schema and end with the same L2 block as the current one,
+// otherwise ErrStreamFileHeadMismatch is returned and the streaming continues with the current file.
+// The data streamer can't stop the current server, so it keeps serving the current file to its clients and holding its port.
+// The new server listens on the same port, so with the default stream server the swap fails until the port is released
+func (s *Sequencer) SwapStreamFile(ctx context.Context, newPath string) error {
+	if s.streamPipeline == nil {
+		return ErrStreamingDisabled
+	}
+	if s.streamPipeline.regenerating.Load() {
+		return ErrStreamFileRegenerating
+	}
+
+	s.swapStreamFileMutex.Lock()
+	defer s.swapStreamFileMutex.Unlock()
+
+	// If the streaming was already paused it's not resumed after the swap
+	if !s.streamPipeline.paused.Load() {
+		s.streamPipeline.pause()
+		defer s.streamPipeline.resume()
+	}
+
+	s.streamPipeline.streamServerMutex.Lock()
+	defer s.streamPipeline.streamServerMutex.Unlock()
+
+	// The L2 blocks not sent are discarded once the streaming is disabled, so the new file can't continue the current one
+	if s.streamPipeline.streamServer == nil {
+		return ErrStreamingDisabled
+	}
+
+	streamServer, err := s.createStreamServer(ctx, newPath)
+	if err != nil {
+		return err
+	}
+
+	lastL2BlockNumber, err := getLastStreamedL2BlockNumber(s.streamServer)
+	if err != nil {
+		return err
+	}
+	newLastL2BlockNumber, err := getLastStreamedL2BlockNumber(streamServer)
+	if err != nil {
+		return err
+	}
+	if newLastL2BlockNumber != lastL2BlockNumber {
+		return fmt.Errorf("%w: l2block %d in %s, l2block %d in the current file", ErrStreamFileHeadMismatch, newLastL2BlockNumber, newPath, lastL2BlockNumber)
+	}
+	err = checkStreamSchema(streamServer, s.cfg.StreamServer)
+	if err != nil {
+		return err
+	}
+
+	s.streamPipeline.setStreamServer(streamServer)
+	s.streamServer = streamServer
+	log.Infof("data stream file swapped to %s, last l2block %d", newPath, lastL2BlockNumber)
+
+	return nil
+}
+
+// HaltFinalizer halts the finalizer with the given reason. If the finalizer is already halted with HaltFinalizer it does nothing
+func (s *Sequencer) HaltFinalizer(reason error) {
+	s.haltFinalizer(HaltReasonManual, reason)
+}
+
+// ResumeFinalizer clears the manual and state inconsistency halt reasons of the finalizer. It fails with a
+// StateInconsistencyNotClearedError if the state inconsistency is still detected, the operator can override it with
+// ForceResumeFinalizer. The finalizer is only resumed if no other halt reason, like the batches ahead of L1, is still active
+func (s *Sequencer) ResumeFinalizer() error {
+	if !s.isFinalizerHalted() {
+		return nil
+	}
+
+	stateInconsistenciesDetected, err := s.stateIntf.CountReorgs(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get number of reorgs, error: %w", err)
+	}
+	if stateInconsistenciesDetected != s.numberOfStateInconsistencies.Load() {
+		return &StateInconsistencyNotClearedError{Detected: stateInconsistenciesDetected, Accepted: s.numberOfStateInconsistencies.Load()}
+	}
+
+	s.clearFinalizerHaltReasons(HaltReasonManual, HaltReasonStateInconsistency)
+	return nil
+}
+
+// ForceResumeFinalizer clears the manual and state inconsistency halt reasons of the finalizer like ResumeFinalizer, even if
+// the state inconsistency is still detected. The state inconsistencies detected are accepted, so the finalizer is only halted
+// again if new ones are detected
+func (s *Sequencer) ForceResumeFinalizer() error {
+	if !s.isFinalizerHalted() {
+		return nil
+	}
+
+	stateInconsistenciesDetected, err := s.stateIntf.CountReorgs(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get number of reorgs, error: %w", err)
+	}
+	if stateInconsistenciesDetected != s.numberOfStateInconsistencies.Swap(stateInconsistenciesDetected) {
+		log.Warnf("resuming finalizer with the state inconsistency not cleared, %d state inconsistencies accepted", stateInconsistenciesDetected)
+	}
+
+	s.clearFinalizerHaltReasons(HaltReasonManual, HaltReasonStateInconsistency)
+	return nil
+}
+
+// isFinalizerHalted returns true if the finalizer has active halt reasons, even if its halt is deferred
+func (s *Sequencer) isFinalizerHalted() bool {
+	s.haltMutex.Lock()
+	defer s.haltMutex.Unlock()
+	return len(s.haltReasons) > 0
+}
+
+// haltFinalizer registers a halt reason of the finalizer, halting it with err if there was no other active halt reason.
+// If the finalizer was halted less than MinHaltInterval ago the halt is deferred until MinHaltInterval elapses, coalescing
+// the halt reasons registered meanwhile. It returns false if the halt reason was already active
+func (s *Sequencer) haltFinalizer(reason string, err error) bool {
+	s.haltMutex.Lock()
+	defer s.haltMutex.Unlock()
+
+	if _, found := s.haltReasons[reason]; found {
+		return false
+	}
+	s.haltReasons[reason] = err
+
+	if s.haltState.Halted {
+		s.haltState.Reasons = s.activeHaltReasons()
+		s.debugStream.addEntry(DebugEntryTypeFinalizerHaltState, s.haltState)
+		return true
+	}
+
+	if s.haltTimer != nil {
+		return true
+	}
+	coolDown := time.Until(s.lastHaltTime.Add(s.cfg.MinHaltInterval.Duration))
+	if coolDown > 0 {
+		log.Warnf("finalizer halt (%s) deferred %s, the finalizer was halted less than %s ago", err, coolDown, s.cfg.MinHaltInterval.Duration)
+		s.haltTimer = time.AfterFunc(coolDown, s.haltDeferredFinalizer)
+		return true
+	}
+
+	s.halt(err)
+	return true
+}
+
+// haltDeferredFinalizer halts the finalizer once the cool-down after the last halt elapses, if there are still active halt reasons
+func (s *Sequencer) haltDeferredFinalizer() {
+	s.haltMutex.Lock()
+	defer s.haltMutex.Unlock()
+
+	s.haltTimer = nil
+	if s.haltState.Halted || len(s.haltReasons) == 0 {
+		return
+	}
+	s.halt(s.haltReasons[s.activeHaltReasons()[0]])
+}
+
+// halt halts the finalizer with err, haltMutex must be held
+func (s *Sequencer) halt(err error) {
+	s.lastHaltTime = time.Now()
+	s.haltState = FinalizerHaltState{Halted: true, Reason: err.Error(), Reasons: s.activeHaltReasons(), Timestamp: s.lastHaltTime}
+	s.debugStream.addEntry(DebugEntryTypeFinalizerHaltState, s.haltState)
+	logStructuredEvent(LogEventFinalizerHalted, "finalizer halted", LogFieldReason, s.haltState.Reason, LogFieldReasons, s.haltState.Reasons)
+	s.finalizer.RequestHalt(context.Background(), err)
+}
+
+// clearFinalizerHaltReasons clears the halt reasons of the finalizer, resuming it once there is no active halt reason.
+// It returns true if any of the halt reasons was active
+func (s *Sequencer) clearFinalizerHaltReasons(reasons ...string) bool {
+	s.haltMutex.Lock()
+	defer s.haltMutex.Unlock()
+
+	cleared := false
+	for _, reason := range reasons {
+		if _, found := s.haltReasons[reason]; found {
+			delete(s.haltReasons, reason)
+			cleared = true
+		}
+	}
+	if !cleared {
+		return false
+	}
+
+	if !s.haltState.Halted {
+		// The halt is deferred, it's cancelled if there are no active halt reasons left
+		if len(s.haltReasons) == 0 && s.haltTimer != nil {
+			s.haltTimer.Stop()
+			s.haltTimer = nil
+		}
+		return true
+	}
+
+	if len(s.haltReasons) > 0 {
+		s.haltState.Reasons = s.activeHaltReasons()
+		s.debugStream.addEntry(DebugEntryTypeFinalizerHaltState, s.haltState)
+		log.Infof("finalizer still halted, active halt reasons: %v", s.haltState.Reasons)
+		return true
+	}
+
+	s.finalizer.Resume(context.Background())
+	s.haltState = FinalizerHaltState{Halted: false, Timestamp: time.Now()}
+	s.debugStream.addEntry(DebugEntryTypeFinalizerHaltState, s.haltState)
+	log.Infof("finalizer resumed, no active halt reasons")
+	return true
+}
+
+// activeHaltReasons returns the active halt reasons of the finalizer sorted, haltMutex must be held
+func (s *Sequencer) activeHaltReasons() []string {
+	reasons := make([]string, 0, len(s.haltReasons))
+	for reason := range s.haltReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// GetFinalizerHaltState returns the halt state of the finalizer
+func (s *Sequencer) GetFinalizerHaltState() FinalizerHaltState {
+	s.haltMutex.Lock()
+	defer s.haltMutex.Unlock()
+
+	return s.haltState
+}
+
+// Pause stops loading txs from the pool. If PauseDrainTimeout is not 0 it waits until the worker is empty, the timeout
+// elapses or ctx is done. It returns true if the worker has been drained (it's empty). If the sequencer is not started
+// there is no worker yet, so it's drained
+func (s *Sequencer) Pause(ctx context.Context) bool {
+	s.paused.Store(true)
+	log.Infof("sequencer paused, txs are not loaded from the pool")
+
+	if s.worker == nil {
+		return true
+	}
+
+	if s.cfg.PauseDrainTimeout.Duration == 0 {
+		return s.worker.CountTxs() == 0
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.PauseDrainTimeout.Duration)
+	defer cancel()
+
+	err := s.worker.WaitEmpty(ctx)
+	if err != nil {
+		log.Warnf("worker not drained after pausing the sequencer, txs: %d, error: %v", s.worker.CountTxs(), err)
+		return false
+	}
+
+	log.Infof("worker drained after pausing the sequencer")
+	return true
+}
+
+// Resume resumes loading txs from the pool after Pause
+func (s *Sequencer) Resume() {
+	s.paused.Store(false)
+	log.Infof("sequencer resumed, txs are loaded from the pool")
+}
+
+// CheckSynced returns ErrNotSynced if the state of the sequencer is not synced with L1
+func (s *Sequencer) CheckSynced(ctx context.Context) error {
+	if !s.isSynced(ctx) {
+		return ErrNotSynced
+	}
+	return nil
+}
+
+// getLatestEthBatchNumber gets the last batch number from L1 retrying up to SyncCheckL1MaxRetries times. If all the
+// retries fail the last known value is returned and flagged as stale
+func (s *Sequencer) getLatestEthBatchNumber(ctx context.Context) (uint64, error) {
+	backoff := s.cfg.SyncCheckL1RetryBackoff.Duration
+	lastEthBatchNum, err := s.etherman.GetLatestBatchNumber()
+	for retry := uint64(0); err != nil && retry < s.cfg.SyncCheckL1MaxRetries; retry++ {
+		log.Warnf("failed to get last eth batch, retrying in %v, error: %w", backoff, err)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		lastEthBatchNum, err = s.etherman.GetLatestBatchNumber()
+	}
+
+	s.lastEthBatchNumMutex.Lock()
+	defer s.lastEthBatchNumMutex.Unlock()
+
+	if err != nil {
+		if !s.lastEthBatchNumKnown {
+			return 0, err
+		}
+		if !s.lastEthBatchNumStale {
+			log.Warnf("failed to get last eth batch, using the stale last known value %d, error: %w", s.lastEthBatchNum, err)
+			s.lastEthBatchNumStale = true
+		}
+		return s.lastEthBatchNum, nil
+	}
+
+	if s.lastEthBatchNumStale {
+		log.Infof("last eth batch got from L1 again: %d", lastEthBatchNum)
+		s.lastEthBatchNumStale = false
+	}
+	s.lastEthBatchNum = lastEthBatchNum
+	s.lastEthBatchNumKnown = true
+
+	return lastEthBatchNum, nil
+}
+
+// getLastBatchNumbers returns the last virtual batch number and the last trusted batch number of the state
+func (s *Sequencer) getLastBatchNumbers(ctx context.Context) (uint64, uint64, error) {
+	lastVirtualBatchNum, err := s.stateIntf.GetLastVirtualBatchNum(ctx, nil)
+	if err != nil && err != state.ErrNotFound {
+		return 0, 0, fmt.Errorf("failed to get last virtual batch num, error: %w", err)
+	}
+	lastTrustedBatchNum, err := s.stateIntf.GetLastBatchNumber(ctx, nil)
+	if err != nil && err != state.ErrNotFound {
+		return 0, 0, fmt.Errorf("failed to get last batch num, error: %w", err)
+	}
+	return lastVirtualBatchNum, lastTrustedBatchNum, nil
+}
+
+func (s *Sequencer) isSynced(ctx context.Context) bool {
+	lastVirtualBatchNum, lastTrustedBatchNum, err := s.getLastBatchNumbers(ctx)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	if lastTrustedBatchNum > lastVirtualBatchNum {
+		return true
+	}
+	lastEthBatchNum, err := s.getLatestEthBatchNumber(ctx)
 	if err != nil {
 		log.Errorf("failed to get last eth batch, error: %w", err)
 		return false
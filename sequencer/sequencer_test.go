@@ -0,0 +1,1813 @@
+package sequencer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	zkmetrics "github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSenderPvtKey = "28b2b0318721be8c8339199172cd7cc8f5e273800a35616ec893083a4b32c02e"
+)
+
+var (
+	testChainID = big.NewInt(1000)
+)
+
+// newTestPoolTx returns a signed pool tx sent by the test sender
+func newTestPoolTx(t *testing.T, nonce uint64, gas uint64) pool.Transaction {
+	privateKey, err := crypto.HexToECDSA(testSenderPvtKey)
+	require.NoError(t, err)
+
+	return newTestPoolTxWithKey(t, privateKey, nonce, gas)
+}
+
+// newTestPoolTxWithKey returns a pool tx signed with the given private key
+func newTestPoolTxWithKey(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64, gas uint64) pool.Transaction {
+	tx := types.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(1), gas, big.NewInt(1), nil)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(testChainID), privateKey)
+	require.NoError(t, err)
+
+	return *pool.NewTransaction(*signedTx, "", false)
+}
+
+// testSenderAddr returns the address of the test sender
+func testSenderAddr(t *testing.T) common.Address {
+	privateKey, err := crypto.HexToECDSA(testSenderPvtKey)
+	require.NoError(t, err)
+	return crypto.PubkeyToAddress(privateKey.PublicKey)
+}
+
+// newTestSequencer returns a sequencer with a real worker and mocked pool and state
+func newTestSequencer(t *testing.T, cfg Config) (*Sequencer, *PoolMock, *StateMock) {
+	txPoolMock := NewPoolMock(t)
+	stMock := NewStateMock(t)
+
+	s := &Sequencer{
+		cfg:       cfg,
+		batchCfg:  state.BatchConfig{Constraints: bc},
+		pool:      txPoolMock,
+		stateIntf: stMock,
+		worker:    NewWorker(stMock, bc),
+
+		dropRecords:   newDropRecords(cfg.DropRecordsSize),
+		recentPoolTxs: newRecentPoolTxs(cfg.LoadPoolTxsDedupTTL.Duration),
+
+		replacementRecords: newReplacementRecords(cfg.ReplacementRecordsSize),
+		dropEventsThrottle: newDropEventsThrottle(cfg.DropEventsMaxPerSecond),
+		senderRateLimiter:  newSenderRateLimiter(cfg.SenderRateLimit, cfg.SenderRateLimitBurst, cfg.SenderRateLimitNewSenderGrace),
+		sessionCounters:    &sessionCounters{},
+		recentBlockHashes:  newRecentBlockHashes(cfg.StreamServer.RecentBlockHashesSize),
+
+		streamServerFactory: newSequencerStreamServer,
+		haltReasons:         map[string]error{},
+	}
+
+	return s, txPoolMock, stMock
+}
+
+// mockTestSenderAccount sets the state mock expectations needed to create the test sender addrQueue in the worker
+func mockTestSenderAccount(t *testing.T, stMock *StateMock, nonce uint64) {
+	stMock.On("GetLastStateRoot", mock.Anything, nil).Return(common.Hash{}, nil).Maybe()
+	stMock.On("GetNonceByStateRoot", mock.Anything, testSenderAddr(t), common.Hash{}).Return(new(big.Int).SetUint64(nonce), nil).Maybe()
+	stMock.On("GetBalanceByStateRoot", mock.Anything, testSenderAddr(t), common.Hash{}).Return(new(big.Int).SetUint64(1e18), nil).Maybe()
+}
+
+func TestSequencer_addTxToWorker_WorkerFullReject(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MaxWorkerTxs: 1, WorkerFullPolicy: WorkerFullPolicyReject})
+	mockTestSenderAccount(t, stMock, 0)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 1, 21000)
+
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, tx1))
+	assert.Equal(t, 1, s.worker.CountTxs())
+
+	failedReason := ErrWorkerFull.Error()
+	txPoolMock.On("UpdateTxStatus", ctx, tx2.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, tx2))
+	assert.Equal(t, 1, s.worker.CountTxs())
+	txPoolMock.AssertNotCalled(t, "UpdateTxWIPStatus", ctx, tx2.Hash(), true)
+}
+
+func TestSequencer_LoadFromPoolNow(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{LoadPoolTxsCheckInterval: cfgTypes.NewDuration(time.Hour)})
+	mockTestSenderAccount(t, stMock, 0)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 1, 21000)
+	tx3 := newTestPoolTx(t, 2, 21000)
+
+	// The available txs are loaded without waiting for the check interval, tx3 fails to be set as WIP and it's not counted
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx2, tx3}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx3.Hash(), true).Return(errors.New("db error")).Once()
+	loaded, err := s.LoadFromPoolNow(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded)
+	assert.Equal(t, 2, s.worker.CountTxs())
+
+	// The error loading the txs from the pool is returned
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(nil, errors.New("pool error")).Once()
+	loaded, err = s.LoadFromPoolNow(ctx)
+	assert.EqualError(t, err, "pool error")
+	assert.Equal(t, 0, loaded)
+
+	// No txs are loaded while the sequencer is paused
+	s.paused.Store(true)
+	loaded, err = s.LoadFromPoolNow(ctx)
+	assert.ErrorIs(t, err, ErrSequencerPaused)
+	assert.Equal(t, 0, loaded)
+	txPoolMock.AssertNumberOfCalls(t, "GetNonWIPPendingTxs", 2)
+}
+
+// workerTxHashes returns the hashes of the txs (ready and notReady) stored in the worker
+func workerTxHashes(w *Worker) []common.Hash {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	hashes := []common.Hash{}
+	for _, addrQueue := range w.pool {
+		if addrQueue.readyTx != nil {
+			hashes = append(hashes, addrQueue.readyTx.Hash)
+		}
+		for _, txTracker := range addrQueue.notReadyTxs {
+			hashes = append(hashes, txTracker.Hash)
+		}
+	}
+	return hashes
+}
+
+func TestSequencer_RebuildWorker(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{LoadPoolTxsDedupTTL: cfgTypes.NewDuration(time.Hour)})
+	mockTestSenderAccount(t, stMock, 0)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 1, 21000)
+	tx3 := newTestPoolTx(t, 2, 21000)
+
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx2}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+	s.loadPoolTxs(ctx)
+	s.worker.SetTxInFlight(tx1.Hash(), testSenderAddr(t), true)
+
+	// The worker drifted from the pool, tx3 is non WIP pending in the pool but it's not in the worker. tx1 is being processed
+	// by the finalizer so it's kept, tx2 is set as non WIP pending and loaded again along with tx3
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), false).Return(nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx2, tx3}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx3.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.RebuildWorker(ctx))
+	assert.ElementsMatch(t, []common.Hash{tx1.Hash(), tx2.Hash(), tx3.Hash()}, workerTxHashes(s.worker))
+	assert.Equal(t, map[common.Address]uint64{testSenderAddr(t): 0}, s.worker.PendingNonces())
+
+	// The worker is not rebuilt while the sequencer is paused
+	s.paused.Store(true)
+	assert.ErrorIs(t, s.RebuildWorker(ctx), ErrSequencerPaused)
+	txPoolMock.AssertNumberOfCalls(t, "GetNonWIPPendingTxs", 2)
+}
+
+func TestSequencer_loadPoolTxs_WorkerFullBlock(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MaxWorkerTxs: 1, WorkerFullPolicy: WorkerFullPolicyBlock})
+	mockTestSenderAccount(t, stMock, 0)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 1, 21000)
+
+	// The worker gets full while loading, tx2 must not be added
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1, tx2}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, 1, s.worker.CountTxs())
+	txPoolMock.AssertNotCalled(t, "UpdateTxWIPStatus", ctx, tx2.Hash(), true)
+
+	// The worker is full, no txs must be loaded from the pool
+	s.loadPoolTxs(ctx)
+	txPoolMock.AssertNumberOfCalls(t, "GetNonWIPPendingTxs", 1)
+
+	// Once there is free space the loading continues
+	s.worker.DeleteTx(tx1.Hash(), testSenderAddr(t))
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx2}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, 1, s.worker.CountTxs())
+}
+
+// gasCapTxTransformer is a TxTransformer that caps the gas limit of the txs
+type gasCapTxTransformer struct {
+	maxGas uint64
+}
+
+func (g gasCapTxTransformer) Transform(tx pool.Transaction) (pool.Transaction, error) {
+	if tx.Gas() <= g.maxGas {
+		return tx, nil
+	}
+	v, r, s := tx.RawSignatureValues()
+	tx.Transaction = *types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		Gas:      g.maxGas,
+		To:       tx.To(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+		V:        v,
+		R:        r,
+		S:        s,
+	})
+	return tx, nil
+}
+
+// failingTxTransformer is a TxTransformer that always fails
+type failingTxTransformer struct{}
+
+func (failingTxTransformer) Transform(tx pool.Transaction) (pool.Transaction, error) {
+	return tx, errors.New("rule not satisfied")
+}
+
+func TestSequencer_addTxToWorker_TxTransformer(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{})
+	s.SetTxTransformer(gasCapTxTransformer{maxGas: 30000})
+
+	// The sender of the transformed tx is not the original one as the gas is a signed field
+	stMock.On("GetLastStateRoot", ctx, nil).Return(common.Hash{}, nil).Once()
+	stMock.On("GetNonceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(big.NewInt(0), nil).Once()
+	stMock.On("GetBalanceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(new(big.Int).SetUint64(1e18), nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, mock.Anything, true).Return(nil).Once()
+
+	tx := newTestPoolTx(t, 0, 50000)
+	require.NoError(t, s.addTxToWorker(ctx, tx))
+
+	require.Equal(t, 1, s.worker.txSortedList.len())
+	assert.Equal(t, uint64(30000), s.worker.txSortedList.getByIndex(0).Gas)
+}
+
+func TestSequencer_addTxToWorker_TxTransformerError(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{})
+	s.SetTxTransformer(failingTxTransformer{})
+
+	tx := newTestPoolTx(t, 0, 21000)
+	failedReason := "failed to transform tx, error: rule not satisfied"
+	txPoolMock.On("UpdateTxStatus", ctx, tx.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+
+	require.NoError(t, s.addTxToWorker(ctx, tx))
+	assert.Equal(t, 0, s.worker.CountTxs())
+}
+
+// newTestSendersPoolTxs returns the keys of 3 senders and their pool txs: 3 txs for the first sender, 2 for the second
+// and 1 for the third. The txs of each sender are returned in reverse nonce order
+func newTestSendersPoolTxs(t *testing.T) ([]*ecdsa.PrivateKey, []pool.Transaction) {
+	keys := []*ecdsa.PrivateKey{}
+	txs := []pool.Transaction{}
+	for i, numTxs := range []int{3, 2, 1} {
+		privateKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys = append(keys, privateKey)
+		for nonce := numTxs - 1; nonce >= 0; nonce-- {
+			txs = append(txs, newTestPoolTxWithKey(t, keys[i], uint64(nonce), 21000))
+		}
+	}
+	return keys, txs
+}
+
+func TestSortPoolTxsRoundRobin(t *testing.T) {
+	keys, txs := newTestSendersPoolTxs(t)
+
+	sortedTxs := sortPoolTxsRoundRobin(txs)
+	require.Len(t, sortedTxs, len(txs))
+
+	expected := []struct {
+		sender int
+		nonce  uint64
+	}{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {0, 2}}
+	for i, tx := range sortedTxs {
+		sender, err := state.GetSender(tx.Transaction)
+		require.NoError(t, err)
+		assert.Equal(t, crypto.PubkeyToAddress(keys[expected[i].sender].PublicKey), sender, "tx %d", i)
+		assert.Equal(t, expected[i].nonce, tx.Nonce(), "tx %d", i)
+	}
+}
+
+func TestSequencer_loadPoolTxs_RoundRobin(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{LoadPoolTxsRoundRobin: true, LoadPoolTxsMaxPerIteration: 4})
+	stMock.On("GetLastStateRoot", ctx, nil).Return(common.Hash{}, nil)
+	stMock.On("GetNonceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(big.NewInt(0), nil)
+	stMock.On("GetBalanceByStateRoot", ctx, mock.Anything, common.Hash{}).Return(new(big.Int).SetUint64(1e18), nil)
+
+	_, txs := newTestSendersPoolTxs(t)
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(txs, nil).Once()
+
+	// Only the first 4 txs in round-robin order are loaded
+	loadedTxs := []common.Hash{}
+	txPoolMock.On("UpdateTxWIPStatus", ctx, mock.Anything, true).Return(nil).Times(4).Run(func(args mock.Arguments) {
+		loadedTxs = append(loadedTxs, args.Get(1).(common.Hash))
+	})
+	s.loadPoolTxs(ctx)
+
+	// Expected order: sender 0 nonce 0, sender 1 nonce 0, sender 2 nonce 0, sender 0 nonce 1
+	assert.Equal(t, []common.Hash{txs[2].Hash(), txs[4].Hash(), txs[5].Hash(), txs[1].Hash()}, loadedTxs)
+}
+
+func TestSequencer_WhyDropped(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{DropRecordsSize: 2})
+	s.SetTxTransformer(failingTxTransformer{})
+	txPoolMock.On("UpdateTxStatus", ctx, mock.Anything, pool.TxStatusFailed, false, mock.Anything).Return(nil)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	require.NoError(t, s.addTxToWorker(ctx, tx1))
+
+	record, found := s.WhyDropped(tx1.Hash())
+	require.True(t, found)
+	assert.Equal(t, tx1.Hash(), record.Hash)
+	assert.Equal(t, DropPhaseAdd, record.Phase)
+	assert.Equal(t, "failed to transform tx, error: rule not satisfied", record.Reason)
+	assert.False(t, record.Timestamp.IsZero())
+
+	// The record of tx1 is kept until 2 more txs are dropped
+	require.NoError(t, s.addTxToWorker(ctx, newTestPoolTx(t, 1, 21000)))
+	_, found = s.WhyDropped(tx1.Hash())
+	assert.True(t, found)
+
+	require.NoError(t, s.addTxToWorker(ctx, newTestPoolTx(t, 2, 21000)))
+	_, found = s.WhyDropped(tx1.Hash())
+	assert.False(t, found)
+}
+
+func TestSequencer_loadPoolTxs_Ramp(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{LoadPoolTxsMaxPerIteration: 10, LoadPoolTxsRampStart: 2, LoadPoolTxsRampMultiplier: 2})
+	// The failing transformer drops the txs so the pool returns the same backlog in each check
+	s.SetTxTransformer(failingTxTransformer{})
+
+	backlog := []pool.Transaction{}
+	for nonce := uint64(0); nonce < 20; nonce++ {
+		backlog = append(backlog, newTestPoolTx(t, nonce, 21000))
+	}
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(backlog, nil)
+
+	addedTxs := 0
+	txPoolMock.On("UpdateTxStatus", ctx, mock.Anything, pool.TxStatusFailed, false, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		addedTxs++
+	})
+
+	for _, expected := range []int{2, 4, 8, 10, 10} {
+		addedTxs = 0
+		s.loadPoolTxs(ctx)
+		assert.Equal(t, expected, addedTxs)
+	}
+}
+
+// fakeFinalizer is a finalizer that signals when it's started, halted and resumed
+type fakeFinalizer struct {
+	started chan struct{}
+	halted  chan error
+	resumed chan struct{}
+	usage   BatchUsage
+}
+
+func (f *fakeFinalizer) Start(ctx context.Context) {
+	close(f.started)
+}
+
+func (f *fakeFinalizer) Halt(ctx context.Context, err error) {
+	f.halted <- err
+}
+
+func (f *fakeFinalizer) RequestHalt(ctx context.Context, err error) {
+	f.halted <- err
+}
+
+func (f *fakeFinalizer) Resume(ctx context.Context) {
+	f.resumed <- struct{}{}
+}
+
+func (f *fakeFinalizer) WIPBatchUsage() BatchUsage {
+	return f.usage
+}
+
+func TestSequencer_checkStateInconsistency_Halt(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{StateConsistencyCheckInterval: cfgTypes.NewDuration(time.Millisecond)})
+
+	fake := &fakeFinalizer{started: make(chan struct{}), halted: make(chan error, 1)}
+	s.finalizer = fake
+	s.startFinalizer(ctx)
+
+	select {
+	case <-fake.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not started")
+	}
+
+	// A reorg is detected in the first check, the second check fails and stops the checking loop
+	stMock.On("CountReorgs", ctx, nil).Return(uint64(1), nil).Once()
+	stMock.On("CountReorgs", ctx, nil).Return(uint64(0), errors.New("state error")).Once()
+	s.checkStateInconsistency(ctx)
+
+	select {
+	case err := <-fake.halted:
+		assert.EqualError(t, err, "state inconsistency detected, halting finalizer")
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted")
+	}
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+}
+
+func TestSequencer_HaltResumeFinalizer(t *testing.T) {
+	s, _, stMock := newTestSequencer(t, Config{})
+	fake := &fakeFinalizer{halted: make(chan error, 2), resumed: make(chan struct{}, 2)}
+	s.finalizer = fake
+
+	// Resuming a finalizer that is not halted does nothing
+	require.NoError(t, s.ResumeFinalizer())
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+
+	// Halting twice only halts the finalizer once
+	s.HaltFinalizer(errors.New("halt reason"))
+	haltState := s.GetFinalizerHaltState()
+	s.HaltFinalizer(errors.New("second halt reason"))
+	assert.Equal(t, haltState, s.GetFinalizerHaltState())
+	assert.True(t, haltState.Halted)
+	assert.Equal(t, "halt reason", haltState.Reason)
+
+	select {
+	case err := <-fake.halted:
+		assert.EqualError(t, err, "halt reason")
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted")
+	}
+
+	// Resuming twice only resumes the finalizer once
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(0), nil).Once()
+	require.NoError(t, s.ResumeFinalizer())
+	require.NoError(t, s.ResumeFinalizer())
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Len(t, fake.resumed, 1)
+	assert.Len(t, fake.halted, 0)
+}
+
+func TestSequencer_ResumeFinalizer_StateInconsistent(t *testing.T) {
+	s, _, stMock := newTestSequencer(t, Config{})
+	fake := &fakeFinalizer{halted: make(chan error, 2), resumed: make(chan struct{}, 2)}
+	s.finalizer = fake
+
+	s.HaltFinalizer(errors.New("state inconsistency detected, halting finalizer"))
+
+	// The inconsistency is still detected, the finalizer must not be resumed
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(1), nil).Once()
+	err := s.ResumeFinalizer()
+	assert.ErrorIs(t, err, ErrStateInconsistencyNotCleared)
+	var notClearedErr *StateInconsistencyNotClearedError
+	require.ErrorAs(t, err, &notClearedErr)
+	assert.Equal(t, StateInconsistencyNotClearedError{Detected: 1, Accepted: 0}, *notClearedErr)
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.resumed)
+
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(0), errors.New("state error")).Once()
+	assert.Error(t, s.ResumeFinalizer())
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.resumed)
+
+	// The operator overrides the check, the inconsistency detected is accepted
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(1), nil).Once()
+	require.NoError(t, s.ForceResumeFinalizer())
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Len(t, fake.resumed, 1)
+
+	// The finalizer is only halted again if a new inconsistency is detected
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(1), nil).Once()
+	s.HaltFinalizer(errors.New("halt reason"))
+	require.NoError(t, s.ResumeFinalizer())
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Len(t, fake.resumed, 2)
+}
+
+func TestSequencer_PauseStreaming_Disabled(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+	assert.ErrorIs(t, s.PauseStreaming(), ErrStreamingDisabled)
+	assert.ErrorIs(t, s.ResumeStreaming(), ErrStreamingDisabled)
+}
+
+func TestSequencer_checkOldWorkerTxs(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	events := make(eventStorageChan, 2)
+	s, _, _ := newTestSequencer(t, Config{TxAgeWarnThreshold: cfgTypes.NewDuration(time.Hour), TxAgeWarnEventInterval: cfgTypes.NewDuration(time.Hour)})
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	// The ready tx and one of the notReady txs are older than the warn threshold
+	now := time.Now()
+	addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+	addrQueue.readyTx = &TxTracker{Nonce: 0, ReceivedAt: now.Add(-2 * time.Hour)}
+	addrQueue.notReadyTxs[2] = &TxTracker{Nonce: 2, ReceivedAt: now.Add(-61 * time.Minute)}
+	addrQueue.notReadyTxs[3] = &TxTracker{Nonce: 3, ReceivedAt: now.Add(-59 * time.Minute)}
+	addrQueue.notReadyTxs[4] = &TxTracker{Nonce: 4, ReceivedAt: now}
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+
+	gauge, ok := zkmetrics.Gauge(metrics.WorkerOldTxsName)
+	require.True(t, ok)
+
+	s.checkOldWorkerTxs(context.Background())
+	assert.Equal(t, float64(2), testutil.ToFloat64(gauge))
+	select {
+	case e := <-events:
+		assert.Equal(t, event.EventID_WorkerTxAgeWarning, e.EventID)
+	default:
+		t.Fatal("tx age warning event not logged")
+	}
+
+	// The event is throttled but the gauge is updated
+	delete(addrQueue.notReadyTxs, 2)
+	s.checkOldWorkerTxs(context.Background())
+	assert.Equal(t, float64(1), testutil.ToFloat64(gauge))
+	assert.Empty(t, events)
+}
+
+func TestSequencer_loadPoolTxs_Dedup(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{LoadPoolTxsDedupTTL: cfgTypes.NewDuration(time.Minute)})
+	// The failing transformer drops the tx so the worker doesn't change in each check
+	s.SetTxTransformer(failingTxTransformer{})
+
+	counter, ok := zkmetrics.Counter(metrics.PoolTxsDeduplicatedName)
+	require.True(t, ok)
+	initial := testutil.ToFloat64(counter)
+
+	// The pool returns the same tx in both checks, it's only processed once
+	tx := newTestPoolTx(t, 0, 21000)
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx}, nil).Twice()
+	txPoolMock.On("UpdateTxStatus", ctx, tx.Hash(), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+
+	s.loadPoolTxs(ctx)
+	s.loadPoolTxs(ctx)
+	assert.Equal(t, initial+1, testutil.ToFloat64(counter))
+}
+
+func TestSequencer_loadPoolTxs_TxTrackerError(t *testing.T) {
+	// The tx is not signed so the sender can't be recovered to create the tx tracker
+	tx := *pool.NewTransaction(*types.NewTransaction(0, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil), "", false)
+
+	t.Run("fail", func(t *testing.T) {
+		ctx := context.Background()
+		s, txPoolMock, _ := newTestSequencer(t, Config{TxTrackerErrorPolicy: TxTrackerErrorPolicyFail, DropRecordsSize: 10})
+
+		txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx}, nil).Once()
+		txPoolMock.On("UpdateTxStatus", ctx, tx.Hash(), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+		s.loadPoolTxs(ctx)
+
+		record, found := s.WhyDropped(tx.Hash())
+		require.True(t, found)
+		assert.Equal(t, DropPhaseAdd, record.Phase)
+
+		// The tx is failed so it's not loaded again from the pool
+		txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(nil, pool.ErrNotFound).Once()
+		s.loadPoolTxs(ctx)
+		txPoolMock.AssertNumberOfCalls(t, "UpdateTxStatus", 1)
+		assert.Equal(t, 0, s.worker.txSortedList.len())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		ctx := context.Background()
+		s, txPoolMock, _ := newTestSequencer(t, Config{TxTrackerErrorPolicy: TxTrackerErrorPolicyRetry})
+
+		// The tx is kept as pending and loaded again in the next check
+		txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx}, nil).Twice()
+		s.loadPoolTxs(ctx)
+		s.loadPoolTxs(ctx)
+		txPoolMock.AssertNotCalled(t, "UpdateTxStatus", ctx, tx.Hash(), pool.TxStatusFailed, false, mock.Anything)
+	})
+}
+
+func TestSequencer_logMetricsLoop(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	// The logs are written to a file to check the snapshot is logged
+	logFile := filepath.Join(t.TempDir(), "sequencer.log")
+	log.Init(log.Config{Environment: log.EnvironmentDevelopment, Level: "info", Outputs: []string{logFile}})
+	t.Cleanup(func() {
+		log.Init(log.Config{Environment: log.EnvironmentDevelopment, Level: "debug", Outputs: []string{"stderr"}})
+	})
+
+	s, _, _ := newTestSequencer(t, Config{})
+	s.dataToStream = make(chan state.DSL2FullBlock, 2)
+	s.dataToStream <- newTestL2FullBlock(1, 1, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tick := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		s.logMetricsLoop(ctx, tick)
+		close(done)
+	}()
+
+	// Nothing is logged until the clock ticks
+	time.Sleep(10 * time.Millisecond)
+	logs, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(logs), "metrics snapshot")
+
+	tick <- time.Now()
+	require.Eventually(t, func() bool {
+		logs, err := os.ReadFile(logFile)
+		return err == nil && strings.Contains(string(logs), "metrics snapshot") && strings.Contains(string(logs), "datastream channel length: 1")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestSequencer_updateOldestPendingTxAge(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{})
+
+	gauge, ok := zkmetrics.Gauge(metrics.PoolOldestPendingTxAgeName)
+	require.True(t, ok)
+
+	// The oldest pending tx was received 1 minute ago
+	txPoolMock.On("GetOldestNonWIPPendingTxTime", ctx).Return(time.Now().Add(-time.Minute), nil).Once()
+	s.updateOldestPendingTxAge(ctx)
+	assert.InDelta(t, time.Minute.Seconds(), testutil.ToFloat64(gauge), 1)
+
+	// There are no pending txs in the pool
+	txPoolMock.On("GetOldestNonWIPPendingTxTime", ctx).Return(time.Time{}, pool.ErrNotFound).Once()
+	s.updateOldestPendingTxAge(ctx)
+	assert.Equal(t, float64(0), testutil.ToFloat64(gauge))
+
+	// The gauge is not updated if the pool fails
+	txPoolMock.On("GetOldestNonWIPPendingTxTime", ctx).Return(time.Now().Add(-time.Hour), nil).Once()
+	s.updateOldestPendingTxAge(ctx)
+	txPoolMock.On("GetOldestNonWIPPendingTxTime", ctx).Return(time.Time{}, errors.New("pool error")).Once()
+	s.updateOldestPendingTxAge(ctx)
+	assert.InDelta(t, time.Hour.Seconds(), testutil.ToFloat64(gauge), 1)
+}
+
+func TestSequencer_startFinalizer_WarmupDelay(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{FinalizerWarmupDelay: cfgTypes.NewDuration(300 * time.Millisecond)})
+	fake := &fakeFinalizer{started: make(chan struct{})}
+	s.finalizer = fake
+
+	start := time.Now()
+	s.startFinalizer(context.Background())
+
+	select {
+	case <-fake.started:
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not started")
+	}
+}
+
+func TestSequencer_startFinalizer_WarmupMinTxs(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{FinalizerWarmupDelay: cfgTypes.NewDuration(time.Hour), FinalizerWarmupMinTxs: 2})
+	fake := &fakeFinalizer{started: make(chan struct{})}
+	s.finalizer = fake
+
+	s.startFinalizer(context.Background())
+
+	addWorkerTxs := func(nonces ...uint64) {
+		s.worker.workerMutex.Lock()
+		defer s.worker.workerMutex.Unlock()
+		addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+		for _, nonce := range nonces {
+			addrQueue.notReadyTxs[nonce] = &TxTracker{Nonce: nonce}
+		}
+		s.worker.pool[addrQueue.fromStr] = addrQueue
+	}
+
+	// The finalizer waits while the worker has less than 2 txs
+	addWorkerTxs(1)
+	select {
+	case <-fake.started:
+		t.Fatal("finalizer started before the worker reached the min txs")
+	case <-time.After(3 * finalizerWarmupCheckInterval):
+	}
+
+	addWorkerTxs(1, 2)
+	select {
+	case <-fake.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not started")
+	}
+}
+
+func TestSequencer_CheckSynced(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{})
+	ethermanMock := NewEthermanMock(t)
+	s.etherman = ethermanMock
+
+	// The last virtual batch is behind L1
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(5), nil)
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(5), nil)
+
+	// The error getting the L1 state without a last known value is reported as not synced
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(0), errors.New("etherman error")).Once()
+	assert.ErrorIs(t, s.CheckSynced(ctx), ErrNotSynced)
+
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(6), nil).Once()
+	assert.ErrorIs(t, s.CheckSynced(ctx), ErrNotSynced)
+
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(5), nil).Once()
+	assert.NoError(t, s.CheckSynced(ctx))
+}
+
+func TestSequencer_loadPoolTxs_OnlyWhenSynced(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{LoadPoolTxsOnlyWhenSynced: true})
+	ethermanMock := NewEthermanMock(t)
+	s.etherman = ethermanMock
+	mockTestSenderAccount(t, stMock, 0)
+
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(5), nil)
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(5), nil)
+
+	// While the state is behind L1 the pool is not read
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(6), nil).Once()
+	loaded, err := s.loadPoolTxs(ctx)
+	require.ErrorIs(t, err, ErrNotSynced)
+	assert.Equal(t, 0, loaded)
+	txPoolMock.AssertNotCalled(t, "GetNonWIPPendingTxs", ctx)
+
+	// Once synced the txs are loaded
+	tx := newTestPoolTx(t, 0, 21000)
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(5), nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx}, nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx.Hash(), true).Return(nil).Once()
+	loaded, err = s.loadPoolTxs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+	assert.Equal(t, 1, s.worker.CountTxs())
+}
+
+func TestSequencer_isSynced_L1Retries(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{SyncCheckL1MaxRetries: 2, SyncCheckL1RetryBackoff: cfgTypes.NewDuration(time.Millisecond)})
+	ethermanMock := NewEthermanMock(t)
+	s.etherman = ethermanMock
+
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(5), nil)
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(5), nil)
+	l1Err := errors.New("etherman error")
+
+	// The transient errors are retried
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(0), l1Err).Twice()
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(5), nil).Once()
+	assert.True(t, s.isSynced(ctx))
+	assert.False(t, s.lastEthBatchNumStale)
+
+	// All the retries fail, the last known value is used and flagged as stale
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(0), l1Err).Times(3)
+	assert.True(t, s.isSynced(ctx))
+	assert.True(t, s.lastEthBatchNumStale)
+
+	// L1 is reachable again
+	ethermanMock.On("GetLatestBatchNumber").Return(uint64(6), nil).Once()
+	assert.False(t, s.isSynced(ctx))
+	assert.False(t, s.lastEthBatchNumStale)
+
+	ethermanMock.AssertNumberOfCalls(t, "GetLatestBatchNumber", 7)
+}
+
+func TestSequencer_Pause(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{PauseDrainTimeout: cfgTypes.NewDuration(300 * time.Millisecond)})
+
+	addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+	addrQueue.readyTx = &TxTracker{Nonce: 0}
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+
+	// The worker is not drained before the timeout
+	start := time.Now()
+	assert.False(t, s.Pause(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// The txs are not loaded from the pool while paused
+	s.loadPoolTxs(ctx)
+	txPoolMock.AssertNotCalled(t, "GetNonWIPPendingTxs", mock.Anything)
+
+	// The worker is drained while waiting
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.worker.workerMutex.Lock()
+		defer s.worker.workerMutex.Unlock()
+		delete(s.worker.pool, addrQueue.fromStr)
+	}()
+	assert.True(t, s.Pause(ctx))
+
+	s.Resume()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{}, nil).Once()
+	s.loadPoolTxs(ctx)
+}
+
+func TestSequencer_Pause_NotStarted(t *testing.T) {
+	s, txPoolMock, _ := newTestSequencer(t, Config{PauseDrainTimeout: cfgTypes.NewDuration(time.Second)})
+	s.worker = nil
+
+	// There is no worker to drain before the sequencer is started
+	start := time.Now()
+	assert.True(t, s.Pause(context.Background()))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The sequencer is started paused
+	s.worker = NewWorker(nil, s.batchCfg.Constraints)
+	s.loadPoolTxs(context.Background())
+	txPoolMock.AssertNotCalled(t, "GetNonWIPPendingTxs", mock.Anything)
+}
+
+func TestSequencer_addTxToWorker_Replacement(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{ReplacementRecordsSize: 10})
+	mockTestSenderAccount(t, stMock, 0)
+
+	counterVec, ok := zkmetrics.CounterVec(metrics.TxReplacedName)
+	require.True(t, ok)
+	initial := testutil.ToFloat64(counterVec.WithLabelValues(string(metrics.TxReplacedLabelSameGasPrice)))
+
+	// tx2 has the same nonce and gas price than tx1 and it replaces it
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 0, 22000)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, mock.Anything, true).Return(nil).Twice()
+	txPoolMock.On("UpdateTxStatus", ctx, tx1.Hash(), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+
+	require.NoError(t, s.addTxToWorker(ctx, tx1))
+	assert.Empty(t, s.RecentReplacements())
+	require.NoError(t, s.addTxToWorker(ctx, tx2))
+
+	assert.Equal(t, initial+1, testutil.ToFloat64(counterVec.WithLabelValues(string(metrics.TxReplacedLabelSameGasPrice))))
+	records := s.RecentReplacements()
+	require.Len(t, records, 1)
+	assert.Equal(t, tx1.Hash(), records[0].Hash)
+	assert.Equal(t, tx2.Hash(), records[0].ReplacedBy)
+	assert.Equal(t, testSenderAddr(t), records[0].From)
+	assert.Equal(t, metrics.TxReplacedLabelSameGasPrice, records[0].Reason)
+}
+
+func TestSequencer_recordDrop_LogDropsToEventLog(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		cfg            Config
+		expectedEvents int
+	}{
+		{"disabled", Config{LogDropsToEventLog: false}, 0},
+		{"enabled", Config{LogDropsToEventLog: true}, 3},
+		{"enabled throttled", Config{LogDropsToEventLog: true, DropEventsMaxPerSecond: 2}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newTestSequencer(t, tc.cfg)
+			events := make(eventStorageChan, 3)
+			s.eventLog = event.NewEventLog(event.Config{}, events)
+
+			for i := int64(1); i <= 3; i++ {
+				s.recordDrop(common.BigToHash(big.NewInt(i)), DropPhaseExpire, ErrExpiredTransaction.Error())
+			}
+
+			require.Len(t, events, tc.expectedEvents)
+			for i := 0; i < tc.expectedEvents; i++ {
+				e := <-events
+				assert.Equal(t, event.EventID_SequencerTxDropped, e.EventID)
+				assert.Equal(t, DropPhaseExpire, e.Json.(DropRecord).Phase)
+			}
+		})
+	}
+}
+
+func TestSequencer_Start_Standby(t *testing.T) {
+	s, txPoolMock, stMock := newTestSequencer(t, Config{
+		Mode:                          ModeStandby,
+		LoadPoolTxsCheckInterval:      cfgTypes.NewDuration(time.Millisecond),
+		TxLifetimeCheckInterval:       cfgTypes.NewDuration(time.Millisecond),
+		StateConsistencyCheckInterval: cfgTypes.NewDuration(time.Millisecond),
+	})
+	s.worker = nil
+	fake := &fakeFinalizer{started: make(chan struct{})}
+	s.finalizerFactory = func(s *Sequencer) finalizerInterface {
+		return fake
+	}
+
+	// The state is synced
+	stMock.On("GetLastVirtualBatchNum", mock.Anything, nil).Return(uint64(0), nil)
+	stMock.On("GetLastBatchNumber", mock.Anything, nil).Return(uint64(1), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-fake.started:
+		t.Fatal("finalizer started in standby mode")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sequencer not stopped")
+	}
+
+	// Neither the pool txs are loaded, nor the worker and the finalizer are created
+	assert.Nil(t, s.worker)
+	assert.Nil(t, s.finalizer)
+	txPoolMock.AssertNotCalled(t, "MarkWIPTxsAsPending", mock.Anything)
+	txPoolMock.AssertNotCalled(t, "GetNonWIPPendingTxs", mock.Anything)
+	stMock.AssertNotCalled(t, "CountReorgs", mock.Anything, mock.Anything)
+}
+
+func TestSequencer_deleteOldPoolTxs_MinDeleteConfirmations(t *testing.T) {
+	s, txPoolMock, stMock := newTestSequencer(t, Config{DeletePoolTxsL1BlockConfirmations: 5, MinDeleteConfirmations: 64, DeletePoolTxsCheckInterval: cfgTypes.NewDuration(time.Millisecond)})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The configured confirmations are clamped up to MinDeleteConfirmations, both for the confirmed and the failed txs
+	assert.Equal(t, uint64(64), s.deletePoolTxsConfirmations())
+	stMock.On("GetTxsOlderThanNL1Blocks", mock.Anything, uint64(64), nil).Return([]common.Hash{}, nil).Once()
+	txPoolMock.On("DeleteFailedTransactionsOlderThan", mock.Anything, mock.MatchedBy(func(date time.Time) bool {
+		return time.Since(date) >= 64*l1BlockTime
+	})).Return(nil).Once().Run(func(args mock.Arguments) { cancel() })
+
+	s.deleteOldPoolTxs(ctx)
+
+	// The config values above the minimum are used as is
+	s.cfg.DeletePoolTxsL1BlockConfirmations = 100
+	assert.Equal(t, uint64(100), s.deletePoolTxsConfirmations())
+}
+
+func TestSequencer_deleteOldPoolTxs_FailedTxsDeletionError(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{
+		DeletePoolTxsCheckInterval:       cfgTypes.NewDuration(time.Millisecond),
+		DeleteFailedPoolTxsRetryInterval: cfgTypes.NewDuration(time.Millisecond),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deleted, ok := zkmetrics.Counter(metrics.PoolTxsDeletedName)
+	require.True(t, ok)
+	failures, ok := zkmetrics.CounterVec(metrics.PoolTxsDeleteFailuresName)
+	require.True(t, ok)
+	initialDeleted := testutil.ToFloat64(deleted)
+	initialConfirmedFailures := testutil.ToFloat64(failures.WithLabelValues(string(metrics.PoolTxsDeleteKindConfirmed)))
+	initialFailedFailures := testutil.ToFloat64(failures.WithLabelValues(string(metrics.PoolTxsDeleteKindFailed)))
+
+	// The confirmed txs are deleted, the deletion of the failed txs fails and it's retried alone after
+	// DeleteFailedPoolTxsRetryInterval instead of waiting for the next check
+	txHashes := []common.Hash{common.HexToHash("0x1"), common.HexToHash("0x2")}
+	stMock.On("GetTxsOlderThanNL1Blocks", mock.Anything, uint64(0), nil).Return(txHashes, nil).Once()
+	txPoolMock.On("DeleteTransactionsByHashes", mock.Anything, txHashes).Return(nil).Once()
+	txPoolMock.On("DeleteFailedTransactionsOlderThan", mock.Anything, mock.Anything).Return(errors.New("pool error")).Once().Run(func(args mock.Arguments) {
+		s.cfg.DeletePoolTxsCheckInterval = cfgTypes.NewDuration(time.Hour)
+	})
+	txPoolMock.On("DeleteFailedTransactionsOlderThan", mock.Anything, mock.Anything).Return(nil).Once().Run(func(args mock.Arguments) { cancel() })
+
+	s.deleteOldPoolTxs(ctx)
+
+	stMock.AssertNumberOfCalls(t, "GetTxsOlderThanNL1Blocks", 1)
+	txPoolMock.AssertNumberOfCalls(t, "DeleteFailedTransactionsOlderThan", 2)
+	assert.Equal(t, initialDeleted+2, testutil.ToFloat64(deleted))
+	assert.Equal(t, initialConfirmedFailures, testutil.ToFloat64(failures.WithLabelValues(string(metrics.PoolTxsDeleteKindConfirmed))))
+	assert.Equal(t, initialFailedFailures+1, testutil.ToFloat64(failures.WithLabelValues(string(metrics.PoolTxsDeleteKindFailed))))
+}
+
+func TestSequencer_deletePoolTxs(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{DeletePoolTxsBatchSize: 1000})
+
+	txHashes := make([]common.Hash, 0, 2500)
+	for i := 0; i < 2500; i++ {
+		txHashes = append(txHashes, common.BigToHash(big.NewInt(int64(i))))
+	}
+
+	// The second chunk fails, the first and last ones are deleted anyway
+	txPoolMock.On("DeleteTransactionsByHashes", ctx, txHashes[:1000]).Return(nil).Once()
+	txPoolMock.On("DeleteTransactionsByHashes", ctx, txHashes[1000:2000]).Return(errors.New("pool error")).Once()
+	txPoolMock.On("DeleteTransactionsByHashes", ctx, txHashes[2000:]).Return(nil).Once()
+
+	assert.Equal(t, 1500, s.deletePoolTxs(ctx, txHashes))
+	txPoolMock.AssertNumberOfCalls(t, "DeleteTransactionsByHashes", 3)
+
+	// Without batch size all the txs are deleted in a single call
+	s.cfg.DeletePoolTxsBatchSize = 0
+	txPoolMock.On("DeleteTransactionsByHashes", ctx, txHashes).Return(nil).Once()
+	assert.Equal(t, 2500, s.deletePoolTxs(ctx, txHashes))
+	txPoolMock.AssertNumberOfCalls(t, "DeleteTransactionsByHashes", 4)
+}
+
+func TestSequencer_expireWorkerTxs_RetryStatusUpdate(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{TxLifetimeMax: cfgTypes.NewDuration(time.Minute), ExpiredTxsMaxRetries: 1, DropRecordsSize: 10})
+
+	counterVec, ok := zkmetrics.CounterVec(metrics.TxProcessedName)
+	require.True(t, ok)
+	initial := testutil.ToFloat64(counterVec.WithLabelValues(string(metrics.TxProcessedLabelFailed)))
+
+	// The txs with nonce 0 and 1 are expired, the status update of both fails and they are retried
+	addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+	expiredTx0 := &TxTracker{Hash: common.HexToHash("0x1"), Nonce: 0, ReceivedAt: time.Now().Add(-time.Hour)}
+	expiredTx1 := &TxTracker{Hash: common.HexToHash("0x2"), Nonce: 1, ReceivedAt: time.Now().Add(-time.Hour)}
+	addrQueue.notReadyTxs[0] = expiredTx0
+	addrQueue.notReadyTxs[1] = expiredTx1
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+
+	txPoolMock.On("UpdateTxStatus", ctx, mock.Anything, pool.TxStatusFailed, false, mock.Anything).Return(errors.New("pool error")).Twice()
+	s.expireWorkerTxs(ctx)
+
+	assert.Equal(t, initial, testutil.ToFloat64(counterVec.WithLabelValues(string(metrics.TxProcessedLabelFailed))))
+	assert.Equal(t, map[common.Hash]uint64{expiredTx0.Hash: 1, expiredTx1.Hash: 1}, s.expiredTxsToRetry)
+
+	// The status update of the first tx succeeds in the next check, the second one fails again and reaches the max retries
+	txPoolMock.On("UpdateTxStatus", ctx, expiredTx0.Hash, pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	txPoolMock.On("UpdateTxStatus", ctx, expiredTx1.Hash, pool.TxStatusFailed, false, mock.Anything).Return(errors.New("pool error")).Once()
+	s.expireWorkerTxs(ctx)
+
+	assert.Equal(t, initial+1, testutil.ToFloat64(counterVec.WithLabelValues(string(metrics.TxProcessedLabelFailed))))
+	assert.Empty(t, s.expiredTxsToRetry)
+	txPoolMock.AssertNumberOfCalls(t, "UpdateTxStatus", 4)
+}
+
+func TestSequencer_expireWorkerTxs_InclusionDeadline(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{TxLifetimeMax: cfgTypes.NewDuration(time.Hour), ExpiredTxsMaxRetries: 1})
+
+	now := time.Now()
+	pastDeadline := now.Add(-time.Second)
+	futureDeadline := now.Add(time.Hour)
+
+	// The txs past their deadline and the ones without deadline older than TxLifetimeMax are expired
+	addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+	addrQueue.readyTx = &TxTracker{Hash: common.HexToHash("0x1"), HashStr: common.HexToHash("0x1").String(), Nonce: 0, GasPrice: big.NewInt(1), ReceivedAt: now, InclusionDeadline: &pastDeadline}
+	addrQueue.notReadyTxs[2] = &TxTracker{Hash: common.HexToHash("0x2"), Nonce: 2, ReceivedAt: now, InclusionDeadline: &futureDeadline}
+	addrQueue.notReadyTxs[3] = &TxTracker{Hash: common.HexToHash("0x3"), Nonce: 3, ReceivedAt: now}
+	addrQueue.notReadyTxs[4] = &TxTracker{Hash: common.HexToHash("0x4"), Nonce: 4, ReceivedAt: now.Add(-2 * time.Hour)}
+	addrQueue.notReadyTxs[5] = &TxTracker{Hash: common.HexToHash("0x5"), Nonce: 5, ReceivedAt: now.Add(-2 * time.Hour), InclusionDeadline: &futureDeadline}
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+	s.worker.txSortedList.add(addrQueue.readyTx)
+
+	txPoolMock.On("UpdateTxStatus", ctx, common.HexToHash("0x1"), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	txPoolMock.On("UpdateTxStatus", ctx, common.HexToHash("0x4"), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	txPoolMock.On("UpdateTxStatus", ctx, common.HexToHash("0x5"), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	s.expireWorkerTxs(ctx)
+
+	txPoolMock.AssertNumberOfCalls(t, "UpdateTxStatus", 3)
+	assert.Nil(t, addrQueue.readyTx)
+	assert.Equal(t, 0, s.worker.txSortedList.len())
+	assert.Len(t, addrQueue.notReadyTxs, 2)
+	assert.Contains(t, addrQueue.notReadyTxs, uint64(2))
+	assert.Contains(t, addrQueue.notReadyTxs, uint64(3))
+}
+
+func TestSequencer_expireWorkerTxs_InFlight(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, _ := newTestSequencer(t, Config{TxLifetimeMax: cfgTypes.NewDuration(time.Minute), TxLifetimeSkipInFlight: true})
+
+	addrQueue := newAddrQueue(testSenderAddr(t), 0, big.NewInt(0))
+	addrQueue.readyTx = &TxTracker{Hash: common.HexToHash("0x1"), HashStr: common.HexToHash("0x1").String(), From: testSenderAddr(t), Nonce: 0, GasPrice: big.NewInt(1), ReceivedAt: time.Now().Add(-time.Hour)}
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+	s.worker.txSortedList.add(addrQueue.readyTx)
+
+	// The tx being processed by the finalizer is not expired
+	s.worker.SetTxInFlight(common.HexToHash("0x1"), testSenderAddr(t), true)
+	s.expireWorkerTxs(ctx)
+	assert.NotNil(t, addrQueue.readyTx)
+	assert.Equal(t, 1, s.worker.txSortedList.len())
+
+	// Once processed it's expired if it's still in the worker
+	s.worker.SetTxInFlight(common.HexToHash("0x1"), testSenderAddr(t), false)
+	txPoolMock.On("UpdateTxStatus", ctx, common.HexToHash("0x1"), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	s.expireWorkerTxs(ctx)
+	assert.Nil(t, addrQueue.readyTx)
+	assert.Equal(t, 0, s.worker.txSortedList.len())
+
+	// Without TxLifetimeSkipInFlight the in-flight txs are expired too
+	s.cfg.TxLifetimeSkipInFlight = false
+	addrQueue.notReadyTxs[1] = &TxTracker{Hash: common.HexToHash("0x2"), Nonce: 1, ReceivedAt: time.Now().Add(-time.Hour)}
+	s.worker.pool[addrQueue.fromStr] = addrQueue
+	s.worker.SetTxInFlight(common.HexToHash("0x2"), testSenderAddr(t), true)
+	assert.True(t, addrQueue.notReadyTxs[1].InFlight)
+	txPoolMock.On("UpdateTxStatus", ctx, common.HexToHash("0x2"), pool.TxStatusFailed, false, mock.Anything).Return(nil).Once()
+	s.expireWorkerTxs(ctx)
+	assert.Empty(t, addrQueue.notReadyTxs)
+}
+
+func TestSequencer_superviseLoop(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{LoopMaxRestarts: 2, LoopRestartBackoff: cfgTypes.NewDuration(time.Millisecond)})
+	events := make(eventStorageChan, 2)
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	// The loop panics on the first run and returns on the second one, once the context is done
+	runs := 0
+	s.superviseLoop(context.Background(), "test", func(ctx context.Context) {
+		runs++
+		if runs == 1 {
+			var m map[string]int
+			m["key"]++
+		}
+	})
+	assert.Equal(t, 2, runs)
+
+	require.Len(t, events, 1)
+	e := <-events
+	assert.Equal(t, event.EventID_SequencerLoopRestart, e.EventID)
+	assert.Equal(t, event.Level_Critical, e.Level)
+	assert.Contains(t, e.Description, "sequencer loop test panicked (assignment to entry in nil map)")
+	assert.Contains(t, e.Description, "restart 1 of 2")
+}
+
+func TestSequencer_superviseLoop_MaxRestarts(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{LoopMaxRestarts: 2, LoopRestartBackoff: cfgTypes.NewDuration(time.Millisecond)})
+	events := make(eventStorageChan, 3)
+	s.eventLog = event.NewEventLog(event.Config{}, events)
+
+	// The loop always panics, it's restarted twice and then the supervisor gives up
+	runs := 0
+	s.superviseLoop(context.Background(), "test", func(ctx context.Context) {
+		runs++
+		panic("loop failure")
+	})
+	assert.Equal(t, 3, runs)
+
+	require.Len(t, events, 3)
+	<-events
+	<-events
+	e := <-events
+	assert.Contains(t, e.Description, "max restarts (2) reached")
+}
+
+func TestSequencer_checkBatchesAheadOfL1(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{MaxBatchesAheadOfL1: 2})
+	fake := &fakeFinalizer{halted: make(chan error, 1), resumed: make(chan struct{}, 1)}
+	s.finalizer = fake
+
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(10), nil)
+
+	// The gap is within the limit
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(12), nil).Once()
+	s.checkBatchesAheadOfL1(ctx)
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+
+	// The gap goes past the limit, the finalizer is halted only once
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(13), nil).Twice()
+	s.checkBatchesAheadOfL1(ctx)
+	s.checkBatchesAheadOfL1(ctx)
+	select {
+	case err := <-fake.halted:
+		assert.ErrorIs(t, err, ErrTooFarAheadOfL1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted")
+	}
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.halted)
+
+	// The gap drops back to the limit, the finalizer is resumed
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(12), nil).Once()
+	s.checkBatchesAheadOfL1(ctx)
+	select {
+	case <-fake.resumed:
+	default:
+		t.Fatal("finalizer not resumed")
+	}
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+}
+
+func TestSequencer_checkBatchesAheadOfL1_OtherHaltReason(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{MaxBatchesAheadOfL1: 2})
+	fake := &fakeFinalizer{halted: make(chan error, 1), resumed: make(chan struct{}, 1)}
+	s.finalizer = fake
+
+	// The finalizer halted because of a state inconsistency is not resumed when the gap is within the limit
+	s.HaltFinalizer(errors.New("state inconsistency detected"))
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(10), nil)
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(11), nil).Once()
+	s.checkBatchesAheadOfL1(ctx)
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.resumed)
+}
+
+func TestSequencer_haltFinalizer_MultipleReasons(t *testing.T) {
+	ctx := context.Background()
+	s, _, stMock := newTestSequencer(t, Config{MaxBatchesAheadOfL1: 2})
+	fake := &fakeFinalizer{halted: make(chan error, 2), resumed: make(chan struct{}, 2)}
+	s.finalizer = fake
+
+	// Both monitors halt the finalizer, it's only halted once with the first reason
+	s.haltFinalizer(HaltReasonStateInconsistency, errors.New("state inconsistency detected"))
+	stMock.On("GetLastVirtualBatchNum", ctx, nil).Return(uint64(10), nil)
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(13), nil).Twice()
+	s.checkBatchesAheadOfL1(ctx)
+	s.checkBatchesAheadOfL1(ctx)
+	haltState := s.GetFinalizerHaltState()
+	assert.True(t, haltState.Halted)
+	assert.Equal(t, "state inconsistency detected", haltState.Reason)
+	assert.Equal(t, []string{HaltReasonAheadOfL1, HaltReasonStateInconsistency}, haltState.Reasons)
+	select {
+	case err := <-fake.halted:
+		assert.EqualError(t, err, "state inconsistency detected")
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted")
+	}
+
+	// The state inconsistency is cleared, the finalizer keeps halted by the batches ahead of L1
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(0), nil).Once()
+	require.NoError(t, s.ResumeFinalizer())
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Equal(t, []string{HaltReasonAheadOfL1}, s.GetFinalizerHaltState().Reasons)
+	assert.Empty(t, fake.resumed)
+
+	// The gap drops back to the limit, the finalizer is resumed once all the reasons are cleared
+	stMock.On("GetLastBatchNumber", ctx, nil).Return(uint64(12), nil).Twice()
+	s.checkBatchesAheadOfL1(ctx)
+	s.checkBatchesAheadOfL1(ctx)
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, s.GetFinalizerHaltState().Reasons)
+	assert.Len(t, fake.resumed, 1)
+	assert.Empty(t, fake.halted)
+}
+
+func TestSequencer_haltFinalizer_MinHaltInterval(t *testing.T) {
+	s, _, stMock := newTestSequencer(t, Config{MinHaltInterval: cfgTypes.NewDuration(200 * time.Millisecond)})
+	fake := &fakeFinalizer{halted: make(chan error, 10), resumed: make(chan struct{}, 10)}
+	s.finalizer = fake
+
+	s.HaltFinalizer(errors.New("halt reason"))
+	assert.EqualError(t, <-fake.halted, "halt reason")
+	stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(0), nil)
+	require.NoError(t, s.ResumeFinalizer())
+
+	// A flapping condition triggers many halts during the cool-down, they are coalesced in a single deferred halt
+	for i := 0; i < 20; i++ {
+		s.haltFinalizer(HaltReasonStateInconsistency, errors.New("state inconsistency detected"))
+		s.clearFinalizerHaltReasons(HaltReasonStateInconsistency)
+		s.haltFinalizer(HaltReasonStateInconsistency, errors.New("state inconsistency detected"))
+	}
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.halted)
+
+	select {
+	case err := <-fake.halted:
+		assert.EqualError(t, err, "state inconsistency detected")
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted after the cool-down")
+	}
+	assert.True(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.halted)
+	assert.Len(t, fake.resumed, 1)
+
+	// The deferred halt is cancelled if its reasons are cleared during the cool-down
+	require.NoError(t, s.ResumeFinalizer())
+	s.HaltFinalizer(errors.New("halt reason"))
+	require.NoError(t, s.ResumeFinalizer())
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, s.GetFinalizerHaltState().Halted)
+	assert.Empty(t, fake.halted)
+}
+
+func TestSequencer_reconcilePendingTxs(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{ReconcilePendingTxsAtStartup: true, DropRecordsSize: 10})
+
+	staleTx := newTestPoolTx(t, 1, 21000)
+	validTx := newTestPoolTx(t, 2, 21000)
+
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{staleTx, validTx}, nil).Once()
+	stMock.On("GetLastStateRoot", ctx, nil).Return(common.HexToHash("0x01"), nil).Once()
+	// The nonce of the sender is got once for all its txs
+	stMock.On("GetNonce", ctx, testSenderAddr(t), common.HexToHash("0x01")).Return(uint64(2), nil).Once()
+
+	failedReason := ErrStaleNonce.Error() + ", tx nonce: 1, sender nonce: 2"
+	txPoolMock.On("UpdateTxStatus", ctx, staleTx.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+
+	s.reconcilePendingTxs(ctx)
+
+	record, found := s.WhyDropped(staleTx.Hash())
+	require.True(t, found)
+	assert.Equal(t, DropPhaseReconcile, record.Phase)
+	_, found = s.WhyDropped(validTx.Hash())
+	assert.False(t, found)
+	txPoolMock.AssertNotCalled(t, "UpdateTxStatus", ctx, validTx.Hash(), mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSequencer_loadPoolTxs_IterationMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{})
+	mockTestSenderAccount(t, stMock, 0)
+
+	idleCounter, ok := zkmetrics.Counter(metrics.LoadPoolIdleIterationsName)
+	require.True(t, ok)
+	productiveCounter, ok := zkmetrics.Counter(metrics.LoadPoolProductiveIterationsName)
+	require.True(t, ok)
+	ratioGauge, ok := zkmetrics.Gauge(metrics.LoadPoolIdleRatioName)
+	require.True(t, ok)
+
+	// The counters are global, so only the increments of this test are checked
+	idleBefore := testutil.ToFloat64(idleCounter)
+	productiveBefore := testutil.ToFloat64(productiveCounter)
+
+	tx1 := newTestPoolTx(t, 0, 21000)
+	tx2 := newTestPoolTx(t, 1, 21000)
+
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{}, pool.ErrNotFound).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx1}, nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{}, nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{tx2}, nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return([]pool.Transaction{}, nil).Once()
+	// The iterations failing to get the txs from the pool are not counted
+	txPoolMock.On("GetNonWIPPendingTxs", ctx).Return(nil, errors.New("pool error")).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(nil).Once()
+
+	for i := 0; i < 6; i++ {
+		s.loadPoolTxs(ctx)
+	}
+
+	idle := testutil.ToFloat64(idleCounter)
+	productive := testutil.ToFloat64(productiveCounter)
+	assert.Equal(t, float64(3), idle-idleBefore)
+	assert.Equal(t, float64(2), productive-productiveBefore)
+	assert.Equal(t, idle/(idle+productive), testutil.ToFloat64(ratioGauge))
+}
+
+func TestSequencer_addTxToWorker_RetryWIPStatusUpdate(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{WIPStatusUpdateMaxRetries: 1, WIPStatusUpdateRetryInterval: cfgTypes.NewDuration(time.Millisecond)})
+	mockTestSenderAccount(t, stMock, 0)
+
+	// The WIP status update fails once and then succeeds, the tx is kept in the worker
+	tx1 := newTestPoolTx(t, 0, 21000)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(errors.New("db error")).Once()
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx1.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, tx1))
+	assert.Equal(t, 1, s.worker.CountTxs())
+	txPoolMock.AssertNumberOfCalls(t, "UpdateTxWIPStatus", 2)
+
+	// The WIP status update fails in all the retries, the tx is deleted from the worker
+	tx2 := newTestPoolTx(t, 1, 21000)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, tx2.Hash(), true).Return(errors.New("db error")).Twice()
+	require.Error(t, s.addTxToWorker(ctx, tx2))
+	assert.Equal(t, 1, s.worker.CountTxs())
+	txPoolMock.AssertNumberOfCalls(t, "UpdateTxWIPStatus", 4)
+}
+
+func TestSequencer_addTxToWorker_ForkIDMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.RegisterForkIDMetrics()
+	initialAdded8 := forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "8")
+	initialAdded9 := forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "9")
+
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MetricsForkIDLabel: true})
+	finalizer := &fakeFinalizer{usage: BatchUsage{BatchNumber: 1}}
+	s.finalizer = finalizer
+	mockTestSenderAccount(t, stMock, 0)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, mock.Anything, true).Return(nil)
+
+	// The fork 9 is activated in the batch 2
+	stMock.On("GetForkIDByBatchNumber", uint64(1)).Return(uint64(8))
+	stMock.On("GetForkIDByBatchNumber", uint64(2)).Return(uint64(9))
+
+	require.NoError(t, s.addTxToWorker(ctx, newTestPoolTx(t, 0, 21000)))
+	finalizer.usage.BatchNumber = 2
+	require.NoError(t, s.addTxToWorker(ctx, newTestPoolTx(t, 1, 21000)))
+	require.NoError(t, s.addTxToWorker(ctx, newTestPoolTx(t, 2, 21000)))
+
+	assert.Equal(t, initialAdded8+1, forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "8"))
+	assert.Equal(t, initialAdded9+2, forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "9"))
+}
+
+func TestSequencer_Start_ForkIDMetrics(t *testing.T) {
+	zkmetrics.Init()
+	metrics.RegisterForkIDMetrics()
+	initialAdded := forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "9")
+
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MetricsForkIDLabel: true, LoadPoolTxsCheckInterval: cfgTypes.NewDuration(time.Millisecond)})
+	s.worker = nil
+	s.finalizerFactory = func(s *Sequencer) finalizerInterface {
+		return &fakeFinalizer{started: make(chan struct{}), usage: BatchUsage{BatchNumber: 1}}
+	}
+	for _, loop := range []string{LoopDeleteOldPoolTxs, LoopExpireOldWorkerTxs, LoopCheckStateInconsistency} {
+		require.NoError(t, s.SetLoopEnabled(loop, false))
+	}
+
+	// The state is synced
+	stMock.On("GetLastVirtualBatchNum", mock.Anything, nil).Return(uint64(0), nil)
+	stMock.On("GetLastBatchNumber", mock.Anything, nil).Return(uint64(1), nil)
+	stMock.On("GetForkIDByBatchNumber", uint64(1)).Return(uint64(9))
+	mockTestSenderAccount(t, stMock, 0)
+
+	// The first load from the pool adds a tx to the worker, labeled with the fork of the WIP batch of the finalizer
+	tx := newTestPoolTx(t, 0, 21000)
+	added := make(chan struct{})
+	txPoolMock.On("MarkWIPTxsAsPending", mock.Anything).Return(nil)
+	txPoolMock.On("GetOldestNonWIPPendingTxTime", mock.Anything).Return(time.Time{}, pool.ErrNotFound)
+	txPoolMock.On("GetNonWIPPendingTxs", mock.Anything).Return([]pool.Transaction{tx}, nil).Once()
+	txPoolMock.On("GetNonWIPPendingTxs", mock.Anything).Return([]pool.Transaction{}, nil)
+	txPoolMock.On("UpdateTxWIPStatus", mock.Anything, tx.Hash(), true).Return(nil).Run(func(args mock.Arguments) {
+		close(added)
+	}).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool tx not added to the worker")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sequencer not stopped")
+	}
+
+	assert.Equal(t, initialAdded+1, forkIDCounterValue(t, metrics.WorkerTxsAddedByForkIDName, "9"))
+}
+
+func TestSequencer_addTxToWorker_MaxWorkerBytes(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MaxWorkerBytes: 2500, DropRecordsSize: 10})
+	mockTestSenderAccount(t, stMock, 0)
+
+	privateKey, err := crypto.HexToECDSA(testSenderPvtKey)
+	require.NoError(t, err)
+	newLargeTx := func(nonce uint64) pool.Transaction {
+		tx := types.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(1), 100000, big.NewInt(1), make([]byte, 1000))
+		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(testChainID), privateKey)
+		require.NoError(t, err)
+		return *pool.NewTransaction(*signedTx, "", false)
+	}
+
+	// The first two txs fit in the worker
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := newLargeTx(nonce)
+		txPoolMock.On("UpdateTxWIPStatus", ctx, tx.Hash(), true).Return(nil).Once()
+		require.NoError(t, s.addTxToWorker(ctx, tx))
+	}
+	assert.Equal(t, 2, s.worker.CountTxs())
+	assert.Greater(t, s.worker.CountBytes(), uint64(2000))
+
+	// The third one exceeds the bytes limit
+	tx3 := newLargeTx(2)
+	failedReason := ErrWorkerBytesLimit.Error()
+	txPoolMock.On("UpdateTxStatus", ctx, tx3.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, tx3))
+	assert.Equal(t, 2, s.worker.CountTxs())
+	record, found := s.WhyDropped(tx3.Hash())
+	require.True(t, found)
+	assert.Equal(t, failedReason, record.Reason)
+}
+
+func TestSequencer_addTxToWorker_MaxAcceptedGasLimit(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{MaxAcceptedGasLimit: 30000, DropRecordsSize: 10})
+	mockTestSenderAccount(t, stMock, 0)
+
+	// The txs below and at the ceiling are added to the worker
+	txBelow := newTestPoolTx(t, 0, 29999)
+	txAt := newTestPoolTx(t, 1, 30000)
+	for _, tx := range []pool.Transaction{txBelow, txAt} {
+		txPoolMock.On("UpdateTxWIPStatus", ctx, tx.Hash(), true).Return(nil).Once()
+		require.NoError(t, s.addTxToWorker(ctx, tx))
+	}
+	assert.Equal(t, 2, s.worker.CountTxs())
+
+	// The tx above the ceiling is dropped
+	txAbove := newTestPoolTx(t, 2, 30001)
+	failedReason := ErrGasLimitAboveCeiling.Error() + ", tx gas limit: 30001, max accepted gas limit: 30000"
+	txPoolMock.On("UpdateTxStatus", ctx, txAbove.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, txAbove))
+	assert.Equal(t, 2, s.worker.CountTxs())
+	txPoolMock.AssertNotCalled(t, "UpdateTxWIPStatus", ctx, txAbove.Hash(), true)
+
+	record, found := s.WhyDropped(txAbove.Hash())
+	require.True(t, found)
+	assert.Equal(t, failedReason, record.Reason)
+	assert.Equal(t, DropPhaseAdd, record.Phase)
+}
+
+func TestSequencer_addTxToWorker_RejectTxsExceedingBatchConstraints(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{RejectTxsExceedingBatchConstraints: true, DropRecordsSize: 10})
+	mockTestSenderAccount(t, stMock, 0)
+
+	// The tx that fits in a batch is added to the worker
+	fittingTx := newTestPoolTx(t, 0, 21000)
+	fittingTx.ZKCounters = state.ZKCounters{GasUsed: 21000, UsedSteps: bc.MaxSteps}
+	txPoolMock.On("UpdateTxWIPStatus", ctx, fittingTx.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, fittingTx))
+	assert.Equal(t, 1, s.worker.CountTxs())
+
+	// The tx that can't fit in any batch is dropped
+	unfittableTx := newTestPoolTx(t, 1, 21000)
+	unfittableTx.ZKCounters = state.ZKCounters{GasUsed: 21000, UsedSteps: bc.MaxSteps + 1, UsedKeccakHashes: bc.MaxKeccakHashes + 1}
+	failedReason := fmt.Sprintf("%s, keccak hashes: %d (max: %d), steps: %d (max: %d)", ErrTxExceedsBatchConstraints.Error(),
+		bc.MaxKeccakHashes+1, bc.MaxKeccakHashes, bc.MaxSteps+1, bc.MaxSteps)
+	txPoolMock.On("UpdateTxStatus", ctx, unfittableTx.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, unfittableTx))
+	assert.Equal(t, 1, s.worker.CountTxs())
+	txPoolMock.AssertNotCalled(t, "UpdateTxWIPStatus", ctx, unfittableTx.Hash(), true)
+
+	record, found := s.WhyDropped(unfittableTx.Hash())
+	require.True(t, found)
+	assert.Equal(t, failedReason, record.Reason)
+
+	// Without the check the tx is dropped by the worker without the counters exceeded
+	s.cfg.RejectTxsExceedingBatchConstraints = false
+	failedReason = pool.ErrOutOfCounters.Error()
+	txPoolMock.On("UpdateTxStatus", ctx, unfittableTx.Hash(), pool.TxStatusFailed, false, &failedReason).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, unfittableTx))
+	assert.Equal(t, 1, s.worker.CountTxs())
+}
+
+func TestSequencer_addTxToWorker_AdmissionBatchFullnessThreshold(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{AdmissionBatchFullnessThreshold: 90, AdmissionHighPriorityGasPrice: 100})
+	mockTestSenderAccount(t, stMock, 0)
+	// The wip batch is near full, 95% of its max txs are used
+	fake := &fakeFinalizer{usage: BatchUsage{BatchNumber: 1, CountOfTxs: bc.MaxTxsPerBatch * 95 / 100}}
+	s.finalizer = fake
+
+	// The low priority tx is deferred, it's left pending in the pool
+	lowPriorityTx := newTestPoolTx(t, 0, 21000)
+	added, err := s.tryAddTxToWorker(ctx, lowPriorityTx)
+	require.ErrorIs(t, err, ErrTxDeferred)
+	assert.False(t, added)
+	assert.Equal(t, 0, s.worker.CountTxs())
+	txPoolMock.AssertNotCalled(t, "UpdateTxStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// The high priority tx is admitted
+	privateKey, err := crypto.HexToECDSA(testSenderPvtKey)
+	require.NoError(t, err)
+	signedTx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(100), nil), types.NewEIP155Signer(testChainID), privateKey)
+	require.NoError(t, err)
+	highPriorityTx := *pool.NewTransaction(*signedTx, "", false)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, highPriorityTx.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, highPriorityTx))
+	assert.Equal(t, 1, s.worker.CountTxs())
+
+	// Once the wip batch is below the threshold the low priority txs are admitted
+	fake.usage = BatchUsage{BatchNumber: 2, CountOfTxs: 1}
+	lowPriorityTx = newTestPoolTx(t, 1, 21000)
+	txPoolMock.On("UpdateTxWIPStatus", ctx, lowPriorityTx.Hash(), true).Return(nil).Once()
+	require.NoError(t, s.addTxToWorker(ctx, lowPriorityTx))
+	assert.Equal(t, 2, s.worker.CountTxs())
+}
+
+func TestSequencer_waitStreamServerReady(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestSequencer(t, Config{StreamServer: StreamServerCfg{StartupDelay: cfgTypes.NewDuration(50 * time.Millisecond), ReadyTimeout: cfgTypes.NewDuration(5 * time.Second)}})
+
+	// The fake server is not ready in the first 2 checks
+	checks := 0
+	ready := func() bool {
+		checks++
+		return checks > 2
+	}
+
+	start := time.Now()
+	require.NoError(t, s.waitStreamServerReady(ctx, ready))
+	firstWrite := time.Since(start)
+	assert.Equal(t, 3, checks)
+	assert.GreaterOrEqual(t, firstWrite, 50*time.Millisecond+2*streamServerReadyPollInterval)
+
+	// The server that is never ready fails after ReadyTimeout
+	s.cfg.StreamServer = StreamServerCfg{ReadyTimeout: cfgTypes.NewDuration(2 * streamServerReadyPollInterval)}
+	err := s.waitStreamServerReady(ctx, func() bool { return false })
+	require.ErrorIs(t, err, ErrStreamServerNotReady)
+
+	// The readiness is not checked without ReadyTimeout
+	s.cfg.StreamServer = StreamServerCfg{}
+	require.NoError(t, s.waitStreamServerReady(ctx, func() bool { return false }))
+}
+
+func TestIsStreamServerListening(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	assert.True(t, isStreamServerListening(port))
+
+	require.NoError(t, ln.Close())
+	assert.False(t, isStreamServerListening(port))
+}
+
+func TestSequencer_SetLoopEnabled_UnknownLoop(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+
+	require.ErrorIs(t, s.SetLoopEnabled("sendDataToStreamer", false), ErrUnknownLoop)
+	for _, name := range loopNames {
+		assert.True(t, s.isLoopEnabled(name))
+	}
+}
+
+func TestSequencer_SetLoopEnabled(t *testing.T) {
+	zkmetrics.Init()
+	metrics.Register()
+	interval := cfgTypes.NewDuration(10 * time.Millisecond)
+
+	// calledOnce returns a mock Run function counting the calls, and a function returning true once it has been called
+	calledOnce := func() (func(mock.Arguments), func() bool) {
+		var calls atomic.Int32
+		return func(mock.Arguments) { calls.Add(1) }, func() bool { return calls.Load() > 0 }
+	}
+	// channelCapacityUpdated returns a function returning true once the data stream channel capacity gauge is updated
+	channelCapacityUpdated := func(t *testing.T, s *Sequencer) func() bool {
+		metrics.DataStreamChannelCapacity(0)
+		s.dataToStream = make(chan state.DSL2FullBlock, 7)
+		gauge, ok := zkmetrics.Gauge(metrics.DataStreamChannelCapacityName)
+		require.True(t, ok)
+		return func() bool { return testutil.ToFloat64(gauge) == 7 }
+	}
+
+	testCases := []struct {
+		name  string
+		cfg   Config
+		setup func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool
+		loop  func(s *Sequencer) func(ctx context.Context)
+	}{
+		{
+			name: LoopLoadFromPool,
+			cfg:  Config{LoadPoolTxsCheckInterval: interval},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				run, worked := calledOnce()
+				txPoolMock.On("GetOldestNonWIPPendingTxTime", mock.Anything).Return(time.Time{}, pool.ErrNotFound).Run(run)
+				txPoolMock.On("GetNonWIPPendingTxs", mock.Anything).Return(nil, pool.ErrNotFound)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.loadFromPool },
+		},
+		{
+			name: LoopDeleteOldPoolTxs,
+			cfg:  Config{DeletePoolTxsCheckInterval: interval},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				run, worked := calledOnce()
+				stMock.On("GetTxsOlderThanNL1Blocks", mock.Anything, uint64(0), nil).Return(nil, errors.New("state error")).Run(run)
+				txPoolMock.On("DeleteFailedTransactionsOlderThan", mock.Anything, mock.Anything).Return(nil)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.deleteOldPoolTxs },
+		},
+		{
+			name: LoopExpireOldWorkerTxs,
+			cfg:  Config{TxLifetimeCheckInterval: interval, TxLifetimeMax: cfgTypes.NewDuration(time.Nanosecond)},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				mockTestSenderAccount(t, stMock, 0)
+				txPoolMock.On("UpdateTxWIPStatus", mock.Anything, mock.Anything, true).Return(nil).Once()
+				require.NoError(t, s.addTxToWorker(context.Background(), newTestPoolTx(t, 0, 21000)))
+
+				run, worked := calledOnce()
+				txPoolMock.On("UpdateTxStatus", mock.Anything, mock.Anything, pool.TxStatusFailed, false, mock.Anything).Return(nil).Run(run)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.expireOldWorkerTxs },
+		},
+		{
+			name: LoopCheckStateInconsistency,
+			cfg:  Config{StateConsistencyCheckInterval: interval},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				run, worked := calledOnce()
+				stMock.On("CountReorgs", mock.Anything, nil).Return(uint64(0), nil).Run(run)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.checkStateInconsistency },
+		},
+		{
+			name: LoopCheckBatchesAheadOfL1,
+			cfg:  Config{MaxBatchesAheadOfL1: 10, BatchesAheadOfL1CheckInterval: interval},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				run, worked := calledOnce()
+				stMock.On("GetLastVirtualBatchNum", mock.Anything, nil).Return(uint64(0), errors.New("state error")).Run(run)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.checkBatchesAheadOfL1Loop },
+		},
+		{
+			name: LoopTrackL2BlockFinality,
+			cfg:  Config{StreamServer: StreamServerCfg{FinalityCheckInterval: interval}},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				run, worked := calledOnce()
+				stMock.On("GetLastVirtualizedL2BlockNumber", mock.Anything, nil).Return(uint64(0), errors.New("state error")).Run(run)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.trackL2BlockFinalityLoop },
+		},
+		{
+			name: LoopMonitorDataToStream,
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				return channelCapacityUpdated(t, s)
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.monitorDataToStream },
+		},
+		{
+			name: LoopLogMetrics,
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				return channelCapacityUpdated(t, s)
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) {
+				return func(ctx context.Context) {
+					ticker := time.NewTicker(10 * time.Millisecond)
+					defer ticker.Stop()
+					s.logMetricsLoop(ctx, ticker.C)
+				}
+			},
+		},
+		{
+			name: LoopUpdateDataStreamerFile,
+			cfg:  Config{Mode: ModeStandby, StandbyStreamUpdateInterval: interval},
+			setup: func(t *testing.T, s *Sequencer, txPoolMock *PoolMock, stMock *StateMock) func() bool {
+				s.streamServer = newTestStreamServer(t)
+				run, worked := calledOnce()
+				stMock.On("GetDSGenesisBlock", mock.Anything, nil).Return(nil, errors.New("state error")).Run(run)
+				return worked
+			},
+			loop: func(s *Sequencer) func(ctx context.Context) { return s.updateDataStreamerFileLoop },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, txPoolMock, stMock := newTestSequencer(t, tc.cfg)
+			worked := tc.setup(t, s, txPoolMock, stMock)
+
+			// The disabled loop keeps running but skips its work
+			require.NoError(t, s.SetLoopEnabled(tc.name, false))
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				tc.loop(s)(ctx)
+				close(done)
+			}()
+			time.Sleep(200 * time.Millisecond)
+			assert.False(t, worked())
+
+			// Once enabled the loop works again
+			require.NoError(t, s.SetLoopEnabled(tc.name, true))
+			require.Eventually(t, worked, 5*time.Second, 10*time.Millisecond)
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("loop not stopped")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package sequencer
+
+import (
+	"sync"
+)
+
+// SessionCounters are the values accumulated by the sequencer since the last call to SnapshotAndResetCounters
+type SessionCounters struct {
+	// StreamedL2Blocks is the number of L2 blocks committed to the data stream
+	StreamedL2Blocks uint64
+	// DroppedTxs is the number of txs dropped by the sequencer, except the expired ones
+	DroppedTxs uint64
+	// ExpiredTxs is the number of txs expired in the worker
+	ExpiredTxs uint64
+}
+
+// sessionCounters accumulates the session counters, they are read and reset atomically
+type sessionCounters struct {
+	counters SessionCounters
+	mutex    sync.Mutex
+}
+
+// addStreamedL2Blocks adds the number of L2 blocks committed to the data stream. It does nothing if c is nil
+func (c *sessionCounters) addStreamedL2Blocks(count uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counters.StreamedL2Blocks += count
+}
+
+// addDroppedTx counts a tx dropped in the given phase. It does nothing if c is nil
+func (c *sessionCounters) addDroppedTx(phase DropPhase) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if phase == DropPhaseExpire {
+		c.counters.ExpiredTxs++
+	} else {
+		c.counters.DroppedTxs++
+	}
+}
+
+// snapshotAndReset returns the accumulated counters and resets them
+func (c *sessionCounters) snapshotAndReset() SessionCounters {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counters := c.counters
+	c.counters = SessionCounters{}
+	return counters
+}
+
+// SnapshotAndResetCounters returns the counters accumulated since the last call and resets them, so they can be reported
+// periodically without counting the same values twice
+func (s *Sequencer) SnapshotAndResetCounters() SessionCounters {
+	return s.sessionCounters.snapshotAndReset()
+}
@@ -0,0 +1,33 @@
+package sequencer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_SnapshotAndResetCounters(t *testing.T) {
+	s, _, stMock := newTestSequencer(t, Config{})
+	streamServer := newTestStreamServer(t)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil)
+	p := newStreamPipeline(StreamServerCfg{}, streamServer, stMock, nil, nil)
+	p.sessionCounters = s.sessionCounters
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 1, 1), newTestL2FullBlock(1, 2, 1)}))
+	s.recordDrop(common.HexToHash("0x1"), DropPhaseAdd, "worker full")
+	s.recordDrop(common.HexToHash("0x2"), DropPhaseReconcile, "stale")
+	s.recordDrop(common.HexToHash("0x3"), DropPhaseExpire, "expired")
+
+	assert.Equal(t, SessionCounters{StreamedL2Blocks: 2, DroppedTxs: 2, ExpiredTxs: 1}, s.SnapshotAndResetCounters())
+	assert.Equal(t, SessionCounters{}, s.SnapshotAndResetCounters())
+
+	// Only the values accumulated after the last snapshot are returned
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{newTestL2FullBlock(1, 3, 1)}))
+	s.recordDrop(common.HexToHash("0x4"), DropPhaseExpire, "expired")
+	assert.Equal(t, SessionCounters{StreamedL2Blocks: 1, ExpiredTxs: 1}, s.SnapshotAndResetCounters())
+}
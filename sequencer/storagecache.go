@@ -0,0 +1,55 @@
+package sequencer
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+)
+
+// storageCacheKey is the key of a storage value read at a state root
+type storageCacheKey struct {
+	address  common.Address
+	position common.Hash
+	root     common.Hash
+}
+
+// storageCache is a read-through LRU cache of the storage values read from the state. The values are keyed by
+// the state root they were read at, so a new state root never gets a value cached for another one.
+// It's not safe for concurrent use
+type storageCache struct {
+	stateIntf stateInterface
+	cache     *lru.BasicLRU[storageCacheKey, *big.Int]
+}
+
+// newStorageCache creates a new storageCache that keeps up to size values. If size is 0 the values are not cached
+func newStorageCache(stateIntf stateInterface, size uint64) *storageCache {
+	c := &storageCache{stateIntf: stateIntf}
+	if size > 0 {
+		cache := lru.NewBasicLRU[storageCacheKey, *big.Int](int(size))
+		c.cache = &cache
+	}
+	return c
+}
+
+// getStorageAt returns the storage value of the address at the position and state root, reading it from the state
+// if it's not cached. The errors are not cached
+func (c *storageCache) getStorageAt(ctx context.Context, address common.Address, position *big.Int, root common.Hash) (*big.Int, error) {
+	if c.cache == nil {
+		return c.stateIntf.GetStorageAt(ctx, address, position, root)
+	}
+
+	key := storageCacheKey{address: address, position: common.BigToHash(position), root: root}
+	if value, found := c.cache.Get(key); found {
+		return new(big.Int).Set(value), nil
+	}
+
+	value, err := c.stateIntf.GetStorageAt(ctx, address, position, root)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(key, new(big.Int).Set(value))
+
+	return value, nil
+}
@@ -0,0 +1,90 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorageState is a stateInterface that counts the GetStorageAt calls
+type countingStorageState struct {
+	stateInterface
+	calls int
+}
+
+func (c *countingStorageState) GetStorageAt(ctx context.Context, address common.Address, position *big.Int, root common.Hash) (*big.Int, error) {
+	c.calls++
+	return new(big.Int).Add(position, root.Big()), nil
+}
+
+func TestStorageCache_getStorageAt(t *testing.T) {
+	ctx := context.Background()
+	stMock := NewStateMock(t)
+	c := newStorageCache(stMock, 2)
+
+	address := common.HexToAddress(state.SystemSC)
+	position := big.NewInt(1)
+	root1 := common.HexToHash("0x01")
+	root2 := common.HexToHash("0x02")
+
+	// The repeated reads of the same position at the same root are cache hits
+	stMock.On("GetStorageAt", ctx, address, position, root1).Return(big.NewInt(10), nil).Once()
+	for i := 0; i < 3; i++ {
+		value, err := c.getStorageAt(ctx, address, position, root1)
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(10), value)
+	}
+
+	// The same position at a new root is read again from the state
+	stMock.On("GetStorageAt", ctx, address, position, root2).Return(big.NewInt(20), nil).Once()
+	value, err := c.getStorageAt(ctx, address, position, root2)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(20), value)
+
+	// The errors are not cached
+	position2 := big.NewInt(2)
+	stMock.On("GetStorageAt", ctx, address, position2, root2).Return(nil, errors.New("storage error")).Once()
+	_, err = c.getStorageAt(ctx, address, position2, root2)
+	require.Error(t, err)
+	stMock.On("GetStorageAt", ctx, address, position2, root2).Return(big.NewInt(30), nil).Once()
+	value, err = c.getStorageAt(ctx, address, position2, root2)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), value)
+
+	// The least recently used value (root1) has been evicted
+	stMock.On("GetStorageAt", ctx, address, position, root1).Return(big.NewInt(10), nil).Once()
+	_, err = c.getStorageAt(ctx, address, position, root1)
+	require.NoError(t, err)
+}
+
+func BenchmarkStorageCache_intermediateStateRoots(b *testing.B) {
+	const txsPerL2Block = 10
+
+	for _, bc := range []struct {
+		name string
+		size uint64
+	}{
+		{name: "nocache", size: 0},
+		{name: "cache", size: 100},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			st := &countingStorageState{}
+			p := newStreamPipeline(StreamServerCfg{StorageCacheSize: bc.size}, nil, st, nil, nil)
+
+			for i := 0; i < b.N; i++ {
+				l2Block := newTestL2FullBlock(1, uint64(i), txsPerL2Block)
+				for range l2Block.Txs {
+					p.getIntermediateStateRoot(l2Block.DSL2Block)
+				}
+			}
+
+			b.ReportMetric(float64(st.calls)/float64(b.N), "GetStorageAt/l2block")
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package sequencer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamPipeline_sendL2Blocks_Checkpoints(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, CheckpointEveryNBlocks: 2}, streamServer, nil, nil, nil)
+
+	// The second checkpoint accumulates L2 blocks sent in different atomic ops
+	l2Blocks := []state.DSL2FullBlock{}
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 5; l2BlockNumber++ {
+		l2Blocks = append(l2Blocks, newTestL2FullBlock(1, l2BlockNumber, 1))
+	}
+	require.NoError(t, p.sendL2Blocks(l2Blocks[:3]))
+	require.NoError(t, p.sendL2Blocks(l2Blocks[3:]))
+
+	checkpoints := []state.DSL2BlockCheckpoint{}
+	entries := streamEntries(t, streamServer)
+	for i, entry := range entries {
+		if entry.Type == state.EntryTypeL2BlockCheckpoint {
+			// The checkpoint is the entry before the end of the last L2 block it includes
+			require.Equal(t, state.EntryTypeL2BlockEnd, entries[i+1].Type)
+			checkpoints = append(checkpoints, state.DSL2BlockCheckpoint{}.Decode(entry.Data))
+		}
+	}
+
+	// The fifth L2 block is accumulated for the next checkpoint
+	require.Len(t, checkpoints, 2)
+	for i, checkpoint := range checkpoints {
+		assert.Equal(t, state.DSL2BlockCheckpointVersion, checkpoint.Version)
+		assert.Equal(t, uint64(2*i+1), checkpoint.FromL2BlockNumber)
+		assert.Equal(t, uint64(2*i+2), checkpoint.ToL2BlockNumber)
+
+		hash := common.Hash{}
+		for _, l2Block := range l2Blocks[checkpoint.FromL2BlockNumber-1 : checkpoint.ToL2BlockNumber] {
+			hash = state.AccumulateL2BlockHash(hash, l2Block.BlockHash)
+		}
+		assert.Equal(t, hash, checkpoint.Hash)
+	}
+	assert.Equal(t, state.DSL2BlockCheckpointAccumulator{FromL2BlockNumber: 5, L2Blocks: 1, Hash: state.AccumulateL2BlockHash(common.Hash{}, l2Blocks[4].BlockHash)}, p.checkpoint)
+}
+
+func TestStreamPipeline_Checkpoints_Restart(t *testing.T) {
+	ctx := context.Background()
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, CheckpointEveryNBlocks: 3}
+	s, _, stMock := newTestSequencer(t, Config{StreamServer: cfg})
+	streamServer := newTestStreamServer(t)
+	s.streamServer = streamServer
+
+	// The sequencer is restarted every 2 L2 blocks, so the checkpoints accumulate L2 blocks streamed before the restarts
+	l2Blocks := []state.DSL2FullBlock{}
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 9; l2BlockNumber++ {
+		l2Blocks = append(l2Blocks, newTestL2FullBlock(l2BlockNumber/7+1, l2BlockNumber, 0))
+	}
+	for i := 0; i < 6; i += 2 {
+		p := newStreamPipeline(cfg, streamServer, nil, nil, nil)
+		p.restoreLastStreamed(streamServer)
+		require.NoError(t, p.sendL2Blocks(l2Blocks[i:i+2]))
+	}
+
+	// The L2 blocks of the next batch are generated from the state, continuing the last checkpoint too
+	dsL2Blocks := []*state.DSL2Block{}
+	for i := range l2Blocks[6:] {
+		dsL2Blocks = append(dsL2Blocks, &l2Blocks[6+i].DSL2Block)
+	}
+	stMock.On("GetDSBatches", ctx, uint64(2), uint64(10002), true, nil).Return([]*state.DSBatch{{Batch: state.Batch{BatchNumber: 2}}}, nil).Once()
+	stMock.On("GetDSL2Blocks", ctx, uint64(2), uint64(2), nil).Return(dsL2Blocks, nil).Once()
+	stMock.On("GetDSL2Transactions", ctx, uint64(7), uint64(9), nil).Return([]*state.DSL2Transaction{}, nil).Once()
+	stMock.On("GetDSBatches", ctx, uint64(10002), uint64(20002), true, nil).Return([]*state.DSBatch{}, nil).Once()
+	_, err := s.updateDataStreamerFile(ctx)
+	require.NoError(t, err)
+
+	checkpoints := []state.DSL2BlockCheckpoint{}
+	for _, entry := range streamEntries(t, streamServer) {
+		if entry.Type == state.EntryTypeL2BlockCheckpoint {
+			checkpoints = append(checkpoints, state.DSL2BlockCheckpoint{}.Decode(entry.Data))
+		}
+	}
+
+	// Consecutive checkpoints are contiguous
+	require.Len(t, checkpoints, 3)
+	for i, checkpoint := range checkpoints {
+		assert.Equal(t, uint64(3*i+1), checkpoint.FromL2BlockNumber)
+		assert.Equal(t, uint64(3*i+3), checkpoint.ToL2BlockNumber)
+
+		hash := common.Hash{}
+		for _, l2Block := range l2Blocks[checkpoint.FromL2BlockNumber-1 : checkpoint.ToL2BlockNumber] {
+			hash = state.AccumulateL2BlockHash(hash, l2Block.BlockHash)
+		}
+		assert.Equal(t, hash, checkpoint.Hash)
+	}
+}
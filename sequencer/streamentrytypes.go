@@ -0,0 +1,153 @@
+package sequencer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// EntryTypeInfo describes a type of entry emitted to the data stream
+type EntryTypeInfo struct {
+	Type datastreamer.EntryType
+	Name string
+	// Version is the version of the encoding of the entry payload, 0 if the payload is not versioned
+	Version uint8
+	// Encoding is the encoding of the entry payload (StreamEncodingBinary or StreamEncodingProtobuf)
+	Encoding string
+}
+
+// StreamEntryTypes returns the types of entries emitted to the data stream with the current config, sorted by entry type.
+// If the data stream is disabled it returns an empty list
+func (s *Sequencer) StreamEntryTypes() []EntryTypeInfo {
+	if !s.cfg.StreamServer.Enabled {
+		return []EntryTypeInfo{}
+	}
+	return streamEntryTypes(s.cfg.StreamServer)
+}
+
+// streamEntryTypes returns the types of entries emitted to the data stream with the given config
+func streamEntryTypes(cfg StreamServerCfg) []EntryTypeInfo {
+	encoding := StreamEncodingBinary
+	if cfg.Encoding == StreamEncodingProtobuf {
+		encoding = StreamEncodingProtobuf
+	}
+	encoder := newStreamEncoder(encoding)
+
+	entryTypes := []EntryTypeInfo{
+		{Type: state.EntryTypeBookMark, Name: "bookmark", Encoding: StreamEncodingBinary},
+	}
+
+	if len(cfg.BlockStartExcludedFields) > 0 {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockStartMasked, Name: "l2_block_start_masked", Version: state.DSL2BlockStartMaskedVersion, Encoding: StreamEncodingBinary})
+	} else if cfg.IncludeL1InfoRoot {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockStartWithL1InfoRoot, Name: "l2_block_start_with_l1_info_root", Version: state.DSL2BlockStartL1InfoRootVersion, Encoding: StreamEncodingBinary})
+	} else {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: encoder.L2BlockStartEntryType(), Name: "l2_block_start", Encoding: encoding})
+	}
+
+	if cfg.IncludeDecodedTxMetadata {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2TxWithMetadata, Name: "l2_tx_with_metadata", Version: state.DSL2TransactionMetadataVersion, Encoding: StreamEncodingBinary})
+	} else if cfg.IncludeSender {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2TxWithSender, Name: "l2_tx_with_sender", Version: state.DSL2TransactionSenderVersion, Encoding: StreamEncodingBinary})
+	} else {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: encoder.L2TransactionEntryType(), Name: "l2_tx", Encoding: encoding})
+	}
+
+	if cfg.IncludeStorageDiffs {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockStorageDiff, Name: "l2_block_storage_diff", Version: state.DSL2BlockStorageDiffVersion, Encoding: StreamEncodingBinary})
+	}
+
+	if cfg.IncludeBlockBlooms {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockBloom, Name: "l2_block_bloom", Version: state.DSL2BlockBloomVersion, Encoding: StreamEncodingBinary})
+	}
+
+	if cfg.CheckpointEveryNBlocks > 0 {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockCheckpoint, Name: "l2_block_checkpoint", Version: state.DSL2BlockCheckpointVersion, Encoding: StreamEncodingBinary})
+	}
+
+	entryTypes = append(entryTypes,
+		EntryTypeInfo{Type: encoder.L2BlockEndEntryType(), Name: "l2_block_end", Encoding: encoding},
+		// The GER updates of the batches without L2 blocks are emitted when the data stream file is updated with the state
+		EntryTypeInfo{Type: state.EntryTypeUpdateGER, Name: "update_ger", Encoding: StreamEncodingBinary},
+	)
+
+	if cfg.EmitBatchBoundaries {
+		entryTypes = append(entryTypes,
+			EntryTypeInfo{Type: state.EntryTypeBatchStart, Name: "batch_start", Encoding: StreamEncodingBinary},
+			EntryTypeInfo{Type: state.EntryTypeBatchEnd, Name: "batch_end", Encoding: StreamEncodingBinary},
+		)
+	}
+
+	if cfg.ExportSchema {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeStreamSchema, Name: "stream_schema", Version: state.DSStreamSchemaVersion, Encoding: StreamEncodingBinary})
+	}
+
+	if cfg.FinalityCheckInterval.Duration > 0 {
+		entryTypes = append(entryTypes, EntryTypeInfo{Type: state.EntryTypeL2BlockFinality, Name: "l2_block_finality", Version: state.DSL2BlockFinalityVersion, Encoding: StreamEncodingBinary})
+	}
+
+	sort.Slice(entryTypes, func(i, j int) bool {
+		return entryTypes[i].Type < entryTypes[j].Type
+	})
+
+	return entryTypes
+}
+
+// checkStreamSchema verifies that the schema header of the data stream file declares the schema version of the running build.
+// If the data stream file is empty and ExportSchema is enabled the header is added. The files without header are not verified
+func checkStreamSchema(streamServer *datastreamer.StreamServer, cfg StreamServerCfg) error {
+	if streamServer.GetHeader().TotalEntries == 0 {
+		if !cfg.ExportSchema {
+			return nil
+		}
+		return addStreamSchema(streamServer, cfg)
+	}
+
+	firstEntry, err := streamServer.GetEntry(0)
+	if err != nil {
+		return fmt.Errorf("failed to get the first entry of the data stream, error: %w", err)
+	}
+	if firstEntry.Type != state.EntryTypeStreamSchema {
+		if cfg.ExportSchema {
+			log.Warnf("data stream file has no schema header, its schema version can't be verified")
+		}
+		return nil
+	}
+
+	schema := state.DSStreamSchema{}.Decode(firstEntry.Data)
+	if schema.Version != state.DSStreamSchemaVersion {
+		return fmt.Errorf("%w, data stream file schema version: %d, build schema version: %d", ErrIncompatibleStreamSchema, schema.Version, state.DSStreamSchemaVersion)
+	}
+	log.Infof("data stream file schema version %d verified, entry types: %v", schema.Version, schema.EntryTypes)
+	return nil
+}
+
+// addStreamSchema adds the schema header with the entry types emitted with the given config to the empty data stream file
+func addStreamSchema(streamServer *datastreamer.StreamServer, cfg StreamServerCfg) error {
+	schema := state.DSStreamSchema{Version: state.DSStreamSchemaVersion}
+	for _, info := range streamEntryTypes(cfg) {
+		schema.EntryTypes = append(schema.EntryTypes, info.Type)
+	}
+
+	err := streamServer.StartAtomicOp()
+	if err != nil {
+		return fmt.Errorf("failed to start atomic op for the data stream schema header, error: %w", err)
+	}
+	_, err = streamServer.AddStreamEntry(state.EntryTypeStreamSchema, schema.Encode())
+	if err != nil {
+		if rollbackErr := streamServer.RollbackAtomicOp(); rollbackErr != nil {
+			log.Errorf("failed to rollback atomic op for the data stream schema header, error: %v", rollbackErr)
+		}
+		return fmt.Errorf("failed to add the data stream schema header, error: %w", err)
+	}
+	err = streamServer.CommitAtomicOp()
+	if err != nil {
+		return fmt.Errorf("failed to commit atomic op for the data stream schema header, error: %w", err)
+	}
+
+	log.Infof("data stream schema header added, schema version: %d", schema.Version)
+	return nil
+}
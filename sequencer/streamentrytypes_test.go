@@ -0,0 +1,117 @@
+package sequencer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entryTypes returns the entry types of the list
+func entryTypes(infos []EntryTypeInfo) []datastreamer.EntryType {
+	types := make([]datastreamer.EntryType, 0, len(infos))
+	for _, info := range infos {
+		types = append(types, info.Type)
+	}
+	return types
+}
+
+func TestSequencer_StreamEntryTypes(t *testing.T) {
+	s, _, _ := newTestSequencer(t, Config{})
+
+	// The data stream is disabled
+	assert.Empty(t, s.StreamEntryTypes())
+
+	s.cfg.StreamServer.Enabled = true
+	infos := s.StreamEntryTypes()
+	assert.Equal(t, []datastreamer.EntryType{state.EntryTypeL2BlockStart, state.EntryTypeL2Tx, state.EntryTypeL2BlockEnd, state.EntryTypeUpdateGER, state.EntryTypeBookMark}, entryTypes(infos))
+	for _, info := range infos {
+		assert.Equal(t, StreamEncodingBinary, info.Encoding)
+		assert.Equal(t, uint8(0), info.Version)
+	}
+
+	s.cfg.StreamServer.IncludeDecodedTxMetadata = true
+	s.cfg.StreamServer.EmitBatchBoundaries = true
+	infos = s.StreamEntryTypes()
+	assert.Equal(t, []datastreamer.EntryType{state.EntryTypeL2BlockStart, state.EntryTypeL2BlockEnd, state.EntryTypeUpdateGER, state.EntryTypeL2TxWithMetadata, state.EntryTypeBatchStart, state.EntryTypeBatchEnd, state.EntryTypeBookMark}, entryTypes(infos))
+	assert.Equal(t, state.DSL2TransactionMetadataVersion, infos[3].Version)
+
+	// The sender is already included in the decoded tx metadata
+	s.cfg.StreamServer.IncludeSender = true
+	assert.Equal(t, infos, s.StreamEntryTypes())
+	s.cfg.StreamServer.IncludeDecodedTxMetadata = false
+	infos = s.StreamEntryTypes()
+	assert.Equal(t, state.EntryTypeL2TxWithSender, infos[5].Type)
+	assert.Equal(t, state.DSL2TransactionSenderVersion, infos[5].Version)
+	s.cfg.StreamServer.IncludeSender = false
+	s.cfg.StreamServer.IncludeDecodedTxMetadata = true
+
+	// The protobuf encoding only applies to the L2 block start, L2 tx and L2 block end entries, with their own entry types
+	s.cfg.StreamServer.IncludeDecodedTxMetadata = false
+	s.cfg.StreamServer.Encoding = StreamEncodingProtobuf
+	assert.Equal(t, []datastreamer.EntryType{state.EntryTypeUpdateGER, state.EntryTypeBatchStart, state.EntryTypeBatchEnd, state.EntryTypeL2BlockStartProto, state.EntryTypeL2TxProto, state.EntryTypeL2BlockEndProto, state.EntryTypeBookMark}, entryTypes(s.StreamEntryTypes()))
+	for _, info := range s.StreamEntryTypes() {
+		switch info.Type {
+		case state.EntryTypeL2BlockStartProto, state.EntryTypeL2TxProto, state.EntryTypeL2BlockEndProto:
+			assert.Equal(t, StreamEncodingProtobuf, info.Encoding)
+		default:
+			assert.Equal(t, StreamEncodingBinary, info.Encoding)
+		}
+	}
+	// The L2 block starts with excluded fields use the masked entry type with the binary encoding
+	s.cfg.StreamServer.BlockStartExcludedFields = []string{BlockStartFieldCoinbase}
+	infos = s.StreamEntryTypes()
+	assert.Equal(t, []datastreamer.EntryType{state.EntryTypeUpdateGER, state.EntryTypeBatchStart, state.EntryTypeBatchEnd, state.EntryTypeL2BlockStartMasked, state.EntryTypeL2TxProto, state.EntryTypeL2BlockEndProto, state.EntryTypeBookMark}, entryTypes(infos))
+	assert.Equal(t, state.DSL2BlockStartMaskedVersion, infos[3].Version)
+	assert.Equal(t, StreamEncodingBinary, infos[3].Encoding)
+
+	// The L2 block finality updates are only streamed when their finality is checked
+	s.cfg.StreamServer.FinalityCheckInterval = cfgTypes.NewDuration(time.Second)
+	infos = s.StreamEntryTypes()
+	assert.Equal(t, state.EntryTypeL2BlockFinality, infos[4].Type)
+	assert.Equal(t, state.DSL2BlockFinalityVersion, infos[4].Version)
+}
+
+func TestCheckStreamSchema(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	cfg := StreamServerCfg{ExportSchema: true}
+
+	// The header is added to the empty data stream file and verified once it exists
+	require.NoError(t, checkStreamSchema(streamServer, cfg))
+	require.NoError(t, checkStreamSchema(streamServer, cfg))
+	require.Equal(t, uint64(1), streamServer.GetHeader().TotalEntries)
+	entry, err := streamServer.GetEntry(0)
+	require.NoError(t, err)
+	require.Equal(t, state.EntryTypeStreamSchema, entry.Type)
+	schema := state.DSStreamSchema{}.Decode(entry.Data)
+	assert.Equal(t, state.DSStreamSchemaVersion, schema.Version)
+	assert.Equal(t, entryTypes(streamEntryTypes(cfg)), schema.EntryTypes)
+
+	// The last streamed batch of a data stream file with only the header is 0
+	lastBatchNumber, err := getLastStreamedBatchNumber(streamServer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), lastBatchNumber)
+}
+
+func TestCheckStreamSchema_IncompatibleVersion(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	schema := state.DSStreamSchema{Version: state.DSStreamSchemaVersion + 1, EntryTypes: []datastreamer.EntryType{state.EntryTypeL2BlockStart}}
+	require.NoError(t, streamServer.StartAtomicOp())
+	_, err := streamServer.AddStreamEntry(state.EntryTypeStreamSchema, schema.Encode())
+	require.NoError(t, err)
+	require.NoError(t, streamServer.CommitAtomicOp())
+
+	// The header is verified even if ExportSchema is disabled
+	assert.ErrorIs(t, checkStreamSchema(streamServer, StreamServerCfg{ExportSchema: true}), ErrIncompatibleStreamSchema)
+	assert.ErrorIs(t, checkStreamSchema(streamServer, StreamServerCfg{}), ErrIncompatibleStreamSchema)
+}
+
+func TestCheckStreamSchema_ExportSchemaDisabled(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	require.NoError(t, checkStreamSchema(streamServer, StreamServerCfg{}))
+	assert.Equal(t, uint64(0), streamServer.GetHeader().TotalEntries)
+}
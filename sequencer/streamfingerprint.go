@@ -0,0 +1,43 @@
+package sequencer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// StreamHeadFingerprint returns the keccak hash of the last n entries of the data stream (type, number and data of each
+// entry), so the data streams of two nodes can be compared without transferring them. If the data stream has less than n
+// entries all of them are hashed. The entries are read from the data stream file, so the head can change while they are read
+// if the streaming is not paused
+func (s *Sequencer) StreamHeadFingerprint(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, ErrInvalidStreamHeadEntries
+	}
+	if s.streamServer == nil {
+		return nil, ErrStreamingDisabled
+	}
+
+	totalEntries := s.streamServer.GetHeader().TotalEntries
+	firstEntry := uint64(0)
+	if totalEntries > uint64(n) {
+		firstEntry = totalEntries - uint64(n)
+	}
+
+	hash := sha3.NewLegacyKeccak256()
+	for entryNumber := firstEntry; entryNumber < totalEntries; entryNumber++ {
+		entry, err := s.streamServer.GetEntry(entryNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get entry %d of the data stream, error: %w", entryNumber, err)
+		}
+
+		var header [16]byte
+		binary.BigEndian.PutUint32(header[0:4], uint32(entry.Type))
+		binary.BigEndian.PutUint64(header[4:12], entry.Number)
+		binary.BigEndian.PutUint32(header[12:16], uint32(len(entry.Data)))
+		hash.Write(header[:])  //nolint:errcheck,gosec
+		hash.Write(entry.Data) //nolint:errcheck,gosec
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,60 @@
+package sequencer
+
+import (
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_StreamHeadFingerprint(t *testing.T) {
+	// newTestStream returns a sequencer whose data stream has the given L2 blocks
+	newTestStream := func(l2Blocks ...state.DSL2FullBlock) *Sequencer {
+		s, _, _ := newTestSequencer(t, Config{})
+		s.streamServer = newTestStreamServer(t)
+		p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, s.streamServer, nil, nil, nil)
+		require.NoError(t, p.sendL2Blocks(l2Blocks))
+		return s
+	}
+
+	// divergentL2Block returns the test L2 block with a different block hash, so its block end entry differs
+	divergentL2Block := func(l2BlockNumber uint64) state.DSL2FullBlock {
+		l2Block := newTestL2FullBlock(1, l2BlockNumber, 0)
+		l2Block.BlockHash = common.HexToHash("0xdead")
+		return l2Block
+	}
+	node1 := newTestStream(newTestL2FullBlock(1, 1, 0), newTestL2FullBlock(1, 2, 0), newTestL2FullBlock(1, 3, 0))
+	node2 := newTestStream(newTestL2FullBlock(1, 1, 0), newTestL2FullBlock(1, 2, 0), newTestL2FullBlock(1, 3, 0))
+	node3 := newTestStream(newTestL2FullBlock(1, 1, 0), newTestL2FullBlock(1, 2, 0), divergentL2Block(3))
+	node4 := newTestStream(divergentL2Block(1), newTestL2FullBlock(1, 2, 0), newTestL2FullBlock(1, 3, 0))
+
+	fingerprint := func(s *Sequencer, n int) []byte {
+		fingerprint, err := s.StreamHeadFingerprint(n)
+		require.NoError(t, err)
+		return fingerprint
+	}
+
+	// The identical heads have the same fingerprint
+	assert.Len(t, fingerprint(node1, 3), 32)
+	assert.Equal(t, fingerprint(node1, 3), fingerprint(node2, 3))
+	assert.Equal(t, fingerprint(node1, 1000), fingerprint(node2, 1000))
+
+	// The divergent heads have different fingerprints
+	assert.NotEqual(t, fingerprint(node1, 1), fingerprint(node3, 1))
+	assert.NotEqual(t, fingerprint(node1, 3), fingerprint(node3, 3))
+	assert.NotEqual(t, fingerprint(node1, 1000), fingerprint(node3, 1000))
+	assert.NotEqual(t, fingerprint(node1, 2), fingerprint(node1, 3))
+
+	// Only the head region is compared, the entries before it can diverge
+	assert.Equal(t, fingerprint(node1, 3), fingerprint(node4, 3))
+	assert.NotEqual(t, fingerprint(node1, 1000), fingerprint(node4, 1000))
+
+	_, err := node1.StreamHeadFingerprint(0)
+	assert.ErrorIs(t, err, ErrInvalidStreamHeadEntries)
+
+	s, _, _ := newTestSequencer(t, Config{})
+	_, err = s.StreamHeadFingerprint(10)
+	assert.ErrorIs(t, err, ErrStreamingDisabled)
+}
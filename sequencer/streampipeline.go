@@ -0,0 +1,1025 @@
+package sequencer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/sequencer/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// streamAtomicOpMaxRetries is the number of times an atomic op is retried after being rolled back
+	streamAtomicOpMaxRetries = 3
+)
+
+// streamPipeline sends the L2 blocks received from the finalizer to the data stream server
+type streamPipeline struct {
+	cfg          StreamServerCfg
+	streamServer dataStreamServer
+	// streamServerMutex is held while the L2 blocks are sent to the data stream server, so it's only replaced between atomic ops
+	streamServerMutex sync.Mutex
+	stateIntf         stateInterface
+	eventLog          *event.EventLog
+	dataToStream      chan state.DSL2FullBlock
+	encoder           state.StreamEncoder
+
+	// l2BlockEntries is the format of the entries of the L2 blocks, shared with the generation of the data stream file
+	l2BlockEntries state.DSL2BlockEntriesConfig
+
+	// storageCache caches the intermediate state roots read from the system SC
+	storageCache *storageCache
+
+	// sessionCounters counts the L2 blocks streamed, nil if they are not counted
+	sessionCounters *sessionCounters
+
+	// recentBlockHashes keeps the hashes of the last L2 blocks streamed, nil if they are not kept
+	recentBlockHashes *recentBlockHashes
+	// priorityStream receives the L2 blocks committed to send their qualifying txs, nil if it's disabled
+	priorityStream *priorityStream
+
+	// currentBatchNumber is the batch of the last L2 block streamed, currentBatchForkID its fork ID and currentBatchL2Blocks
+	// the number of L2 blocks streamed of it
+	currentBatchNumber   uint64
+	currentBatchForkID   uint64
+	currentBatchL2Blocks uint64
+
+	// lastTimestamp is the timestamp of the last L2 block streamed
+	lastTimestamp int64
+
+	// lastStateRoot is the state root of the last L2 block streamed, used as the final state root of the batch end entry
+	lastStateRoot common.Hash
+
+	// lastL2BlockNumber is the number of the last L2 block streamed
+	lastL2BlockNumber uint64
+
+	// checkpoint accumulates the hashes of the L2 blocks streamed since the last checkpoint entry
+	checkpoint state.DSL2BlockCheckpointAccumulator
+
+	// safeL2BlockNumber and finalizedL2BlockNumber are the last L2 blocks known to be safe and finalized. Their finality updates
+	// are streamed in the next atomic op, once the L2 blocks are streamed. finalityCh wakes up the pipeline to stream them
+	safeL2BlockNumber      atomic.Uint64
+	finalizedL2BlockNumber atomic.Uint64
+	finalityCh             chan struct{}
+
+	// lastSafeL2BlockNumber and lastFinalizedL2BlockNumber are the L2 blocks of the last finality updates streamed
+	lastSafeL2BlockNumber      uint64
+	lastFinalizedL2BlockNumber uint64
+
+	// paused is true while the streaming is paused, the L2 blocks read meanwhile are kept in pauseBuffer
+	paused      atomic.Bool
+	pauseBuffer []state.DSL2FullBlock
+	resumeCh    chan struct{}
+	// pauseBufferRegenerated is true if the L2 blocks in pauseBuffer were read while the data stream file was regenerated
+	pauseBufferRegenerated bool
+
+	// regenerating is true while the data stream file is updated with the batches of the state in the background
+	// (RegenerateInBackground). The L2 blocks read meanwhile are kept in pauseBuffer as if the streaming was paused
+	regenerating atomic.Bool
+
+	// debugStream receives the intermediate state roots of the L2 blocks streamed, nil if it's disabled
+	debugStream *debugStream
+}
+
+// newStreamPipeline creates a new streamPipeline. The invalid fields of BlockStartExcludedFields are ignored
+func newStreamPipeline(cfg StreamServerCfg, streamServer dataStreamServer, stateIntf stateInterface, eventLog *event.EventLog, dataToStream chan state.DSL2FullBlock) *streamPipeline {
+	return &streamPipeline{
+		cfg:          cfg,
+		streamServer: streamServer,
+		stateIntf:    stateIntf,
+		eventLog:     eventLog,
+		dataToStream: dataToStream,
+		encoder:      newStreamEncoder(cfg.Encoding),
+		storageCache: newStorageCache(stateIntf, cfg.StorageCacheSize),
+		resumeCh:     make(chan struct{}, 1),
+
+		l2BlockEntries: newL2BlockEntriesConfig(cfg),
+		finalityCh:     make(chan struct{}, 1),
+	}
+}
+
+// newStreamEncoder returns the StreamEncoder of the encoding. If the encoding is unknown the binary encoder is returned
+func newStreamEncoder(encoding string) state.StreamEncoder {
+	if encoding == StreamEncodingProtobuf {
+		return state.DSProtobufEncoder{}
+	}
+	return state.DSBinaryEncoder{}
+}
+
+// newL2BlockEntriesConfig returns the format of the entries of the L2 blocks streamed with the config. The invalid fields of
+// BlockStartExcludedFields are ignored
+func newL2BlockEntriesConfig(cfg StreamServerCfg) state.DSL2BlockEntriesConfig {
+	fields, _ := blockStartFields(cfg.BlockStartExcludedFields)
+	return state.DSL2BlockEntriesConfig{
+		Encoder:                  newStreamEncoder(cfg.Encoding),
+		BlockStartMasked:         len(cfg.BlockStartExcludedFields) > 0,
+		BlockStartFields:         fields,
+		IncludeL1InfoRoot:        cfg.IncludeL1InfoRoot,
+		IncludeDecodedTxMetadata: cfg.IncludeDecodedTxMetadata,
+		IncludeSender:            cfg.IncludeSender,
+		IncludeStorageDiffs:      cfg.IncludeStorageDiffs,
+		IncludeBlockBlooms:       cfg.IncludeBlockBlooms,
+		CheckpointEveryNBlocks:   cfg.CheckpointEveryNBlocks,
+	}
+}
+
+// blockStartFields returns the optional fields of the L2 block start entries without the excluded ones.
+// It returns ErrInvalidBlockStartField if an excluded field is unknown, along with the fields without the known excluded ones
+func blockStartFields(excluded []string) (state.DSL2BlockStartFields, error) {
+	var err error
+	fields := state.DSL2BlockStartAllFields
+	for _, field := range excluded {
+		switch field {
+		case BlockStartFieldGlobalExitRoot:
+			fields &^= state.DSL2BlockStartFieldGlobalExitRoot
+		case BlockStartFieldCoinbase:
+			fields &^= state.DSL2BlockStartFieldCoinbase
+		case BlockStartFieldForkID:
+			fields &^= state.DSL2BlockStartFieldForkID
+		default:
+			err = fmt.Errorf("%w: %s", ErrInvalidBlockStartField, field)
+		}
+	}
+	return fields, err
+}
+
+// pause pauses the streaming
+func (p *streamPipeline) pause() {
+	p.paused.Store(true)
+}
+
+// resume resumes the streaming
+func (p *streamPipeline) resume() {
+	p.paused.Store(false)
+	select {
+	case p.resumeCh <- struct{}{}:
+	default:
+	}
+}
+
+// endRegeneration resumes the streaming held while the data stream file was updated in the background
+func (p *streamPipeline) endRegeneration() {
+	p.regenerating.Store(false)
+	select {
+	case p.resumeCh <- struct{}{}:
+	default:
+	}
+}
+
+// restoreLastStreamed sets the batch, state root and L2 block of the last entries of the data stream, so the pipeline
+// continues streaming after them
+func (p *streamPipeline) restoreLastStreamed(streamServer *datastreamer.StreamServer) {
+	var err error
+	// The batch bookmark of the last batch in the data stream is already added
+	p.currentBatchNumber, err = getLastStreamedBatchNumber(streamServer)
+	if err != nil {
+		log.Errorf("failed to get the last batch number in the data stream, error: %w", err)
+	}
+	// The batch end entry of the last batch in the data stream has the state root of its last L2 block
+	p.lastStateRoot, err = getLastStreamedStateRoot(streamServer)
+	if err != nil {
+		log.Errorf("failed to get the last state root in the data stream, error: %w", err)
+	}
+	// The finality updates are streamed only for the L2 blocks already in the data stream
+	p.lastL2BlockNumber, err = getLastStreamedL2BlockNumber(streamServer)
+	if err != nil {
+		log.Errorf("failed to get the last l2block number in the data stream, error: %w", err)
+	}
+	// The next checkpoint continues from the last one in the data stream
+	p.checkpoint, err = state.RestoreDSL2BlockCheckpointAccumulator(streamServer, p.l2BlockEntries.CheckpointEveryNBlocks)
+	if err != nil {
+		log.Errorf("failed to restore the l2block checkpoint from the data stream, error: %w", err)
+	}
+}
+
+// setStreamServer replaces the data stream server and restores the last entries streamed from the new one. It must be called
+// holding streamServerMutex. If the entries are archived the ones of the new data stream server are archived to the same sink
+func (p *streamPipeline) setStreamServer(streamServer *datastreamer.StreamServer) {
+	var newStreamServer dataStreamServer = streamServer
+	if archived, ok := p.streamServer.(*archivedStreamServer); ok {
+		newStreamServer = newArchivedStreamServer(streamServer, archived.sink)
+	}
+	p.streamServer = newStreamServer
+	p.restoreLastStreamed(streamServer)
+}
+
+// setL2BlockFinality sets the last L2 blocks known to be safe and finalized, waking up the pipeline to stream their finality updates
+func (p *streamPipeline) setL2BlockFinality(safeL2BlockNumber, finalizedL2BlockNumber uint64) {
+	if safeL2BlockNumber <= p.safeL2BlockNumber.Load() && finalizedL2BlockNumber <= p.finalizedL2BlockNumber.Load() {
+		return
+	}
+
+	p.safeL2BlockNumber.Store(max(safeL2BlockNumber, p.safeL2BlockNumber.Load()))
+	p.finalizedL2BlockNumber.Store(max(finalizedL2BlockNumber, p.finalizedL2BlockNumber.Load()))
+	select {
+	case p.finalityCh <- struct{}{}:
+	default:
+	}
+}
+
+// start keeps reading the L2 blocks from the channel and sending them to the data stream server.
+// If the L2 blocks fail to be sent the atomic op is rolled back and retried. If all the retries fail and ReconnectQuietPeriod
+// is set, the streaming is resumed with the same L2 blocks once the data stream server is recovered. Otherwise the next L2 blocks are discarded.
+// If PipelinedEncoding is enabled the next L2 blocks are prepared in the background while the previous ones are committed
+func (p *streamPipeline) start() {
+	if p.cfg.PipelinedEncoding {
+		p.startPipelined()
+		return
+	}
+
+	for {
+		// Read data from channel
+		l2Blocks, regenerated := p.nextL2Blocks(nil)
+		if regenerated {
+			l2Blocks = p.skipRegeneratedL2Blocks(l2Blocks)
+		}
+		p.streamL2Blocks(l2Blocks, p.sendL2Blocks)
+	}
+}
+
+// preparedL2Blocks are the L2 blocks prepared in the background to be committed when PipelinedEncoding is enabled
+type preparedL2Blocks struct {
+	l2Blocks []state.DSL2FullBlock
+	// regenerated is true if the L2 blocks were read while the data stream file was regenerated
+	regenerated bool
+}
+
+// startPipelined commits the L2 blocks while the next ones are read and prepared in a background goroutine. The background
+// goroutine is stopped before it returns (e.g. if it panics), and the L2 blocks read but not streamed are put back in the
+// pause buffer so they are streamed once it's started again
+func (p *streamPipeline) startPipelined() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	prepared := make(chan preparedL2Blocks, max(p.cfg.PipelineBufferSize, 1))
+	go func() {
+		defer close(stopped)
+		p.prepareNextL2Blocks(done, prepared)
+	}()
+
+	defer func() {
+		close(done)
+		<-stopped
+
+		// The L2 blocks already prepared go before the ones put back by the background goroutine
+		var pendingL2Blocks []state.DSL2FullBlock
+		for len(prepared) > 0 {
+			next := <-prepared
+			pendingL2Blocks = append(pendingL2Blocks, next.l2Blocks...)
+			p.pauseBufferRegenerated = p.pauseBufferRegenerated || next.regenerated
+		}
+		p.pauseBuffer = append(pendingL2Blocks, p.pauseBuffer...)
+	}()
+
+	for {
+		next := <-prepared
+		l2Blocks := next.l2Blocks
+		if next.regenerated {
+			l2Blocks = p.skipRegeneratedL2Blocks(l2Blocks)
+		}
+		p.streamL2Blocks(l2Blocks, p.commitL2Blocks)
+	}
+}
+
+// prepareNextL2Blocks reads and prepares the next L2 blocks to stream until done is closed. The L2 blocks read and not sent to
+// the prepared channel are put back in the pause buffer
+func (p *streamPipeline) prepareNextL2Blocks(done <-chan struct{}, prepared chan<- preparedL2Blocks) {
+	for {
+		l2Blocks, regenerated := p.nextL2Blocks(done)
+		next := preparedL2Blocks{l2Blocks: p.prepareL2Blocks(l2Blocks), regenerated: regenerated}
+
+		select {
+		case prepared <- next:
+		case <-done:
+			p.pauseBuffer = append(next.l2Blocks, p.pauseBuffer...)
+			p.pauseBufferRegenerated = p.pauseBufferRegenerated || next.regenerated
+			return
+		}
+	}
+}
+
+// streamL2Blocks sends the L2 blocks to the data stream server with the send function, waiting for the data stream server
+// to recover if all the retries fail
+func (p *streamPipeline) streamL2Blocks(l2Blocks []state.DSL2FullBlock, send func([]state.DSL2FullBlock) error) {
+	p.streamServerMutex.Lock()
+	defer p.streamServerMutex.Unlock()
+
+	if p.streamServer == nil {
+		return
+	}
+
+	for !p.sendL2BlocksWithRetries(l2Blocks, send) {
+		if p.cfg.ReconnectQuietPeriod.Duration == 0 {
+			log.Errorf("next l2blocks will not be streamed")
+			p.streamServer = nil
+			return
+		}
+		p.waitStreamServerRecovery()
+	}
+}
+
+// sendL2BlocksWithRetries sends the L2 blocks to the data stream server with the send function, rolling back and retrying the atomic op if it fails.
+// It returns false if all the retries fail
+func (p *streamPipeline) sendL2BlocksWithRetries(l2Blocks []state.DSL2FullBlock, send func([]state.DSL2FullBlock) error) bool {
+	for retry := 0; ; retry++ {
+		err := send(l2Blocks)
+		if err == nil {
+			return true
+		}
+
+		err = p.streamServer.RollbackAtomicOp()
+		if err != nil {
+			log.Errorf("failed to rollback atomic op, error: %w", err)
+		}
+
+		if retry >= streamAtomicOpMaxRetries {
+			log.Errorf("failed to send %s after %d retries", describeL2Blocks(l2Blocks), retry)
+			return false
+		}
+
+		log.Infof("retrying to send %s to the data stream server", describeL2Blocks(l2Blocks))
+	}
+}
+
+// waitStreamServerRecovery waits until the data stream server is recovered. After each ReconnectQuietPeriod the data stream server
+// is probed with an empty atomic op, returning once the probe succeeds
+func (p *streamPipeline) waitStreamServerRecovery() {
+	for {
+		log.Infof("waiting %s to probe the data stream server before resuming the streaming", p.cfg.ReconnectQuietPeriod.Duration)
+		time.Sleep(p.cfg.ReconnectQuietPeriod.Duration)
+
+		err := p.probeStreamServer()
+		if err == nil {
+			log.Infof("data stream server probe succeeded, resuming the streaming")
+			return
+		}
+		log.Warnf("data stream server probe failed, error: %v", err)
+
+		err = p.streamServer.RollbackAtomicOp()
+		if err != nil {
+			log.Errorf("failed to rollback probe atomic op, error: %w", err)
+		}
+	}
+}
+
+// probeStreamServer checks that the data stream server accepts writes by committing an empty atomic op
+func (p *streamPipeline) probeStreamServer() error {
+	err := p.streamServer.StartAtomicOp()
+	if err != nil {
+		return err
+	}
+	return p.commitAtomicOp("probe")
+}
+
+// commitAtomicOp commits the current atomic op to the data stream server. If AtomicOpTimeout is set and the commit doesn't
+// finish in time an event is logged. The stalled commit is still waited for, the atomic op is never rolled back or retried
+// while its commit is in flight
+func (p *streamPipeline) commitAtomicOp(description string) error {
+	if p.cfg.AtomicOpTimeout.Duration == 0 {
+		return p.streamServer.CommitAtomicOp()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- p.streamServer.CommitAtomicOp()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(p.cfg.AtomicOpTimeout.Duration):
+	}
+
+	log.Errorf("commit of atomic op for %s didn't finish after %s, waiting for it", description, p.cfg.AtomicOpTimeout.Duration)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Error,
+		EventID:     event.EventID_DataStreamerAtomicOpTimeout,
+		Description: fmt.Sprintf("commit of atomic op for %s didn't finish after %s", description, p.cfg.AtomicOpTimeout.Duration),
+	}
+	logEvent(context.Background(), p.eventLog, event)
+
+	start := time.Now()
+	err := <-result
+	log.Warnf("stalled commit of atomic op for %s finished after %s, error: %v", description, p.cfg.AtomicOpTimeout.Duration+time.Since(start), err)
+	return err
+}
+
+// nextL2Blocks returns the next L2 blocks to stream. While the streaming is paused, or the data stream file is regenerated,
+// the L2 blocks read from the channel are kept in the pause buffer, and they are returned in order before reading again from
+// the channel once resumed. regenerated is true for the L2 blocks buffered during the regeneration, the ones already written
+// by it must be skipped. It returns no L2 blocks once done is closed
+func (p *streamPipeline) nextL2Blocks(done <-chan struct{}) (l2Blocks []state.DSL2FullBlock, regenerated bool) {
+	for p.paused.Load() || p.regenerating.Load() {
+		p.pauseBufferRegenerated = p.pauseBufferRegenerated || p.regenerating.Load()
+		pauseBufferFull := uint64(len(p.pauseBuffer)) >= p.cfg.PauseBufferSize
+		if pauseBufferFull && p.cfg.PauseBufferFullPolicy != PauseBufferFullPolicyDrop {
+			select {
+			case <-p.resumeCh:
+			case <-done:
+				return nil, false
+			}
+			continue
+		}
+
+		select {
+		case l2Block := <-p.dataToStream:
+			if pauseBufferFull {
+				p.dropL2Block(l2Block)
+				continue
+			}
+			p.pauseBuffer = append(p.pauseBuffer, l2Block)
+		case <-p.resumeCh:
+		case <-done:
+			return nil, false
+		}
+	}
+
+	if len(p.pauseBuffer) > 0 {
+		n := 1
+		if p.cfg.BlocksPerAtomicOp > 1 {
+			n = int(min(uint64(len(p.pauseBuffer)), p.cfg.BlocksPerAtomicOp))
+		}
+		l2Blocks, regenerated = p.pauseBuffer[:n], p.pauseBufferRegenerated
+		p.pauseBuffer = p.pauseBuffer[n:]
+		if len(p.pauseBuffer) == 0 {
+			p.pauseBufferRegenerated = false
+		}
+		return l2Blocks, regenerated
+	}
+
+	return p.readL2Blocks(done), false
+}
+
+// skipRegeneratedL2Blocks returns the L2 blocks without the ones already written to the data stream file by its regeneration
+func (p *streamPipeline) skipRegeneratedL2Blocks(l2Blocks []state.DSL2FullBlock) []state.DSL2FullBlock {
+	pendingL2Blocks := make([]state.DSL2FullBlock, 0, len(l2Blocks))
+	for _, l2Block := range l2Blocks {
+		if l2Block.L2BlockNumber <= p.lastL2BlockNumber {
+			log.Debugf("l2block %d skipped, already written by the regeneration of the data stream file", l2Block.L2BlockNumber)
+			continue
+		}
+		pendingL2Blocks = append(pendingL2Blocks, l2Block)
+	}
+	return pendingL2Blocks
+}
+
+// dropL2Block drops a L2 block read while the streaming is paused and the pause buffer is full
+func (p *streamPipeline) dropL2Block(l2Block state.DSL2FullBlock) {
+	description := fmt.Sprintf("l2block %d not streamed, pause buffer is full (size: %d)", l2Block.L2BlockNumber, p.cfg.PauseBufferSize)
+	log.Error(description)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Error,
+		EventID:     event.EventID_DataStreamerL2BlockDropped,
+		Description: description,
+	}
+
+	logEvent(context.Background(), p.eventLog, event)
+}
+
+// readL2Blocks waits for a L2 block from the channel and drains the L2 blocks already available in it, up to BlocksPerAtomicOp L2 blocks.
+// If the finality of the L2 blocks is updated meanwhile no L2 blocks are returned so the finality updates are streamed, as well as once done is closed
+func (p *streamPipeline) readL2Blocks(done <-chan struct{}) []state.DSL2FullBlock {
+	var l2Blocks []state.DSL2FullBlock
+	select {
+	case l2Block := <-p.dataToStream:
+		l2Blocks = []state.DSL2FullBlock{l2Block}
+	case <-p.finalityCh:
+		return nil
+	case <-done:
+		return nil
+	}
+
+	for uint64(len(l2Blocks)) < p.cfg.BlocksPerAtomicOp {
+		select {
+		case l2Block := <-p.dataToStream:
+			l2Blocks = append(l2Blocks, l2Block)
+		default:
+			return l2Blocks
+		}
+	}
+
+	return l2Blocks
+}
+
+// sendL2Blocks sends the L2 blocks and their txs to the data stream server in a single atomic op
+func (p *streamPipeline) sendL2Blocks(l2Blocks []state.DSL2FullBlock) error {
+	return p.commitL2Blocks(p.prepareL2Blocks(l2Blocks))
+}
+
+// prepareL2Blocks returns the L2 blocks to stream, filtering out the ones that fail the verifications and populating
+// the intermediate state roots of their txs. It doesn't use the data stream server
+func (p *streamPipeline) prepareL2Blocks(l2Blocks []state.DSL2FullBlock) []state.DSL2FullBlock {
+	if p.cfg.VerifyBlockHash {
+		verifiedL2Blocks := make([]state.DSL2FullBlock, 0, len(l2Blocks))
+		for _, l2Block := range l2Blocks {
+			if p.verifyL2Block(context.Background(), l2Block.DSL2Block) {
+				verifiedL2Blocks = append(verifiedL2Blocks, l2Block)
+			}
+		}
+		l2Blocks = verifiedL2Blocks
+	}
+
+	if p.cfg.VerifyBatchNumber {
+		verifiedL2Blocks := make([]state.DSL2FullBlock, 0, len(l2Blocks))
+		for _, l2Block := range l2Blocks {
+			if p.verifyL2BlockBatchNumber(context.Background(), l2Block.DSL2Block) {
+				verifiedL2Blocks = append(verifiedL2Blocks, l2Block)
+			}
+		}
+		l2Blocks = verifiedL2Blocks
+	}
+
+	if p.cfg.SkipIntermediateStateRoots {
+		return l2Blocks
+	}
+
+	preparedL2Blocks := make([]state.DSL2FullBlock, 0, len(l2Blocks))
+	for _, l2Block := range l2Blocks {
+		// The txs are copied so the L2 block received from the finalizer is not modified
+		txs := make([]state.DSL2Transaction, 0, len(l2Block.Txs))
+		// The position of the system SC depends only on the L2 block number, so it's read at most once per L2 block
+		var blockStateRoot *common.Hash
+		for _, l2Transaction := range l2Block.Txs {
+			// The state root after each tx is set by the executor, the system SC is only read for the txs without it
+			if l2Transaction.StateRoot == (common.Hash{}) {
+				if blockStateRoot == nil {
+					imStateRoot := p.getIntermediateStateRoot(l2Block.DSL2Block)
+					blockStateRoot = &imStateRoot
+				}
+				l2Transaction.StateRoot = *blockStateRoot
+			}
+			p.debugStream.addEntry(DebugEntryTypeIntermediateStateRoot, DebugIntermediateStateRoot{L2BlockNumber: l2Block.L2BlockNumber, StateRoot: l2Transaction.StateRoot})
+			txs = append(txs, l2Transaction)
+		}
+		l2Block.Txs = txs
+		preparedL2Blocks = append(preparedL2Blocks, l2Block)
+	}
+
+	return preparedL2Blocks
+}
+
+// commitL2Blocks sends the L2 blocks already prepared and their txs to the data stream server in a single atomic op
+func (p *streamPipeline) commitL2Blocks(l2Blocks []state.DSL2FullBlock) error {
+	l2Blocks, lastTimestamp := p.checkL2BlocksTimestamp(l2Blocks)
+
+	lastL2BlockNumber := p.lastL2BlockNumber
+	if len(l2Blocks) > 0 {
+		lastL2BlockNumber = l2Blocks[len(l2Blocks)-1].L2BlockNumber
+	}
+	finalityUpdates := p.l2BlockFinalityUpdates(lastL2BlockNumber)
+
+	if len(l2Blocks) == 0 && len(finalityUpdates) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	err := p.streamServer.StartAtomicOp()
+	metrics.DataStreamAtomicOpTime(metrics.DataStreamAtomicOpPhaseStart, time.Since(start))
+	if err != nil {
+		log.Errorf("failed to start atomic op for %s, error: %w ", describeL2Blocks(l2Blocks), err)
+		return err
+	}
+
+	// Time spent adding the entries (the intermediate state roots are computed before starting the atomic op)
+	var addEntriesTime time.Duration
+
+	batchNumber, stateRoot, checkpoint := p.currentBatchNumber, p.lastStateRoot, p.checkpoint
+	for _, l2Block := range l2Blocks {
+		// Add the batch bookmark before the first L2 block of a new batch
+		if l2Block.BatchNumber != batchNumber {
+			// Close the previous batch before the bookmark of the new one
+			if p.cfg.EmitBatchBoundaries && batchNumber != 0 {
+				batchEndTime, err := p.addBatchBoundaryEntry(state.EntryTypeBatchEnd, batchNumber, state.DSBatchEnd{BatchNumber: batchNumber, StateRoot: stateRoot}.Encode())
+				addEntriesTime += batchEndTime
+				if err != nil {
+					return err
+				}
+			}
+
+			bookmarkTime, err := p.addBatchBookmark(l2Block.BatchNumber)
+			addEntriesTime += bookmarkTime
+			if err != nil {
+				return err
+			}
+			batchNumber = l2Block.BatchNumber
+
+			if p.cfg.EmitBatchBoundaries {
+				batchStartTime, err := p.addBatchBoundaryEntry(state.EntryTypeBatchStart, batchNumber, state.DSBatchStart{BatchNumber: batchNumber}.Encode())
+				addEntriesTime += batchStartTime
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		var checkpointEntry *state.DSL2BlockCheckpoint
+		checkpoint, checkpointEntry = checkpoint.Add(l2Block.L2BlockNumber, l2Block.BlockHash, p.l2BlockEntries.CheckpointEveryNBlocks)
+
+		l2BlockEntriesTime, err := p.addL2BlockEntries(l2Block, checkpointEntry)
+		addEntriesTime += l2BlockEntriesTime
+		if err != nil {
+			return err
+		}
+		stateRoot = l2Block.StateRoot
+	}
+
+	for _, finalityUpdate := range finalityUpdates {
+		start = time.Now()
+		_, err = p.streamServer.AddStreamEntry(state.EntryTypeL2BlockFinality, finalityUpdate.Encode())
+		addEntriesTime += time.Since(start)
+		if err != nil {
+			log.Errorf("failed to add %s finality stream entry for l2block %d, error: %w", finalityUpdate.Finality, finalityUpdate.L2BlockNumber, err)
+			return err
+		}
+	}
+	metrics.DataStreamAtomicOpTime(metrics.DataStreamAtomicOpPhaseAddEntries, addEntriesTime)
+
+	start = time.Now()
+	err = p.commitAtomicOp(describeL2Blocks(l2Blocks))
+	metrics.DataStreamAtomicOpTime(metrics.DataStreamAtomicOpPhaseCommit, time.Since(start))
+	if err != nil {
+		log.Errorf("failed to commit atomic op for %s, error: %w ", describeL2Blocks(l2Blocks), err)
+		return err
+	}
+	p.lastTimestamp = lastTimestamp
+	p.lastStateRoot = stateRoot
+	p.lastL2BlockNumber = lastL2BlockNumber
+	p.checkpoint = checkpoint
+	for _, finalityUpdate := range finalityUpdates {
+		if finalityUpdate.Finality == state.L2BlockFinalitySafe {
+			p.lastSafeL2BlockNumber = finalityUpdate.L2BlockNumber
+		} else {
+			p.lastFinalizedL2BlockNumber = finalityUpdate.L2BlockNumber
+		}
+	}
+
+	for _, l2Block := range l2Blocks {
+		metrics.DataStreamL2BlocksStreamed(uint64(l2Block.ForkID), 1)
+		logStructuredEvent(LogEventL2BlockStreamed, "l2block streamed", LogFieldBatchNumber, l2Block.BatchNumber, LogFieldL2BlockNumber, l2Block.L2BlockNumber, LogFieldTxs, len(l2Block.Txs))
+		p.recentBlockHashes.add(l2Block.L2BlockNumber, l2Block.BlockHash)
+	}
+	p.countL2BlocksPerBatch(l2Blocks)
+	p.sessionCounters.addStreamedL2Blocks(uint64(len(l2Blocks)))
+	p.priorityStream.commit(l2Blocks)
+
+	if p.cfg.EmitReceiptsReadyEvents {
+		for _, l2Block := range l2Blocks {
+			p.emitReceiptsReadyEvent(l2Block)
+		}
+	}
+
+	return nil
+}
+
+// l2BlockFinalityUpdates returns the finality updates to stream for the L2 blocks up to lastL2BlockNumber. The L2 blocks
+// not streamed yet are upgraded once they are streamed
+func (p *streamPipeline) l2BlockFinalityUpdates(lastL2BlockNumber uint64) []state.DSL2BlockFinality {
+	finalityUpdates := []state.DSL2BlockFinality{}
+	for _, f := range []struct {
+		finality                  state.L2BlockFinality
+		l2BlockNumber             uint64
+		lastStreamedL2BlockNumber uint64
+	}{
+		{state.L2BlockFinalitySafe, p.safeL2BlockNumber.Load(), p.lastSafeL2BlockNumber},
+		{state.L2BlockFinalityFinalized, p.finalizedL2BlockNumber.Load(), p.lastFinalizedL2BlockNumber},
+	} {
+		l2BlockNumber := min(f.l2BlockNumber, lastL2BlockNumber)
+		if l2BlockNumber > f.lastStreamedL2BlockNumber {
+			finalityUpdates = append(finalityUpdates, state.DSL2BlockFinality{
+				Version:       state.DSL2BlockFinalityVersion,
+				L2BlockNumber: l2BlockNumber,
+				Finality:      f.finality,
+			})
+		}
+	}
+	return finalityUpdates
+}
+
+// describeL2Blocks returns the range of the L2 blocks sent in an atomic op for the logs
+func describeL2Blocks(l2Blocks []state.DSL2FullBlock) string {
+	if len(l2Blocks) == 0 {
+		return "l2block finality updates"
+	}
+	return fmt.Sprintf("l2blocks %d to %d", l2Blocks[0].L2BlockNumber, l2Blocks[len(l2Blocks)-1].L2BlockNumber)
+}
+
+// countL2BlocksPerBatch counts the L2 blocks streamed of the current batch. When a L2 block of a new batch is streamed
+// the number of L2 blocks of the previous batch is observed into the L2 blocks per batch metric
+func (p *streamPipeline) countL2BlocksPerBatch(l2Blocks []state.DSL2FullBlock) {
+	for _, l2Block := range l2Blocks {
+		if l2Block.BatchNumber != p.currentBatchNumber {
+			if p.currentBatchL2Blocks > 0 {
+				metrics.DataStreamL2BlocksPerBatch(p.currentBatchForkID, p.currentBatchL2Blocks)
+			}
+			p.currentBatchNumber = l2Block.BatchNumber
+			p.currentBatchForkID = uint64(l2Block.ForkID)
+			p.currentBatchL2Blocks = 0
+		}
+		p.currentBatchL2Blocks++
+	}
+}
+
+// receiptsReady is the data of the receipts ready event
+type receiptsReady struct {
+	L2BlockNumber uint64        `json:"l2BlockNumber"`
+	TxHashes      []common.Hash `json:"txHashes"`
+}
+
+// emitReceiptsReadyEvent logs an event signaling that the txs of the L2 block are final for the sequencer.
+// The event is logged in the background, failing to log it doesn't affect the streaming
+func (p *streamPipeline) emitReceiptsReadyEvent(l2Block state.DSL2FullBlock) {
+	data := receiptsReady{
+		L2BlockNumber: l2Block.L2BlockNumber,
+		TxHashes:      make([]common.Hash, 0, len(l2Block.Txs)),
+	}
+	for _, l2Transaction := range l2Block.Txs {
+		tx := types.Transaction{}
+		err := tx.UnmarshalBinary(l2Transaction.Encoded)
+		if err != nil {
+			log.Warnf("failed to decode tx of l2block %d for the receipts ready event, error: %w", l2Block.L2BlockNumber, err)
+			continue
+		}
+		data.TxHashes = append(data.TxHashes, tx.Hash())
+	}
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Info,
+		EventID:     event.EventID_DataStreamerReceiptsReady,
+		Description: fmt.Sprintf("l2block %d receipts ready, txs: %d", l2Block.L2BlockNumber, len(data.TxHashes)),
+		Json:        data,
+	}
+
+	go logEvent(context.Background(), p.eventLog, event)
+}
+
+// addBatchBookmark adds the bookmark of a batch to the current atomic op, returning the time spent adding it
+func (p *streamPipeline) addBatchBookmark(batchNumber uint64) (time.Duration, error) {
+	bookMark := state.DSBookMark{
+		Type:          state.BookMarkTypeBatch,
+		L2BlockNumber: batchNumber,
+	}
+
+	start := time.Now()
+	_, err := p.streamServer.AddStreamBookmark(bookMark.Encode())
+	addBookmarkTime := time.Since(start)
+	if err != nil {
+		log.Errorf("failed to add stream bookmark for batch %d, error: %w", batchNumber, err)
+	}
+
+	return addBookmarkTime, err
+}
+
+// addBatchBoundaryEntry adds the batch start or batch end entry of a batch to the current atomic op, returning the time spent adding it
+func (p *streamPipeline) addBatchBoundaryEntry(entryType datastreamer.EntryType, batchNumber uint64, data []byte) (time.Duration, error) {
+	start := time.Now()
+	_, err := p.streamServer.AddStreamEntry(entryType, data)
+	addEntryTime := time.Since(start)
+	if err != nil {
+		log.Errorf("failed to add stream entry type %d for batch %d, error: %w", entryType, batchNumber, err)
+	}
+
+	return addEntryTime, err
+}
+
+// addL2BlockEntries adds the bookmark and entries of a L2 block and its txs to the current atomic op. If checkpoint is not
+// nil it's added before the end of the L2 block. It returns the time spent adding the entries
+func (p *streamPipeline) addL2BlockEntries(l2Block state.DSL2FullBlock, checkpoint *state.DSL2BlockCheckpoint) (time.Duration, error) {
+	var addEntriesTime time.Duration
+
+	bookMark := state.DSBookMark{
+		Type:          state.BookMarkTypeL2Block,
+		L2BlockNumber: l2Block.L2BlockNumber,
+	}
+
+	start := time.Now()
+	_, err := p.streamServer.AddStreamBookmark(bookMark.Encode())
+	addEntriesTime += time.Since(start)
+	if err != nil {
+		log.Errorf("failed to add stream bookmark for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+		return addEntriesTime, err
+	}
+
+	blockStart := state.DSL2BlockStart{
+		BatchNumber:    l2Block.BatchNumber,
+		L2BlockNumber:  l2Block.L2BlockNumber,
+		Timestamp:      l2Block.Timestamp,
+		GlobalExitRoot: l2Block.GlobalExitRoot,
+		Coinbase:       l2Block.Coinbase,
+		ForkID:         l2Block.ForkID,
+	}
+
+	entryType, encoded := p.l2BlockEntries.L2BlockStartEntry(blockStart, l2Block.L1InfoRoot)
+
+	start = time.Now()
+	_, err = p.streamServer.AddStreamEntry(entryType, encoded)
+	addEntriesTime += time.Since(start)
+	if err != nil {
+		log.Errorf("failed to add stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+		return addEntriesTime, err
+	}
+
+	for _, l2Transaction := range l2Block.Txs {
+		entryType, encoded, err := p.l2BlockEntries.L2TransactionEntry(l2Transaction)
+		if err != nil {
+			log.Errorf("failed to encode l2tx for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+			return addEntriesTime, err
+		}
+
+		start = time.Now()
+		_, err = p.streamServer.AddStreamEntry(entryType, encoded)
+		addEntriesTime += time.Since(start)
+		if err != nil {
+			log.Errorf("failed to add l2tx stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+			return addEntriesTime, err
+		}
+	}
+
+	if p.l2BlockEntries.IncludeStorageDiffs {
+		storageDiff := state.DSL2BlockStorageDiff{
+			Version:       state.DSL2BlockStorageDiffVersion,
+			L2BlockNumber: l2Block.L2BlockNumber,
+			Unavailable:   l2Block.StorageChangesUnavailable,
+			Changes:       l2Block.StorageChanges,
+		}
+
+		start = time.Now()
+		_, err = p.streamServer.AddStreamEntry(state.EntryTypeL2BlockStorageDiff, storageDiff.Encode())
+		addEntriesTime += time.Since(start)
+		if err != nil {
+			log.Errorf("failed to add storage diff stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+			return addEntriesTime, err
+		}
+	}
+
+	if p.l2BlockEntries.IncludeBlockBlooms {
+		start = time.Now()
+		_, err = p.streamServer.AddStreamEntry(state.EntryTypeL2BlockBloom, state.NewDSL2BlockBloom(l2Block.L2BlockNumber, l2Block.Logs).Encode())
+		addEntriesTime += time.Since(start)
+		if err != nil {
+			log.Errorf("failed to add bloom stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+			return addEntriesTime, err
+		}
+	}
+
+	if checkpoint != nil {
+		start = time.Now()
+		_, err = p.streamServer.AddStreamEntry(state.EntryTypeL2BlockCheckpoint, checkpoint.Encode())
+		addEntriesTime += time.Since(start)
+		if err != nil {
+			log.Errorf("failed to add checkpoint stream entry for l2blocks %d to %d, error: %w", checkpoint.FromL2BlockNumber, checkpoint.ToL2BlockNumber, err)
+			return addEntriesTime, err
+		}
+	}
+
+	blockEnd := state.DSL2BlockEnd{
+		L2BlockNumber: l2Block.L2BlockNumber,
+		BlockHash:     l2Block.BlockHash,
+		StateRoot:     l2Block.StateRoot,
+	}
+
+	start = time.Now()
+	entryType, encoded = p.l2BlockEntries.L2BlockEndEntry(blockEnd)
+	_, err = p.streamServer.AddStreamEntry(entryType, encoded)
+	addEntriesTime += time.Since(start)
+	if err != nil {
+		log.Errorf("failed to add stream entry for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+		return addEntriesTime, err
+	}
+
+	return addEntriesTime, nil
+}
+
+// getIntermediateStateRoot returns the intermediate state root of the L2 block stored in the system SC.
+// If it can't be retrieved an empty hash is returned
+func (p *streamPipeline) getIntermediateStateRoot(l2Block state.DSL2Block) common.Hash {
+	position := state.GetSystemSCPosition(l2Block.L2BlockNumber)
+	imStateRoot, err := p.storageCache.getStorageAt(context.Background(), common.HexToAddress(state.SystemSC), big.NewInt(0).SetBytes(position), l2Block.StateRoot)
+	if err != nil {
+		log.Errorf("failed to get storage at for l2block %d, error: %w", l2Block.L2BlockNumber, err)
+		return common.Hash{}
+	}
+	return common.BigToHash(imStateRoot)
+}
+
+// checkL2BlocksTimestamp checks that the timestamp of each L2 block is not before the timestamp of the previous L2 block
+// beyond TimestampSkewTolerance. If it is an event is logged and the L2 block is clamped or skipped according to TimestampSkewPolicy.
+// It returns the L2 blocks to stream and the timestamp of the last one
+func (p *streamPipeline) checkL2BlocksTimestamp(l2Blocks []state.DSL2FullBlock) ([]state.DSL2FullBlock, int64) {
+	lastTimestamp := p.lastTimestamp
+	tolerance := int64(p.cfg.TimestampSkewTolerance.Seconds())
+
+	checkedL2Blocks := make([]state.DSL2FullBlock, 0, len(l2Blocks))
+	for _, l2Block := range l2Blocks {
+		if l2Block.Timestamp >= lastTimestamp-tolerance {
+			checkedL2Blocks = append(checkedL2Blocks, l2Block)
+			lastTimestamp = max(lastTimestamp, l2Block.Timestamp)
+			continue
+		}
+
+		description := fmt.Sprintf("l2block %d timestamp %d is before the previous l2block timestamp %d", l2Block.L2BlockNumber, l2Block.Timestamp, lastTimestamp)
+		if p.cfg.TimestampSkewPolicy == TimestampSkewPolicySkip {
+			description += ", l2block not streamed"
+		} else {
+			description += fmt.Sprintf(", l2block streamed with timestamp %d", lastTimestamp+1)
+			l2Block.Timestamp = lastTimestamp + 1
+			lastTimestamp = l2Block.Timestamp
+			checkedL2Blocks = append(checkedL2Blocks, l2Block)
+		}
+		log.Warn(description)
+
+		event := &event.Event{
+			ReceivedAt:  time.Now(),
+			Source:      event.Source_Node,
+			Component:   event.Component_Sequencer,
+			Level:       event.Level_Warning,
+			EventID:     event.EventID_DataStreamerTimestampSkew,
+			Description: description,
+		}
+
+		logEvent(context.Background(), p.eventLog, event)
+	}
+
+	return checkedL2Blocks, lastTimestamp
+}
+
+// verifyL2Block cross-checks the block hash and state root of the L2 block to stream against the block stored in the state.
+// If they don't match an event is logged and false is returned. If the block can't be retrieved from the state the check is skipped
+func (p *streamPipeline) verifyL2Block(ctx context.Context, l2Block state.DSL2Block) bool {
+	l2Header, err := p.stateIntf.GetL2BlockHeaderByNumber(ctx, l2Block.L2BlockNumber, nil)
+	if err != nil {
+		log.Errorf("failed to get l2block %d header to verify block hash, error: %w", l2Block.L2BlockNumber, err)
+		return true
+	}
+
+	// In etrog the block hash is the state root of the block, stored as the header root
+	if l2Header.Root == l2Block.BlockHash && l2Header.Root == l2Block.StateRoot {
+		return true
+	}
+
+	description := fmt.Sprintf("l2block %d not streamed, block hash %s and state root %s don't match the state root %s stored in the state",
+		l2Block.L2BlockNumber, l2Block.BlockHash.String(), l2Block.StateRoot.String(), l2Header.Root.String())
+	log.Error(description)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Error,
+		EventID:     event.EventID_DataStreamerBlockHashMismatch,
+		Description: description,
+	}
+
+	logEvent(ctx, p.eventLog, event)
+
+	return false
+}
+
+// verifyL2BlockBatchNumber checks that the batch number of the L2 block matches the batch of the L2 block stored in the state.
+// If it doesn't match an event is logged and false is returned. If the state can't be queried the L2 block is considered valid
+func (p *streamPipeline) verifyL2BlockBatchNumber(ctx context.Context, l2Block state.DSL2Block) bool {
+	batchNumber, err := p.stateIntf.GetBatchNumberOfL2Block(ctx, l2Block.L2BlockNumber, nil)
+	if err != nil {
+		log.Errorf("failed to get batch number of l2block %d to verify it, error: %w", l2Block.L2BlockNumber, err)
+		return true
+	}
+
+	if batchNumber == l2Block.BatchNumber {
+		return true
+	}
+
+	description := fmt.Sprintf("l2block %d not streamed, batch number %d doesn't match the batch number %d stored in the state",
+		l2Block.L2BlockNumber, l2Block.BatchNumber, batchNumber)
+	log.Error(description)
+
+	event := &event.Event{
+		ReceivedAt:  time.Now(),
+		Source:      event.Source_Node,
+		Component:   event.Component_Sequencer,
+		Level:       event.Level_Error,
+		EventID:     event.EventID_DataStreamerBatchNumberMismatch,
+		Description: description,
+	}
+
+	logEvent(ctx, p.eventLog, event)
+
+	return false
+}
@@ -0,0 +1,511 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/event"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStreamBatchBookmark sets the stream server mock expectation to add the bookmark of a batch in the current atomic op
+func mockStreamBatchBookmark(streamServerMock *DataStreamServerMock, batchNumber uint64) {
+	bookMark := state.DSBookMark{
+		Type:          state.BookMarkTypeBatch,
+		L2BlockNumber: batchNumber,
+	}
+	streamServerMock.On("AddStreamBookmark", bookMark.Encode()).Return(uint64(0), nil).Once()
+}
+
+// mockStreamL2Block sets the stream server mock expectations to add the bookmark, block start and block end entries of a L2 block in the current atomic op
+func mockStreamL2Block(streamServerMock *DataStreamServerMock, l2Block state.DSL2FullBlock) {
+	bookMark := state.DSBookMark{
+		Type:          state.BookMarkTypeL2Block,
+		L2BlockNumber: l2Block.L2BlockNumber,
+	}
+	blockStart := state.DSL2BlockStart{
+		BatchNumber:    l2Block.BatchNumber,
+		L2BlockNumber:  l2Block.L2BlockNumber,
+		Timestamp:      l2Block.Timestamp,
+		GlobalExitRoot: l2Block.GlobalExitRoot,
+		Coinbase:       l2Block.Coinbase,
+		ForkID:         l2Block.ForkID,
+	}
+	blockEnd := state.DSL2BlockEnd{
+		L2BlockNumber: l2Block.L2BlockNumber,
+		BlockHash:     l2Block.BlockHash,
+		StateRoot:     l2Block.StateRoot,
+	}
+
+	streamServerMock.On("AddStreamBookmark", bookMark.Encode()).Return(uint64(0), nil).Once()
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2BlockStart, blockStart.Encode()).Return(uint64(1), nil).Once()
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2BlockEnd, blockEnd.Encode()).Return(uint64(2), nil).Once()
+}
+
+func TestStreamPipeline_sendL2Blocks(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	p := newStreamPipeline(StreamServerCfg{}, streamServerMock, stMock, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 2)
+	imStateRoot := big.NewInt(100)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, l2Block.StateRoot).Return(imStateRoot, nil).Once()
+
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	for _, l2Tx := range l2Block.Txs {
+		l2Tx.StateRoot = common.BigToHash(imStateRoot)
+		streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, l2Tx.Encode()).Return(uint64(0), nil).Once()
+	}
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once()
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+}
+
+func TestStreamPipeline_sendL2Blocks_IntermediateStateRootError(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	p := newStreamPipeline(StreamServerCfg{}, streamServerMock, stMock, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 1)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, l2Block.StateRoot).Return(nil, errors.New("storage error")).Once()
+
+	// The tx is streamed with an empty intermediate state root
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	l2Tx := l2Block.Txs[0]
+	l2Tx.StateRoot = common.Hash{}
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, l2Tx.Encode()).Return(uint64(0), nil).Once()
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once()
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+}
+
+func TestStreamPipeline_sendL2Blocks_TxStateRoots(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	p := newStreamPipeline(StreamServerCfg{}, streamServerMock, stMock, nil, nil)
+
+	// The first 2 txs have the state root set by the executor, the last one is read from the system SC
+	l2Block := newTestL2FullBlock(1, 1, 3)
+	l2Block.Txs[0].StateRoot = common.HexToHash("0x01")
+	l2Block.Txs[1].StateRoot = common.HexToHash("0x02")
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, l2Block.StateRoot).Return(big.NewInt(3), nil).Once()
+
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	stateRoots := []common.Hash{}
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, mock.Anything).Return(uint64(0), nil).Times(3).Run(func(args mock.Arguments) {
+		l2Tx := state.DSL2Transaction{}.Decode(args.Get(1).([]byte))
+		stateRoots = append(stateRoots, l2Tx.StateRoot)
+	})
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once()
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+	assert.Equal(t, []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")}, stateRoots)
+}
+
+func TestStreamPipeline_sendL2Blocks_SkipIntermediateStateRoots(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true}, streamServerMock, stMock, nil, nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 2)
+
+	// The txs are streamed with an empty intermediate state root
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	for _, l2Tx := range l2Block.Txs {
+		streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, l2Tx.Encode()).Return(uint64(0), nil).Once()
+	}
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once()
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+	stMock.AssertNotCalled(t, "GetStorageAt", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// eventStorageChan is an event storage that sends the logged events to a channel
+type eventStorageChan chan *event.Event
+
+func (e eventStorageChan) LogEvent(ctx context.Context, event *event.Event) error {
+	e <- event
+	return nil
+}
+
+func TestStreamPipeline_sendL2Blocks_ReceiptsReadyEvent(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	events := make(eventStorageChan, 1)
+	p := newStreamPipeline(StreamServerCfg{EmitReceiptsReadyEvents: true, SkipIntermediateStateRoots: true}, streamServerMock, stMock, event.NewEventLog(event.Config{}, events), nil)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+	txHashes := []common.Hash{}
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := newTestPoolTx(t, nonce, 21000)
+		encoded, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		l2Block.Txs = append(l2Block.Txs, state.DSL2Transaction{L2BlockNumber: 1, IsValid: 1, EncodedLength: uint32(len(encoded)), Encoded: encoded})
+		txHashes = append(txHashes, tx.Hash())
+	}
+
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, mock.Anything).Return(uint64(0), nil).Twice()
+
+	// The event must be emitted after the commit
+	committed := false
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { committed = true })
+
+	require.NoError(t, p.sendL2Blocks([]state.DSL2FullBlock{l2Block}))
+
+	select {
+	case e := <-events:
+		assert.True(t, committed)
+		assert.Equal(t, event.EventID_DataStreamerReceiptsReady, e.EventID)
+		assert.Equal(t, receiptsReady{L2BlockNumber: 1, TxHashes: txHashes}, e.Json)
+	case <-time.After(5 * time.Second):
+		t.Fatal("receipts ready event not emitted")
+	}
+}
+
+func TestStreamPipeline_start_BlocksPerAtomicOp(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	dataToStream := make(chan state.DSL2FullBlock, 3)
+	p := newStreamPipeline(StreamServerCfg{BlocksPerAtomicOp: 3}, streamServerMock, stMock, nil, dataToStream)
+
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, mock.Anything).Return(big.NewInt(1), nil).Times(3)
+
+	// The 3 L2 blocks are written in a single atomic op
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 3; l2BlockNumber++ {
+		l2Block := newTestL2FullBlock(1, l2BlockNumber, 1)
+		mockStreamL2Block(streamServerMock, l2Block)
+		dataToStream <- l2Block
+	}
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, mock.Anything).Return(uint64(0), nil).Times(3)
+
+	committed := make(chan struct{})
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { close(committed) })
+
+	go p.start()
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op not committed")
+	}
+}
+
+func TestStreamPipeline_start_RollbackAndRetry(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	dataToStream := make(chan state.DSL2FullBlock)
+	p := newStreamPipeline(StreamServerCfg{}, streamServerMock, stMock, nil, dataToStream)
+
+	l2Block := newTestL2FullBlock(1, 1, 1)
+	stMock.On("GetStorageAt", mock.Anything, common.HexToAddress(state.SystemSC), mock.Anything, l2Block.StateRoot).Return(big.NewInt(1), nil).Twice()
+
+	// The first attempt fails adding the tx entry and it's rolled back
+	streamServerMock.On("StartAtomicOp").Return(nil).Twice()
+	streamServerMock.On("AddStreamBookmark", mock.Anything).Return(uint64(0), nil).Twice()
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2BlockStart, mock.Anything).Return(uint64(1), nil).Once()
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, mock.Anything).Return(uint64(0), errors.New("add entry error")).Once()
+	streamServerMock.On("RollbackAtomicOp").Return(nil).Once()
+
+	// The retry succeeds
+	mockStreamBatchBookmark(streamServerMock, 1)
+	mockStreamL2Block(streamServerMock, l2Block)
+	streamServerMock.On("AddStreamEntry", state.EntryTypeL2Tx, mock.Anything).Return(uint64(0), nil).Once()
+
+	committed := make(chan struct{})
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { close(committed) })
+
+	go p.start()
+	dataToStream <- l2Block
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op not committed")
+	}
+}
+
+func TestStreamPipeline_start_AtomicOpTimeout(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	events := make(eventStorageChan, 1)
+	dataToStream := make(chan state.DSL2FullBlock)
+	cfg := StreamServerCfg{SkipIntermediateStateRoots: true, AtomicOpTimeout: cfgTypes.NewDuration(50 * time.Millisecond)}
+	p := newStreamPipeline(cfg, streamServerMock, stMock, event.NewEventLog(event.Config{}, events), dataToStream)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+
+	// The commit stalls past the timeout, it's waited for and the atomic op is neither rolled back nor retried
+	stalled := make(chan time.Time)
+	committed := make(chan struct{})
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	streamServerMock.On("AddStreamBookmark", mock.Anything).Return(uint64(0), nil).Twice()
+	streamServerMock.On("AddStreamEntry", mock.Anything, mock.Anything).Return(uint64(0), nil).Twice()
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().WaitUntil(stalled).Run(func(args mock.Arguments) { close(committed) })
+
+	go p.start()
+	dataToStream <- l2Block
+
+	select {
+	case e := <-events:
+		assert.Equal(t, event.EventID_DataStreamerAtomicOpTimeout, e.EventID)
+		assert.Equal(t, event.Level_Error, e.Level)
+		assert.Contains(t, e.Description, "l2blocks 1 to 1")
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op timeout event not logged")
+	}
+
+	close(stalled)
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op not committed")
+	}
+}
+
+func TestStreamPipeline_commitAtomicOp_Timeout(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	events := make(eventStorageChan, 1)
+	p := newStreamPipeline(StreamServerCfg{AtomicOpTimeout: cfgTypes.NewDuration(10 * time.Millisecond)}, streamServerMock, nil, event.NewEventLog(event.Config{}, events), nil)
+
+	// A stalled commit is waited for and its result returned
+	stalled := make(chan time.Time)
+	streamServerMock.On("CommitAtomicOp").Return(errors.New("stalled commit error")).Once().WaitUntil(stalled)
+	result := make(chan error, 1)
+	go func() {
+		result <- p.commitAtomicOp("probe")
+	}()
+
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op timeout event not logged")
+	}
+	assert.Empty(t, result)
+
+	close(stalled)
+	select {
+	case err := <-result:
+		require.EqualError(t, err, "stalled commit error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled commit not waited for")
+	}
+
+	// A commit finishing in time returns its result
+	streamServerMock.On("CommitAtomicOp").Return(errors.New("commit error")).Once()
+	require.EqualError(t, p.commitAtomicOp("probe"), "commit error")
+}
+
+func TestStreamPipeline_start_RollbackRetriesExhausted(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	dataToStream := make(chan state.DSL2FullBlock)
+	p := newStreamPipeline(StreamServerCfg{}, streamServerMock, stMock, nil, dataToStream)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+
+	// All the attempts fail starting the atomic op
+	streamServerMock.On("StartAtomicOp").Return(errors.New("start atomic op error")).Times(streamAtomicOpMaxRetries + 1)
+
+	rollbacks := make(chan struct{}, streamAtomicOpMaxRetries+1)
+	streamServerMock.On("RollbackAtomicOp").Return(nil).Times(streamAtomicOpMaxRetries + 1).Run(func(args mock.Arguments) { rollbacks <- struct{}{} })
+
+	go p.start()
+	dataToStream <- l2Block
+
+	for i := 0; i < streamAtomicOpMaxRetries+1; i++ {
+		select {
+		case <-rollbacks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("atomic op not rolled back")
+		}
+	}
+
+	// After all the retries fail the next L2 blocks are discarded
+	dataToStream <- newTestL2FullBlock(1, 2, 1)
+	streamServerMock.AssertNotCalled(t, "CommitAtomicOp")
+	assert.Nil(t, p.streamServer)
+}
+
+func TestStreamPipeline_start_ReconnectQuietPeriod(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	dataToStream := make(chan state.DSL2FullBlock)
+	p := newStreamPipeline(StreamServerCfg{SkipIntermediateStateRoots: true, ReconnectQuietPeriod: cfgTypes.NewDuration(10 * time.Millisecond)}, streamServerMock, nil, nil, dataToStream)
+
+	l2Block := newTestL2FullBlock(1, 1, 0)
+
+	// All the attempts fail starting the atomic op and so does the first probe
+	streamServerMock.On("StartAtomicOp").Return(errors.New("start atomic op error")).Times(streamAtomicOpMaxRetries + 2)
+	streamServerMock.On("RollbackAtomicOp").Return(nil).Times(streamAtomicOpMaxRetries + 2)
+
+	// The second probe succeeds
+	probed := false
+	streamServerMock.On("StartAtomicOp").Return(nil).Twice()
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { probed = true })
+
+	// The L2 block is streamed only after the probe succeeds
+	mockStreamBatchBookmark(streamServerMock, 1)
+	streamServerMock.On("AddStreamBookmark", mock.Anything).Return(uint64(0), nil).Once().Run(func(args mock.Arguments) {
+		assert.True(t, probed)
+	})
+	streamServerMock.On("AddStreamEntry", mock.Anything, mock.Anything).Return(uint64(1), nil).Twice()
+	committed := make(chan struct{})
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { close(committed) })
+
+	go p.start()
+	dataToStream <- l2Block
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("atomic op not committed")
+	}
+	assert.NotNil(t, p.streamServer)
+}
+
+func TestStreamPipeline_start_PauseAndResume(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	dataToStream := make(chan state.DSL2FullBlock)
+	p := newStreamPipeline(StreamServerCfg{PauseBufferSize: 10, PauseBufferFullPolicy: PauseBufferFullPolicyBlock, SkipIntermediateStateRoots: true}, streamServerMock, stMock, nil, dataToStream)
+
+	p.pause()
+	go p.start()
+
+	// The L2 blocks are read from the channel but not streamed while paused
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 3; l2BlockNumber++ {
+		dataToStream <- newTestL2FullBlock(1, l2BlockNumber, 0)
+	}
+	streamServerMock.AssertNotCalled(t, "StartAtomicOp")
+
+	streamedBookmarks := make(chan []byte, 4)
+	streamServerMock.On("StartAtomicOp").Return(nil).Times(3)
+	streamServerMock.On("AddStreamBookmark", mock.Anything).Return(uint64(0), nil).Times(4).Run(func(args mock.Arguments) {
+		streamedBookmarks <- args.Get(0).([]byte)
+	})
+	streamServerMock.On("AddStreamEntry", mock.Anything, mock.Anything).Return(uint64(0), nil).Times(6)
+	streamServerMock.On("CommitAtomicOp").Return(nil).Times(3)
+
+	p.resume()
+
+	// The buffered L2 blocks are flushed in order, after the bookmark of their batch
+	select {
+	case bookMark := <-streamedBookmarks:
+		assert.Equal(t, state.DSBookMark{Type: state.BookMarkTypeBatch, L2BlockNumber: 1}.Encode(), bookMark)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch 1 bookmark not streamed")
+	}
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 3; l2BlockNumber++ {
+		select {
+		case bookMark := <-streamedBookmarks:
+			assert.Equal(t, state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: l2BlockNumber}.Encode(), bookMark)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("l2block %d not streamed", l2BlockNumber)
+		}
+	}
+}
+
+func TestStreamPipeline_start_PauseBufferFullDrop(t *testing.T) {
+	streamServerMock := NewDataStreamServerMock(t)
+	stMock := NewStateMock(t)
+	events := make(eventStorageChan, 1)
+	dataToStream := make(chan state.DSL2FullBlock)
+	p := newStreamPipeline(StreamServerCfg{PauseBufferSize: 1, PauseBufferFullPolicy: PauseBufferFullPolicyDrop, SkipIntermediateStateRoots: true}, streamServerMock, stMock, event.NewEventLog(event.Config{}, events), dataToStream)
+
+	p.pause()
+	go p.start()
+
+	// The L2 block 2 doesn't fit in the pause buffer and it's dropped
+	dataToStream <- newTestL2FullBlock(1, 1, 0)
+	dataToStream <- newTestL2FullBlock(1, 2, 0)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, event.EventID_DataStreamerL2BlockDropped, e.EventID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("l2block dropped event not emitted")
+	}
+
+	// Only the L2 block 1 is streamed after resuming
+	committed := make(chan struct{})
+	streamServerMock.On("StartAtomicOp").Return(nil).Once()
+	mockStreamBatchBookmark(streamServerMock, 1)
+	streamServerMock.On("AddStreamBookmark", state.DSBookMark{Type: state.BookMarkTypeL2Block, L2BlockNumber: 1}.Encode()).Return(uint64(0), nil).Once()
+	streamServerMock.On("AddStreamEntry", mock.Anything, mock.Anything).Return(uint64(0), nil).Twice()
+	streamServerMock.On("CommitAtomicOp").Return(nil).Once().Run(func(args mock.Arguments) { close(committed) })
+
+	p.resume()
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("l2block 1 not streamed")
+	}
+}
+
+func TestStreamPipeline_start_Regeneration(t *testing.T) {
+	streamServer := newTestStreamServer(t)
+	committed := make(chan struct{}, 5)
+	dataToStream := make(chan state.DSL2FullBlock)
+	cfg := StreamServerCfg{PauseBufferSize: 10, PauseBufferFullPolicy: PauseBufferFullPolicyBlock, SkipIntermediateStateRoots: true}
+	p := newStreamPipeline(cfg, committedStreamServer{streamServer, committed}, nil, nil, dataToStream)
+
+	p.regenerating.Store(true)
+	go p.start()
+
+	// The finalizer produces the L2 blocks 1 to 5 while the regeneration writes the L2 blocks 1 and 2 already stored in the state
+	l2Blocks := []state.DSL2FullBlock{}
+	for l2BlockNumber := uint64(1); l2BlockNumber <= 5; l2BlockNumber++ {
+		l2Blocks = append(l2Blocks, newTestL2FullBlock(1, l2BlockNumber, 1))
+	}
+	enqueued := make(chan struct{})
+	go func() {
+		for _, l2Block := range l2Blocks {
+			dataToStream <- l2Block
+		}
+		close(enqueued)
+	}()
+	require.NoError(t, newStreamPipeline(cfg, streamServer, nil, nil, nil).sendL2Blocks(l2Blocks[:2]))
+
+	select {
+	case <-enqueued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("l2blocks not read during the regeneration")
+	}
+	// The L2 blocks are held by the pipeline until the regeneration ends
+	assert.Empty(t, committed)
+
+	p.restoreLastStreamed(streamServer)
+	p.endRegeneration()
+
+	// Only the L2 blocks not written by the regeneration are streamed
+	for l2BlockNumber := 3; l2BlockNumber <= 5; l2BlockNumber++ {
+		select {
+		case <-committed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("l2block %d not streamed", l2BlockNumber)
+		}
+	}
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, streamedL2BlockNumbers(t, streamServer))
+}
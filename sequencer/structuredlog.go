@@ -0,0 +1,49 @@
+package sequencer
+
+import (
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// The key lifecycle events of the sequencer are logged with structured fields, so they can be parsed when the log is
+// encoded as JSON (Log.Encoding = "json"). The names of the events and fields are stable
+const (
+	// LogFieldEvent is the field with the name of the event
+	LogFieldEvent = "event"
+
+	// LogEventSynced is logged once the state is synced with L1 when the sequencer starts
+	LogEventSynced = "sequencer_synced"
+	// LogEventStreamingStarted is logged once the data stream server is started and its file is updated
+	LogEventStreamingStarted = "streaming_started"
+	// LogEventL2BlockStreamed is logged for each L2 block committed to the data stream
+	LogEventL2BlockStreamed = "l2block_streamed"
+	// LogEventTxDropped is logged for each tx dropped by the sequencer
+	LogEventTxDropped = "tx_dropped"
+	// LogEventFinalizerHalted is logged when the finalizer is halted
+	LogEventFinalizerHalted = "finalizer_halted"
+
+	// LogFieldMode is the mode of the sequencer
+	LogFieldMode = "mode"
+	// LogFieldBatchNumber is the number of a batch
+	LogFieldBatchNumber = "batchNumber"
+	// LogFieldL2BlockNumber is the number of a L2 block
+	LogFieldL2BlockNumber = "l2BlockNumber"
+	// LogFieldTxs is the number of txs
+	LogFieldTxs = "txs"
+	// LogFieldTxHash is the hash of a tx
+	LogFieldTxHash = "txHash"
+	// LogFieldPhase is the phase where a tx was dropped
+	LogFieldPhase = "phase"
+	// LogFieldReason is the reason of the event
+	LogFieldReason = "reason"
+	// LogFieldReasons are the active halt reasons of the finalizer
+	LogFieldReasons = "reasons"
+	// LogFieldEntries is the number of entries of the data stream
+	LogFieldEntries = "entries"
+	// LogFieldPort is the port of a server
+	LogFieldPort = "port"
+)
+
+// logStructuredEvent logs the event at info level with msg and the fields given as key-value pairs
+func logStructuredEvent(name string, msg string, kv ...interface{}) {
+	log.Infow(msg, append([]interface{}{LogFieldEvent, name}, kv...)...)
+}
@@ -0,0 +1,74 @@
+package sequencer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureJSONLogs sends the logs encoded as JSON to a file until the test ends. It returns a function that reads the
+// log lines of the given event written so far
+func captureJSONLogs(t *testing.T) func(event string) []map[string]interface{} {
+	logFile := filepath.Join(t.TempDir(), "sequencer.log")
+	log.Init(log.Config{Environment: log.EnvironmentDevelopment, Level: "info", Outputs: []string{logFile}, Encoding: log.EncodingJSON})
+	t.Cleanup(func() {
+		log.Init(log.Config{Environment: log.EnvironmentDevelopment, Level: "debug", Outputs: []string{"stderr"}})
+	})
+
+	return func(event string) []map[string]interface{} {
+		file, err := os.Open(logFile)
+		require.NoError(t, err)
+		defer file.Close()
+
+		lines := []map[string]interface{}{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			if line[LogFieldEvent] == event {
+				lines = append(lines, line)
+			}
+		}
+		require.NoError(t, scanner.Err())
+		return lines
+	}
+}
+
+func TestLogStructuredEvent(t *testing.T) {
+	readEvents := captureJSONLogs(t)
+
+	s, _, _ := newTestSequencer(t, Config{DropRecordsSize: 10})
+	fake := &fakeFinalizer{halted: make(chan error, 1)}
+	s.finalizer = fake
+
+	txHash := common.HexToHash("0x1")
+	s.recordDrop(txHash, DropPhaseExpire, "expired")
+	s.HaltFinalizer(errors.New("halt reason"))
+	select {
+	case <-fake.halted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalizer not halted")
+	}
+
+	drops := readEvents(LogEventTxDropped)
+	require.Len(t, drops, 1)
+	assert.Equal(t, "tx dropped", drops[0]["msg"])
+	assert.Equal(t, txHash.String(), drops[0][LogFieldTxHash])
+	assert.Equal(t, string(DropPhaseExpire), drops[0][LogFieldPhase])
+	assert.Equal(t, "expired", drops[0][LogFieldReason])
+
+	halts := readEvents(LogEventFinalizerHalted)
+	require.Len(t, halts, 1)
+	assert.Equal(t, "info", halts[0]["level"])
+	assert.Equal(t, "halt reason", halts[0][LogFieldReason])
+	assert.Contains(t, halts[0], LogFieldReasons)
+}
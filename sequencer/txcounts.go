@@ -0,0 +1,38 @@
+package sequencer
+
+import (
+	"context"
+	"fmt"
+)
+
+// TxCounts is the breakdown of the txs pending to be sequenced
+type TxCounts struct {
+	// WorkerTxs is the number of txs (ready and notReady) stored in the worker
+	WorkerTxs uint64
+	// PoolWIPTxs is the number of pending txs of the pool that are WIP (loaded in the worker)
+	PoolWIPTxs uint64
+	// PoolNonWIPTxs is the number of pending txs of the pool that are not WIP (not loaded yet)
+	PoolNonWIPTxs uint64
+}
+
+// TxCounts returns the number of txs in the worker and the number of WIP and non WIP pending txs in the pool.
+// It's read-only and cheap enough to be polled periodically. If the sequencer is not started the worker count is 0
+func (s *Sequencer) TxCounts(ctx context.Context) (TxCounts, error) {
+	var counts TxCounts
+	if s.worker != nil {
+		counts.WorkerTxs = uint64(s.worker.CountTxs())
+	}
+
+	var err error
+	counts.PoolWIPTxs, err = s.pool.CountPendingTxsByWIPStatus(ctx, true)
+	if err != nil {
+		return TxCounts{}, fmt.Errorf("failed to count WIP pending txs in the pool, error: %w", err)
+	}
+
+	counts.PoolNonWIPTxs, err = s.pool.CountPendingTxsByWIPStatus(ctx, false)
+	if err != nil {
+		return TxCounts{}, fmt.Errorf("failed to count non WIP pending txs in the pool, error: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,34 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer_TxCounts(t *testing.T) {
+	ctx := context.Background()
+	s, txPoolMock, stMock := newTestSequencer(t, Config{})
+	mockTestSenderAccount(t, stMock, 0)
+
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx := newTestPoolTx(t, nonce, 21000)
+		txPoolMock.On("UpdateTxWIPStatus", ctx, tx.Hash(), true).Return(nil).Once()
+		require.NoError(t, s.addTxToWorker(ctx, tx))
+	}
+
+	txPoolMock.On("CountPendingTxsByWIPStatus", ctx, true).Return(uint64(3), nil).Once()
+	txPoolMock.On("CountPendingTxsByWIPStatus", ctx, false).Return(uint64(5), nil).Once()
+
+	counts, err := s.TxCounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, TxCounts{WorkerTxs: 3, PoolWIPTxs: 3, PoolNonWIPTxs: 5}, counts)
+
+	txPoolMock.On("CountPendingTxsByWIPStatus", ctx, true).Return(uint64(0), errors.New("db error")).Once()
+
+	_, err = s.TxCounts(ctx)
+	require.Error(t, err)
+}
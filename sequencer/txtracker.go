@@ -30,10 +30,13 @@ type TxTracker struct {
 	EGPLog            state.EffectiveGasPriceLog
 	L1GasPrice        uint64
 	L2GasPrice        uint64
+	InclusionDeadline *time.Time              // If set, the tx is expired when the deadline is reached even if it's younger than TxLifetimeMax
+	InFlight          bool                    // Set while the finalizer is processing the tx, so it's not expired meanwhile
+	StorageChanges    []state.DSStorageChange // Storage slots changed by the tx, only set when the storage diffs are streamed
 }
 
 // newTxTracker creates and inti a TxTracker
-func newTxTracker(tx types.Transaction, counters state.ZKCounters, ip string) (*TxTracker, error) {
+func newTxTracker(tx types.Transaction, counters state.ZKCounters, ip string, inclusionDeadline *time.Time) (*TxTracker, error) {
 	addr, err := state.GetSender(tx)
 	if err != nil {
 		return nil, err
@@ -60,6 +63,7 @@ func newTxTracker(tx types.Transaction, counters state.ZKCounters, ip string) (*
 		RawTx:             rawTx,
 		ReceivedAt:        time.Now(),
 		IP:                ip,
+		InclusionDeadline: inclusionDeadline,
 		EffectiveGasPrice: new(big.Int).SetUint64(0),
 		EGPLog: state.EffectiveGasPriceLog{
 			ValueFinal:     new(big.Int).SetUint64(0),
@@ -74,6 +78,14 @@ func newTxTracker(tx types.Transaction, counters state.ZKCounters, ip string) (*
 	return txTracker, nil
 }
 
+// isExpired returns true if the tx has been in the worker for more than maxTime or its inclusion deadline has been reached
+func (tx *TxTracker) isExpired(maxTime time.Duration, now time.Time) bool {
+	if tx.InclusionDeadline != nil && !now.Before(*tx.InclusionDeadline) {
+		return true
+	}
+	return tx.ReceivedAt.Add(maxTime).Before(now)
+}
+
 // updateZKCounters updates the counters of the tx
 func (tx *TxTracker) updateZKCounters(counters state.ZKCounters) {
 	tx.BatchResources.ZKCounters = counters
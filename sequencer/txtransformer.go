@@ -0,0 +1,20 @@
+package sequencer
+
+import (
+	"github.com/0xPolygonHermez/zkevm-node/pool"
+)
+
+// TxTransformer rewrites/normalizes the pool txs before they are added to the worker (e.g. to cap the gas limit
+// or to enforce a chain-specific rule). If Transform returns an error the tx is dropped and set as failed in the pool.
+// Note that changing a signed field of the tx (nonce, gas, gasPrice, etc.) also changes the tx hash and its sender.
+type TxTransformer interface {
+	Transform(tx pool.Transaction) (pool.Transaction, error)
+}
+
+// identityTxTransformer is the default TxTransformer, it returns the tx without changes
+type identityTxTransformer struct{}
+
+// Transform returns the tx without changes
+func (identityTxTransformer) Transform(tx pool.Transaction) (pool.Transaction, error) {
+	return tx, nil
+}
@@ -15,7 +15,15 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-// Worker represents the worker component of the sequencer
+const (
+	// waitEmptyCheckInterval is the time between the checks of the number of txs in the worker while waiting for it to be empty
+	waitEmptyCheckInterval = 100 * time.Millisecond
+)
+
+// Worker represents the worker component of the sequencer. It's accessed concurrently by the finalizer and the
+// loadFromPool and expireOldWorkerTxs loops of the sequencer, so all its exported methods are synchronized with
+// workerMutex (NewTxTracker and WaitEmpty don't access the worker data directly). The pool and txSortedList fields
+// must not be accessed outside the worker methods
 type Worker struct {
 	pool             map[string]*addrQueue
 	txSortedList     *txSortedList
@@ -37,8 +45,8 @@ func NewWorker(state stateInterface, constraints state.BatchConstraintsCfg) *Wor
 }
 
 // NewTxTracker creates and inits a TxTracker
-func (w *Worker) NewTxTracker(tx types.Transaction, counters state.ZKCounters, ip string) (*TxTracker, error) {
-	return newTxTracker(tx, counters, ip)
+func (w *Worker) NewTxTracker(tx types.Transaction, counters state.ZKCounters, ip string, inclusionDeadline *time.Time) (*TxTracker, error) {
+	return newTxTracker(tx, counters, ip, inclusionDeadline)
 }
 
 // AddTxTracker adds a new Tx to the Worker
@@ -51,6 +59,12 @@ func (w *Worker) AddTxTracker(ctx context.Context, tx *TxTracker) (replacedTx *T
 		return nil, pool.ErrInvalidIP
 	}
 
+	// Make sure the inclusion deadline of the tx has not been reached yet
+	if tx.InclusionDeadline != nil && !time.Now().Before(*tx.InclusionDeadline) {
+		w.workerMutex.Unlock()
+		return nil, ErrExpiredTransaction
+	}
+
 	// Make sure the transaction's batch resources are within the constraints.
 	if !w.batchConstraints.IsWithinConstraints(tx.BatchResources.ZKCounters) {
 		log.Errorf("outOfCounters error (node level) for tx %s", tx.Hash.String())
@@ -82,13 +96,16 @@ func (w *Worker) AddTxTracker(ctx context.Context, tx *TxTracker) (replacedTx *T
 			return nil, dropReason
 		}
 
-		addr = newAddrQueue(tx.From, nonce.Uint64(), balance)
-
 		// Lock again the worker
 		w.workerMutex.Lock()
 
-		w.pool[tx.FromStr] = addr
-		log.Debugf("new addrQueue %s created (nonce: %d, balance: %s)", tx.FromStr, nonce.Uint64(), balance.String())
+		// The addrQueue may have been created while the worker was unlocked
+		addr, found = w.pool[tx.FromStr]
+		if !found {
+			addr = newAddrQueue(tx.From, nonce.Uint64(), balance)
+			w.pool[tx.FromStr] = addr
+			log.Debugf("new addrQueue %s created (nonce: %d, balance: %s)", tx.FromStr, nonce.Uint64(), balance.String())
+		}
 	}
 
 	// Add the txTracker to Addr and get the newReadyTx and prevReadyTx
@@ -285,6 +302,29 @@ func (w *Worker) DeletePendingTxToStore(txHash common.Hash, addr common.Address)
 	}
 }
 
+// SetTxInFlight marks a tx as being processed by the finalizer (in-flight), or unmarks it once it's processed. It's a no-op
+// if the tx is not in the worker (e.g. it was already deleted after being processed)
+func (w *Worker) SetTxInFlight(txHash common.Hash, addr common.Address, inFlight bool) {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	addrQueue, found := w.pool[addr.String()]
+	if !found {
+		return
+	}
+
+	if addrQueue.readyTx != nil && addrQueue.readyTx.Hash == txHash {
+		addrQueue.readyTx.InFlight = inFlight
+		return
+	}
+	for _, txTracker := range addrQueue.notReadyTxs {
+		if txTracker.Hash == txHash {
+			txTracker.InFlight = inFlight
+			return
+		}
+	}
+}
+
 // GetBestFittingTx gets the most efficient tx that fits in the available batch resources
 func (w *Worker) GetBestFittingTx(resources state.BatchResources) (*TxTracker, error) {
 	w.workerMutex.Lock()
@@ -345,8 +385,143 @@ func (w *Worker) GetBestFittingTx(resources state.BatchResources) (*TxTracker, e
 	}
 }
 
-// ExpireTransactions deletes old txs
-func (w *Worker) ExpireTransactions(maxTime time.Duration) []*TxTracker {
+// CountTxs returns the number of txs (ready and notReady) stored in the worker
+func (w *Worker) CountTxs() int {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	count := 0
+	for _, addrQueue := range w.pool {
+		count += addrQueue.countTxs()
+	}
+
+	return count
+}
+
+// CountBytes returns the approximate number of bytes held by the txs (ready and notReady) stored in the worker,
+// computed as the size of their raw txs
+func (w *Worker) CountBytes() uint64 {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	bytes := uint64(0)
+	for _, addrQueue := range w.pool {
+		bytes += addrQueue.countBytes()
+	}
+
+	return bytes
+}
+
+// PendingNonces returns the lowest nonce of the txs (ready and notReady) stored in the worker for each sender.
+// The senders without txs in the worker are not included
+func (w *Worker) PendingNonces() map[common.Address]uint64 {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	nonces := make(map[common.Address]uint64, len(w.pool))
+	for _, addrQueue := range w.pool {
+		if nonce, ok := addrQueue.lowestNonce(); ok {
+			nonces[addrQueue.from] = nonce
+		}
+	}
+
+	return nonces
+}
+
+// WorkerAddrQueueSnapshot is a copy of the state of an addrQueue of the worker
+type WorkerAddrQueueSnapshot struct {
+	From           common.Address
+	CurrentNonce   uint64
+	CurrentBalance *big.Int
+	// ReadyTx is the hash of the readyTx, nil if there isn't one
+	ReadyTx     *common.Hash
+	NotReadyTxs int
+}
+
+// WorkerSnapshot is a copy of the state of the worker that can be read without synchronization
+type WorkerSnapshot struct {
+	AddrQueues []WorkerAddrQueueSnapshot
+	// ReadyTxs is the number of txs in the txSortedList
+	ReadyTxs int
+}
+
+// Snapshot returns a copy of the state of the addrQueues of the worker
+func (w *Worker) Snapshot() WorkerSnapshot {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	snapshot := WorkerSnapshot{
+		AddrQueues: make([]WorkerAddrQueueSnapshot, 0, len(w.pool)),
+		ReadyTxs:   w.txSortedList.len(),
+	}
+	for _, addrQueue := range w.pool {
+		addrQueueSnapshot := WorkerAddrQueueSnapshot{
+			From:           addrQueue.from,
+			CurrentNonce:   addrQueue.currentNonce,
+			CurrentBalance: new(big.Int).Set(addrQueue.currentBalance),
+			NotReadyTxs:    len(addrQueue.notReadyTxs),
+		}
+		if addrQueue.readyTx != nil {
+			readyTx := addrQueue.readyTx.Hash
+			addrQueueSnapshot.ReadyTx = &readyTx
+		}
+		snapshot.AddrQueues = append(snapshot.AddrQueues, addrQueueSnapshot)
+	}
+
+	return snapshot
+}
+
+// WaitEmpty waits until there are no txs (ready and notReady) stored in the worker. It returns an error if ctx is done before
+func (w *Worker) WaitEmpty(ctx context.Context) error {
+	for w.CountTxs() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitEmptyCheckInterval):
+		}
+	}
+	return nil
+}
+
+// CountTxsOlderThan returns the number of txs (ready and notReady) stored in the worker for more than age
+func (w *Worker) CountTxsOlderThan(age time.Duration) int {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	count := 0
+	for _, addrQueue := range w.pool {
+		count += addrQueue.countTxsOlderThan(age)
+	}
+
+	return count
+}
+
+// Clear deletes the txs (ready and notReady) stored in the worker, except the ones marked as in-flight with SetTxInFlight.
+// The forced txs and the txs pending to store are kept. It returns the txs deleted
+func (w *Worker) Clear() []*TxTracker {
+	w.workerMutex.Lock()
+	defer w.workerMutex.Unlock()
+
+	var txs []*TxTracker
+	for _, addrQueue := range w.pool {
+		subTxs, prevReadyTx := addrQueue.clear()
+		txs = append(txs, subTxs...)
+
+		if prevReadyTx != nil {
+			w.txSortedList.delete(prevReadyTx)
+		}
+
+		if addrQueue.IsEmpty() {
+			delete(w.pool, addrQueue.fromStr)
+		}
+	}
+	log.Debugf("worker cleared, txs deleted: %d", len(txs))
+
+	return txs
+}
+
+// ExpireTransactions deletes old txs. If skipInFlight is true the txs marked as in-flight with SetTxInFlight are kept
+func (w *Worker) ExpireTransactions(maxTime time.Duration, skipInFlight bool) []*TxTracker {
 	w.workerMutex.Lock()
 	defer w.workerMutex.Unlock()
 
@@ -354,7 +529,7 @@ func (w *Worker) ExpireTransactions(maxTime time.Duration) []*TxTracker {
 
 	log.Debugf("expire transactions started, addrQueue length: %d", len(w.pool))
 	for _, addrQueue := range w.pool {
-		subTxs, prevReadyTx := addrQueue.ExpireTransactions(maxTime)
+		subTxs, prevReadyTx := addrQueue.ExpireTransactions(maxTime, skipInFlight)
 		txs = append(txs, subTxs...)
 
 		if prevReadyTx != nil {
@@ -3,12 +3,16 @@ package sequencer
 import (
 	"context"
 	"math/big"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/0xPolygonHermez/zkevm-node/pool"
 	"github.com/0xPolygonHermez/zkevm-node/state"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -292,3 +296,114 @@ func initWorker(stateMock *StateMock, rcMax state.BatchConstraintsCfg) *Worker {
 	worker := NewWorker(stateMock, rcMax)
 	return worker
 }
+
+func TestWorker_ConcurrentAccess(t *testing.T) {
+	const (
+		senders      = 4
+		txsPerSender = 50
+	)
+
+	ctx := context.Background()
+	stateMock := NewStateMock(t)
+	worker := NewWorker(stateMock, rcMax)
+
+	stateMock.On("GetLastStateRoot", ctx, nil).Return(common.Hash{0}, nil)
+	stateMock.On("GetNonceByStateRoot", ctx, mock.Anything, common.Hash{0}).Return(big.NewInt(0), nil)
+	stateMock.On("GetBalanceByStateRoot", ctx, mock.Anything, common.Hash{0}).Return(big.NewInt(1000), nil)
+
+	// Each sender adds its txs while the txs are expired, counted and the worker snapshots are taken
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(sender int) {
+			defer wg.Done()
+			from := common.Address{byte(sender + 1)}
+			for nonce := uint64(0); nonce < txsPerSender; nonce++ {
+				hash := common.BigToHash(big.NewInt(int64(sender*txsPerSender) + int64(nonce) + 1))
+				tx := &TxTracker{
+					Hash:       hash,
+					HashStr:    hash.String(),
+					From:       from,
+					FromStr:    from.String(),
+					Nonce:      nonce,
+					GasPrice:   big.NewInt(int64(nonce + 1)),
+					Cost:       big.NewInt(1),
+					ReceivedAt: time.Now().Add(-time.Duration(nonce%2) * time.Hour),
+				}
+				_, err := worker.AddTxTracker(ctx, tx)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			worker.ExpireTransactions(time.Minute, false)
+			worker.CountTxsOlderThan(time.Minute)
+			for _, addrQueue := range worker.Snapshot().AddrQueues {
+				assert.NotNil(t, addrQueue.CurrentBalance)
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	// Only one addrQueue is created per sender, and after the last expiration only the recent txs (even nonces) are kept
+	worker.ExpireTransactions(time.Minute, false)
+	snapshot := worker.Snapshot()
+	require.Len(t, snapshot.AddrQueues, senders)
+	assert.Equal(t, senders*txsPerSender/2, worker.CountTxs())
+	assert.Equal(t, 0, worker.CountTxsOlderThan(time.Minute))
+	assert.Equal(t, senders, snapshot.ReadyTxs)
+}
+
+func TestWorker_PendingNonces(t *testing.T) {
+	ctx := context.Background()
+	stateMock := NewStateMock(t)
+	worker := NewWorker(stateMock, rcMax)
+
+	sender1 := common.Address{1}
+	sender2 := common.Address{2}
+	sender3 := common.Address{3}
+	stateMock.On("GetLastStateRoot", ctx, nil).Return(common.Hash{0}, nil)
+	stateMock.On("GetNonceByStateRoot", ctx, sender1, common.Hash{0}).Return(big.NewInt(0), nil)
+	stateMock.On("GetNonceByStateRoot", ctx, sender2, common.Hash{0}).Return(big.NewInt(3), nil)
+	stateMock.On("GetNonceByStateRoot", ctx, sender3, common.Hash{0}).Return(big.NewInt(0), nil)
+	stateMock.On("GetBalanceByStateRoot", ctx, mock.Anything, common.Hash{0}).Return(big.NewInt(1000), nil)
+
+	// sender1 has a ready tx, sender2 only notReady txs with a nonce gap and sender3 has no txs left
+	for i, tx := range []struct {
+		from  common.Address
+		nonce uint64
+	}{
+		{sender1, 1}, {sender1, 0}, {sender1, 2},
+		{sender2, 7}, {sender2, 5},
+		{sender3, 0},
+	} {
+		hash := common.BigToHash(big.NewInt(int64(i + 1)))
+		_, err := worker.AddTxTracker(ctx, &TxTracker{
+			Hash:     hash,
+			HashStr:  hash.String(),
+			From:     tx.from,
+			FromStr:  tx.from.String(),
+			Nonce:    tx.nonce,
+			GasPrice: big.NewInt(1),
+			Cost:     big.NewInt(1),
+		})
+		require.NoError(t, err)
+	}
+	worker.DeleteTx(common.BigToHash(big.NewInt(6)), sender3)
+
+	assert.Equal(t, map[common.Address]uint64{sender1: 0, sender2: 5}, worker.PendingNonces())
+}
@@ -83,6 +83,16 @@ func (s *State) ProcessBatchV2(ctx context.Context, request ProcessRequest, upda
 		processBatchRequest.SkipVerifyL1InfoRoot = cTrue
 	}
 
+	if request.StorageTraceTxHash_V2 != nil {
+		processBatchRequest.TraceConfig = &executor.TraceConfigV2{
+			TxHashToGenerateFullTrace: request.StorageTraceTxHash_V2.Bytes(),
+			DisableStorage:            cFalse,
+			DisableStack:              cFalse,
+			EnableMemory:              cFalse,
+			EnableReturnData:          cFalse,
+		}
+	}
+
 	res, err := s.sendBatchRequestToExecutorV2(ctx, processBatchRequest, request.Caller)
 	if err != nil {
 		return nil, err
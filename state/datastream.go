@@ -3,11 +3,14 @@ package state
 import (
 	"context"
 	"encoding/binary"
+	"fmt"
 	"math/big"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state/runtime/instrumentation"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/iden3/go-iden3-crypto/keccak256"
 	"github.com/jackc/pgx/v4"
 )
@@ -25,8 +28,59 @@ const (
 	EntryTypeL2BlockEnd datastreamer.EntryType = 3
 	// EntryTypeUpdateGER represents a GER update
 	EntryTypeUpdateGER datastreamer.EntryType = 4
+	// EntryTypeL2TxWithMetadata represents a L2 transaction with its decoded metadata
+	EntryTypeL2TxWithMetadata datastreamer.EntryType = 5
+	// EntryTypeBatchStart represents the start of a batch
+	EntryTypeBatchStart datastreamer.EntryType = 6
+	// EntryTypeBatchEnd represents the end of a batch
+	EntryTypeBatchEnd datastreamer.EntryType = 7
+	// EntryTypeL2BlockStartMasked represents a L2 block start including only a subset of its fields
+	EntryTypeL2BlockStartMasked datastreamer.EntryType = 8
+	// EntryTypeL2BlockFinality represents an update of the finality of the L2 blocks
+	EntryTypeL2BlockFinality datastreamer.EntryType = 9
+	// EntryTypeL2TxWithSender represents a L2 transaction with the address of its sender
+	EntryTypeL2TxWithSender datastreamer.EntryType = 10
+	// EntryTypeStreamSchema represents the schema header of the data stream, its first entry
+	EntryTypeStreamSchema datastreamer.EntryType = 11
+	// EntryTypeL2BlockStartWithL1InfoRoot represents a L2 block start with the L1 info root used to process the L2 block
+	EntryTypeL2BlockStartWithL1InfoRoot datastreamer.EntryType = 12
+	// EntryTypeL2BlockStorageDiff represents the storage slots changed by the txs of a L2 block
+	EntryTypeL2BlockStorageDiff datastreamer.EntryType = 13
+	// EntryTypeL2BlockCheckpoint represents a checkpoint accumulating the hashes of the L2 blocks since the previous checkpoint
+	EntryTypeL2BlockCheckpoint datastreamer.EntryType = 14
+	// EntryTypeL2BlockBloom represents the bloom filter of the logs of a L2 block
+	EntryTypeL2BlockBloom datastreamer.EntryType = 15
+	// EntryTypeL2BlockStartProto represents a L2 block start encoded as a L2BlockStart protobuf message
+	EntryTypeL2BlockStartProto datastreamer.EntryType = 16
+	// EntryTypeL2TxProto represents a L2 transaction encoded as a L2Transaction protobuf message
+	EntryTypeL2TxProto datastreamer.EntryType = 17
+	// EntryTypeL2BlockEndProto represents a L2 block end encoded as a L2BlockEnd protobuf message
+	EntryTypeL2BlockEndProto datastreamer.EntryType = 18
+	// DSL2TransactionMetadataVersion is the version of the encoding of DSL2TransactionWithMetadata. The version 2 adds the
+	// type and the chain id of the tx
+	DSL2TransactionMetadataVersion uint8 = 2
+	// DSL2BlockStartMaskedVersion is the version of the encoding of DSL2BlockStartMasked
+	DSL2BlockStartMaskedVersion uint8 = 1
+	// DSL2BlockFinalityVersion is the version of the encoding of DSL2BlockFinality
+	DSL2BlockFinalityVersion uint8 = 1
+	// DSL2TransactionSenderVersion is the version of the encoding of DSL2TransactionWithSender
+	DSL2TransactionSenderVersion uint8 = 1
+	// DSL2BlockStartL1InfoRootVersion is the version of the encoding of DSL2BlockStartWithL1InfoRoot
+	DSL2BlockStartL1InfoRootVersion uint8 = 1
+	// DSL2BlockStorageDiffVersion is the version of the encoding of DSL2BlockStorageDiff. The version 2 adds the flag marking
+	// the changes as unavailable
+	DSL2BlockStorageDiffVersion uint8 = 2
+	// DSL2BlockCheckpointVersion is the version of the encoding of DSL2BlockCheckpoint
+	DSL2BlockCheckpointVersion uint8 = 1
+	// DSL2BlockBloomVersion is the version of the encoding of DSL2BlockBloom
+	DSL2BlockBloomVersion uint8 = 1
+	// DSStreamSchemaVersion is the version of the data stream schema written by this build. A data stream file declaring
+	// another schema version is not compatible
+	DSStreamSchemaVersion uint8 = 1
 	// BookMarkTypeL2Block represents a L2 block bookmark
 	BookMarkTypeL2Block byte = 0
+	// BookMarkTypeBatch represents a batch bookmark
+	BookMarkTypeBatch byte = 1
 	// SystemSC is the system smart contract address
 	SystemSC = "0x000000000000000000000000000000005ca1ab1e"
 	// posConstant is the constant used to compute the position of the intermediate state root
@@ -61,6 +115,13 @@ type DSL2Block struct {
 	ForkID         uint16         // 2 bytes
 	BlockHash      common.Hash    // 32 bytes
 	StateRoot      common.Hash    // 32 bytes
+	L1InfoRoot     common.Hash    // 32 bytes, only included in the encoded data of DSL2BlockStartWithL1InfoRoot
+	// StorageChanges are the storage slots changed by the txs of the L2 block, only set when the storage diffs are streamed
+	StorageChanges []DSStorageChange
+	// StorageChangesUnavailable is set when the storage changes of some tx of the L2 block are not known
+	StorageChangesUnavailable bool
+	// Logs are the logs emitted by the txs of the L2 block, only set when the bloom filters are streamed
+	Logs []*types.Log
 }
 
 // DSL2BlockStart represents a data stream L2 block start
@@ -96,6 +157,113 @@ func (b DSL2BlockStart) Decode(data []byte) DSL2BlockStart {
 	return b
 }
 
+// DSL2BlockStartWithL1InfoRoot represents a data stream L2 block start with the L1 info root used to process the L2 block
+type DSL2BlockStartWithL1InfoRoot struct {
+	Version uint8 // 1 byte
+	DSL2BlockStart
+	L1InfoRoot common.Hash // 32 bytes
+}
+
+// NewDSL2BlockStartWithL1InfoRoot returns the L2 block start with the given L1 info root
+func NewDSL2BlockStartWithL1InfoRoot(blockStart DSL2BlockStart, l1InfoRoot common.Hash) DSL2BlockStartWithL1InfoRoot {
+	return DSL2BlockStartWithL1InfoRoot{
+		Version:        DSL2BlockStartL1InfoRootVersion,
+		DSL2BlockStart: blockStart,
+		L1InfoRoot:     l1InfoRoot,
+	}
+}
+
+// Encode returns the encoded DSL2BlockStartWithL1InfoRoot as a byte slice
+func (b DSL2BlockStartWithL1InfoRoot) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version)
+	bytes = append(bytes, b.DSL2BlockStart.Encode()...)
+	bytes = append(bytes, b.L1InfoRoot.Bytes()...)
+	return bytes
+}
+
+// Decode decodes the DSL2BlockStartWithL1InfoRoot from a byte slice
+func (b DSL2BlockStartWithL1InfoRoot) Decode(data []byte) DSL2BlockStartWithL1InfoRoot {
+	b.Version = data[0]
+	b.DSL2BlockStart = DSL2BlockStart{}.Decode(data[1:79])
+	b.L1InfoRoot = common.BytesToHash(data[79:111])
+	return b
+}
+
+// DSL2BlockStartFields is a mask of the optional fields of a L2 block start. The batch number, L2 block number and timestamp are always included
+type DSL2BlockStartFields uint8
+
+const (
+	// DSL2BlockStartFieldGlobalExitRoot selects the global exit root of the L2 block start
+	DSL2BlockStartFieldGlobalExitRoot DSL2BlockStartFields = 1 << iota
+	// DSL2BlockStartFieldCoinbase selects the coinbase of the L2 block start
+	DSL2BlockStartFieldCoinbase
+	// DSL2BlockStartFieldForkID selects the fork ID of the L2 block start
+	DSL2BlockStartFieldForkID
+	// DSL2BlockStartAllFields selects all the optional fields of the L2 block start
+	DSL2BlockStartAllFields = DSL2BlockStartFieldGlobalExitRoot | DSL2BlockStartFieldCoinbase | DSL2BlockStartFieldForkID
+)
+
+// DSL2BlockStartMasked represents a data stream L2 block start including only the optional fields selected by Fields.
+// The fields not selected are decoded with their zero value
+type DSL2BlockStartMasked struct {
+	Version uint8                // 1 byte
+	Fields  DSL2BlockStartFields // 1 byte
+	DSL2BlockStart
+}
+
+// NewDSL2BlockStartMasked returns the L2 block start including only the given fields
+func NewDSL2BlockStartMasked(blockStart DSL2BlockStart, fields DSL2BlockStartFields) DSL2BlockStartMasked {
+	return DSL2BlockStartMasked{
+		Version:        DSL2BlockStartMaskedVersion,
+		Fields:         fields & DSL2BlockStartAllFields,
+		DSL2BlockStart: blockStart,
+	}
+}
+
+// Encode returns the encoded DSL2BlockStartMasked as a byte slice
+func (b DSL2BlockStartMasked) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version, byte(b.Fields))
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.BatchNumber)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.L2BlockNumber)
+	bytes = binary.LittleEndian.AppendUint64(bytes, uint64(b.Timestamp))
+	if b.Fields&DSL2BlockStartFieldGlobalExitRoot != 0 {
+		bytes = append(bytes, b.GlobalExitRoot.Bytes()...)
+	}
+	if b.Fields&DSL2BlockStartFieldCoinbase != 0 {
+		bytes = append(bytes, b.Coinbase.Bytes()...)
+	}
+	if b.Fields&DSL2BlockStartFieldForkID != 0 {
+		bytes = binary.LittleEndian.AppendUint16(bytes, b.ForkID)
+	}
+	return bytes
+}
+
+// Decode decodes the DSL2BlockStartMasked from a byte slice
+func (b DSL2BlockStartMasked) Decode(data []byte) DSL2BlockStartMasked {
+	b.Version = data[0]
+	b.Fields = DSL2BlockStartFields(data[1])
+	b.DSL2BlockStart = DSL2BlockStart{
+		BatchNumber:   binary.LittleEndian.Uint64(data[2:10]),
+		L2BlockNumber: binary.LittleEndian.Uint64(data[10:18]),
+		Timestamp:     int64(binary.LittleEndian.Uint64(data[18:26])),
+	}
+	pos := 26
+	if b.Fields&DSL2BlockStartFieldGlobalExitRoot != 0 {
+		b.GlobalExitRoot = common.BytesToHash(data[pos : pos+32])
+		pos += 32
+	}
+	if b.Fields&DSL2BlockStartFieldCoinbase != 0 {
+		b.Coinbase = common.BytesToAddress(data[pos : pos+20])
+		pos += 20
+	}
+	if b.Fields&DSL2BlockStartFieldForkID != 0 {
+		b.ForkID = binary.LittleEndian.Uint16(data[pos : pos+2])
+	}
+	return b
+}
+
 // DSL2Transaction represents a data stream L2 transaction
 type DSL2Transaction struct {
 	L2BlockNumber               uint64      // Not included in the encoded data
@@ -104,6 +272,7 @@ type DSL2Transaction struct {
 	StateRoot                   common.Hash // 32 bytes
 	EncodedLength               uint32      // 4 bytes
 	Encoded                     []byte
+	From                        common.Address // Only included in the encoded data of DSL2TransactionWithSender
 }
 
 // Encode returns the encoded DSL2Transaction as a byte slice
@@ -127,6 +296,146 @@ func (l DSL2Transaction) Decode(data []byte) DSL2Transaction {
 	return l
 }
 
+// DSL2TransactionWithMetadata represents a data stream L2 transaction with the metadata decoded from the encoded tx
+type DSL2TransactionWithMetadata struct {
+	Version uint8 // 1 byte
+	DSL2Transaction
+	From  common.Address  // 20 bytes
+	To    *common.Address // 1 byte (flag) + 20 bytes, nil for contract creations
+	Nonce uint64          // 8 bytes
+	Value *big.Int        // 32 bytes
+	// The fields below are only included since the version 2, they are zero in the entries of the version 1
+	TxType  uint8    // 1 byte, types.LegacyTxType, types.AccessListTxType or types.DynamicFeeTxType
+	ChainID *big.Int // 32 bytes, 0 for the legacy txs without replay protection (pre-EIP-155)
+}
+
+// NewDSL2TransactionWithMetadata returns the L2 transaction with the metadata decoded from its encoded tx
+func NewDSL2TransactionWithMetadata(l2Transaction DSL2Transaction) (DSL2TransactionWithMetadata, error) {
+	tx := new(types.Transaction)
+	err := tx.UnmarshalBinary(l2Transaction.Encoded)
+	if err != nil {
+		return DSL2TransactionWithMetadata{}, err
+	}
+
+	from, err := getDSTransactionSender(tx)
+	if err != nil {
+		return DSL2TransactionWithMetadata{}, err
+	}
+
+	return DSL2TransactionWithMetadata{
+		Version:         DSL2TransactionMetadataVersion,
+		DSL2Transaction: l2Transaction,
+		From:            from,
+		To:              tx.To(),
+		Nonce:           tx.Nonce(),
+		Value:           tx.Value(),
+		TxType:          tx.Type(),
+		ChainID:         tx.ChainId(),
+	}, nil
+}
+
+// Encode returns the encoded DSL2TransactionWithMetadata as a byte slice
+func (l DSL2TransactionWithMetadata) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, l.Version)
+	bytes = append(bytes, l.DSL2Transaction.Encode()...)
+	bytes = append(bytes, l.From[:]...)
+	if l.To != nil {
+		bytes = append(bytes, 1)
+		bytes = append(bytes, l.To[:]...)
+	} else {
+		bytes = append(bytes, 0)
+		bytes = append(bytes, common.Address{}.Bytes()...)
+	}
+	bytes = binary.LittleEndian.AppendUint64(bytes, l.Nonce)
+	bytes = append(bytes, common.BigToHash(l.Value).Bytes()...)
+	if l.Version >= 2 {
+		bytes = append(bytes, l.TxType)
+		chainID := l.ChainID
+		if chainID == nil {
+			chainID = big.NewInt(0)
+		}
+		bytes = append(bytes, common.BigToHash(chainID).Bytes()...)
+	}
+	return bytes
+}
+
+// Decode decodes the DSL2TransactionWithMetadata from a byte slice. The entries of the version 1 are decoded without
+// type and chain id
+func (l DSL2TransactionWithMetadata) Decode(data []byte) DSL2TransactionWithMetadata {
+	l.Version = data[0]
+	encodedLength := binary.LittleEndian.Uint32(data[35:39])
+	metadataStart := 39 + encodedLength
+	l.DSL2Transaction = DSL2Transaction{}.Decode(data[1:metadataStart])
+	l.From = common.BytesToAddress(data[metadataStart : metadataStart+20])
+	if data[metadataStart+20] == 1 {
+		to := common.BytesToAddress(data[metadataStart+21 : metadataStart+41])
+		l.To = &to
+	} else {
+		l.To = nil
+	}
+	l.Nonce = binary.LittleEndian.Uint64(data[metadataStart+41 : metadataStart+49])
+	l.Value = new(big.Int).SetBytes(data[metadataStart+49 : metadataStart+81])
+	if l.Version >= 2 {
+		l.TxType = data[metadataStart+81]
+		l.ChainID = new(big.Int).SetBytes(data[metadataStart+82 : metadataStart+114])
+	}
+	return l
+}
+
+// getDSTransactionSender returns the sender of the tx, supporting the legacy txs with and without replay protection
+// (pre-EIP-155) and the typed txs (EIP-2930 and EIP-1559)
+func getDSTransactionSender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
+
+// DSL2TransactionWithSender represents a data stream L2 transaction with the address of its sender
+type DSL2TransactionWithSender struct {
+	Version uint8 // 1 byte
+	DSL2Transaction
+}
+
+// NewDSL2TransactionWithSender returns the L2 transaction with its sender. If the sender of the L2 transaction is not set
+// it's recovered from the encoded tx
+func NewDSL2TransactionWithSender(l2Transaction DSL2Transaction) (DSL2TransactionWithSender, error) {
+	if l2Transaction.From == (common.Address{}) {
+		tx := new(types.Transaction)
+		err := tx.UnmarshalBinary(l2Transaction.Encoded)
+		if err != nil {
+			return DSL2TransactionWithSender{}, err
+		}
+
+		l2Transaction.From, err = getDSTransactionSender(tx)
+		if err != nil {
+			return DSL2TransactionWithSender{}, err
+		}
+	}
+
+	return DSL2TransactionWithSender{
+		Version:         DSL2TransactionSenderVersion,
+		DSL2Transaction: l2Transaction,
+	}, nil
+}
+
+// Encode returns the encoded DSL2TransactionWithSender as a byte slice
+func (l DSL2TransactionWithSender) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, l.Version)
+	bytes = append(bytes, l.DSL2Transaction.Encode()...)
+	bytes = append(bytes, l.From[:]...)
+	return bytes
+}
+
+// Decode decodes the DSL2TransactionWithSender from a byte slice
+func (l DSL2TransactionWithSender) Decode(data []byte) DSL2TransactionWithSender {
+	l.Version = data[0]
+	encodedLength := binary.LittleEndian.Uint32(data[35:39])
+	senderStart := 39 + encodedLength
+	l.DSL2Transaction = DSL2Transaction{}.Decode(data[1:senderStart])
+	l.From = common.BytesToAddress(data[senderStart : senderStart+20])
+	return l
+}
+
 // DSL2BlockEnd represents a L2 block end
 type DSL2BlockEnd struct {
 	L2BlockNumber uint64      // 8 bytes
@@ -151,9 +460,325 @@ func (b DSL2BlockEnd) Decode(data []byte) DSL2BlockEnd {
 	return b
 }
 
+// L2BlockFinality is the finality status of a L2 block
+type L2BlockFinality uint8
+
+const (
+	// L2BlockFinalityTrusted is the finality of the L2 blocks closed by the trusted sequencer, the finality of all the L2 blocks streamed
+	L2BlockFinalityTrusted L2BlockFinality = 0
+	// L2BlockFinalitySafe is the finality of the L2 blocks of the batches sequenced (virtualized) in L1
+	L2BlockFinalitySafe L2BlockFinality = 1
+	// L2BlockFinalityFinalized is the finality of the L2 blocks of the batches verified (consolidated) in L1
+	L2BlockFinalityFinalized L2BlockFinality = 2
+)
+
+// String returns the name of the finality status
+func (f L2BlockFinality) String() string {
+	switch f {
+	case L2BlockFinalityTrusted:
+		return "trusted"
+	case L2BlockFinalitySafe:
+		return "safe"
+	case L2BlockFinalityFinalized:
+		return "finalized"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(f))
+}
+
+// DSStreamSchema represents the schema header of the data stream, declaring its schema version and the entry types emitted
+type DSStreamSchema struct {
+	Version    uint8                    // 1 byte
+	EntryTypes []datastreamer.EntryType // 1 byte (count) + 4 bytes per entry type
+}
+
+// Encode returns the encoded DSStreamSchema as a byte slice
+func (s DSStreamSchema) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, s.Version, byte(len(s.EntryTypes)))
+	for _, entryType := range s.EntryTypes {
+		bytes = binary.LittleEndian.AppendUint32(bytes, uint32(entryType))
+	}
+	return bytes
+}
+
+// Decode decodes the DSStreamSchema from a byte slice
+func (s DSStreamSchema) Decode(data []byte) DSStreamSchema {
+	s.Version = data[0]
+	s.EntryTypes = make([]datastreamer.EntryType, 0, data[1])
+	for pos := 2; pos+4 <= len(data) && len(s.EntryTypes) < int(data[1]); pos += 4 {
+		s.EntryTypes = append(s.EntryTypes, datastreamer.EntryType(binary.LittleEndian.Uint32(data[pos:pos+4])))
+	}
+	return s
+}
+
+// DSL2BlockFinality represents an update of the finality of the L2 blocks. All the L2 blocks up to L2BlockNumber
+// (included) have at least the finality Finality
+type DSL2BlockFinality struct {
+	Version       uint8           // 1 byte
+	L2BlockNumber uint64          // 8 bytes
+	Finality      L2BlockFinality // 1 byte
+}
+
+// Encode returns the encoded DSL2BlockFinality as a byte slice
+func (b DSL2BlockFinality) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.L2BlockNumber)
+	bytes = append(bytes, byte(b.Finality))
+	return bytes
+}
+
+// Decode decodes the DSL2BlockFinality from a byte slice
+func (b DSL2BlockFinality) Decode(data []byte) DSL2BlockFinality {
+	b.Version = data[0]
+	b.L2BlockNumber = binary.LittleEndian.Uint64(data[1:9])
+	b.Finality = L2BlockFinality(data[9])
+	return b
+}
+
+// DSStorageChange represents the value of a storage slot of an account after it's changed
+type DSStorageChange struct {
+	Address common.Address // 20 bytes
+	Slot    common.Hash    // 32 bytes
+	Value   common.Hash    // 32 bytes
+}
+
+// DSL2BlockStorageDiff represents the storage slots changed by the txs of a L2 block, with their values at the end of it.
+// If Unavailable is set the changes are not known, e.g. the L2 block was written from the state, and Changes is empty
+type DSL2BlockStorageDiff struct {
+	Version       uint8  // 1 byte
+	L2BlockNumber uint64 // 8 bytes
+	Unavailable   bool   // 1 byte, only encoded since the version 2
+	Changes       []DSStorageChange
+}
+
+const dsStorageChangeLength = common.AddressLength + 2*common.HashLength
+
+// Encode returns the encoded DSL2BlockStorageDiff as a byte slice
+func (b DSL2BlockStorageDiff) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.L2BlockNumber)
+	if b.Version >= 2 {
+		if b.Unavailable {
+			bytes = append(bytes, 1)
+		} else {
+			bytes = append(bytes, 0)
+		}
+	}
+	bytes = binary.LittleEndian.AppendUint32(bytes, uint32(len(b.Changes)))
+	for _, change := range b.Changes {
+		bytes = append(bytes, change.Address[:]...)
+		bytes = append(bytes, change.Slot[:]...)
+		bytes = append(bytes, change.Value[:]...)
+	}
+	return bytes
+}
+
+// Decode decodes the DSL2BlockStorageDiff from a byte slice. The entries of the version 1 are decoded as available
+func (b DSL2BlockStorageDiff) Decode(data []byte) DSL2BlockStorageDiff {
+	b.Version = data[0]
+	b.L2BlockNumber = binary.LittleEndian.Uint64(data[1:9])
+	pos := 9
+	if b.Version >= 2 {
+		b.Unavailable = data[pos] == 1
+		pos++
+	}
+	count := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+	b.Changes = make([]DSStorageChange, 0, count)
+	for pos += 4; pos+dsStorageChangeLength <= len(data) && len(b.Changes) < count; pos += dsStorageChangeLength {
+		b.Changes = append(b.Changes, DSStorageChange{
+			Address: common.BytesToAddress(data[pos : pos+20]),
+			Slot:    common.BytesToHash(data[pos+20 : pos+52]),
+			Value:   common.BytesToHash(data[pos+52 : pos+84]),
+		})
+	}
+	return b
+}
+
+// DSL2BlockCheckpoint represents a checkpoint of the L2 blocks from FromL2BlockNumber to ToL2BlockNumber (included), streamed
+// since the previous checkpoint. Hash accumulates their block hashes in order with AccumulateL2BlockHash, starting from an empty hash
+type DSL2BlockCheckpoint struct {
+	Version           uint8       // 1 byte
+	FromL2BlockNumber uint64      // 8 bytes
+	ToL2BlockNumber   uint64      // 8 bytes
+	Hash              common.Hash // 32 bytes
+}
+
+// Encode returns the encoded DSL2BlockCheckpoint as a byte slice
+func (b DSL2BlockCheckpoint) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.FromL2BlockNumber)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.ToL2BlockNumber)
+	bytes = append(bytes, b.Hash[:]...)
+	return bytes
+}
+
+// Decode decodes the DSL2BlockCheckpoint from a byte slice
+func (b DSL2BlockCheckpoint) Decode(data []byte) DSL2BlockCheckpoint {
+	b.Version = data[0]
+	b.FromL2BlockNumber = binary.LittleEndian.Uint64(data[1:9])
+	b.ToL2BlockNumber = binary.LittleEndian.Uint64(data[9:17])
+	b.Hash = common.BytesToHash(data[17:49])
+	return b
+}
+
+// AccumulateL2BlockHash returns the hash accumulating the block hash of the next L2 block into the accumulated hash of the
+// previous ones: keccak256(accumulatedHash || blockHash)
+func AccumulateL2BlockHash(accumulatedHash, blockHash common.Hash) common.Hash {
+	return common.BytesToHash(keccak256.Hash(accumulatedHash[:], blockHash[:]))
+}
+
+// DSL2BlockBloom represents the bloom filter of the addresses and topics of the logs emitted by the txs of a L2 block
+type DSL2BlockBloom struct {
+	Version       uint8       // 1 byte
+	L2BlockNumber uint64      // 8 bytes
+	Bloom         types.Bloom // 256 bytes
+}
+
+// NewDSL2BlockBloom returns the bloom filter of the logs of a L2 block, computed as the logs bloom of the Ethereum receipts
+func NewDSL2BlockBloom(l2BlockNumber uint64, logs []*types.Log) DSL2BlockBloom {
+	b := DSL2BlockBloom{Version: DSL2BlockBloomVersion, L2BlockNumber: l2BlockNumber}
+	for _, txLog := range logs {
+		b.Bloom.Add(txLog.Address.Bytes())
+		for _, topic := range txLog.Topics {
+			b.Bloom.Add(topic.Bytes())
+		}
+	}
+	return b
+}
+
+// Encode returns the encoded DSL2BlockBloom as a byte slice
+func (b DSL2BlockBloom) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = append(bytes, b.Version)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.L2BlockNumber)
+	bytes = append(bytes, b.Bloom[:]...)
+	return bytes
+}
+
+// Decode decodes the DSL2BlockBloom from a byte slice
+func (b DSL2BlockBloom) Decode(data []byte) DSL2BlockBloom {
+	b.Version = data[0]
+	b.L2BlockNumber = binary.LittleEndian.Uint64(data[1:9])
+	b.Bloom = types.BytesToBloom(data[9 : 9+types.BloomByteLength])
+	return b
+}
+
+// NewDSStorageChanges returns the storage slots changed by the SSTORE steps of the full trace of a tx. The changes of
+// the calls reverted or failed are discarded, and all of them if the tx failed. The trace must include the stack
+func NewDSStorageChanges(txResponse *ProcessTransactionResponse) []DSStorageChange {
+	if txResponse.RomError != nil || len(txResponse.FullTrace.Steps) == 0 {
+		return nil
+	}
+
+	// The changes of each call are kept apart until the call ends, merging them into the caller if it succeeded
+	steps := txResponse.FullTrace.Steps
+	depth := steps[0].Depth
+	frames := [][]DSStorageChange{nil}
+	frameLastSteps := []instrumentation.Step{{}}
+	for _, step := range steps {
+		for step.Depth > depth {
+			frames = append(frames, nil)
+			frameLastSteps = append(frameLastSteps, instrumentation.Step{})
+			depth++
+		}
+		for step.Depth < depth && len(frames) > 1 {
+			last := len(frames) - 1
+			if lastStep := frameLastSteps[last]; lastStep.Error == nil && lastStep.OpCode != "REVERT" {
+				frames[last-1] = append(frames[last-1], frames[last]...)
+			}
+			frames, frameLastSteps = frames[:last], frameLastSteps[:last]
+			depth--
+		}
+
+		last := len(frames) - 1
+		frameLastSteps[last] = step
+		if step.OpCode == "SSTORE" && step.Error == nil && len(step.Stack) >= 2 { //nolint:gomnd
+			frames[last] = append(frames[last], DSStorageChange{
+				Address: step.Contract.Address,
+				Slot:    common.BigToHash(step.Stack[len(step.Stack)-1]),
+				Value:   common.BigToHash(step.Stack[len(step.Stack)-2]),
+			})
+		}
+	}
+
+	changes := []DSStorageChange{}
+	for _, frame := range frames {
+		changes = append(changes, frame...)
+	}
+	return MergeDSStorageChanges(changes)
+}
+
+// MergeDSStorageChanges merges the lists of storage changes, in the order they were applied. Each slot is returned once
+// with its last value, in the order it was first changed
+func MergeDSStorageChanges(changesLists ...[]DSStorageChange) []DSStorageChange {
+	type storageKey struct {
+		address common.Address
+		slot    common.Hash
+	}
+
+	merged := []DSStorageChange{}
+	indexes := map[storageKey]int{}
+	for _, changes := range changesLists {
+		for _, change := range changes {
+			key := storageKey{change.Address, change.Slot}
+			if index, found := indexes[key]; found {
+				merged[index].Value = change.Value
+				continue
+			}
+			indexes[key] = len(merged)
+			merged = append(merged, change)
+		}
+	}
+	return merged
+}
+
+// DSBatchStart represents a data stream batch start
+type DSBatchStart struct {
+	BatchNumber uint64 // 8 bytes
+}
+
+// Encode returns the encoded DSBatchStart as a byte slice
+func (b DSBatchStart) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.BatchNumber)
+	return bytes
+}
+
+// Decode decodes the DSBatchStart from a byte slice
+func (b DSBatchStart) Decode(data []byte) DSBatchStart {
+	b.BatchNumber = binary.LittleEndian.Uint64(data[0:8])
+	return b
+}
+
+// DSBatchEnd represents a data stream batch end
+type DSBatchEnd struct {
+	BatchNumber uint64      // 8 bytes
+	StateRoot   common.Hash // 32 bytes
+}
+
+// Encode returns the encoded DSBatchEnd as a byte slice
+func (b DSBatchEnd) Encode() []byte {
+	bytes := make([]byte, 0)
+	bytes = binary.LittleEndian.AppendUint64(bytes, b.BatchNumber)
+	bytes = append(bytes, b.StateRoot[:]...)
+	return bytes
+}
+
+// Decode decodes the DSBatchEnd from a byte slice
+func (b DSBatchEnd) Decode(data []byte) DSBatchEnd {
+	b.BatchNumber = binary.LittleEndian.Uint64(data[0:8])
+	b.StateRoot = common.BytesToHash(data[8:40])
+	return b
+}
+
 // DSBookMark represents a data stream bookmark
 type DSBookMark struct {
-	Type          byte
+	Type byte
+	// L2BlockNumber is the number of the L2 block of the BookMarkTypeL2Block bookmarks, and the number of the batch of the
+	// BookMarkTypeBatch ones
 	L2BlockNumber uint64
 }
 
@@ -213,26 +838,89 @@ type DSState interface {
 	GetDSL2Transactions(ctx context.Context, firstL2Block, lastL2Block uint64, dbTx pgx.Tx) ([]*DSL2Transaction, error)
 	GetStorageAt(ctx context.Context, address common.Address, position *big.Int, root common.Hash) (*big.Int, error)
 	GetLastL2BlockHeader(ctx context.Context, dbTx pgx.Tx) (*L2Header, error)
+	GetLogsByBlockNumber(ctx context.Context, blockNumber uint64, dbTx pgx.Tx) ([]*types.Log, error)
 }
 
-// GenerateDataStreamerFile generates or resumes a data stream file
-func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.StreamServer, stateDB DSState, readWIPBatch bool, imStateRoots *map[uint64][]byte) error {
+// DSGenerationError is the error returned by GenerateDataStreamerFile. The atomic op in progress when the error happened
+// is rolled back, so the generation can be resumed from the last entry committed calling GenerateDataStreamerFile again
+type DSGenerationError struct {
+	// TotalEntries is the number of entries committed in the data stream file
+	TotalEntries uint64
+	// LastBatchNumber is the last batch number committed in the data stream file
+	LastBatchNumber uint64
+	Err             error
+}
+
+// Error returns the error message
+func (e *DSGenerationError) Error() string {
+	return fmt.Sprintf("data stream generation failed, total entries: %d, last batch number: %d, error: %s", e.TotalEntries, e.LastBatchNumber, e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *DSGenerationError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateDataStreamerFile generates or resumes a data stream file, writing the entries of the L2 blocks with the format of
+// entriesCfg. The state doesn't keep the L1 info roots nor the storage changes of the L2 blocks, so if they are included
+// the L2 block starts are written without L1 info root and the storage diffs are marked as unavailable. If it fails a *DSGenerationError
+// is returned and the generation can be resumed from the last entry committed
+func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.StreamServer, stateDB DSState, readWIPBatch bool, imStateRoots *map[uint64][]byte, entriesCfg DSL2BlockEntriesConfig) error {
 	header := streamServer.GetHeader()
 
+	if entriesCfg.IncludeL1InfoRoot && !entriesCfg.BlockStartMasked {
+		log.Warn("the L1 info roots of the L2 blocks are not kept in the state, the L2 block starts are generated without L1 info root")
+		// An empty L1 info root would be taken by the consumers as the one used to process the L2 block
+		entriesCfg.IncludeL1InfoRoot = false
+	}
+	if entriesCfg.IncludeStorageDiffs {
+		log.Warn("the storage changes of the L2 blocks are not kept in the state, the storage diffs are generated marked as unavailable")
+	}
+
 	var currentBatchNumber uint64 = 0
 	var currentL2Block uint64 = 0
 
-	if header.TotalEntries == 0 {
+	// lastBatchNumber is the last batch committed and inAtomicOp is true while there is an atomic op in progress
+	var lastBatchNumber uint64 = 0
+	inAtomicOp := false
+	fail := func(err error) error {
+		if inAtomicOp {
+			if rollbackErr := streamServer.RollbackAtomicOp(); rollbackErr != nil {
+				log.Errorf("Error rolling back atomic op: %s", rollbackErr.Error())
+			}
+		}
+		return &DSGenerationError{TotalEntries: streamServer.GetHeader().TotalEntries, LastBatchNumber: lastBatchNumber, Err: err}
+	}
+
+	// checkpoint accumulates the hashes of the L2 blocks generated since the last checkpoint entry, including the ones already
+	// in the data stream when it's resumed
+	checkpoint, err := RestoreDSL2BlockCheckpointAccumulator(streamServer, entriesCfg.CheckpointEveryNBlocks)
+	if err != nil {
+		return fail(err)
+	}
+
+	// The schema header doesn't belong to any batch, a data stream with only the header is empty
+	streamEmpty := header.TotalEntries == 0
+	if header.TotalEntries == 1 {
+		firstEntry, err := streamServer.GetEntry(0)
+		if err != nil {
+			return fail(err)
+		}
+		streamEmpty = firstEntry.Type == EntryTypeStreamSchema
+	}
+
+	if streamEmpty {
 		// Get Genesis block
 		genesisL2Block, err := stateDB.GetDSGenesisBlock(ctx, nil)
 		if err != nil {
-			return err
+			return fail(err)
 		}
 
 		err = streamServer.StartAtomicOp()
 		if err != nil {
-			return err
+			return fail(err)
 		}
+		inAtomicOp = true
 
 		bookMark := DSBookMark{
 			Type:          BookMarkTypeL2Block,
@@ -241,7 +929,7 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 		_, err = streamServer.AddStreamBookmark(bookMark.Encode())
 		if err != nil {
-			return err
+			return fail(err)
 		}
 
 		genesisBlock := DSL2BlockStart{
@@ -255,9 +943,14 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 		log.Infof("Genesis block: %+v", genesisBlock)
 
-		_, err = streamServer.AddStreamEntry(1, genesisBlock.Encode())
+		_, err = streamServer.AddStreamEntry(entriesCfg.L2BlockStartEntry(genesisBlock, genesisL2Block.L1InfoRoot))
 		if err != nil {
-			return err
+			return fail(err)
+		}
+
+		checkpoint, err = addDSL2BlockTrailingEntries(ctx, streamServer, stateDB, entriesCfg, genesisL2Block, checkpoint)
+		if err != nil {
+			return fail(err)
 		}
 
 		genesisBlockEnd := DSL2BlockEnd{
@@ -266,30 +959,43 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 			StateRoot:     genesisL2Block.StateRoot,
 		}
 
-		_, err = streamServer.AddStreamEntry(EntryTypeL2BlockEnd, genesisBlockEnd.Encode())
+		_, err = streamServer.AddStreamEntry(entriesCfg.L2BlockEndEntry(genesisBlockEnd))
 		if err != nil {
-			return err
+			return fail(err)
 		}
 
 		err = streamServer.CommitAtomicOp()
 		if err != nil {
-			return err
+			return fail(err)
 		}
+		inAtomicOp = false
 	} else {
 		latestEntry, err := streamServer.GetEntry(header.TotalEntries - 1)
 		if err != nil {
-			return err
+			return fail(err)
+		}
+
+		// The L2 block finality updates don't belong to any batch, the latest entry is the one before them
+		for latestEntry.Type == EntryTypeL2BlockFinality && latestEntry.Number > 0 {
+			latestEntry, err = streamServer.GetEntry(latestEntry.Number - 1)
+			if err != nil {
+				return fail(err)
+			}
 		}
 
 		log.Infof("Latest entry: %+v", latestEntry)
 
-		switch latestEntry.Type {
-		case EntryTypeUpdateGER:
+		switch {
+		case latestEntry.Type == EntryTypeUpdateGER:
 			log.Info("Latest entry type is UpdateGER")
 			currentBatchNumber = binary.LittleEndian.Uint64(latestEntry.Data[0:8])
-		case EntryTypeL2BlockEnd:
+		case IsL2BlockEndEntry(latestEntry.Type):
 			log.Info("Latest entry type is L2BlockEnd")
-			currentL2Block = binary.LittleEndian.Uint64(latestEntry.Data[0:8])
+			blockEnd, err := DecodeL2BlockEndEntry(latestEntry)
+			if err != nil {
+				return fail(err)
+			}
+			currentL2Block = blockEnd.L2BlockNumber
 
 			bookMark := DSBookMark{
 				Type:          BookMarkTypeL2Block,
@@ -298,15 +1004,20 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 			firstEntry, err := streamServer.GetFirstEventAfterBookmark(bookMark.Encode())
 			if err != nil {
-				return err
+				return fail(err)
+			}
+			currentBatchNumber, err = DecodeL2BlockStartBatchNumber(firstEntry)
+			if err != nil {
+				return fail(err)
 			}
-			currentBatchNumber = binary.LittleEndian.Uint64(firstEntry.Data[0:8])
 		}
 	}
 
 	log.Infof("Current Batch number: %d", currentBatchNumber)
 	log.Infof("Current L2 block number: %d", currentL2Block)
 
+	lastBatchNumber = currentBatchNumber
+
 	var entry uint64 = header.TotalEntries
 	var currentGER = common.Hash{}
 
@@ -317,8 +1028,6 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 	// Start on the current batch number + 1
 	currentBatchNumber++
 
-	var err error
-
 	const limit = 10000
 
 	for err == nil {
@@ -331,7 +1040,7 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 				break
 			}
 			log.Errorf("Error getting batch %d: %s", currentBatchNumber, err.Error())
-			return err
+			return fail(err)
 		}
 
 		// Finished?
@@ -342,7 +1051,7 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 		l2Blocks, err := stateDB.GetDSL2Blocks(ctx, batches[0].BatchNumber, batches[len(batches)-1].BatchNumber, nil)
 		if err != nil {
 			log.Errorf("Error getting L2 blocks for batches starting at %d: %s", batches[0].BatchNumber, err.Error())
-			return err
+			return fail(err)
 		}
 
 		l2Txs := make([]*DSL2Transaction, 0)
@@ -350,7 +1059,7 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 			l2Txs, err = stateDB.GetDSL2Transactions(ctx, l2Blocks[0].L2BlockNumber, l2Blocks[len(l2Blocks)-1].L2BlockNumber, nil)
 			if err != nil {
 				log.Errorf("Error getting L2 transactions for blocks starting at %d: %s", l2Blocks[0].L2BlockNumber, err.Error())
-				return err
+				return fail(err)
 			}
 		}
 
@@ -374,18 +1083,21 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 					err = streamServer.StartAtomicOp()
 					if err != nil {
-						return err
+						return fail(err)
 					}
+					inAtomicOp = true
 
 					entry, err = streamServer.AddStreamEntry(EntryTypeUpdateGER, updateGer.Encode())
 					if err != nil {
-						return err
+						return fail(err)
 					}
 
 					err = streamServer.CommitAtomicOp()
 					if err != nil {
-						return err
+						return fail(err)
 					}
+					inAtomicOp = false
+					lastBatchNumber = batch.BatchNumber
 
 					currentGER = batch.GlobalExitRoot
 				}
@@ -394,7 +1106,18 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 			err = streamServer.StartAtomicOp()
 			if err != nil {
-				return err
+				return fail(err)
+			}
+			inAtomicOp = true
+
+			bookMark := DSBookMark{
+				Type:          BookMarkTypeBatch,
+				L2BlockNumber: batch.BatchNumber,
+			}
+
+			_, err = streamServer.AddStreamBookmark(bookMark.Encode())
+			if err != nil {
+				return fail(err)
 			}
 
 			for _, l2block := range batch.L2Blocks {
@@ -414,12 +1137,12 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 
 				_, err = streamServer.AddStreamBookmark(bookMark.Encode())
 				if err != nil {
-					return err
+					return fail(err)
 				}
 
-				_, err = streamServer.AddStreamEntry(EntryTypeL2BlockStart, blockStart.Encode())
+				_, err = streamServer.AddStreamEntry(entriesCfg.L2BlockStartEntry(blockStart, l2block.L1InfoRoot))
 				if err != nil {
-					return err
+					return fail(err)
 				}
 
 				for _, tx := range l2block.Txs {
@@ -428,42 +1151,95 @@ func GenerateDataStreamerFile(ctx context.Context, streamServer *datastreamer.St
 						position := GetSystemSCPosition(l2block.L2BlockNumber)
 						imStateRoot, err := stateDB.GetStorageAt(ctx, common.HexToAddress(SystemSC), big.NewInt(0).SetBytes(position), l2block.StateRoot)
 						if err != nil {
-							return err
+							return fail(err)
 						}
 						tx.StateRoot = common.BigToHash(imStateRoot)
 					} else {
 						tx.StateRoot = common.BytesToHash((*imStateRoots)[blockStart.L2BlockNumber])
 					}
 
-					entry, err = streamServer.AddStreamEntry(EntryTypeL2Tx, tx.Encode())
+					entryType, encoded, err := entriesCfg.L2TransactionEntry(tx)
+					if err != nil {
+						return fail(err)
+					}
+
+					entry, err = streamServer.AddStreamEntry(entryType, encoded)
 					if err != nil {
-						return err
+						return fail(err)
 					}
 				}
 
+				checkpoint, err = addDSL2BlockTrailingEntries(ctx, streamServer, stateDB, entriesCfg, &l2block.DSL2Block, checkpoint)
+				if err != nil {
+					return fail(err)
+				}
+
 				blockEnd := DSL2BlockEnd{
 					L2BlockNumber: l2block.L2BlockNumber,
 					BlockHash:     l2block.BlockHash,
 					StateRoot:     l2block.StateRoot,
 				}
 
-				_, err = streamServer.AddStreamEntry(EntryTypeL2BlockEnd, blockEnd.Encode())
+				_, err = streamServer.AddStreamEntry(entriesCfg.L2BlockEndEntry(blockEnd))
 				if err != nil {
-					return err
+					return fail(err)
 				}
 				currentGER = l2block.GlobalExitRoot
 			}
 			// Commit at the end of each batch group
 			err = streamServer.CommitAtomicOp()
 			if err != nil {
-				return err
+				return fail(err)
 			}
+			inAtomicOp = false
+			lastBatchNumber = batch.BatchNumber
 		}
 	}
 
 	return err
 }
 
+// addDSL2BlockTrailingEntries adds the storage diff, bloom and checkpoint entries that follow the txs of the L2 block, as
+// configured in entriesCfg, returning the updated checkpoint accumulator
+func addDSL2BlockTrailingEntries(ctx context.Context, streamServer *datastreamer.StreamServer, stateDB DSState, entriesCfg DSL2BlockEntriesConfig, l2Block *DSL2Block, checkpoint DSL2BlockCheckpointAccumulator) (DSL2BlockCheckpointAccumulator, error) {
+	if entriesCfg.IncludeStorageDiffs {
+		// The storage changes are not kept in the state
+		storageDiff := DSL2BlockStorageDiff{
+			Version:       DSL2BlockStorageDiffVersion,
+			L2BlockNumber: l2Block.L2BlockNumber,
+			Unavailable:   true,
+		}
+
+		_, err := streamServer.AddStreamEntry(EntryTypeL2BlockStorageDiff, storageDiff.Encode())
+		if err != nil {
+			return checkpoint, err
+		}
+	}
+
+	if entriesCfg.IncludeBlockBlooms {
+		logs, err := stateDB.GetLogsByBlockNumber(ctx, l2Block.L2BlockNumber, nil)
+		if err != nil {
+			return checkpoint, err
+		}
+
+		_, err = streamServer.AddStreamEntry(EntryTypeL2BlockBloom, NewDSL2BlockBloom(l2Block.L2BlockNumber, logs).Encode())
+		if err != nil {
+			return checkpoint, err
+		}
+	}
+
+	var checkpointEntry *DSL2BlockCheckpoint
+	checkpoint, checkpointEntry = checkpoint.Add(l2Block.L2BlockNumber, l2Block.BlockHash, entriesCfg.CheckpointEveryNBlocks)
+	if checkpointEntry != nil {
+		_, err := streamServer.AddStreamEntry(EntryTypeL2BlockCheckpoint, checkpointEntry.Encode())
+		if err != nil {
+			return checkpoint, err
+		}
+	}
+
+	return checkpoint, nil
+}
+
 // GetSystemSCPosition computes the position of the intermediate state root for the system smart contract
 func GetSystemSCPosition(blockNumber uint64) []byte {
 	v1 := big.NewInt(0).SetUint64(blockNumber).Bytes()
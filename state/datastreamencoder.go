@@ -0,0 +1,285 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// StreamEncoder encodes and decodes the payload of the L2 block start, L2 transaction and L2 block end data stream entries.
+// Each encoding has its own entry types, so the entries can be decoded whatever the encoding they were written with
+type StreamEncoder interface {
+	L2BlockStartEntryType() datastreamer.EntryType
+	L2TransactionEntryType() datastreamer.EntryType
+	L2BlockEndEntryType() datastreamer.EntryType
+	EncodeL2BlockStart(b DSL2BlockStart) []byte
+	DecodeL2BlockStart(data []byte) (DSL2BlockStart, error)
+	EncodeL2Transaction(l DSL2Transaction) []byte
+	DecodeL2Transaction(data []byte) (DSL2Transaction, error)
+	EncodeL2BlockEnd(b DSL2BlockEnd) []byte
+	DecodeL2BlockEnd(data []byte) (DSL2BlockEnd, error)
+}
+
+// StreamEncoderOf returns the StreamEncoder of the L2 block start, L2 transaction or L2 block end entry type.
+// It returns false if the entry type is not encoded with a StreamEncoder
+func StreamEncoderOf(entryType datastreamer.EntryType) (StreamEncoder, bool) {
+	switch entryType {
+	case EntryTypeL2BlockStart, EntryTypeL2Tx, EntryTypeL2BlockEnd:
+		return DSBinaryEncoder{}, true
+	case EntryTypeL2BlockStartProto, EntryTypeL2TxProto, EntryTypeL2BlockEndProto:
+		return DSProtobufEncoder{}, true
+	}
+	return nil, false
+}
+
+// IsL2BlockEndEntry returns true if the entry type is a L2 block end, whatever its encoding
+func IsL2BlockEndEntry(entryType datastreamer.EntryType) bool {
+	return entryType == EntryTypeL2BlockEnd || entryType == EntryTypeL2BlockEndProto
+}
+
+// DecodeL2BlockEndEntry decodes the DSL2BlockEnd of a L2 block end entry, whatever its encoding
+func DecodeL2BlockEndEntry(entry datastreamer.FileEntry) (DSL2BlockEnd, error) {
+	encoder, ok := StreamEncoderOf(entry.Type)
+	if !ok || !IsL2BlockEndEntry(entry.Type) {
+		return DSL2BlockEnd{}, fmt.Errorf("entry %d of type %d is not a l2 block end", entry.Number, entry.Type)
+	}
+	return encoder.DecodeL2BlockEnd(entry.Data)
+}
+
+// DecodeL2BlockStartBatchNumber returns the batch number of a L2 block start entry, whatever its entry type and encoding
+func DecodeL2BlockStartBatchNumber(entry datastreamer.FileEntry) (uint64, error) {
+	switch entry.Type {
+	case EntryTypeL2BlockStartMasked:
+		return DSL2BlockStartMasked{}.Decode(entry.Data).BatchNumber, nil
+	case EntryTypeL2BlockStartWithL1InfoRoot:
+		return DSL2BlockStartWithL1InfoRoot{}.Decode(entry.Data).BatchNumber, nil
+	case EntryTypeL2BlockStart, EntryTypeL2BlockStartProto:
+		encoder, _ := StreamEncoderOf(entry.Type)
+		blockStart, err := encoder.DecodeL2BlockStart(entry.Data)
+		return blockStart.BatchNumber, err
+	}
+	return 0, fmt.Errorf("entry %d of type %d is not a l2 block start", entry.Number, entry.Type)
+}
+
+// DSBinaryEncoder is the default StreamEncoder, using the fixed size binary encoding of each entry type
+type DSBinaryEncoder struct{}
+
+// L2BlockStartEntryType returns EntryTypeL2BlockStart
+func (DSBinaryEncoder) L2BlockStartEntryType() datastreamer.EntryType {
+	return EntryTypeL2BlockStart
+}
+
+// L2TransactionEntryType returns EntryTypeL2Tx
+func (DSBinaryEncoder) L2TransactionEntryType() datastreamer.EntryType {
+	return EntryTypeL2Tx
+}
+
+// L2BlockEndEntryType returns EntryTypeL2BlockEnd
+func (DSBinaryEncoder) L2BlockEndEntryType() datastreamer.EntryType {
+	return EntryTypeL2BlockEnd
+}
+
+// EncodeL2BlockStart returns the binary encoding of the DSL2BlockStart
+func (DSBinaryEncoder) EncodeL2BlockStart(b DSL2BlockStart) []byte {
+	return b.Encode()
+}
+
+// DecodeL2BlockStart decodes the DSL2BlockStart from its binary encoding
+func (DSBinaryEncoder) DecodeL2BlockStart(data []byte) (DSL2BlockStart, error) {
+	if len(data) < dsL2BlockStartLength {
+		return DSL2BlockStart{}, fmt.Errorf("invalid l2 block start length %d", len(data))
+	}
+	return DSL2BlockStart{}.Decode(data), nil
+}
+
+// EncodeL2Transaction returns the binary encoding of the DSL2Transaction
+func (DSBinaryEncoder) EncodeL2Transaction(l DSL2Transaction) []byte {
+	return l.Encode()
+}
+
+// DecodeL2Transaction decodes the DSL2Transaction from its binary encoding
+func (DSBinaryEncoder) DecodeL2Transaction(data []byte) (DSL2Transaction, error) {
+	if len(data) < dsL2TransactionMinLength {
+		return DSL2Transaction{}, fmt.Errorf("invalid l2 transaction length %d", len(data))
+	}
+	return DSL2Transaction{}.Decode(data), nil
+}
+
+// EncodeL2BlockEnd returns the binary encoding of the DSL2BlockEnd
+func (DSBinaryEncoder) EncodeL2BlockEnd(b DSL2BlockEnd) []byte {
+	return b.Encode()
+}
+
+// DecodeL2BlockEnd decodes the DSL2BlockEnd from its binary encoding
+func (DSBinaryEncoder) DecodeL2BlockEnd(data []byte) (DSL2BlockEnd, error) {
+	if len(data) < dsL2BlockEndLength {
+		return DSL2BlockEnd{}, fmt.Errorf("invalid l2 block end length %d", len(data))
+	}
+	return DSL2BlockEnd{}.Decode(data), nil
+}
+
+const (
+	dsL2BlockStartLength     = 78
+	dsL2TransactionMinLength = 38
+	dsL2BlockEndLength       = 72
+)
+
+// DSProtobufEncoder is a StreamEncoder using the protobuf messages defined in proto/src/proto/datastream/v1/datastream.proto,
+// so the payloads are self-describing and can be decoded by non-Go consumers
+type DSProtobufEncoder struct{}
+
+// L2BlockStartEntryType returns EntryTypeL2BlockStartProto
+func (DSProtobufEncoder) L2BlockStartEntryType() datastreamer.EntryType {
+	return EntryTypeL2BlockStartProto
+}
+
+// L2TransactionEntryType returns EntryTypeL2TxProto
+func (DSProtobufEncoder) L2TransactionEntryType() datastreamer.EntryType {
+	return EntryTypeL2TxProto
+}
+
+// L2BlockEndEntryType returns EntryTypeL2BlockEndProto
+func (DSProtobufEncoder) L2BlockEndEntryType() datastreamer.EntryType {
+	return EntryTypeL2BlockEndProto
+}
+
+// EncodeL2BlockStart returns the DSL2BlockStart encoded as a L2BlockStart protobuf message
+func (DSProtobufEncoder) EncodeL2BlockStart(b DSL2BlockStart) []byte {
+	bytes := make([]byte, 0)
+	bytes = appendVarintField(bytes, 1, b.BatchNumber)
+	bytes = appendVarintField(bytes, 2, b.L2BlockNumber)
+	bytes = appendVarintField(bytes, 3, uint64(b.Timestamp))
+	bytes = appendBytesField(bytes, 4, b.GlobalExitRoot.Bytes())
+	bytes = appendBytesField(bytes, 5, b.Coinbase.Bytes())
+	bytes = appendVarintField(bytes, 6, uint64(b.ForkID))
+	return bytes
+}
+
+// DecodeL2BlockStart decodes the DSL2BlockStart from a L2BlockStart protobuf message
+func (DSProtobufEncoder) DecodeL2BlockStart(data []byte) (DSL2BlockStart, error) {
+	var b DSL2BlockStart
+	err := consumeFields(data, func(num protowire.Number, value uint64, bytes []byte) {
+		switch num {
+		case 1:
+			b.BatchNumber = value
+		case 2:
+			b.L2BlockNumber = value
+		case 3:
+			b.Timestamp = int64(value)
+		case 4:
+			b.GlobalExitRoot = common.BytesToHash(bytes)
+		case 5:
+			b.Coinbase = common.BytesToAddress(bytes)
+		case 6:
+			b.ForkID = uint16(value)
+		}
+	})
+	return b, err
+}
+
+// EncodeL2Transaction returns the DSL2Transaction encoded as a L2Transaction protobuf message
+func (DSProtobufEncoder) EncodeL2Transaction(l DSL2Transaction) []byte {
+	bytes := make([]byte, 0)
+	bytes = appendVarintField(bytes, 1, uint64(l.EffectiveGasPricePercentage))
+	bytes = appendVarintField(bytes, 2, uint64(l.IsValid))
+	bytes = appendBytesField(bytes, 3, l.StateRoot.Bytes())
+	bytes = appendBytesField(bytes, 4, l.Encoded)
+	return bytes
+}
+
+// DecodeL2Transaction decodes the DSL2Transaction from a L2Transaction protobuf message
+func (DSProtobufEncoder) DecodeL2Transaction(data []byte) (DSL2Transaction, error) {
+	var l DSL2Transaction
+	err := consumeFields(data, func(num protowire.Number, value uint64, bytes []byte) {
+		switch num {
+		case 1:
+			l.EffectiveGasPricePercentage = uint8(value)
+		case 2:
+			l.IsValid = uint8(value)
+		case 3:
+			l.StateRoot = common.BytesToHash(bytes)
+		case 4:
+			l.Encoded = bytes
+			l.EncodedLength = uint32(len(bytes))
+		}
+	})
+	return l, err
+}
+
+// EncodeL2BlockEnd returns the DSL2BlockEnd encoded as a L2BlockEnd protobuf message
+func (DSProtobufEncoder) EncodeL2BlockEnd(b DSL2BlockEnd) []byte {
+	bytes := make([]byte, 0)
+	bytes = appendVarintField(bytes, 1, b.L2BlockNumber)
+	bytes = appendBytesField(bytes, 2, b.BlockHash.Bytes())
+	bytes = appendBytesField(bytes, 3, b.StateRoot.Bytes())
+	return bytes
+}
+
+// DecodeL2BlockEnd decodes the DSL2BlockEnd from a L2BlockEnd protobuf message
+func (DSProtobufEncoder) DecodeL2BlockEnd(data []byte) (DSL2BlockEnd, error) {
+	var b DSL2BlockEnd
+	err := consumeFields(data, func(num protowire.Number, value uint64, bytes []byte) {
+		switch num {
+		case 1:
+			b.L2BlockNumber = value
+		case 2:
+			b.BlockHash = common.BytesToHash(bytes)
+		case 3:
+			b.StateRoot = common.BytesToHash(bytes)
+		}
+	})
+	return b, err
+}
+
+// appendVarintField appends a varint field to a protobuf message. As in proto3, the field is omitted if the value is 0
+func appendVarintField(bytes []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return bytes
+	}
+	bytes = protowire.AppendTag(bytes, num, protowire.VarintType)
+	return protowire.AppendVarint(bytes, value)
+}
+
+// appendBytesField appends a length-delimited field to a protobuf message
+func appendBytesField(bytes []byte, num protowire.Number, value []byte) []byte {
+	bytes = protowire.AppendTag(bytes, num, protowire.BytesType)
+	return protowire.AppendBytes(bytes, value)
+}
+
+// consumeFields calls setField with the value of each varint and length-delimited field of a protobuf message.
+// The fields of other types are skipped
+func consumeFields(data []byte, setField func(num protowire.Number, value uint64, bytes []byte)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			setField(num, value, nil)
+			data = data[n:]
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			setField(num, 0, value)
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DSL2BlockEntriesConfig is the format of the entries written to the data stream for each L2 block. It's shared by the
+// sequencer, when it streams the L2 blocks it processes, and by GenerateDataStreamerFile, so the L2 blocks written by
+// both have the same entries
+type DSL2BlockEntriesConfig struct {
+	// Encoder encodes the L2 block start, L2 transaction and L2 block end entries. If nil the binary encoding is used
+	Encoder StreamEncoder
+	// BlockStartMasked makes the L2 block starts to be written as EntryTypeL2BlockStartMasked entries with BlockStartFields
+	BlockStartMasked bool
+	BlockStartFields DSL2BlockStartFields
+	// IncludeL1InfoRoot makes the L2 block starts to be written as EntryTypeL2BlockStartWithL1InfoRoot entries, unless they are masked
+	IncludeL1InfoRoot bool
+	// IncludeDecodedTxMetadata makes the txs to be written as EntryTypeL2TxWithMetadata entries
+	IncludeDecodedTxMetadata bool
+	// IncludeSender makes the txs to be written as EntryTypeL2TxWithSender entries, unless they include the decoded metadata
+	IncludeSender bool
+	// IncludeStorageDiffs, IncludeBlockBlooms and CheckpointEveryNBlocks add the EntryTypeL2BlockStorageDiff, EntryTypeL2BlockBloom
+	// and EntryTypeL2BlockCheckpoint entries after the txs of the L2 blocks
+	IncludeStorageDiffs    bool
+	IncludeBlockBlooms     bool
+	CheckpointEveryNBlocks uint64
+}
+
+// encoder returns the StreamEncoder of the config, the binary encoder if it's not set
+func (c DSL2BlockEntriesConfig) encoder() StreamEncoder {
+	if c.Encoder == nil {
+		return DSBinaryEncoder{}
+	}
+	return c.Encoder
+}
+
+// L2BlockStartEntry returns the entry type and payload of the L2 block start. The L1 info root is only used if IncludeL1InfoRoot is set
+func (c DSL2BlockEntriesConfig) L2BlockStartEntry(blockStart DSL2BlockStart, l1InfoRoot common.Hash) (datastreamer.EntryType, []byte) {
+	if c.BlockStartMasked {
+		return EntryTypeL2BlockStartMasked, NewDSL2BlockStartMasked(blockStart, c.BlockStartFields).Encode()
+	}
+	if c.IncludeL1InfoRoot {
+		return EntryTypeL2BlockStartWithL1InfoRoot, NewDSL2BlockStartWithL1InfoRoot(blockStart, l1InfoRoot).Encode()
+	}
+	return c.encoder().L2BlockStartEntryType(), c.encoder().EncodeL2BlockStart(blockStart)
+}
+
+// L2TransactionEntry returns the entry type and payload of the L2 transaction. It fails if the metadata or sender of the
+// tx are included and they can't be decoded
+func (c DSL2BlockEntriesConfig) L2TransactionEntry(l2Transaction DSL2Transaction) (datastreamer.EntryType, []byte, error) {
+	if c.IncludeDecodedTxMetadata {
+		l2TransactionWithMetadata, err := NewDSL2TransactionWithMetadata(l2Transaction)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decode metadata of l2tx, error: %w", err)
+		}
+		return EntryTypeL2TxWithMetadata, l2TransactionWithMetadata.Encode(), nil
+	}
+	if c.IncludeSender {
+		l2TransactionWithSender, err := NewDSL2TransactionWithSender(l2Transaction)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get sender of l2tx, error: %w", err)
+		}
+		return EntryTypeL2TxWithSender, l2TransactionWithSender.Encode(), nil
+	}
+	return c.encoder().L2TransactionEntryType(), c.encoder().EncodeL2Transaction(l2Transaction), nil
+}
+
+// L2BlockEndEntry returns the entry type and payload of the L2 block end
+func (c DSL2BlockEntriesConfig) L2BlockEndEntry(blockEnd DSL2BlockEnd) (datastreamer.EntryType, []byte) {
+	return c.encoder().L2BlockEndEntryType(), c.encoder().EncodeL2BlockEnd(blockEnd)
+}
+
+// DSL2BlockCheckpointAccumulator accumulates the hashes of the L2 blocks written since the last checkpoint entry
+type DSL2BlockCheckpointAccumulator struct {
+	FromL2BlockNumber uint64
+	L2Blocks          uint64
+	Hash              common.Hash
+}
+
+// Add accumulates the hash of the L2 block, returning the updated accumulator. Once everyNBlocks L2 blocks are accumulated it
+// also returns the checkpoint entry to write, and the next L2 block starts a new checkpoint. If everyNBlocks is 0 nothing is accumulated
+func (c DSL2BlockCheckpointAccumulator) Add(l2BlockNumber uint64, blockHash common.Hash, everyNBlocks uint64) (DSL2BlockCheckpointAccumulator, *DSL2BlockCheckpoint) {
+	if everyNBlocks == 0 {
+		return c, nil
+	}
+
+	if c.L2Blocks == 0 {
+		c.FromL2BlockNumber = l2BlockNumber
+	}
+	c.Hash = AccumulateL2BlockHash(c.Hash, blockHash)
+	c.L2Blocks++
+	if c.L2Blocks < everyNBlocks {
+		return c, nil
+	}
+
+	checkpoint := &DSL2BlockCheckpoint{
+		Version:           DSL2BlockCheckpointVersion,
+		FromL2BlockNumber: c.FromL2BlockNumber,
+		ToL2BlockNumber:   l2BlockNumber,
+		Hash:              c.Hash,
+	}
+	return DSL2BlockCheckpointAccumulator{}, checkpoint
+}
+
+// RestoreDSL2BlockCheckpointAccumulator rebuilds the checkpoint accumulator with the hashes of the L2 blocks written to the data
+// stream after its last checkpoint entry, reading the data stream back from its head, so the next checkpoint written continues
+// from the last one. If there is no checkpoint entry yet all the L2 blocks of the data stream are accumulated, unless there are
+// more than everyNBlocks, e.g. because the checkpoints have just been enabled, and then the accumulator starts empty
+func RestoreDSL2BlockCheckpointAccumulator(streamServer *datastreamer.StreamServer, everyNBlocks uint64) (DSL2BlockCheckpointAccumulator, error) {
+	if everyNBlocks == 0 {
+		return DSL2BlockCheckpointAccumulator{}, nil
+	}
+
+	// blockEnds are the L2 block ends read since the head, in reverse order
+	blockEnds := []DSL2BlockEnd{}
+	lastCheckpointL2BlockNumber, checkpointFound := uint64(0), false
+	entryNum := streamServer.GetHeader().TotalEntries
+	for ; entryNum > 0 && uint64(len(blockEnds)) <= everyNBlocks; entryNum-- {
+		entry, err := streamServer.GetEntry(entryNum - 1)
+		if err != nil {
+			return DSL2BlockCheckpointAccumulator{}, err
+		}
+
+		if entry.Type == EntryTypeL2BlockCheckpoint {
+			lastCheckpointL2BlockNumber, checkpointFound = DSL2BlockCheckpoint{}.Decode(entry.Data).ToL2BlockNumber, true
+			break
+		}
+		if IsL2BlockEndEntry(entry.Type) {
+			blockEnd, err := DecodeL2BlockEndEntry(entry)
+			if err != nil {
+				return DSL2BlockCheckpointAccumulator{}, err
+			}
+			blockEnds = append(blockEnds, blockEnd)
+		}
+	}
+	if !checkpointFound && entryNum > 0 {
+		return DSL2BlockCheckpointAccumulator{}, nil
+	}
+
+	// The checkpoint entry is written before the end of its last L2 block, which is not accumulated
+	var checkpoint DSL2BlockCheckpointAccumulator
+	for i := len(blockEnds) - 1; i >= 0; i-- {
+		if !checkpointFound || blockEnds[i].L2BlockNumber > lastCheckpointL2BlockNumber {
+			checkpoint, _ = checkpoint.Add(blockEnds[i].L2BlockNumber, blockEnds[i].BlockHash, everyNBlocks)
+		}
+	}
+	return checkpoint, nil
+}
@@ -2,12 +2,19 @@ package test
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/0xPolygonHermez/zkevm-node/state/runtime"
+	"github.com/0xPolygonHermez/zkevm-node/state/runtime/instrumentation"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestL2BlockStartEncode(t *testing.T) {
@@ -28,6 +35,81 @@ func TestL2BlockStartEncode(t *testing.T) {
 	assert.Equal(t, expected, encoded)
 }
 
+func TestL2BlockStartMaskedRoundTrip(t *testing.T) {
+	l2BlockStart := state.DSL2BlockStart{
+		BatchNumber:    1,
+		L2BlockNumber:  2,
+		Timestamp:      3,
+		GlobalExitRoot: common.HexToHash("0x04"),
+		Coinbase:       common.HexToAddress("0x05"),
+		ForkID:         6,
+	}
+
+	testCases := []struct {
+		name           string
+		fields         state.DSL2BlockStartFields
+		expectedLength int
+		expected       state.DSL2BlockStart
+	}{
+		{
+			name:           "all fields",
+			fields:         state.DSL2BlockStartAllFields,
+			expectedLength: 2 + 24 + 32 + 20 + 2,
+			expected:       l2BlockStart,
+		},
+		{
+			name:           "without coinbase",
+			fields:         state.DSL2BlockStartFieldGlobalExitRoot | state.DSL2BlockStartFieldForkID,
+			expectedLength: 2 + 24 + 32 + 2,
+			expected:       state.DSL2BlockStart{BatchNumber: 1, L2BlockNumber: 2, Timestamp: 3, GlobalExitRoot: common.HexToHash("0x04"), ForkID: 6},
+		},
+		{
+			name:           "only fork id",
+			fields:         state.DSL2BlockStartFieldForkID,
+			expectedLength: 2 + 24 + 2,
+			expected:       state.DSL2BlockStart{BatchNumber: 1, L2BlockNumber: 2, Timestamp: 3, ForkID: 6},
+		},
+		{
+			name:           "no optional fields",
+			fields:         0,
+			expectedLength: 2 + 24,
+			expected:       state.DSL2BlockStart{BatchNumber: 1, L2BlockNumber: 2, Timestamp: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := state.NewDSL2BlockStartMasked(l2BlockStart, tc.fields).Encode()
+			assert.Len(t, encoded, tc.expectedLength)
+
+			decoded := state.DSL2BlockStartMasked{}.Decode(encoded)
+			assert.Equal(t, state.DSL2BlockStartMaskedVersion, decoded.Version)
+			assert.Equal(t, tc.fields, decoded.Fields)
+			assert.Equal(t, tc.expected, decoded.DSL2BlockStart)
+		})
+	}
+}
+
+func TestL2BlockStartWithL1InfoRootDecode(t *testing.T) {
+	l2BlockStart := state.DSL2BlockStart{
+		BatchNumber:    1,
+		L2BlockNumber:  2,
+		Timestamp:      3,
+		GlobalExitRoot: common.HexToHash("0x04"),
+		Coinbase:       common.HexToAddress("0x05"),
+		ForkID:         6,
+	}
+	l1InfoRoot := common.HexToHash("0x07")
+
+	encoded := state.NewDSL2BlockStartWithL1InfoRoot(l2BlockStart, l1InfoRoot).Encode()
+	assert.Len(t, encoded, 1+78+32)
+
+	decoded := state.DSL2BlockStartWithL1InfoRoot{}.Decode(encoded)
+	assert.Equal(t, state.DSL2BlockStartL1InfoRootVersion, decoded.Version)
+	assert.Equal(t, l2BlockStart, decoded.DSL2BlockStart)
+	assert.Equal(t, l1InfoRoot, decoded.L1InfoRoot)
+}
+
 func TestL2TransactionEncode(t *testing.T) {
 	l2Transaction := state.DSL2Transaction{
 		EffectiveGasPricePercentage: 128,                          // 1 byte
@@ -42,6 +124,197 @@ func TestL2TransactionEncode(t *testing.T) {
 	assert.Equal(t, expected, encoded)
 }
 
+func TestL2TransactionDecode(t *testing.T) {
+	l2Transaction := state.DSL2Transaction{
+		EffectiveGasPricePercentage: 128,
+		IsValid:                     1,
+		StateRoot:                   common.HexToHash("0x010203"),
+		EncodedLength:               5,
+		Encoded:                     []byte{1, 2, 3, 4, 5},
+	}
+
+	decoded := state.DSL2Transaction{}.Decode(l2Transaction.Encode())
+	assert.Equal(t, l2Transaction, decoded)
+}
+
+func TestL2TransactionWithMetadataDecode(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(privateKey.PublicKey)
+	to := common.HexToAddress("0x0102")
+	signer := types.NewEIP155Signer(big.NewInt(1000))
+
+	for _, txData := range []*types.LegacyTx{
+		{Nonce: 7, To: &to, Value: big.NewInt(1234), Gas: 21000, GasPrice: big.NewInt(1)},
+		{Nonce: 8, Value: big.NewInt(0), Gas: 100000, GasPrice: big.NewInt(1), Data: []byte{1, 2, 3}},
+	} {
+		tx, err := types.SignNewTx(privateKey, signer, txData)
+		require.NoError(t, err)
+		encoded, err := tx.MarshalBinary()
+		require.NoError(t, err)
+
+		l2Transaction := state.DSL2Transaction{
+			EffectiveGasPricePercentage: 255,
+			IsValid:                     1,
+			StateRoot:                   common.HexToHash("0x010203"),
+			EncodedLength:               uint32(len(encoded)),
+			Encoded:                     encoded,
+		}
+		l2TransactionWithMetadata, err := state.NewDSL2TransactionWithMetadata(l2Transaction)
+		require.NoError(t, err)
+
+		decoded := state.DSL2TransactionWithMetadata{}.Decode(l2TransactionWithMetadata.Encode())
+		assert.Equal(t, state.DSL2TransactionMetadataVersion, decoded.Version)
+		assert.Equal(t, l2Transaction, decoded.DSL2Transaction)
+		assert.Equal(t, from, decoded.From)
+		assert.Equal(t, tx.To(), decoded.To)
+		assert.Equal(t, tx.Nonce(), decoded.Nonce)
+		assert.Equal(t, 0, tx.Value().Cmp(decoded.Value))
+
+		// The decoded fields match the encoded tx
+		decodedTx := new(types.Transaction)
+		require.NoError(t, decodedTx.UnmarshalBinary(decoded.Encoded))
+		assert.Equal(t, tx.Hash(), decodedTx.Hash())
+	}
+}
+
+func TestL2TransactionWithMetadataDecode_TxTypes(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(privateKey.PublicKey)
+	to := common.HexToAddress("0x0102")
+	chainID := big.NewInt(1000)
+
+	testCases := []struct {
+		name    string
+		signer  types.Signer
+		txData  types.TxData
+		txType  uint8
+		chainID *big.Int
+	}{
+		{
+			name:    "legacy pre-EIP-155",
+			signer:  types.HomesteadSigner{},
+			txData:  &types.LegacyTx{Nonce: 1, To: &to, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(1)},
+			txType:  types.LegacyTxType,
+			chainID: big.NewInt(0),
+		},
+		{
+			name:    "legacy EIP-155",
+			signer:  types.NewEIP155Signer(chainID),
+			txData:  &types.LegacyTx{Nonce: 2, To: &to, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(1)},
+			txType:  types.LegacyTxType,
+			chainID: chainID,
+		},
+		{
+			name:    "access list EIP-2930",
+			signer:  types.NewEIP2930Signer(chainID),
+			txData:  &types.AccessListTx{ChainID: chainID, Nonce: 3, To: &to, Value: big.NewInt(1), Gas: 30000, GasPrice: big.NewInt(1), AccessList: types.AccessList{{Address: to}}},
+			txType:  types.AccessListTxType,
+			chainID: chainID,
+		},
+		{
+			name:    "dynamic fee EIP-1559",
+			signer:  types.NewLondonSigner(chainID),
+			txData:  &types.DynamicFeeTx{ChainID: chainID, Nonce: 4, Value: big.NewInt(1), Gas: 100000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Data: []byte{1, 2, 3}},
+			txType:  types.DynamicFeeTxType,
+			chainID: chainID,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := types.SignNewTx(privateKey, tc.signer, tc.txData)
+			require.NoError(t, err)
+			encoded, err := tx.MarshalBinary()
+			require.NoError(t, err)
+
+			l2Transaction := state.DSL2Transaction{
+				EffectiveGasPricePercentage: 255,
+				IsValid:                     1,
+				StateRoot:                   common.HexToHash("0x010203"),
+				EncodedLength:               uint32(len(encoded)),
+				Encoded:                     encoded,
+			}
+			l2TransactionWithMetadata, err := state.NewDSL2TransactionWithMetadata(l2Transaction)
+			require.NoError(t, err)
+
+			decoded := state.DSL2TransactionWithMetadata{}.Decode(l2TransactionWithMetadata.Encode())
+			assert.Equal(t, state.DSL2TransactionMetadataVersion, decoded.Version)
+			assert.Equal(t, tc.txType, decoded.TxType)
+			assert.Equal(t, 0, tc.chainID.Cmp(decoded.ChainID))
+			assert.Equal(t, from, decoded.From)
+			assert.Equal(t, tx.Nonce(), decoded.Nonce)
+			assert.Equal(t, l2Transaction, decoded.DSL2Transaction)
+
+			// The sender of the entry with sender is recovered for all the tx types too
+			l2TransactionWithSender, err := state.NewDSL2TransactionWithSender(l2Transaction)
+			require.NoError(t, err)
+			assert.Equal(t, from, l2TransactionWithSender.From)
+		})
+	}
+}
+
+func TestL2TransactionWithMetadataDecode_Version1(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := common.HexToAddress("0x0102")
+	tx, err := types.SignNewTx(privateKey, types.NewEIP155Signer(big.NewInt(1000)), &types.LegacyTx{Nonce: 7, To: &to, Value: big.NewInt(1234), Gas: 21000, GasPrice: big.NewInt(1)})
+	require.NoError(t, err)
+	encoded, err := tx.MarshalBinary()
+	require.NoError(t, err)
+
+	l2TransactionWithMetadata, err := state.NewDSL2TransactionWithMetadata(state.DSL2Transaction{EncodedLength: uint32(len(encoded)), Encoded: encoded})
+	require.NoError(t, err)
+
+	// The entries of the version 1 don't include the type and the chain id
+	l2TransactionWithMetadata.Version = 1
+	data := l2TransactionWithMetadata.Encode()
+	decoded := state.DSL2TransactionWithMetadata{}.Decode(data)
+	assert.Equal(t, uint8(1), decoded.Version)
+	assert.Equal(t, uint64(7), decoded.Nonce)
+	assert.Equal(t, uint8(0), decoded.TxType)
+	assert.Nil(t, decoded.ChainID)
+	assert.Len(t, data, len(state.DSL2TransactionWithMetadata{Version: state.DSL2TransactionMetadataVersion, DSL2Transaction: decoded.DSL2Transaction, Value: big.NewInt(0)}.Encode())-33)
+}
+
+func TestL2TransactionWithSenderDecode(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := common.HexToAddress("0x0102")
+	tx, err := types.SignNewTx(privateKey, types.NewEIP155Signer(big.NewInt(1000)), &types.LegacyTx{Nonce: 7, To: &to, Value: big.NewInt(1234), Gas: 21000, GasPrice: big.NewInt(1)})
+	require.NoError(t, err)
+	encoded, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	sender, err := state.GetSender(*tx)
+	require.NoError(t, err)
+
+	// The sender is taken from the L2 transaction if it's set, otherwise it's recovered from the encoded tx
+	for _, from := range []common.Address{sender, {}} {
+		l2Transaction := state.DSL2Transaction{
+			EffectiveGasPricePercentage: 255,
+			IsValid:                     1,
+			StateRoot:                   common.HexToHash("0x010203"),
+			EncodedLength:               uint32(len(encoded)),
+			Encoded:                     encoded,
+			From:                        from,
+		}
+		l2TransactionWithSender, err := state.NewDSL2TransactionWithSender(l2Transaction)
+		require.NoError(t, err)
+
+		decoded := state.DSL2TransactionWithSender{}.Decode(l2TransactionWithSender.Encode())
+		assert.Equal(t, state.DSL2TransactionSenderVersion, decoded.Version)
+		assert.Equal(t, encoded, decoded.Encoded)
+
+		// The decoded sender matches the sender recovered from the decoded tx
+		decodedTx := new(types.Transaction)
+		require.NoError(t, decodedTx.UnmarshalBinary(decoded.Encoded))
+		recovered, err := state.GetSender(*decodedTx)
+		require.NoError(t, err)
+		assert.Equal(t, recovered, decoded.From)
+	}
+}
+
 func TestL2BlockEndEncode(t *testing.T) {
 	l2BlockEnd := state.DSL2BlockEnd{
 		L2BlockNumber: 1,                        // 8 bytes
@@ -57,6 +330,193 @@ func TestL2BlockEndEncode(t *testing.T) {
 	assert.Equal(t, expected, encoded)
 }
 
+func TestBatchStartDecode(t *testing.T) {
+	batchStart := state.DSBatchStart{BatchNumber: 1}
+
+	encoded := batchStart.Encode()
+	assert.Equal(t, []byte{1, 0, 0, 0, 0, 0, 0, 0}, encoded)
+	assert.Equal(t, batchStart, state.DSBatchStart{}.Decode(encoded))
+}
+
+func TestBatchEndDecode(t *testing.T) {
+	batchEnd := state.DSBatchEnd{
+		BatchNumber: 1,                        // 8 bytes
+		StateRoot:   common.HexToHash("0x02"), // 32 bytes
+	}
+
+	encoded := batchEnd.Encode()
+	expected := []byte{1, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	assert.Equal(t, expected, encoded)
+	assert.Equal(t, batchEnd, state.DSBatchEnd{}.Decode(encoded))
+}
+
+func TestL2BlockFinalityDecode(t *testing.T) {
+	finality := state.DSL2BlockFinality{
+		Version:       state.DSL2BlockFinalityVersion, // 1 byte
+		L2BlockNumber: 1,                              // 8 bytes
+		Finality:      state.L2BlockFinalityFinalized, // 1 byte
+	}
+
+	encoded := finality.Encode()
+	assert.Equal(t, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 2}, encoded)
+	assert.Equal(t, finality, state.DSL2BlockFinality{}.Decode(encoded))
+	assert.Equal(t, "finalized", finality.Finality.String())
+}
+
+func TestL2BlockStorageDiffDecode(t *testing.T) {
+	storageDiff := state.DSL2BlockStorageDiff{
+		Version:       state.DSL2BlockStorageDiffVersion, // 1 byte
+		L2BlockNumber: 1,                                 // 8 bytes
+		Changes: []state.DSStorageChange{ // 4 bytes + 2 * 84 bytes
+			{Address: common.HexToAddress("0x01"), Slot: common.HexToHash("0x02"), Value: common.HexToHash("0x03")},
+			{Address: common.HexToAddress("0x04"), Slot: common.HexToHash("0x05"), Value: common.HexToHash("0x06")},
+		},
+	}
+
+	encoded := storageDiff.Encode()
+	require.Len(t, encoded, 1+8+1+4+2*84)
+	assert.Equal(t, []byte{2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0}, encoded[:14])
+	assert.Equal(t, storageDiff, state.DSL2BlockStorageDiff{}.Decode(encoded))
+
+	// A diff without changes
+	empty := state.DSL2BlockStorageDiff{Version: state.DSL2BlockStorageDiffVersion, L2BlockNumber: 2, Changes: []state.DSStorageChange{}}
+	assert.Equal(t, empty, state.DSL2BlockStorageDiff{}.Decode(empty.Encode()))
+
+	// A diff whose changes are unavailable is not taken as a diff without changes
+	unavailable := state.DSL2BlockStorageDiff{Version: state.DSL2BlockStorageDiffVersion, L2BlockNumber: 3, Unavailable: true, Changes: []state.DSStorageChange{}}
+	encoded = unavailable.Encode()
+	assert.Equal(t, []byte{2, 3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}, encoded)
+	decoded := state.DSL2BlockStorageDiff{}.Decode(encoded)
+	assert.True(t, decoded.Unavailable)
+	assert.Equal(t, unavailable, decoded)
+
+	// The version 1 has no flag, its diffs are available
+	v1 := state.DSL2BlockStorageDiff{Version: 1, L2BlockNumber: 4, Changes: storageDiff.Changes}
+	encoded = v1.Encode()
+	require.Len(t, encoded, 1+8+4+2*84)
+	assert.Equal(t, v1, state.DSL2BlockStorageDiff{}.Decode(encoded))
+}
+
+func TestL2BlockCheckpointDecode(t *testing.T) {
+	checkpoint := state.DSL2BlockCheckpoint{
+		Version:           state.DSL2BlockCheckpointVersion, // 1 byte
+		FromL2BlockNumber: 1,                                // 8 bytes
+		ToL2BlockNumber:   2,                                // 8 bytes
+		Hash:              common.HexToHash("0x03"),         // 32 bytes
+	}
+
+	encoded := checkpoint.Encode()
+	require.Len(t, encoded, 1+8+8+32)
+	assert.Equal(t, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}, encoded[:17])
+	assert.Equal(t, checkpoint, state.DSL2BlockCheckpoint{}.Decode(encoded))
+}
+
+func TestAccumulateL2BlockHash(t *testing.T) {
+	blockHash1, blockHash2 := common.HexToHash("0x01"), common.HexToHash("0x02")
+
+	accumulated := state.AccumulateL2BlockHash(state.AccumulateL2BlockHash(common.Hash{}, blockHash1), blockHash2)
+	expected := crypto.Keccak256Hash(crypto.Keccak256(common.Hash{}.Bytes(), blockHash1.Bytes()), blockHash2.Bytes())
+	assert.Equal(t, expected, accumulated)
+
+	// The order of the L2 blocks matters
+	assert.NotEqual(t, accumulated, state.AccumulateL2BlockHash(state.AccumulateL2BlockHash(common.Hash{}, blockHash2), blockHash1))
+}
+
+func TestL2BlockBloomDecode(t *testing.T) {
+	contract, absentContract := common.HexToAddress("0x10"), common.HexToAddress("0x20")
+	topic, absentTopic := common.HexToHash("0x01"), common.HexToHash("0x02")
+	logs := []*types.Log{
+		{Address: contract, Topics: []common.Hash{topic}},
+		{Address: contract},
+	}
+
+	bloom := state.NewDSL2BlockBloom(1, logs)
+	assert.Equal(t, state.DSL2BlockBloomVersion, bloom.Version)
+	assert.True(t, types.BloomLookup(bloom.Bloom, contract))
+	assert.True(t, types.BloomLookup(bloom.Bloom, topic))
+	assert.False(t, types.BloomLookup(bloom.Bloom, absentContract))
+	assert.False(t, types.BloomLookup(bloom.Bloom, absentTopic))
+	// The bloom is the same as the logs bloom of the Ethereum receipts
+	assert.Equal(t, types.CreateBloom(types.Receipts{{Logs: logs}}), bloom.Bloom)
+
+	encoded := bloom.Encode()
+	require.Len(t, encoded, 1+8+types.BloomByteLength)
+	assert.Equal(t, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0}, encoded[:9])
+	assert.Equal(t, bloom, state.DSL2BlockBloom{}.Decode(encoded))
+
+	// A L2 block without logs has an empty bloom
+	assert.Equal(t, types.Bloom{}, state.NewDSL2BlockBloom(2, nil).Bloom)
+}
+
+func TestNewDSStorageChanges(t *testing.T) {
+	contract := common.HexToAddress("0x10")
+	callee := common.HexToAddress("0x20")
+	sstore := func(depth int, address common.Address, slot, value int64) instrumentation.Step {
+		return instrumentation.Step{
+			Depth:    depth,
+			OpCode:   "SSTORE",
+			Contract: instrumentation.Contract{Address: address},
+			Stack:    []*big.Int{big.NewInt(100), big.NewInt(value), big.NewInt(slot)},
+		}
+	}
+	step := func(depth int, opCode string) instrumentation.Step {
+		return instrumentation.Step{Depth: depth, OpCode: opCode}
+	}
+
+	txResponse := &state.ProcessTransactionResponse{
+		FullTrace: instrumentation.FullTrace{
+			Steps: []instrumentation.Step{
+				sstore(1, contract, 1, 10),
+				step(1, "CALL"),
+				// The changes of a successful call are kept
+				sstore(2, callee, 1, 20),
+				step(2, "STOP"),
+				step(1, "CALL"),
+				// The changes of a reverted call are discarded
+				sstore(2, callee, 2, 30),
+				step(2, "REVERT"),
+				// The slot changed again keeps its first position with the last value
+				sstore(1, contract, 1, 11),
+				sstore(1, contract, 3, 0),
+				step(1, "STOP"),
+			},
+		},
+	}
+
+	assert.Equal(t, []state.DSStorageChange{
+		{Address: contract, Slot: common.BigToHash(big.NewInt(1)), Value: common.BigToHash(big.NewInt(11))},
+		{Address: callee, Slot: common.BigToHash(big.NewInt(1)), Value: common.BigToHash(big.NewInt(20))},
+		{Address: contract, Slot: common.BigToHash(big.NewInt(3)), Value: common.Hash{}},
+	}, state.NewDSStorageChanges(txResponse))
+
+	// The changes of a failed tx are discarded
+	txResponse.RomError = runtime.ErrExecutionReverted
+	assert.Empty(t, state.NewDSStorageChanges(txResponse))
+
+	// The changes of the txs of a L2 block are merged in order
+	first := []state.DSStorageChange{{Address: contract, Slot: common.HexToHash("0x1"), Value: common.HexToHash("0x1")}}
+	second := []state.DSStorageChange{
+		{Address: callee, Slot: common.HexToHash("0x1"), Value: common.HexToHash("0x2")},
+		{Address: contract, Slot: common.HexToHash("0x1"), Value: common.HexToHash("0x3")},
+	}
+	assert.Equal(t, []state.DSStorageChange{
+		{Address: contract, Slot: common.HexToHash("0x1"), Value: common.HexToHash("0x3")},
+		{Address: callee, Slot: common.HexToHash("0x1"), Value: common.HexToHash("0x2")},
+	}, state.MergeDSStorageChanges(first, nil, second))
+}
+
+func TestStreamSchemaDecode(t *testing.T) {
+	schema := state.DSStreamSchema{
+		Version:    state.DSStreamSchemaVersion,                                                    // 1 byte
+		EntryTypes: []datastreamer.EntryType{state.EntryTypeL2BlockStart, state.EntryTypeBookMark}, // 1 byte + 2 * 4 bytes
+	}
+
+	encoded := schema.Encode()
+	assert.Equal(t, []byte{1, 2, 1, 0, 0, 0, 176, 0, 0, 0}, encoded)
+	assert.Equal(t, schema, state.DSStreamSchema{}.Decode(encoded))
+}
+
 func TestCalculateSCPosition(t *testing.T) {
 	a := time.Now()
 	blockNumber := uint64(2934867)
@@ -68,3 +528,76 @@ func TestCalculateSCPosition(t *testing.T) {
 	c := b.Sub(a)
 	fmt.Println(c)
 }
+
+func TestProtobufEncoderRoundTrip(t *testing.T) {
+	encoder := state.DSProtobufEncoder{}
+
+	l2BlockStart := state.DSL2BlockStart{
+		BatchNumber:    1,
+		L2BlockNumber:  2,
+		Timestamp:      3,
+		GlobalExitRoot: common.HexToHash("0x04"),
+		Coinbase:       common.HexToAddress("0x05"),
+		ForkID:         6,
+	}
+	decodedL2BlockStart, err := encoder.DecodeL2BlockStart(encoder.EncodeL2BlockStart(l2BlockStart))
+	require.NoError(t, err)
+	assert.Equal(t, l2BlockStart, decodedL2BlockStart)
+
+	l2Transaction := state.DSL2Transaction{
+		EffectiveGasPricePercentage: 255,
+		IsValid:                     1,
+		StateRoot:                   common.HexToHash("0x010203"),
+		EncodedLength:               5,
+		Encoded:                     []byte{1, 2, 3, 4, 5},
+	}
+	decodedL2Transaction, err := encoder.DecodeL2Transaction(encoder.EncodeL2Transaction(l2Transaction))
+	require.NoError(t, err)
+	assert.Equal(t, l2Transaction, decodedL2Transaction)
+
+	l2BlockEnd := state.DSL2BlockEnd{
+		L2BlockNumber: 2,
+		BlockHash:     common.HexToHash("0x07"),
+		StateRoot:     common.HexToHash("0x08"),
+	}
+	decodedL2BlockEnd, err := encoder.DecodeL2BlockEnd(encoder.EncodeL2BlockEnd(l2BlockEnd))
+	require.NoError(t, err)
+	assert.Equal(t, l2BlockEnd, decodedL2BlockEnd)
+
+	_, err = encoder.DecodeL2BlockEnd([]byte{0x0a, 0x20, 1})
+	require.Error(t, err)
+}
+
+func TestDecodeStreamEntriesWithEncoding(t *testing.T) {
+	l2BlockStart := state.DSL2BlockStart{BatchNumber: 1, L2BlockNumber: 2, Timestamp: 3}
+	l2BlockEnd := state.DSL2BlockEnd{L2BlockNumber: 2, BlockHash: common.HexToHash("0x07"), StateRoot: common.HexToHash("0x08")}
+
+	// The entries are decoded with the encoding of their entry type
+	for _, encoder := range []state.StreamEncoder{state.DSBinaryEncoder{}, state.DSProtobufEncoder{}} {
+		startEntry := datastreamer.FileEntry{Type: encoder.L2BlockStartEntryType(), Data: encoder.EncodeL2BlockStart(l2BlockStart)}
+		batchNumber, err := state.DecodeL2BlockStartBatchNumber(startEntry)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), batchNumber)
+
+		endEntry := datastreamer.FileEntry{Type: encoder.L2BlockEndEntryType(), Data: encoder.EncodeL2BlockEnd(l2BlockEnd)}
+		assert.True(t, state.IsL2BlockEndEntry(endEntry.Type))
+		decodedL2BlockEnd, err := state.DecodeL2BlockEndEntry(endEntry)
+		require.NoError(t, err)
+		assert.Equal(t, l2BlockEnd, decodedL2BlockEnd)
+
+		entryEncoder, ok := state.StreamEncoderOf(encoder.L2TransactionEntryType())
+		require.True(t, ok)
+		assert.Equal(t, encoder, entryEncoder)
+	}
+
+	// The batch number of the L2 block starts with L1 info root is decoded after their version
+	startEntry := datastreamer.FileEntry{Type: state.EntryTypeL2BlockStartWithL1InfoRoot, Data: state.NewDSL2BlockStartWithL1InfoRoot(l2BlockStart, common.HexToHash("0x09")).Encode()}
+	batchNumber, err := state.DecodeL2BlockStartBatchNumber(startEntry)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), batchNumber)
+
+	_, ok := state.StreamEncoderOf(state.EntryTypeUpdateGER)
+	assert.False(t, ok)
+	_, err = state.DecodeL2BlockEndEntry(datastreamer.FileEntry{Type: state.EntryTypeUpdateGER})
+	assert.Error(t, err)
+}
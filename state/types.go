@@ -32,6 +32,9 @@ type ProcessRequest struct {
 	SkipWriteBlockInfoRoot_V2 bool
 	SkipVerifyL1InfoRoot_V2   bool
 	ForkID                    uint64
+	// StorageTraceTxHash_V2 is the hash of the tx whose full trace is generated with the stack and the storage (no memory nor
+	// return data), to get its storage changes with NewDSStorageChanges. If it's nil no trace is generated
+	StorageTraceTxHash_V2 *common.Hash
 }
 
 // L1DataV2 represents the L1InfoTree data used in ProcessRequest.L1InfoTreeData_V2 parameter
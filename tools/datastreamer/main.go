@@ -272,7 +272,8 @@ func generate(cliCtx *cli.Context) error {
 		}
 	}
 
-	err = state.GenerateDataStreamerFile(cliCtx.Context, streamServer, stateDB, false, &imStateRoots)
+	// The tool generates the plain binary entries, without the optional formats of the sequencer stream
+	err = state.GenerateDataStreamerFile(cliCtx.Context, streamServer, stateDB, false, &imStateRoots, state.DSL2BlockEntriesConfig{})
 	if err != nil {
 		log.Error(err)
 		os.Exit(1)
@@ -413,7 +414,7 @@ func reprocess(cliCtx *cli.Context) error {
 		var entryToUpdate *datastreamer.FileEntry
 
 		switch currentEntry.Type {
-		case state.EntryTypeBookMark:
+		case state.EntryTypeBookMark, state.EntryTypeBatchStart, state.EntryTypeBatchEnd:
 			printEntry(currentEntry)
 			entryToUpdate = nil
 			continue
@@ -434,7 +435,7 @@ func reprocess(cliCtx *cli.Context) error {
 
 			expectedNewRoot = currentEntry.Data[70:102]
 			entryToUpdate = nil
-		case state.EntryTypeL2BlockStart:
+		case state.EntryTypeL2BlockStart, state.EntryTypeL2BlockStartProto:
 			startEntry = currentEntry
 			printEntry(startEntry)
 
@@ -452,9 +453,25 @@ func reprocess(cliCtx *cli.Context) error {
 			}
 			printEntry(endEntry)
 
-			forkID := uint64(binary.LittleEndian.Uint16(startEntry.Data[76:78]))
+			// The L2 block start, tx and end entries are written with the same encoding
+			encoder, _ := state.StreamEncoderOf(startEntry.Type)
+			blockStart, err := encoder.DecodeL2BlockStart(startEntry.Data)
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+			l2Tx, err := encoder.DecodeL2Transaction(txEntry.Data)
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+			blockEnd, err := encoder.DecodeL2BlockEnd(endEntry.Data)
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
 
-			tx, err := state.DecodeTx(common.Bytes2Hex((txEntry.Data[6:])))
+			tx, err := state.DecodeTx(common.Bytes2Hex(l2Tx.Encoded))
 			if err != nil {
 				log.Error(err)
 				os.Exit(1)
@@ -464,26 +481,26 @@ func reprocess(cliCtx *cli.Context) error {
 			oldStateRoot := getOldStateRoot(startEntry.Number, streamServer)
 
 			// RLP encode the transaction using the proper fork id
-			batchL2Data, err := state.EncodeTransaction(*tx, txEntry.Data[0], forkID) //nolint:gomnd
+			batchL2Data, err := state.EncodeTransaction(*tx, l2Tx.EffectiveGasPricePercentage, uint64(blockStart.ForkID))
 			if err != nil {
 				log.Error(err)
 				os.Exit(1)
 			}
 
 			processBatchRequest = &executor.ProcessBatchRequest{
-				OldBatchNum:      binary.LittleEndian.Uint64(startEntry.Data[0:8]) - 1,
-				Coinbase:         common.Bytes2Hex(startEntry.Data[56:76]),
+				OldBatchNum:      blockStart.BatchNumber - 1,
+				Coinbase:         common.Bytes2Hex(blockStart.Coinbase.Bytes()),
 				BatchL2Data:      batchL2Data,
 				OldStateRoot:     oldStateRoot,
-				GlobalExitRoot:   startEntry.Data[24:56],
+				GlobalExitRoot:   blockStart.GlobalExitRoot.Bytes(),
 				OldAccInputHash:  []byte{},
-				EthTimestamp:     binary.LittleEndian.Uint64(startEntry.Data[16:24]),
+				EthTimestamp:     uint64(blockStart.Timestamp),
 				UpdateMerkleTree: uint32(1),
 				ChainId:          c.ChainID,
-				ForkId:           uint64(binary.LittleEndian.Uint16(startEntry.Data[76:78])),
+				ForkId:           uint64(blockStart.ForkID),
 			}
 
-			expectedNewRoot = endEntry.Data[40:72]
+			expectedNewRoot = blockEnd.StateRoot.Bytes()
 			entryToUpdate = &endEntry
 			x += 2 //nolint:gomnd
 		}
@@ -507,13 +524,19 @@ func reprocess(cliCtx *cli.Context) error {
 			printColored(color.FgRed, fmt.Sprintf("Expected New State Root: %s\n", "0x"+common.Bytes2Hex(expectedNewRoot)))
 			// Check if we must update the file with the new state root
 			if cliCtx.Bool("update") {
-				if entryToUpdate.Type != state.EntryTypeL2BlockEnd {
+				if !state.IsL2BlockEndEntry(entryToUpdate.Type) {
 					printColored(color.FgRed, "Error: Entry to update is not a L2BlockEnd\n")
 					os.Exit(1)
 				}
-				blockEnd := state.DSL2BlockEnd{}.Decode(entryToUpdate.Data)
+				blockEnd, err := state.DecodeL2BlockEndEntry(*entryToUpdate)
+				if err != nil {
+					printColored(color.FgRed, fmt.Sprintf("Error: %v\n", err))
+					os.Exit(1)
+				}
 				blockEnd.StateRoot = common.BytesToHash(processBatchResponse.NewStateRoot)
-				err = streamServer.UpdateEntryData(entryToUpdate.Number, state.EntryTypeL2BlockEnd, blockEnd.Encode())
+				// The entry is updated keeping its encoding
+				encoder, _ := state.StreamEncoderOf(entryToUpdate.Type)
+				err = streamServer.UpdateEntryData(entryToUpdate.Number, entryToUpdate.Type, encoder.EncodeL2BlockEnd(blockEnd))
 				if err != nil {
 					printColored(color.FgRed, fmt.Sprintf("Error: %v\n", err))
 					os.Exit(1)
@@ -611,7 +634,9 @@ func decodeL2Block(cliCtx *cli.Context) error {
 	printEntry(secondEntry)
 
 	i := uint64(2) //nolint:gomnd
-	for secondEntry.Type == state.EntryTypeL2Tx {
+	for secondEntry.Type == state.EntryTypeL2Tx || secondEntry.Type == state.EntryTypeL2TxProto || secondEntry.Type == state.EntryTypeL2TxWithMetadata || secondEntry.Type == state.EntryTypeL2TxWithSender ||
+		secondEntry.Type == state.EntryTypeL2BlockStorageDiff || secondEntry.Type == state.EntryTypeL2BlockCheckpoint ||
+		secondEntry.Type == state.EntryTypeL2BlockBloom {
 		client.FromEntry = firstEntry.Number + i
 		err = client.ExecCommand(datastreamer.CmdEntry)
 		if err != nil {
@@ -689,7 +714,9 @@ func decodeL2BlockOffline(cliCtx *cli.Context) error {
 
 	i := uint64(2) //nolint:gomnd
 	printEntry(secondEntry)
-	for secondEntry.Type == state.EntryTypeL2Tx {
+	for secondEntry.Type == state.EntryTypeL2Tx || secondEntry.Type == state.EntryTypeL2TxProto || secondEntry.Type == state.EntryTypeL2TxWithMetadata || secondEntry.Type == state.EntryTypeL2TxWithSender ||
+		secondEntry.Type == state.EntryTypeL2BlockStorageDiff || secondEntry.Type == state.EntryTypeL2BlockCheckpoint ||
+		secondEntry.Type == state.EntryTypeL2BlockBloom {
 		secondEntry, err = streamServer.GetEntry(firstEntry.Number + i)
 		if err != nil {
 			log.Error(err)
@@ -736,10 +763,19 @@ func printEntry(entry datastreamer.FileEntry) {
 		printColored(color.FgHiYellow, "BookMark\n")
 		printColored(color.FgGreen, "Entry Number....: ")
 		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
-		printColored(color.FgGreen, "L2 Block Number.: ")
+		if bookmark.Type == state.BookMarkTypeBatch {
+			printColored(color.FgGreen, "Batch Number....: ")
+		} else {
+			printColored(color.FgGreen, "L2 Block Number.: ")
+		}
 		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", bookmark.L2BlockNumber))
-	case state.EntryTypeL2BlockStart:
-		blockStart := state.DSL2BlockStart{}.Decode(entry.Data)
+	case state.EntryTypeL2BlockStart, state.EntryTypeL2BlockStartProto:
+		encoder, _ := state.StreamEncoderOf(entry.Type)
+		blockStart, err := encoder.DecodeL2BlockStart(entry.Data)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
 		printColored(color.FgGreen, "Entry Type......: ")
 		printColored(color.FgHiYellow, "L2 Block Start\n")
 		printColored(color.FgGreen, "Entry Number....: ")
@@ -756,8 +792,125 @@ func printEntry(entry datastreamer.FileEntry) {
 		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.Coinbase))
 		printColored(color.FgGreen, "Fork ID.........: ")
 		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.ForkID))
-	case state.EntryTypeL2Tx:
-		dsTx := state.DSL2Transaction{}.Decode(entry.Data)
+	case state.EntryTypeL2BlockStartMasked:
+		blockStart := state.DSL2BlockStartMasked{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Start Masked\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.Version))
+		printColored(color.FgGreen, "Batch Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.BatchNumber))
+		printColored(color.FgGreen, "L2 Block Number.: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.L2BlockNumber))
+		printColored(color.FgGreen, "Timestamp.......: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%v (%d)\n", time.Unix(blockStart.Timestamp, 0), blockStart.Timestamp))
+		if blockStart.Fields&state.DSL2BlockStartFieldGlobalExitRoot != 0 {
+			printColored(color.FgGreen, "Global Exit Root: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.GlobalExitRoot))
+		}
+		if blockStart.Fields&state.DSL2BlockStartFieldCoinbase != 0 {
+			printColored(color.FgGreen, "Coinbase........: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.Coinbase))
+		}
+		if blockStart.Fields&state.DSL2BlockStartFieldForkID != 0 {
+			printColored(color.FgGreen, "Fork ID.........: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.ForkID))
+		}
+	case state.EntryTypeL2BlockStartWithL1InfoRoot:
+		blockStart := state.DSL2BlockStartWithL1InfoRoot{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Start With L1 Info Root\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.Version))
+		printColored(color.FgGreen, "Batch Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.BatchNumber))
+		printColored(color.FgGreen, "L2 Block Number.: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.L2BlockNumber))
+		printColored(color.FgGreen, "Timestamp.......: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%v (%d)\n", time.Unix(blockStart.Timestamp, 0), blockStart.Timestamp))
+		printColored(color.FgGreen, "Global Exit Root: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.GlobalExitRoot))
+		printColored(color.FgGreen, "Coinbase........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.Coinbase))
+		printColored(color.FgGreen, "Fork ID.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", blockStart.ForkID))
+		printColored(color.FgGreen, "L1 Info Root....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", blockStart.L1InfoRoot))
+	case state.EntryTypeStreamSchema:
+		schema := state.DSStreamSchema{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "Stream Schema\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Schema Version..: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", schema.Version))
+		printColored(color.FgGreen, "Entry Types.....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%v\n", schema.EntryTypes))
+	case state.EntryTypeL2BlockFinality:
+		finality := state.DSL2BlockFinality{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Finality\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", finality.Version))
+		printColored(color.FgGreen, "L2 Block Number.: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", finality.L2BlockNumber))
+		printColored(color.FgGreen, "Finality........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", finality.Finality))
+	case state.EntryTypeL2BlockBloom:
+		bloom := state.DSL2BlockBloom{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Bloom\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", bloom.Version))
+		printColored(color.FgGreen, "L2 Block Number.: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", bloom.L2BlockNumber))
+		printColored(color.FgGreen, "Bloom...........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", "0x"+common.Bytes2Hex(bloom.Bloom[:])))
+	case state.EntryTypeL2BlockCheckpoint:
+		checkpoint := state.DSL2BlockCheckpoint{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Checkpoint\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", checkpoint.Version))
+		printColored(color.FgGreen, "L2 Blocks.......: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d to %d\n", checkpoint.FromL2BlockNumber, checkpoint.ToL2BlockNumber))
+		printColored(color.FgGreen, "Hash............: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", checkpoint.Hash))
+	case state.EntryTypeL2BlockStorageDiff:
+		storageDiff := state.DSL2BlockStorageDiff{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Block Storage Diff\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", storageDiff.Version))
+		printColored(color.FgGreen, "L2 Block Number.: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", storageDiff.L2BlockNumber))
+		printColored(color.FgGreen, "Unavailable.....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%t\n", storageDiff.Unavailable))
+		printColored(color.FgGreen, "Changes.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", len(storageDiff.Changes)))
+		for _, change := range storageDiff.Changes {
+			printColored(color.FgGreen, "  Slot..........: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%s %s = %s\n", change.Address, change.Slot, change.Value))
+		}
+	case state.EntryTypeL2Tx, state.EntryTypeL2TxProto:
+		encoder, _ := state.StreamEncoderOf(entry.Type)
+		dsTx, err := encoder.DecodeL2Transaction(entry.Data)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
 		printColored(color.FgGreen, "Entry Type......: ")
 		printColored(color.FgHiYellow, "L2 Transaction\n")
 		printColored(color.FgGreen, "Entry Number....: ")
@@ -790,8 +943,68 @@ func printEntry(entry datastreamer.FileEntry) {
 		nonce := tx.Nonce()
 		printColored(color.FgGreen, "Nonce...........: ")
 		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", nonce))
-	case state.EntryTypeL2BlockEnd:
-		blockEnd := state.DSL2BlockEnd{}.Decode(entry.Data)
+	case state.EntryTypeL2TxWithMetadata:
+		dsTx := state.DSL2TransactionWithMetadata{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Transaction With Metadata\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.Version))
+		printColored(color.FgGreen, "Effec. Gas Price: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.EffectiveGasPricePercentage))
+		printColored(color.FgGreen, "Is Valid........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%t\n", dsTx.IsValid == 1))
+		printColored(color.FgGreen, "State Root......: ")
+		printColored(color.FgHiWhite, fmt.Sprint(dsTx.StateRoot.Hex()+"\n"))
+		printColored(color.FgGreen, "Encoded Length..: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.EncodedLength))
+		printColored(color.FgGreen, "Encoded.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", "0x"+common.Bytes2Hex(dsTx.Encoded)))
+		printColored(color.FgGreen, "From............: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", dsTx.From))
+		printColored(color.FgGreen, "To..............: ")
+		if dsTx.To != nil {
+			printColored(color.FgHiWhite, fmt.Sprintf("%s\n", dsTx.To))
+		} else {
+			printColored(color.FgHiWhite, "contract creation\n")
+		}
+		printColored(color.FgGreen, "Nonce...........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.Nonce))
+		printColored(color.FgGreen, "Value...........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", dsTx.Value))
+		if dsTx.Version >= 2 { //nolint:gomnd
+			printColored(color.FgGreen, "Tx Type.........: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.TxType))
+			printColored(color.FgGreen, "Chain ID........: ")
+			printColored(color.FgHiWhite, fmt.Sprintf("%s\n", dsTx.ChainID))
+		}
+	case state.EntryTypeL2TxWithSender:
+		dsTx := state.DSL2TransactionWithSender{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "L2 Transaction With Sender\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Version.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.Version))
+		printColored(color.FgGreen, "Effec. Gas Price: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.EffectiveGasPricePercentage))
+		printColored(color.FgGreen, "Is Valid........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%t\n", dsTx.IsValid == 1))
+		printColored(color.FgGreen, "State Root......: ")
+		printColored(color.FgHiWhite, fmt.Sprint(dsTx.StateRoot.Hex()+"\n"))
+		printColored(color.FgGreen, "Encoded Length..: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", dsTx.EncodedLength))
+		printColored(color.FgGreen, "Encoded.........: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", "0x"+common.Bytes2Hex(dsTx.Encoded)))
+		printColored(color.FgGreen, "From............: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%s\n", dsTx.From))
+	case state.EntryTypeL2BlockEnd, state.EntryTypeL2BlockEndProto:
+		blockEnd, err := state.DecodeL2BlockEndEntry(entry)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
 		printColored(color.FgGreen, "Entry Type......: ")
 		printColored(color.FgHiYellow, "L2 Block End\n")
 		printColored(color.FgGreen, "Entry Number....: ")
@@ -802,6 +1015,24 @@ func printEntry(entry datastreamer.FileEntry) {
 		printColored(color.FgHiWhite, fmt.Sprint(blockEnd.BlockHash.Hex()+"\n"))
 		printColored(color.FgGreen, "State Root......: ")
 		printColored(color.FgHiWhite, fmt.Sprint(blockEnd.StateRoot.Hex()+"\n"))
+	case state.EntryTypeBatchStart:
+		batchStart := state.DSBatchStart{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "Batch Start\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Batch Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", batchStart.BatchNumber))
+	case state.EntryTypeBatchEnd:
+		batchEnd := state.DSBatchEnd{}.Decode(entry.Data)
+		printColored(color.FgGreen, "Entry Type......: ")
+		printColored(color.FgHiYellow, "Batch End\n")
+		printColored(color.FgGreen, "Entry Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", entry.Number))
+		printColored(color.FgGreen, "Batch Number....: ")
+		printColored(color.FgHiWhite, fmt.Sprintf("%d\n", batchEnd.BatchNumber))
+		printColored(color.FgGreen, "State Root......: ")
+		printColored(color.FgHiWhite, fmt.Sprint(batchEnd.StateRoot.Hex()+"\n"))
 	case state.EntryTypeUpdateGER:
 		updateGer := state.DSUpdateGER{}.Decode(entry.Data)
 		printColored(color.FgGreen, "Entry Type......: ")
@@ -917,7 +1148,7 @@ func getOldStateRoot(entityNumber uint64, streamServer *datastreamer.StreamServe
 			os.Exit(1)
 		}
 
-		if entry.Type == state.EntryTypeL2BlockEnd || entry.Type == state.EntryTypeUpdateGER {
+		if state.IsL2BlockEndEntry(entry.Type) || entry.Type == state.EntryTypeUpdateGER {
 			found = true
 		}
 	}
@@ -934,5 +1165,10 @@ func getOldStateRoot(entityNumber uint64, streamServer *datastreamer.StreamServe
 		return entry.Data[70:102]
 	}
 
-	return entry.Data[40:72]
+	blockEnd, err := state.DecodeL2BlockEndEntry(entry)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	return blockEnd.StateRoot.Bytes()
 }